@@ -1,10 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/ethereum/go-ethereum/common"
@@ -67,10 +69,57 @@ func (cfg ChainConfig) GetPrivateKey() (string, error) {
 	}
 }
 
+// Signer of the transactions sent by the voting, mirroring and uptime voting clients
+type SignerConfig struct {
+	// Valid values are: private_key (default, uses the private key of the chain
+	// config), keystore and remote
+	Type string `toml:"type" envconfig:"SIGNER_TYPE"`
+
+	// Encrypted JSON keystore file, the passphrase is read from the file or from
+	// the environment variable SIGNER_KEYSTORE_PASSPHRASE (never from the config file)
+	KeystoreFile           string `toml:"keystore_file" envconfig:"SIGNER_KEYSTORE_FILE"`
+	KeystorePassphraseFile string `toml:"keystore_passphrase_file" envconfig:"SIGNER_KEYSTORE_PASSPHRASE_FILE"`
+	KeystorePassphrase     string `toml:"-" envconfig:"SIGNER_KEYSTORE_PASSPHRASE"`
+
+	// Remote signer implementing eth_signTransaction and the account it signs with
+	RemoteURL     string         `toml:"remote_url" envconfig:"SIGNER_REMOTE_URL"`
+	RemoteAddress common.Address `toml:"remote_address" envconfig:"SIGNER_REMOTE_ADDRESS"`
+}
+
+func (cfg SignerConfig) GetKeystorePassphrase() (string, error) {
+	if cfg.KeystorePassphraseFile == "" {
+		if cfg.KeystorePassphrase == "" {
+			return "", errors.New("keystore passphrase not set")
+		}
+		return cfg.KeystorePassphrase, nil
+	}
+	content, err := os.ReadFile(cfg.KeystorePassphraseFile)
+	if err != nil {
+		return "", fmt.Errorf("error opening keystore passphrase file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
 type EpochConfig struct {
 	First int64 `toml:"first" envconfig:"EPOCH_FIRST"`
 }
 
+// Refresh of the epoch configuration of the voting contract
+type EpochScheduleConfig struct {
+	RefreshInterval time.Duration `toml:"refresh_interval"`
+}
+
+// Thresholds of the /healthz and /readyz checks of the indexer and the services
+type HealthConfig struct {
+	// Max number of indexes the indexers may lag behind the last accepted
+	// index of the node, 0 to disable
+	MaxIndexLag uint64 `toml:"max_index_lag"`
+	// Max age of the last update of the indexer states, 0 to disable
+	MaxStateAge time.Duration `toml:"max_state_age"`
+	// Timeout of the database and node checks
+	CheckTimeout time.Duration `toml:"check_timeout"`
+}
+
 type ContractAddresses struct {
 	Voting common.Address `toml:"voting" envconfig:"VOTING_CONTRACT_ADDRESS"`
 }
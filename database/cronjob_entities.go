@@ -12,6 +12,8 @@ const (
 	UptimeCronjobStatusTimeout        UptimeCronjobStatus = -1
 	UptimeCronjobStatusServiceError   UptimeCronjobStatus = -2
 	UptimeCronjobStatusIndexerStarted UptimeCronjobStatus = -3
+	// No samples of the node for longer than the max gap, only in uptime intervals
+	UptimeCronjobStatusGap UptimeCronjobStatus = -4
 )
 
 type UptimeCronjob struct {
@@ -19,6 +21,25 @@ type UptimeCronjob struct {
 	Timestamp time.Time `gorm:"index"`
 	NodeID    *string   `gorm:"type:varchar(60);index"`
 	Status    UptimeCronjobStatus
+
+	// Id of the observer node, empty for the node of the chain config and for
+	// observations recorded before multiple observers were supported
+	Observer string `gorm:"type:varchar(60);not null;default:''"`
+
+	// Uptime percentage of the node reported by the observer, nil if the
+	// observer does not report it
+	ReportedUptime *float64
+}
+
+// Time range (StartTime, EndTime] in which the node had the same status in the
+// consecutive uptime samples compacted into the interval. The status of a
+// sample applies to the time since the previous sample of the node.
+type UptimeInterval struct {
+	BaseEntity
+	NodeID    string `gorm:"type:varchar(60);index:idx_uptime_interval_node_end"`
+	Status    UptimeCronjobStatus
+	StartTime time.Time
+	EndTime   time.Time `gorm:"index:idx_uptime_interval_node_end;index"`
 }
 
 type UptimeAggregation struct {
@@ -37,4 +58,160 @@ type UptimeAggregation struct {
 
 	// Length of the staking interval(s) intersecting with the epoch interval
 	StakingDuration int64
+
+	// Part of the staking duration with an error status or without samples,
+	// and the part of it excluded from the uptime ratio by the uptime policy
+	AmbiguousDuration int64
+	ExcludedDuration  int64
+
+	// Mean uptime ratio reported by the observers in the samples of the node
+	// during its staking time in the epoch and the number of these samples
+	ReportedUptime  float64
+	ReportedSamples int64
+}
+
+// Reason for not mirroring a transaction, determined from the revert of the
+// mirroring contract
+type MirrorSkipReason string
+
+const (
+	MirrorSkipAlreadyMirrored       MirrorSkipReason = "already_mirrored"
+	MirrorSkipStakingEnded          MirrorSkipReason = "staking_ended"
+	MirrorSkipUnknownStakingAddress MirrorSkipReason = "unknown_staking_address"
+	MirrorSkipStakingDataInvalid    MirrorSkipReason = "staking_data_invalid"
+	MirrorSkipMaxNodeIDsExceeded    MirrorSkipReason = "max_node_ids_exceeded"
+)
+
+type MirrorTxStatus string
+
+const (
+	// Mirroring is in progress or the transaction is waiting for the first attempt
+	MirrorTxStatusPending  MirrorTxStatus = "pending"
+	MirrorTxStatusMirrored MirrorTxStatus = "mirrored"
+	MirrorTxStatusSkipped  MirrorTxStatus = "skipped"
+	// Mirroring failed, it is retried at NextRetry
+	MirrorTxStatusFailed MirrorTxStatus = "failed"
+)
+
+// Mirroring status of a p-chain transaction
+type MirrorStatus struct {
+	BaseEntity
+	TxID  string `gorm:"type:varchar(50);uniqueIndex"`
+	Epoch int64  `gorm:"index"`
+
+	Status     MirrorTxStatus   `gorm:"type:varchar(20);index"`
+	SkipReason MirrorSkipReason `gorm:"type:varchar(50)"`
+
+	// Revert of a skipped transaction or error of the last failed attempt
+	Error string `gorm:"type:varchar(256)"`
+
+	// Hash of the last mirrorStake transaction on the C-chain, empty if it was
+	// not sent
+	CChainTxHash string `gorm:"type:varchar(66)"`
+
+	Attempts int
+
+	// Time of the next attempt, nil if the transaction is not retried
+	NextRetry *time.Time `gorm:"index"`
+
+	Updated time.Time
+}
+
+type EpochJobRunStatus string
+
+const (
+	EpochJobRunSucceeded EpochJobRunStatus = "succeeded"
+	// Processing failed, it is retried at NextRetry
+	EpochJobRunFailed EpochJobRunStatus = "failed"
+	// Epoch was not processed because the indexer was behind
+	EpochJobRunSkipped EpochJobRunStatus = "skipped"
+	// Re-run of the epoch was requested by an operator
+	EpochJobRunPending EpochJobRunStatus = "pending"
+)
+
+// Outcome of the last run of an epoch job (voting, mirroring, uptime voting)
+// for an epoch
+type EpochJobRun struct {
+	BaseEntity
+	Job   string `gorm:"type:varchar(40);uniqueIndex:idx_epoch_job_run_job_epoch"`
+	Epoch int64  `gorm:"uniqueIndex:idx_epoch_job_run_job_epoch"`
+
+	Status EpochJobRunStatus `gorm:"type:varchar(20);index"`
+
+	// Failed attempts since the last success, error of the last failed attempt
+	Attempts int
+	Error    string `gorm:"type:varchar(256)"`
+
+	// Time of the next attempt of a failed run, nil if it is not retried
+	NextRetry *time.Time
+
+	// Transactions of the last attempt that were not mined in time (comma
+	// separated hashes), checked by the job before sending again
+	TxHashes string `gorm:"type:text"`
+
+	Started time.Time
+	Updated time.Time
+}
+
+// Result of the last comparison of the merkle root computed from the indexed
+// p-chain transactions with the root finalized on the voting contract
+type EpochRootCheck struct {
+	BaseEntity
+	Epoch         int64  `gorm:"uniqueIndex"`
+	LocalRoot     string `gorm:"type:varchar(66)"`
+	FinalizedRoot string `gorm:"type:varchar(66)"`
+	Mismatch      bool   `gorm:"index"`
+	NumLeaves     int
+
+	// Comma separated ids of transactions whose leaves are missing from or
+	// extra in the local tree compared to the last local tree of the epoch
+	// that matched the finalized root. A transaction in both lists has changed
+	// stake data. Empty if the epoch never matched.
+	MissingLeaves string `gorm:"type:mediumtext"`
+	ExtraLeaves   string `gorm:"type:mediumtext"`
+
+	Checked time.Time
+}
+
+// Leaf of the last local merkle tree of the epoch that matched the finalized
+// root
+type EpochRootLeaf struct {
+	BaseEntity
+	Epoch    int64  `gorm:"index"`
+	TxID     string `gorm:"type:varchar(50)"`
+	LeafHash string `gorm:"type:varchar(66)"`
+}
+
+// Contract call that a cronjob in dry-run mode would have sent
+type DryRunSubmission struct {
+	BaseEntity
+	Cronjob string `gorm:"type:varchar(20);index"`
+	Epoch   int64  `gorm:"index"`
+
+	// Called contract and method
+	Contract string `gorm:"type:varchar(42)"`
+	Method   string `gorm:"type:varchar(50)"`
+
+	// Submitted merkle root (voting)
+	MerkleRoot string `gorm:"type:varchar(66)"`
+
+	// P-chain transaction and JSON encoded stake data (mirroring)
+	TxID      string `gorm:"type:varchar(50)"`
+	StakeData string `gorm:"type:text"`
+
+	// Comma separated node ids (uptime voting)
+	NodeIDs string `gorm:"type:mediumtext"`
+
+	// Hex encoded calldata of the transaction
+	CallData string `gorm:"type:mediumtext"`
+
+	// Number of indexed submissions of other voters for the epoch with the same
+	// and with different data at the time of the dry run
+	OthersMatching  int
+	OthersDifferent int
+
+	// Finalized merkle root of the epoch at the time of the dry run, if indexed
+	FinalizedRoot string `gorm:"type:varchar(66)"`
+
+	Timestamp time.Time
 }
@@ -26,6 +26,34 @@ type State struct {
 	Updated        time.Time
 }
 
+// Name of the state of the P-chain block indexer, also read by the services
+// to check the age of the indexed data
+const PChainBlockStateName = "p_chain_block"
+
+// Lease of the leadership of indexer instances sharing the database, held by
+// the leader until it expires
+type LeaderLease struct {
+	BaseEntity
+	Name    string `gorm:"type:varchar(50);uniqueIndex"`
+	Holder  string `gorm:"type:varchar(100)"`
+	Expires time.Time
+	Updated time.Time
+}
+
+// Action requested through the admin API of the indexer
+type AdminAction struct {
+	BaseEntity
+	Action string `gorm:"type:varchar(20);index"`
+	// Indexer or cronjob the action applies to, empty for actions of the process
+	Job string `gorm:"type:varchar(40)"`
+	// Parameters of the action, e.g., the epoch of a reset
+	Params string `gorm:"type:varchar(100)"`
+	Remote string `gorm:"type:varchar(60)"`
+	// Error of the action, empty if it succeeded
+	Error     string    `gorm:"type:varchar(256)"`
+	Timestamp time.Time `gorm:"index"`
+}
+
 // Abstact entity, common columns for X-chain and P-chain transaction inputs
 type TxInput struct {
 	BaseEntity
@@ -12,6 +12,57 @@ func (s *State) UpdateTime() {
 	s.Updated = time.Now()
 }
 
+// Ratio of the uptime to the staking duration without the excluded time, false
+// if the node was not staking or all of its staking time was excluded
+func (a *UptimeAggregation) UptimeRatio() (float64, bool) {
+	duration := a.StakingDuration - a.ExcludedDuration
+	if duration <= 0 {
+		return 0, false
+	}
+	return float64(a.Value) / float64(duration), true
+}
+
+// Uptime ratio reported by the observers, false if there are no reported
+// uptimes of the node in the epoch
+func (a *UptimeAggregation) ReportedUptimeRatio() (float64, bool) {
+	if a.ReportedSamples == 0 {
+		return 0, false
+	}
+	return a.ReportedUptime, true
+}
+
+// Difference between the sampled and the reported uptime ratio, false if one of
+// them is not available
+func (a *UptimeAggregation) ReportedUptimeDivergence() (float64, bool) {
+	sampled, ok := a.UptimeRatio()
+	if !ok {
+		return 0, false
+	}
+	reported, ok := a.ReportedUptimeRatio()
+	if !ok {
+		return 0, false
+	}
+	return sampled - reported, true
+}
+
+func (s UptimeCronjobStatus) String() string {
+	switch s {
+	case UptimeCronjobStatusConnected:
+		return "connected"
+	case UptimeCronjobStatusDisconnected:
+		return "disconnected"
+	case UptimeCronjobStatusTimeout:
+		return "timeout"
+	case UptimeCronjobStatusServiceError:
+		return "service_error"
+	case UptimeCronjobStatusIndexerStarted:
+		return "indexer_started"
+	case UptimeCronjobStatusGap:
+		return "gap"
+	}
+	return "unknown"
+}
+
 func (out TxOutput) Addr() string {
 	return out.Address
 }
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+const persistStartKey = "metrics:persist_start"
+
+var persistDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_persist_duration_seconds",
+	Help:    "Duration of inserts and updates of the entities by table",
+	Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+}, []string{"entity"})
+
+// Register callbacks recording the duration of creates and updates of the
+// entities
+func registerMetricsCallbacks(db *gorm.DB) error {
+	start := func(tx *gorm.DB) {
+		tx.InstanceSet(persistStartKey, time.Now())
+	}
+	observe := func(tx *gorm.DB) {
+		start, ok := tx.InstanceGet(persistStartKey)
+		if !ok || len(tx.Statement.Table) == 0 {
+			return
+		}
+		persistDuration.WithLabelValues(tx.Statement.Table).Observe(time.Since(start.(time.Time)).Seconds())
+	}
+
+	create := db.Callback().Create()
+	if err := create.Before("gorm:create").Register("metrics:before_create", start); err != nil {
+		return err
+	}
+	if err := create.After("gorm:create").Register("metrics:after_create", observe); err != nil {
+		return err
+	}
+	update := db.Callback().Update()
+	if err := update.Before("gorm:update").Register("metrics:before_update", start); err != nil {
+		return err
+	}
+	return update.After("gorm:update").Register("metrics:after_update", observe)
+}
@@ -284,3 +284,22 @@ func FetchNodeStakingIntervals(db *gorm.DB, txType PChainTxType, startTime time.
 		Find(&txs).Error
 	return txs, err
 }
+
+// Number and max row id of the staking transactions starting in the interval,
+// changes whenever staking transactions of the interval are added, removed or
+// reindexed
+type PChainTxsFingerprint struct {
+	TxCount int64
+	MaxTxID uint64
+}
+
+func FetchPChainTxsFingerprint(db *gorm.DB, from time.Time, to time.Time) (PChainTxsFingerprint, error) {
+	var fingerprint PChainTxsFingerprint
+	err := db.
+		Table("p_chain_txes").
+		Where("type = ? OR type = ?", PChainAddValidatorTx, PChainAddDelegatorTx).
+		Where("start_time >= ?", from).Where("start_time < ?", to).
+		Select("COUNT(*) AS tx_count, COALESCE(MAX(id), 0) AS max_tx_id").
+		Scan(&fingerprint).Error
+	return fingerprint, err
+}
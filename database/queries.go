@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func FetchState(db *gorm.DB, name string) (State, error) {
@@ -12,6 +13,68 @@ func FetchState(db *gorm.DB, name string) (State, error) {
 	return currentState, err
 }
 
+// Fetch the state and lock its row until the end of the transaction
+func FetchStateForUpdate(tx *gorm.DB, name string) (State, error) {
+	var state State
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&State{Name: name}).First(&state).Error
+	return state, err
+}
+
+// Fetch the states with the given names, states that do not exist are omitted
+func FetchStates(db *gorm.DB, names []string) ([]State, error) {
+	var states []State
+	if len(names) == 0 {
+		return states, nil
+	}
+	err := db.Where("name IN ?", names).Order("name").Find(&states).Error
+	return states, err
+}
+
+// Acquire or renew the lease for the holder until now + duration. Returns
+// false if the lease is held by another holder and has not expired yet.
+func AcquireLeaderLease(db *gorm.DB, name string, holder string, now time.Time, duration time.Duration) (bool, error) {
+	acquired := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var lease LeaderLease
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&LeaderLease{Name: name}).First(&lease).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if err == nil && lease.Holder != holder && lease.Expires.After(now) {
+			return nil
+		}
+
+		lease.Name = name
+		lease.Holder = holder
+		lease.Expires = now.Add(duration)
+		lease.Updated = now
+		if err := tx.Save(&lease).Error; err != nil {
+			return err
+		}
+		acquired = true
+		return nil
+	})
+	return acquired, err
+}
+
+// Expire the lease if it is held by the holder
+func ReleaseLeaderLease(db *gorm.DB, name string, holder string, now time.Time) error {
+	return db.Model(&LeaderLease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Updates(map[string]interface{}{"expires": now, "updated": now}).Error
+}
+
+func PersistAdminAction(db *gorm.DB, action *AdminAction) error {
+	return db.Create(action).Error
+}
+
+// Fetch the last admin actions, newest first
+func FetchAdminActions(db *gorm.DB, limit int) ([]AdminAction, error) {
+	var actions []AdminAction
+	err := db.Order("timestamp desc, id desc").Limit(limit).Find(&actions).Error
+	return actions, err
+}
+
 func FetchMigrations(db *gorm.DB) ([]Migration, error) {
 	var migrations []Migration
 	err := db.Order("version asc").Find(&migrations).Error
@@ -59,6 +122,103 @@ func FetchNodeUptimes(db *gorm.DB, nodeID string, startTime time.Time, endTime t
 	return uptimes, err
 }
 
+// Uptime samples of the validators in the time range [startTime, endTime),
+// sorted by node id and timestamp
+func FetchUptimeSamples(db *gorm.DB, startTime time.Time, endTime time.Time) ([]UptimeCronjob, error) {
+	var uptimes []UptimeCronjob
+	err := db.Where("node_id IS NOT NULL AND timestamp >= ? AND timestamp < ?", startTime, endTime).
+		Order("node_id asc").Order("timestamp asc").Order("observer asc").
+		Find(&uptimes).Error
+	return uptimes, err
+}
+
+// Uptime samples without a node, i.e., failed calls of the observers and starts
+// of the indexer, in the time range [startTime, endTime), sorted by timestamp
+func FetchUptimeErrors(db *gorm.DB, startTime time.Time, endTime time.Time) ([]UptimeCronjob, error) {
+	var uptimes []UptimeCronjob
+	err := db.Where("node_id IS NULL AND timestamp >= ? AND timestamp < ?", startTime, endTime).
+		Order("timestamp asc").Order("observer asc").
+		Find(&uptimes).Error
+	return uptimes, err
+}
+
+// Nodes with uptime intervals ending at or after the given time
+func FetchActiveUptimeNodes(db *gorm.DB, since time.Time) ([]string, error) {
+	var nodeIDs []string
+	err := db.Model(&UptimeInterval{}).Distinct("node_id").Where("end_time >= ?", since).
+		Order("node_id asc").Pluck("node_id", &nodeIDs).Error
+	return nodeIDs, err
+}
+
+// Timestamp of the first uptime sample, zero time if there are no samples
+func FetchFirstUptimeTimestamp(db *gorm.DB) (time.Time, error) {
+	var uptime UptimeCronjob
+	err := db.Order("timestamp asc").First(&uptime).Error
+	if err == gorm.ErrRecordNotFound {
+		return time.Time{}, nil
+	}
+	return uptime.Timestamp, err
+}
+
+// Last uptime interval of each of the nodes
+func FetchLastUptimeIntervals(db *gorm.DB, nodeIDs []string) ([]UptimeInterval, error) {
+	var intervals []UptimeInterval
+	if len(nodeIDs) == 0 {
+		return intervals, nil
+	}
+	last := db.Model(&UptimeInterval{}).Select("MAX(id)").Where("node_id IN ?", nodeIDs).Group("node_id")
+	err := db.Where("id IN (?)", last).Find(&intervals).Error
+	return intervals, err
+}
+
+// Insert new and update extended uptime intervals
+func PersistUptimeIntervals(db *gorm.DB, intervals []*UptimeInterval) error {
+	if len(intervals) == 0 {
+		return nil
+	}
+	return db.Save(intervals).Error
+}
+
+// Uptime intervals of the node overlapping with the time range [startTime, endTime)
+func FetchNodeUptimeIntervals(db *gorm.DB, nodeID string, startTime time.Time, endTime time.Time) ([]UptimeInterval, error) {
+	var intervals []UptimeInterval
+	err := db.Where("node_id = ? AND end_time > ? AND start_time < ?", nodeID, startTime, endTime).
+		Order("end_time asc").Find(&intervals).Error
+	return intervals, err
+}
+
+func DeleteUptimeIntervalsBefore(db *gorm.DB, timestamp time.Time) error {
+	return db.Where("end_time < ?", timestamp).Delete(&UptimeInterval{}).Error
+}
+
+// Sum and number of the uptime percentages reported for the node by the
+// observers in the samples in (start, end]
+func FetchNodeReportedUptimes(db *gorm.DB, nodeID string, start, end time.Time) (float64, int64, error) {
+	var result struct {
+		Sum   float64
+		Count int64
+	}
+	err := db.Model(&UptimeCronjob{}).
+		Select("COALESCE(SUM(reported_uptime), 0) AS sum, COUNT(reported_uptime) AS count").
+		Where("node_id = ? AND timestamp > ? AND timestamp <= ?", nodeID, start, end).
+		Scan(&result).Error
+	return result.Sum, result.Count, err
+}
+
+// Latest uptime aggregations of the node, sorted by epoch descending
+func FetchNodeUptimeAggregations(db *gorm.DB, nodeID string, limit int) ([]UptimeAggregation, error) {
+	var aggregations []UptimeAggregation
+	err := db.Where("node_id = ?", nodeID).Order("epoch desc").Limit(limit).Find(&aggregations).Error
+	return aggregations, err
+}
+
+// Uptime aggregations of all nodes in the epoch, sorted by node id
+func FetchEpochUptimeAggregations(db *gorm.DB, epoch int64) ([]UptimeAggregation, error) {
+	var aggregations []UptimeAggregation
+	err := db.Where("epoch = ?", epoch).Order("node_id asc").Find(&aggregations).Error
+	return aggregations, err
+}
+
 func PersistUptimeAggregations(db *gorm.DB, aggregations []*UptimeAggregation) error {
 	if len(aggregations) == 0 {
 		return nil
@@ -66,6 +226,143 @@ func PersistUptimeAggregations(db *gorm.DB, aggregations []*UptimeAggregation) e
 	return db.Create(aggregations).Error
 }
 
+// Replace the uptime aggregations of the epochs in [firstEpoch, lastEpoch] in
+// a single transaction
+func ReplaceUptimeAggregations(db *gorm.DB, firstEpoch, lastEpoch int64, aggregations []*UptimeAggregation) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("epoch >= ? AND epoch <= ?", firstEpoch, lastEpoch).Delete(&UptimeAggregation{}).Error
+		if err != nil {
+			return err
+		}
+		return PersistUptimeAggregations(tx, aggregations)
+	})
+}
+
 func DeleteUptimesBefore(db *gorm.DB, timestamp time.Time) error {
 	return db.Where("timestamp < ?", timestamp).Delete(&UptimeCronjob{}).Error
 }
+
+// Insert or update the mirroring status of the transaction
+func PersistMirrorStatus(db *gorm.DB, status *MirrorStatus) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tx_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"epoch", "status", "skip_reason", "error", "c_chain_tx_hash", "attempts", "next_retry", "updated",
+		}),
+	}).Create(status).Error
+}
+
+// Fetch the mirroring status of the transaction, returns gorm.ErrRecordNotFound
+// if the transaction was not processed by the mirroring cronjob
+func FetchMirrorStatus(db *gorm.DB, txID string) (*MirrorStatus, error) {
+	var status MirrorStatus
+	err := db.Where(&MirrorStatus{TxID: txID}).First(&status).Error
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func FetchMirrorStatuses(db *gorm.DB, txIDs []string) ([]MirrorStatus, error) {
+	var statuses []MirrorStatus
+	if len(txIDs) == 0 {
+		return statuses, nil
+	}
+	err := db.Where("tx_id IN ?", txIDs).Find(&statuses).Error
+	return statuses, err
+}
+
+// Fetch epochs with pending or failed transactions due for (re)mirroring at the
+// given time
+func FetchMirrorRetryEpochs(db *gorm.DB, now time.Time) ([]int64, error) {
+	var epochs []int64
+	err := db.Model(&MirrorStatus{}).
+		Where("status IN ?", []MirrorTxStatus{MirrorTxStatusPending, MirrorTxStatusFailed}).
+		Where("next_retry <= ?", now).
+		Distinct().
+		Order("epoch").
+		Pluck("epoch", &epochs).Error
+	return epochs, err
+}
+
+// Fetch the run of the job for the epoch, nil if the epoch was not run
+func FetchEpochJobRun(db *gorm.DB, job string, epoch int64) (*EpochJobRun, error) {
+	var run EpochJobRun
+	err := db.Where(&EpochJobRun{Job: job, Epoch: epoch}).First(&run).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Fetch the runs of the job for epochs before the given one that are pending
+// or failed and due for retry at the given time, sorted by epoch
+func FetchEpochJobRetries(db *gorm.DB, job string, before int64, now time.Time) ([]EpochJobRun, error) {
+	var runs []EpochJobRun
+	err := db.Where("job = ? AND epoch < ?", job, before).
+		Where("status = ? OR (status = ? AND next_retry <= ?)", EpochJobRunPending, EpochJobRunFailed, now).
+		Order("epoch").
+		Find(&runs).Error
+	return runs, err
+}
+
+// Insert or update the run of the job for the epoch
+func PersistEpochJobRun(db *gorm.DB, run *EpochJobRun) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "job"}, {Name: "epoch"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"status", "attempts", "error", "next_retry", "started", "updated",
+		}),
+	}).Create(run).Error
+}
+
+// Insert or update the root check of the epoch. If leaves are given, they
+// replace the stored leaves of the epoch.
+func PersistEpochRootCheck(db *gorm.DB, check *EpochRootCheck, leaves []*EpochRootLeaf) error {
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "epoch"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"local_root", "finalized_root", "mismatch", "num_leaves", "missing_leaves", "extra_leaves", "checked",
+		}),
+	}).Create(check).Error
+	if err != nil || leaves == nil {
+		return err
+	}
+
+	if err := db.Where("epoch = ?", check.Epoch).Delete(&EpochRootLeaf{}).Error; err != nil {
+		return err
+	}
+	if len(leaves) == 0 {
+		return nil
+	}
+	return db.CreateInBatches(leaves, 1000).Error
+}
+
+// Fetch the root check of the epoch, returns nil if the epoch was not checked
+func FetchEpochRootCheck(db *gorm.DB, epoch int64) (*EpochRootCheck, error) {
+	var checks []EpochRootCheck
+	err := db.Where("epoch = ?", epoch).Limit(1).Find(&checks).Error
+	if err != nil || len(checks) == 0 {
+		return nil, err
+	}
+	return &checks[0], nil
+}
+
+func FetchEpochRootLeaves(db *gorm.DB, epoch int64) ([]EpochRootLeaf, error) {
+	var leaves []EpochRootLeaf
+	err := db.Where("epoch = ?", epoch).Find(&leaves).Error
+	return leaves, err
+}
+
+func FetchMismatchedEpochs(db *gorm.DB) ([]int64, error) {
+	var epochs []int64
+	err := db.Model(&EpochRootCheck{}).Where("mismatch = ?", true).Order("epoch").Pluck("epoch", &epochs).Error
+	return epochs, err
+}
+
+func CreateDryRunSubmission(db *gorm.DB, submission *DryRunSubmission) error {
+	return db.Create(submission).Error
+}
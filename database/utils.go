@@ -23,7 +23,20 @@ var (
 		PChainTxInput{},
 		PChainTxOutput{},
 		UptimeCronjob{},
+		UptimeInterval{},
 		UptimeAggregation{},
+		MirrorStatus{},
+		VotingVote{},
+		VotingFinalized{},
+		VotingUptimeVote{},
+		EpochRootCheck{},
+		EpochRootLeaf{},
+		DryRunSubmission{},
+		EpochMerkleTree{},
+		EpochConfigSegment{},
+		EpochJobRun{},
+		AdminAction{},
+		LeaderLease{},
 	}
 )
 
@@ -63,6 +76,11 @@ func ConnectAndInitialize(cfg *config.DBConfig) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	err = registerMetricsCallbacks(db)
+	if err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
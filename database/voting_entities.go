@@ -0,0 +1,72 @@
+package database
+
+import "time"
+
+// Position of the event log on the C-chain, logs are identified by the
+// transaction hash and the index of the log in the block
+type ContractLog struct {
+	BlockNumber uint64 `gorm:"index"`
+	TxHash      string `gorm:"type:varchar(66);uniqueIndex:idx_tx_log"`
+	LogIndex    uint   `gorm:"uniqueIndex:idx_tx_log"`
+}
+
+// Merkle root vote of a voter submitted to the voting contract
+type VotingVote struct {
+	BaseEntity
+	ContractLog
+	Epoch      int64  `gorm:"index"`
+	Voter      string `gorm:"type:varchar(42);index"`
+	MerkleRoot string `gorm:"type:varchar(66)"`
+}
+
+// Merkle root of the epoch finalized by the voting contract after the voting
+// threshold was reached
+type VotingFinalized struct {
+	BaseEntity
+	ContractLog
+	Epoch      int64  `gorm:"index"`
+	MerkleRoot string `gorm:"type:varchar(66)"`
+}
+
+// Uptime vote of a voter submitted to the voting contract
+type VotingUptimeVote struct {
+	BaseEntity
+	ContractLog
+	RewardEpoch int64     `gorm:"index"`
+	Timestamp   time.Time // Time of the vote as reported by the contract
+	Voter       string    `gorm:"type:varchar(42);index"`
+
+	// Comma separated list of node ids with sufficient uptime
+	NodeIDs string `gorm:"type:text"`
+}
+
+// Merkle tree of the staking transactions of an epoch, stored when the epoch
+// is voted so that proofs can be served without querying the epoch data
+type EpochMerkleTree struct {
+	BaseEntity
+	Epoch     int64  `gorm:"uniqueIndex"`
+	Root      string `gorm:"type:varchar(66)"`
+	NumLeaves int
+	TreeSize  int // Number of nodes of the tree
+
+	// JSON encoded leaves (tx id, input address and hash) in the order of the
+	// tree
+	Leaves string `gorm:"type:mediumtext"`
+
+	// Fingerprint of the p-chain data the tree was computed from, the tree is
+	// stale if the fingerprint of the current data differs
+	TxCount int64
+	MaxTxID uint64
+
+	Updated time.Time
+}
+
+// Epoch configuration of the voting contract, valid from FirstEpoch on. New
+// rows are added when the configuration of the contract changes.
+type EpochConfigSegment struct {
+	BaseEntity
+	FirstEpoch    int64     `gorm:"uniqueIndex"`
+	Start         time.Time // Start of epoch 0 of the configuration
+	PeriodSeconds int64
+	Created       time.Time
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Persist events of the voting contract, events that are already stored (e.g.
+// after reindexing a block range) are ignored
+func CreateVotingEvents(db *gorm.DB, votes []*VotingVote, finalized []*VotingFinalized, uptimeVotes []*VotingUptimeVote) error {
+	db = db.Clauses(clause.OnConflict{DoNothing: true})
+	if len(votes) > 0 {
+		if err := db.Create(votes).Error; err != nil {
+			return err
+		}
+	}
+	if len(finalized) > 0 {
+		if err := db.Create(finalized).Error; err != nil {
+			return err
+		}
+	}
+	if len(uptimeVotes) > 0 {
+		if err := db.Create(uptimeVotes).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func FetchVotingVotes(db *gorm.DB, epoch int64) ([]VotingVote, error) {
+	var votes []VotingVote
+	err := db.Where("epoch = ?", epoch).Order("block_number, log_index").Find(&votes).Error
+	return votes, err
+}
+
+// Fetch the finalization of the epoch, returns nil if the epoch is not
+// finalized. If the voting was reset and the epoch finalized again, the last
+// finalization is returned.
+func FetchVotingFinalized(db *gorm.DB, epoch int64) (*VotingFinalized, error) {
+	var finalized []VotingFinalized
+	err := db.Where("epoch = ?", epoch).Order("block_number DESC, log_index DESC").Limit(1).Find(&finalized).Error
+	if err != nil || len(finalized) == 0 {
+		return nil, err
+	}
+	return &finalized[0], nil
+}
+
+func FetchVotingUptimeVotes(db *gorm.DB, rewardEpoch int64) ([]VotingUptimeVote, error) {
+	var votes []VotingUptimeVote
+	err := db.Where("reward_epoch = ?", rewardEpoch).Order("block_number, log_index").Find(&votes).Error
+	return votes, err
+}
+
+// Insert or replace the merkle tree of the epoch
+func PersistEpochMerkleTree(db *gorm.DB, tree *EpochMerkleTree) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "epoch"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"root", "num_leaves", "tree_size", "leaves", "tx_count", "max_tx_id", "updated",
+		}),
+	}).Create(tree).Error
+}
+
+// Fetch the stored merkle tree of the epoch, returns nil if there is none
+func FetchEpochMerkleTree(db *gorm.DB, epoch int64) (*EpochMerkleTree, error) {
+	var trees []EpochMerkleTree
+	err := db.Where("epoch = ?", epoch).Limit(1).Find(&trees).Error
+	if err != nil || len(trees) == 0 {
+		return nil, err
+	}
+	return &trees[0], nil
+}
+
+// Fetch the epoch configurations ordered by their first epoch
+func FetchEpochConfigSegments(db *gorm.DB) ([]EpochConfigSegment, error) {
+	var segments []EpochConfigSegment
+	err := db.Order("first_epoch").Find(&segments).Error
+	return segments, err
+}
+
+// Create the epoch configuration, an existing configuration with the same first
+// epoch is kept
+func CreateEpochConfigSegment(db *gorm.DB, segment *EpochConfigSegment) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(segment).Error
+}
@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"flare-indexer/indexer/shared"
+	"flare-indexer/logger"
+	"flare-indexer/services/api"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+
+	// Number of admin actions returned by the audit route
+	auditLimit = 100
+
+	// Max length of the error stored with the admin action
+	actionErrorLength = 256
+)
+
+// Admin API of the indexer, lists the indexers and cronjobs with their states,
+// pauses, resumes, calls and resets them and changes the log level. Every
+// action is recorded in the admin actions table.
+type Server struct {
+	db     adminDB
+	apiKey string
+	jobs   []*shared.JobControl
+
+	// For testing to set "now" to some past date
+	now func() time.Time
+}
+
+type adminDB interface {
+	FetchStates(names []string) ([]database.State, error)
+	SaveAdminAction(action *database.AdminAction) error
+	FetchAdminActions(limit int) ([]database.AdminAction, error)
+}
+
+type JobResponse struct {
+	Name       string          `json:"name"`
+	Kind       string          `json:"kind"`
+	Running    bool            `json:"running"`
+	Paused     bool            `json:"paused"`
+	Resettable bool            `json:"resettable"`
+	States     []StateResponse `json:"states"`
+}
+
+type StateResponse struct {
+	Name           string    `json:"name"`
+	NextIndex      uint64    `json:"nextIndex"`
+	LastChainIndex uint64    `json:"lastChainIndex"`
+	Updated        time.Time `json:"updated"`
+}
+
+type ActionResponse struct {
+	Action    string    `json:"action"`
+	Job       string    `json:"job"`
+	Params    string    `json:"params"`
+	Remote    string    `json:"remote"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+func NewServer(cfg *config.AdminConfig, db *gorm.DB, jobs []*shared.JobControl) *Server {
+	return newServer(cfg.ApiKey, &adminDBGorm{g: db}, jobs)
+}
+
+func newServer(apiKey string, db adminDB, jobs []*shared.JobControl) *Server {
+	return &Server{db: db, apiKey: apiKey, jobs: jobs, now: time.Now}
+}
+
+func (s *Server) Register(r *mux.Router) {
+	sr := r.PathPrefix("/admin").Subrouter()
+	sr.Use(s.authenticate)
+
+	sr.HandleFunc("/jobs", s.listJobs).Methods(http.MethodGet)
+	sr.HandleFunc("/jobs/{name}/pause", s.jobAction("pause", s.pause)).Methods(http.MethodPost)
+	sr.HandleFunc("/jobs/{name}/resume", s.jobAction("resume", s.resume)).Methods(http.MethodPost)
+	sr.HandleFunc("/jobs/{name}/call", s.jobAction("call", s.call)).Methods(http.MethodPost)
+	sr.HandleFunc("/jobs/{name}/reset/{epoch}", s.jobAction("reset", s.reset)).Methods(http.MethodPost)
+	sr.HandleFunc("/log-level", s.getLogLevel).Methods(http.MethodGet)
+	sr.HandleFunc("/log-level/{level}", s.setLogLevel).Methods(http.MethodPut)
+	sr.HandleFunc("/audit", s.listActions).Methods(http.MethodGet)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+		if len(s.apiKey) == 0 || subtle.ConstantTimeCompare([]byte(key), []byte(s.apiKey)) != 1 {
+			logger.Warn("Unauthorized admin request %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			writeError(w, http.StatusUnauthorized, api.ApiResStatusUnauthorized, "invalid api key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := make([]JobResponse, len(s.jobs))
+	for i, job := range s.jobs {
+		states, err := s.db.FetchStates(job.StateNames)
+		if err != nil {
+			logger.Error("Admin: failed fetching states of %s: %v", job.Name, err)
+			writeError(w, http.StatusInternalServerError, api.ApiResStatusError, "failed fetching states")
+			return
+		}
+		jobs[i] = JobResponse{
+			Name:       job.Name,
+			Kind:       job.Kind,
+			Running:    job.Running(),
+			Paused:     job.Paused(),
+			Resettable: job.ResetEpoch != nil,
+			States:     make([]StateResponse, len(states)),
+		}
+		for j, state := range states {
+			jobs[i].States[j] = StateResponse{
+				Name:           state.Name,
+				NextIndex:      state.NextDBIndex,
+				LastChainIndex: state.LastChainIndex,
+				Updated:        state.Updated,
+			}
+		}
+	}
+	writeOk(w, jobs)
+}
+
+// Error of an admin action with the status of the response
+type actionError struct {
+	code int
+	err  error
+}
+
+func (e *actionError) Error() string {
+	return e.err.Error()
+}
+
+// Handler of an action of the job in the path, the action returns its
+// parameters for the audit table
+func (s *Server) jobAction(action string, f func(r *http.Request, job *shared.JobControl) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		job := s.job(name)
+		if job == nil {
+			s.audited(w, r, action, name, "", &actionError{http.StatusNotFound, errors.Errorf("unknown job %q", name)})
+			return
+		}
+		params, err := f(r, job)
+		s.audited(w, r, action, name, params, err)
+	}
+}
+
+func (s *Server) job(name string) *shared.JobControl {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+func (s *Server) pause(r *http.Request, job *shared.JobControl) (string, error) {
+	job.SetPaused(true)
+	return "", nil
+}
+
+func (s *Server) resume(r *http.Request, job *shared.JobControl) (string, error) {
+	job.SetPaused(false)
+	return "", nil
+}
+
+func (s *Server) call(r *http.Request, job *shared.JobControl) (string, error) {
+	return "", job.Trigger(r.Context())
+}
+
+func (s *Server) reset(r *http.Request, job *shared.JobControl) (string, error) {
+	epochParam := mux.Vars(r)["epoch"]
+	params := "epoch=" + epochParam
+	epoch, err := strconv.ParseInt(epochParam, 10, 64)
+	if err != nil || epoch <= 0 {
+		return params, &actionError{http.StatusBadRequest, errors.Errorf("invalid epoch %q", epochParam)}
+	}
+	if job.ResetEpoch == nil {
+		return params, &actionError{http.StatusBadRequest, errors.Errorf("%s has no epoch cursor", job.Name)}
+	}
+	return params, job.Do(r.Context(), func() error { return job.ResetEpoch(epoch) })
+}
+
+func (s *Server) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeOk(w, LogLevelResponse{Level: logger.Level()})
+}
+
+func (s *Server) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	level := mux.Vars(r)["level"]
+	err := logger.SetLevel(level)
+	if err != nil {
+		err = &actionError{http.StatusBadRequest, err}
+	}
+	s.audited(w, r, "log_level", "", "level="+level, err)
+}
+
+func (s *Server) listActions(w http.ResponseWriter, r *http.Request) {
+	actions, err := s.db.FetchAdminActions(auditLimit)
+	if err != nil {
+		logger.Error("Admin: failed fetching admin actions: %v", err)
+		writeError(w, http.StatusInternalServerError, api.ApiResStatusError, "failed fetching admin actions")
+		return
+	}
+	resp := make([]ActionResponse, len(actions))
+	for i, a := range actions {
+		resp[i] = ActionResponse{
+			Action:    a.Action,
+			Job:       a.Job,
+			Params:    a.Params,
+			Remote:    a.Remote,
+			Error:     a.Error,
+			Timestamp: a.Timestamp,
+		}
+	}
+	writeOk(w, resp)
+}
+
+// Record the outcome of the action in the audit table and write the response
+func (s *Server) audited(w http.ResponseWriter, r *http.Request, action, job, params string, err error) {
+	record := &database.AdminAction{
+		Action:    action,
+		Job:       job,
+		Params:    params,
+		Remote:    r.RemoteAddr,
+		Timestamp: s.now(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+		if len(record.Error) > actionErrorLength {
+			record.Error = record.Error[:actionErrorLength]
+		}
+	}
+	if dbErr := s.db.SaveAdminAction(record); dbErr != nil {
+		logger.Error("Admin: failed recording action %s %s: %v", action, job, dbErr)
+		writeError(w, http.StatusInternalServerError, api.ApiResStatusError, "failed recording admin action")
+		return
+	}
+
+	if err != nil {
+		logger.Warn("Admin: %s %s %s failed: %v", action, job, params, err)
+		code := http.StatusInternalServerError
+		var actionErr *actionError
+		if errors.As(err, &actionErr) {
+			code = actionErr.code
+		} else if errors.Is(err, shared.ErrJobNotRunning) {
+			code = http.StatusConflict
+		}
+		writeError(w, code, api.ApiResStatusError, err.Error())
+		return
+	}
+	logger.Info("Admin: %s %s %s", action, job, params)
+	writeOk(w, fmt.Sprintf("%s done", action))
+}
+
+func writeOk(w http.ResponseWriter, data any) {
+	writeResponse(w, http.StatusOK, api.ApiResponseWrapper[any]{Status: api.ApiResStatusOk, Data: data})
+}
+
+func writeError(w http.ResponseWriter, code int, status api.ApiResStatusEnum, message string) {
+	writeResponse(w, code, api.ApiResponseWrapper[any]{Status: status, ErrorMessage: message})
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp api.ApiResponseWrapper[any]) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("Admin: failed writing response: %v", err)
+	}
+}
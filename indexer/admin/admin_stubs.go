@@ -0,0 +1,23 @@
+package admin
+
+import (
+	"flare-indexer/database"
+
+	"gorm.io/gorm"
+)
+
+type adminDBGorm struct {
+	g *gorm.DB
+}
+
+func (db *adminDBGorm) FetchStates(names []string) ([]database.State, error) {
+	return database.FetchStates(db.g, names)
+}
+
+func (db *adminDBGorm) SaveAdminAction(action *database.AdminAction) error {
+	return database.PersistAdminAction(db.g, action)
+}
+
+func (db *adminDBGorm) FetchAdminActions(limit int) ([]database.AdminAction, error) {
+	return database.FetchAdminActions(db.g, limit)
+}
@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"encoding/json"
+	"flare-indexer/database"
+	"flare-indexer/indexer/shared"
+	"flare-indexer/logger"
+	"flare-indexer/services/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const testApiKey = "secret"
+
+type testAdminDB struct {
+	states  []database.State
+	actions []database.AdminAction
+}
+
+func (db *testAdminDB) FetchStates(names []string) ([]database.State, error) {
+	var states []database.State
+	for _, state := range db.states {
+		for _, name := range names {
+			if state.Name == name {
+				states = append(states, state)
+			}
+		}
+	}
+	return states, nil
+}
+
+func (db *testAdminDB) SaveAdminAction(action *database.AdminAction) error {
+	db.actions = append(db.actions, *action)
+	return nil
+}
+
+func (db *testAdminDB) FetchAdminActions(limit int) ([]database.AdminAction, error) {
+	var actions []database.AdminAction
+	for i := len(db.actions) - 1; i >= 0 && len(actions) < limit; i-- {
+		actions = append(actions, db.actions[i])
+	}
+	return actions, nil
+}
+
+// Cronjob counting its calls, the epoch cursor is set by resets
+type testJob struct {
+	calls chan struct{}
+	epoch int64
+}
+
+func newTestServer(t *testing.T) (*mux.Router, *testAdminDB, *testJob) {
+	db := &testAdminDB{
+		states: []database.State{
+			{Name: "voting_cronjob", NextDBIndex: 5},
+			{Name: "p_chain_block", NextDBIndex: 100, LastChainIndex: 120},
+		},
+	}
+	job := &testJob{calls: make(chan struct{}, 10)}
+
+	voting := shared.NewJobControl("voting", shared.JobKindCronjob, "voting_cronjob")
+	voting.ResetEpoch = func(epoch int64) error {
+		job.epoch = epoch
+		return nil
+	}
+	go voting.Run(time.Hour, func() error {
+		job.calls <- struct{}{}
+		return nil
+	})
+	require.Eventually(t, voting.Running, time.Second, time.Millisecond)
+
+	// Disabled indexer, not running
+	indexer := shared.NewJobControl("p_chain_block", shared.JobKindIndexer, "p_chain_block")
+
+	s := newServer(testApiKey, db, []*shared.JobControl{indexer, voting})
+	s.now = func() time.Time { return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC) }
+	r := mux.NewRouter()
+	s.Register(r)
+	return r, db, job
+}
+
+func adminRequest[T any](t *testing.T, r *mux.Router, method, path, apiKey string, code int) api.ApiResponseWrapper[T] {
+	req := httptest.NewRequest(method, path, nil)
+	if len(apiKey) > 0 {
+		req.Header.Set(apiKeyHeader, apiKey)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, code, rr.Code, rr.Body.String())
+
+	var resp api.ApiResponseWrapper[T]
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestUnauthorized(t *testing.T) {
+	r, db, _ := newTestServer(t)
+
+	adminRequest[any](t, r, http.MethodGet, "/admin/jobs", "", http.StatusUnauthorized)
+	adminRequest[any](t, r, http.MethodPost, "/admin/jobs/voting/pause", "wrong", http.StatusUnauthorized)
+	require.Empty(t, db.actions)
+}
+
+func TestListJobs(t *testing.T) {
+	r, _, _ := newTestServer(t)
+
+	resp := adminRequest[[]JobResponse](t, r, http.MethodGet, "/admin/jobs", testApiKey, http.StatusOK)
+	require.Len(t, resp.Data, 2)
+	require.Equal(t, "p_chain_block", resp.Data[0].Name)
+	require.Equal(t, shared.JobKindIndexer, resp.Data[0].Kind)
+	require.False(t, resp.Data[0].Running)
+	require.False(t, resp.Data[0].Resettable)
+	require.Equal(t, uint64(120), resp.Data[0].States[0].LastChainIndex)
+	require.Equal(t, "voting", resp.Data[1].Name)
+	require.True(t, resp.Data[1].Running)
+	require.True(t, resp.Data[1].Resettable)
+	require.Equal(t, uint64(5), resp.Data[1].States[0].NextIndex)
+}
+
+func TestJobActions(t *testing.T) {
+	r, db, job := newTestServer(t)
+
+	adminRequest[string](t, r, http.MethodPost, "/admin/jobs/voting/pause", testApiKey, http.StatusOK)
+	resp := adminRequest[[]JobResponse](t, r, http.MethodGet, "/admin/jobs", testApiKey, http.StatusOK)
+	require.True(t, resp.Data[1].Paused)
+
+	// Paused jobs can be called
+	adminRequest[string](t, r, http.MethodPost, "/admin/jobs/voting/call", testApiKey, http.StatusOK)
+	require.Len(t, job.calls, 1)
+
+	adminRequest[string](t, r, http.MethodPost, "/admin/jobs/voting/resume", testApiKey, http.StatusOK)
+	adminRequest[string](t, r, http.MethodPost, "/admin/jobs/voting/reset/12", testApiKey, http.StatusOK)
+	require.Equal(t, int64(12), job.epoch)
+
+	adminRequest[any](t, r, http.MethodPost, "/admin/jobs/voting/reset/0", testApiKey, http.StatusBadRequest)
+	adminRequest[any](t, r, http.MethodPost, "/admin/jobs/p_chain_block/reset/12", testApiKey, http.StatusBadRequest)
+	adminRequest[any](t, r, http.MethodPost, "/admin/jobs/p_chain_block/call", testApiKey, http.StatusConflict)
+	adminRequest[any](t, r, http.MethodPost, "/admin/jobs/unknown/pause", testApiKey, http.StatusNotFound)
+
+	require.Len(t, db.actions, 8)
+	require.Equal(t, "pause", db.actions[0].Action)
+	require.Equal(t, "voting", db.actions[0].Job)
+	require.Empty(t, db.actions[0].Error)
+	require.Equal(t, "epoch=12", db.actions[3].Params)
+	require.Equal(t, "invalid epoch \"0\"", db.actions[4].Error)
+	require.Equal(t, shared.ErrJobNotRunning.Error(), db.actions[6].Error)
+	require.Equal(t, "unknown", db.actions[7].Job)
+
+	audit := adminRequest[[]ActionResponse](t, r, http.MethodGet, "/admin/audit", testApiKey, http.StatusOK)
+	require.Len(t, audit.Data, 8)
+	require.Equal(t, "pause", audit.Data[0].Action)
+	require.Equal(t, "unknown", audit.Data[0].Job)
+}
+
+func TestJobCallError(t *testing.T) {
+	control := shared.NewJobControl("mirror", shared.JobKindCronjob)
+	go control.Run(time.Hour, func() error { return errors.New("call failed") })
+	require.Eventually(t, control.Running, time.Second, time.Millisecond)
+
+	db := &testAdminDB{}
+	r := mux.NewRouter()
+	newServer(testApiKey, db, []*shared.JobControl{control}).Register(r)
+
+	resp := adminRequest[any](t, r, http.MethodPost, "/admin/jobs/mirror/call", testApiKey, http.StatusInternalServerError)
+	require.Equal(t, "call failed", resp.ErrorMessage)
+	require.Equal(t, "call failed", db.actions[0].Error)
+}
+
+func TestLogLevel(t *testing.T) {
+	r, db, _ := newTestServer(t)
+	defer func() { require.NoError(t, logger.SetLevel("DEBUG")) }()
+
+	adminRequest[string](t, r, http.MethodPut, "/admin/log-level/warn", testApiKey, http.StatusOK)
+	resp := adminRequest[LogLevelResponse](t, r, http.MethodGet, "/admin/log-level", testApiKey, http.StatusOK)
+	require.Equal(t, "WARN", resp.Data.Level)
+
+	adminRequest[any](t, r, http.MethodPut, "/admin/log-level/verbose", testApiKey, http.StatusBadRequest)
+	require.Len(t, db.actions, 2)
+	require.Equal(t, "log_level", db.actions[1].Action)
+	require.Equal(t, "level=verbose", db.actions[1].Params)
+	require.NotEmpty(t, db.actions[1].Error)
+}
@@ -6,23 +6,43 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
 )
 
 type Config struct {
-	DB                config.DBConfig     `toml:"db"`
-	Logger            config.LoggerConfig `toml:"logger"`
-	Chain             config.ChainConfig  `toml:"chain"`
-	Metrics           MetricsConfig       `toml:"metrics"`
-	XChainIndexer     IndexerConfig       `toml:"x_chain_indexer"`
-	PChainIndexer     IndexerConfig       `toml:"p_chain_indexer"`
-	UptimeCronjob     UptimeConfig        `toml:"uptime_cronjob"`
-	Mirror            MirrorConfig        `toml:"mirroring_cronjob"`
-	VotingCronjob     VotingConfig        `toml:"voting_cronjob"`
-	ContractAddresses ContractAddresses   `toml:"contract_addresses"`
+	DB                config.DBConfig            `toml:"db"`
+	Logger            config.LoggerConfig        `toml:"logger"`
+	Chain             config.ChainConfig         `toml:"chain"`
+	Signer            config.SignerConfig        `toml:"signer"`
+	Transactions      TxConfig                   `toml:"transactions"`
+	Metrics           MetricsConfig              `toml:"metrics"`
+	Admin             AdminConfig                `toml:"admin"`
+	Health            config.HealthConfig        `toml:"health"`
+	XChainIndexer     IndexerConfig              `toml:"x_chain_indexer"`
+	PChainIndexer     IndexerConfig              `toml:"p_chain_indexer"`
+	VotingEvents      IndexerConfig              `toml:"voting_events_indexer"`
+	UptimeCronjob     UptimeConfig               `toml:"uptime_cronjob"`
+	Mirror            MirrorConfig               `toml:"mirroring_cronjob"`
+	VotingCronjob     VotingConfig               `toml:"voting_cronjob"`
+	Divergence        DivergenceConfig           `toml:"divergence_cronjob"`
+	EpochJobs         EpochJobConfig             `toml:"epoch_jobs"`
+	LeaderElection    LeaderElectionConfig       `toml:"leader_election"`
+	EpochSchedule     config.EpochScheduleConfig `toml:"epoch_schedule"`
+	ContractAddresses ContractAddresses          `toml:"contract_addresses"`
 }
 
 type MetricsConfig struct {
 	PrometheusAddress string `toml:"prometheus_address envconfig:"PROMETHEUS_ADDRESS"`
+
+	// Value of the network label of all metrics, defaults to the address HRP
+	// of the chain
+	Network string `toml:"network" envconfig:"METRICS_NETWORK"`
+}
+
+// Admin API served by the metrics server, disabled if the API key is not set
+type AdminConfig struct {
+	// Key expected in the X-API-Key header of admin requests
+	ApiKey string `toml:"api_key" envconfig:"ADMIN_API_KEY"`
 }
 
 type IndexerConfig struct {
@@ -42,26 +62,148 @@ type CronjobConfig struct {
 type MirrorConfig struct {
 	CronjobConfig
 	config.EpochConfig
+
+	// Delay before retrying a failed transaction, doubled on each failed attempt
+	RetryDelay time.Duration `toml:"retry_delay"`
+	// Failed transactions are not retried after this many attempts, 0 for no limit
+	MaxAttempts int `toml:"max_attempts"`
+	// Number of txs mirrored in a single multicall transaction, 0 or 1 to
+	// mirror each tx in a separate transaction
+	TxBatchSize int `toml:"tx_batch_size"`
+	// Record mirroring transactions instead of sending them
+	DryRun bool `toml:"dry_run"`
 }
 
 type VotingConfig struct {
 	CronjobConfig
 	config.EpochConfig
+
+	// Record votes instead of sending them
+	DryRun bool `toml:"dry_run"`
+}
+
+type DivergenceConfig struct {
+	CronjobConfig
+	config.EpochConfig
+
+	// Number of epochs before the next unchecked epoch that are checked again
+	// on each run, e.g., to detect changes of the indexed data after reindexing
+	RecheckEpochs int64 `toml:"recheck_epochs"`
 }
 
 type UptimeConfig struct {
 	CronjobConfig
-	Period                         time.Duration   `toml:"period" envconfig:"UPTIME_EPOCH_PERIOD"`
-	Start                          utils.Timestamp `toml:"start" envconfig:"UPTIME_EPOCH_START"`
-	First                          int64           `toml:"first" envconfig:"UPTIME_EPOCH_FIRST"`
-	EnableVoting                   bool            `toml:"enable_voting"`
-	UptimeThreshold                float64         `toml:"uptime_threshold"`
-	DeleteOldUptimesEpochThreshold int64           `toml:"delete_old_uptimes_epoch_threshold"`
+	Period          time.Duration   `toml:"period" envconfig:"UPTIME_EPOCH_PERIOD"`
+	Start           utils.Timestamp `toml:"start" envconfig:"UPTIME_EPOCH_START"`
+	First           int64           `toml:"first" envconfig:"UPTIME_EPOCH_FIRST"`
+	EnableVoting    bool            `toml:"enable_voting"`
+	UptimeThreshold float64         `toml:"uptime_threshold"`
+	// Uptime ratio compared with the threshold: "sampled" (default), "reported"
+	// by the observers, or "both" of them
+	UptimeSource                   string `toml:"uptime_source"`
+	DeleteOldUptimesEpochThreshold int64  `toml:"delete_old_uptimes_epoch_threshold"`
+	// Record uptime votes instead of sending them
+	DryRun bool `toml:"dry_run"`
+
+	// Nodes asked for the connected validators, the node of the chain config
+	// is the only observer if empty
+	Observers []UptimeObserverConfig `toml:"observers"`
+	// Rule deciding whether a validator is connected from the observations of
+	// the observers: "majority", "any", "all" or the minimum number of
+	// observers reporting it connected
+	Quorum string `toml:"quorum"`
+
+	Compaction UptimeCompactionConfig `toml:"compaction"`
+	Policy     UptimePolicyConfig     `toml:"policy"`
+}
+
+// Treatment of the time with an ambiguous status of a validator in the uptime
+// aggregation: "connected" (default), "disconnected" or "excluded" from the
+// staking duration the uptime ratio is computed from
+type UptimePolicyConfig struct {
+	// Time before samples with these error statuses
+	Timeout        string `toml:"timeout"`
+	ServiceError   string `toml:"service_error"`
+	IndexerStarted string `toml:"indexer_started"`
+
+	// Time without samples longer than GapPeriods sampling periods (timeout of
+	// the uptime cronjob), 0 to disable
+	Gap        string `toml:"gap"`
+	GapPeriods int    `toml:"gap_periods"`
+}
+
+// Compaction of the uptime samples into connectivity intervals, enabled with
+// the uptime cronjob
+type UptimeCompactionConfig struct {
+	// Interval between compaction runs
+	Interval time.Duration `toml:"interval"`
+	// Samples younger than this are not compacted yet, samples of some
+	// observers may not be stored yet
+	Delay time.Duration `toml:"delay"`
+	// Compacted samples and intervals are deleted after these durations, 0 to
+	// keep them
+	RawRetention      time.Duration `toml:"raw_retention"`
+	IntervalRetention time.Duration `toml:"interval_retention"`
+}
+
+type UptimeObserverConfig struct {
+	// Stored with the observations, at most 60 characters
+	ID      string `toml:"id"`
+	NodeURL string `toml:"node_url"`
+	ApiKey  string `toml:"api_key"`
+}
+
+// Retry of failed epochs of the voting, mirroring and uptime voting cronjobs
+type EpochJobConfig struct {
+	// Delay before retrying a failed epoch, doubled on each failed attempt
+	RetryDelay time.Duration `toml:"retry_delay"`
+	// Failed epochs are not retried after this many attempts and the cronjob
+	// continues with the next epoch, 0 for no limit
+	MaxAttempts int `toml:"max_attempts"`
+}
+
+// Leader election of indexer instances sharing the database. Only the leader
+// runs the voting, mirroring and uptime voting cronjobs, all instances index.
+type LeaderElectionConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Name of the lease, instances with the same lease compete for leadership
+	Lease string `toml:"lease"`
+	// Holder of the lease stored in the database, defaults to the host name and
+	// the process id
+	InstanceID string `toml:"instance_id" envconfig:"LEADER_INSTANCE_ID"`
+	// Leadership is lost if the lease is not renewed within this duration,
+	// should be longer than a call of the cronjobs run by the leader
+	LeaseDuration time.Duration `toml:"lease_duration"`
+	RenewInterval time.Duration `toml:"renew_interval"`
+}
+
+// Settings of transactions sent by the voting, mirroring and uptime voting cronjobs
+type TxConfig struct {
+	// Caps of the EIP-1559 fees in gwei, 0 means no cap
+	MaxFeeGwei         uint64 `toml:"max_fee_gwei" envconfig:"TX_MAX_FEE_GWEI"`
+	MaxPriorityFeeGwei uint64 `toml:"max_priority_fee_gwei" envconfig:"TX_MAX_PRIORITY_FEE_GWEI"`
+
+	ReceiptTimeout time.Duration `toml:"receipt_timeout"`
+	PollInterval   time.Duration `toml:"poll_interval"`
+
+	// Max duration of each request to the C-chain node made when sending
+	// transactions
+	RPCTimeout time.Duration `toml:"rpc_timeout"`
+
+	// Fees of a transaction that is not mined within the receipt timeout are
+	// increased by this percent (min 10) and the transaction is resubmitted
+	FeeBumpPercent   int `toml:"fee_bump_percent"`
+	MaxResubmissions int `toml:"max_resubmissions"`
+
+	// Balance of the voting account (in FLR) below which the low balance
+	// metric is set, 0 to disable
+	LowBalance float64 `toml:"low_balance"`
 }
 
 type ContractAddresses struct {
 	config.ContractAddresses
 	Mirroring common.Address `toml:"mirroring" envconfig:"MIRRORING_CONTRACT_ADDRESS"`
+	Multicall common.Address `toml:"multicall" envconfig:"MULTICALL_CONTRACT_ADDRESS"`
 }
 
 func newConfig() *Config {
@@ -78,15 +220,55 @@ func newConfig() *Config {
 			BatchSize:  10,
 			StartIndex: 0,
 		},
+		VotingEvents: IndexerConfig{
+			Enabled:   false,
+			Timeout:   10 * time.Second,
+			BatchSize: 1000,
+		},
 		UptimeCronjob: UptimeConfig{
 			CronjobConfig: CronjobConfig{
 				Enabled: false,
 				Timeout: 60 * time.Second,
 			},
+			Quorum: "majority",
+			Compaction: UptimeCompactionConfig{
+				Interval: time.Minute,
+				Delay:    10 * time.Second,
+			},
 		},
 		Chain: config.ChainConfig{
 			NodeURL: "http://localhost:9650/",
 		},
+		Mirror: MirrorConfig{
+			RetryDelay:  time.Minute,
+			MaxAttempts: 10,
+		},
+		Divergence: DivergenceConfig{
+			RecheckEpochs: 10,
+		},
+		EpochJobs: EpochJobConfig{
+			RetryDelay: time.Minute,
+		},
+		LeaderElection: LeaderElectionConfig{
+			Lease:         "voter",
+			LeaseDuration: 60 * time.Second,
+			RenewInterval: 10 * time.Second,
+		},
+		EpochSchedule: config.EpochScheduleConfig{
+			RefreshInterval: 5 * time.Minute,
+		},
+		Health: config.HealthConfig{
+			MaxIndexLag:  100,
+			MaxStateAge:  5 * time.Minute,
+			CheckTimeout: 5 * time.Second,
+		},
+		Transactions: TxConfig{
+			ReceiptTimeout:   60 * time.Second,
+			PollInterval:     1 * time.Second,
+			RPCTimeout:       10 * time.Second,
+			FeeBumpPercent:   20,
+			MaxResubmissions: 3,
+		},
 	}
 }
 
@@ -108,5 +290,34 @@ func BuildConfig(cfgFileName string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(cfg.Metrics.Network) == 0 {
+		cfg.Metrics.Network = cfg.Chain.ChainAddressHRP
+	}
+	if err := cfg.UptimeCronjob.validate(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
+
+// Max time after the end of an uptime epoch until it is aggregated: the
+// samples are compacted after the compaction delay at the next compaction run
+// and the epoch is aggregated at the next run of the uptime voting cronjob
+func (c *UptimeConfig) aggregationDelay() time.Duration {
+	return c.Compaction.Delay + c.Compaction.Interval + c.Timeout
+}
+
+// Raw samples must be kept until their epoch is aggregated, since the
+// aggregations contain the uptimes reported in the samples
+func (c *UptimeConfig) validate() error {
+	retention := c.Compaction.RawRetention
+	if !c.Enabled || retention <= 0 {
+		return nil
+	}
+	if minRetention := c.Period + c.aggregationDelay(); retention < minRetention {
+		return errors.Errorf(
+			"uptime_cronjob.compaction.raw_retention %s is shorter than the uptime epoch period plus the aggregation delay %s",
+			retention, minRetention,
+		)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	globalConfig "flare-indexer/config"
 	"flare-indexer/database"
 	"flare-indexer/indexer/config"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -25,6 +26,25 @@ type IndexerFlags struct {
 	// Set start epoch for mirroring cronjob to this value, overrides config and database value,
 	// valid value is > 0
 	ResetMirrorCronjob int64
+
+	// Run with recorded p-chain data and simulated contracts instead of the nodes
+	Simulate bool
+
+	// Simulation time runs SimulateSpeed times faster than the real time
+	SimulateSpeed float64
+
+	// Simulation start time (RFC3339), defaults to the start of the recorded data
+	SimulateStart string
+
+	// Epoch period of the simulated voting contract
+	SimulateEpochPeriod time.Duration
+
+	// Directory with recorded data, defaults to the test recordings
+	SimulateData string
+
+	// Admin command and its arguments following the flags, the indexer is
+	// not started if set
+	Command []string
 }
 
 type indexerContext struct {
@@ -63,11 +83,22 @@ func parseIndexerFlags() *IndexerFlags {
 	cfgFlag := flag.String("config", globalConfig.CONFIG_FILE, "Configuration file (toml format)")
 	resetVotingFlag := flag.Int64("reset-voting", 0, "Set start epoch for voting cronjob to this value, overrides config and database value, valid values are > 0")
 	resetMirrorFlag := flag.Int64("reset-mirroring", 0, "Set start epoch for mirroring cronjob to this value, overrides config and database value, valid values are > 0")
+	simulateFlag := flag.Bool("simulate", false, "Run with recorded p-chain data and simulated contracts instead of the nodes")
+	simulateSpeedFlag := flag.Float64("simulate-speed", 60, "Run simulation time this many times faster than the real time")
+	simulateStartFlag := flag.String("simulate-start", "", "Simulation start time (RFC3339), defaults to the start of the recorded data")
+	simulateEpochPeriodFlag := flag.Duration("simulate-epoch-period", 90*time.Second, "Epoch period of the simulated voting contract")
+	simulateDataFlag := flag.String("simulate-data", "", "Directory with recorded data, defaults to the test recordings")
 	flag.Parse()
 
 	return &IndexerFlags{
-		ConfigFileName:     *cfgFlag,
-		ResetVotingCronjob: *resetVotingFlag,
-		ResetMirrorCronjob: *resetMirrorFlag,
+		ConfigFileName:      *cfgFlag,
+		ResetVotingCronjob:  *resetVotingFlag,
+		ResetMirrorCronjob:  *resetMirrorFlag,
+		Simulate:            *simulateFlag,
+		SimulateSpeed:       *simulateSpeedFlag,
+		SimulateStart:       *simulateStartFlag,
+		SimulateEpochPeriod: *simulateEpochPeriodFlag,
+		SimulateData:        *simulateDataFlag,
+		Command:             flag.Args(),
 	}
 }
@@ -0,0 +1,118 @@
+package cronjob
+
+import (
+	"flare-indexer/indexer/config"
+	"flare-indexer/utils"
+	"flare-indexer/utils/chain"
+	"flare-indexer/utils/staking"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"gorm.io/gorm"
+)
+
+// Chain clients and time used by the cronjobs. Clients that are not set are
+// created from the configuration, time defaults to the real time.
+type Clients struct {
+	// Backend of the voting and mirroring contracts
+	Eth EthBackend
+
+	Uptime chain.UptimeClient
+
+	Time *utils.ShiftedTime
+
+	// Transactions are only sent while Leader returns true, if set, so that a
+	// call outliving the leader lease does not send transactions with the key
+	// and the nonces used by the new leader
+	Leader func() bool
+
+	// Transaction sender shared by the cronjobs, so nonces of the voting account
+	// are managed in one place
+	sender *txSender
+
+	// Epoch schedule shared by the cronjobs, so changes of the epoch
+	// configuration are seen by all of them
+	schedule *staking.EpochSchedule
+}
+
+func (c *Clients) ethBackend(cfg *config.Config) (EthBackend, error) {
+	if c.Eth != nil {
+		return c.Eth, nil
+	}
+	eth, err := ethclient.Dial(cfg.Chain.EthRPCURL)
+	if err != nil {
+		return nil, err
+	}
+	c.Eth = &instrumentedBackend{Client: eth}
+	return c.Eth, nil
+}
+
+func (c *Clients) txSender(cfg *config.Config) (*txSender, error) {
+	if c.sender != nil {
+		return c.sender, nil
+	}
+	eth, err := c.ethBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	txOpts, err := newTransactOpts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.sender = newTxSender(eth, txOpts, &cfg.Transactions)
+	c.sender.leader = c.Leader
+	c.sender.updateBalance()
+	return c.sender, nil
+}
+
+// Epoch schedule loaded from the database and the voting contract on first use
+func (c *Clients) epochSchedule(cfg *config.Config, db *gorm.DB) (*staking.EpochSchedule, error) {
+	if c.schedule != nil {
+		return c.schedule, nil
+	}
+	contract, err := newEpochConfigContractCChain(cfg, c)
+	if err != nil {
+		return nil, err
+	}
+	schedule := staking.NewEpochSchedule()
+	now := c.time()
+	if err := refreshEpochSchedule(schedule, &epochScheduleDBGorm{g: db}, contract, now.Now()); err != nil {
+		return nil, err
+	}
+	c.schedule = schedule
+	return schedule, nil
+}
+
+func (c *Clients) uptimeClient(cfg *config.Config) chain.UptimeClient {
+	if c.Uptime != nil {
+		return c.Uptime
+	}
+	return newAvalancheUptimeClient(cfg.Chain.NodeURL, cfg.Chain.ApiKey)
+}
+
+// Observers of the uptime cronjob, the uptime client is the only observer if
+// it is set or no observers are configured
+func (c *Clients) uptimeObservers(cfg *config.Config) []uptimeObserver {
+	if c.Uptime != nil || len(cfg.UptimeCronjob.Observers) == 0 {
+		return []uptimeObserver{{client: c.uptimeClient(cfg)}}
+	}
+	observers := make([]uptimeObserver, len(cfg.UptimeCronjob.Observers))
+	for i, o := range cfg.UptimeCronjob.Observers {
+		observers[i] = uptimeObserver{
+			id:     o.ID,
+			client: newAvalancheUptimeClient(o.NodeURL, o.ApiKey),
+		}
+	}
+	return observers
+}
+
+func newAvalancheUptimeClient(nodeURL string, apiKey string) chain.UptimeClient {
+	endpoint := utils.JoinPaths(nodeURL, "ext/bc/P"+chain.RPCClientOptions(apiKey))
+	return chain.NewAvalancheUptimeClient(endpoint)
+}
+
+func (c *Clients) time() utils.ShiftedTime {
+	if c.Time != nil {
+		return *c.Time
+	}
+	return utils.ShiftedTime{}
+}
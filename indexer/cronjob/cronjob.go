@@ -3,10 +3,13 @@ package cronjob
 import (
 	"flare-indexer/database"
 	"flare-indexer/indexer/config"
+	"flare-indexer/indexer/shared"
 	"flare-indexer/logger"
 	"flare-indexer/utils"
 	"flare-indexer/utils/staking"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type Cronjob interface {
@@ -17,7 +20,9 @@ type Cronjob interface {
 	OnStart() error
 }
 
-func RunCronjob(c Cronjob) {
+// Run the cronjob controlled by the admin API with the control created by
+// NewCronjobControl
+func RunCronjob(c Cronjob, control *shared.JobControl) {
 	if !c.Enabled() {
 		logger.Debug("%s cronjob disabled", c.Name())
 		return
@@ -30,14 +35,35 @@ func RunCronjob(c Cronjob) {
 	}
 
 	logger.Debug("starting %s cronjob", c.Name())
+	control.Run(c.Timeout(), instrumentedCall(c.Name(), c.Call))
+}
+
+// Cronjob with an epoch cursor that can be set to an earlier or later epoch
+type resettableCronjob interface {
+	reset(firstEpoch int64) error
+}
 
-	ticker := time.NewTicker(c.Timeout())
-	for range ticker.C {
-		err := c.Call()
-		if err != nil {
-			logger.Error("%s cronjob error %s", c.Name(), err.Error())
+// Names of the state rows of the cronjobs
+var cronjobStateNames = map[string][]string{
+	"voting":            {votingStateName},
+	"mirror":            {mirrorStateName},
+	"uptime_compaction": {uptimeCompactionStateName},
+	"uptime_aggregator": {uptimeVotingStateName},
+	"voting_events":     {votingEventsStateName},
+	"divergence":        {divergenceStateName},
+}
+
+func NewCronjobControl(c Cronjob) *shared.JobControl {
+	control := shared.NewJobControl(c.Name(), shared.JobKindCronjob, cronjobStateNames[c.Name()]...)
+	if r, ok := c.(resettableCronjob); ok {
+		control.ResetEpoch = func(epoch int64) error {
+			if epoch <= 0 {
+				return errors.New("epoch must be > 0")
+			}
+			return r.reset(epoch)
 		}
 	}
+	return control
 }
 
 const (
@@ -50,6 +76,9 @@ type epochCronjob struct {
 	epochs    staking.EpochInfo
 	delay     time.Duration // voting delay
 	batchSize int64
+
+	// Retry of failed epochs of epoch jobs
+	retry epochJobRetry
 }
 
 type epochRange struct {
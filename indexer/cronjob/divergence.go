@@ -0,0 +1,290 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	indexerctx "flare-indexer/indexer/context"
+	"flare-indexer/indexer/pchain"
+	"flare-indexer/logger"
+	"flare-indexer/utils"
+	"flare-indexer/utils/staking"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	divergenceStateName string = "divergence_cronjob"
+)
+
+var (
+	epochRootMismatch = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "epoch_root_mismatch",
+		Help: "Number of epochs whose merkle root computed from the indexed data differs from the finalized root",
+	})
+	epochRootMismatchLast = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "epoch_root_mismatch_last_epoch",
+		Help: "Last epoch whose local merkle root differs from the finalized root, -1 if there is none",
+	})
+	epochRootLastChecked = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "epoch_root_last_checked_epoch",
+		Help: "Last epoch whose local merkle root was compared with the finalized root",
+	})
+)
+
+// Cronjob comparing merkle roots computed from the indexed p-chain
+// transactions with the roots finalized on the voting contract
+type divergenceCronjob struct {
+	epochCronjob
+
+	db       divergenceDB
+	contract divergenceContract
+
+	// Number of already checked epochs that are checked again on each run
+	recheckEpochs int64
+
+	// For testing to set "now" to some past date
+	time utils.ShiftedTime
+}
+
+type divergenceDB interface {
+	FetchState(name string) (database.State, error)
+	UpdateState(state *database.State) error
+	FetchPChainVotingData(start, end time.Time) ([]database.PChainTxData, error)
+	FetchEpochRootCheck(epoch int64) (*database.EpochRootCheck, error)
+	FetchEpochRootLeaves(epoch int64) ([]database.EpochRootLeaf, error)
+	FetchMismatchedEpochs() ([]int64, error)
+
+	// Save the check, leaves (if not nil) replace the stored leaves of the epoch
+	SaveEpochRootCheck(check *database.EpochRootCheck, leaves []*database.EpochRootLeaf) error
+}
+
+type divergenceContract interface {
+	GetMerkleRoot(epoch int64) ([32]byte, error)
+	EpochConfig() (time.Time, time.Duration, error)
+}
+
+func NewDivergenceCronjob(ctx indexerctx.IndexerContext, clients *Clients) (*divergenceCronjob, error) {
+	cfg := ctx.Config()
+	if !cfg.Divergence.Enabled {
+		return &divergenceCronjob{}, nil
+	}
+
+	contract, err := newDivergenceContractCChain(cfg, clients)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := clients.epochSchedule(cfg, ctx.DB())
+	if err != nil {
+		return nil, err
+	}
+	epochs := staking.NewScheduledEpochInfo(&cfg.Divergence.EpochConfig, schedule)
+
+	return &divergenceCronjob{
+		epochCronjob:  newEpochCronjob(&cfg.Divergence.CronjobConfig, epochs),
+		db:            &divergenceDBGorm{g: ctx.DB()},
+		contract:      contract,
+		recheckEpochs: cfg.Divergence.RecheckEpochs,
+		time:          clients.time(),
+	}, nil
+}
+
+func (c *divergenceCronjob) Name() string {
+	return "divergence"
+}
+
+func (c *divergenceCronjob) OnStart() error {
+	return nil
+}
+
+func (c *divergenceCronjob) Call() error {
+	idxState, err := c.db.FetchState(pchain.StateName)
+	if err != nil {
+		return err
+	}
+
+	state, err := c.db.FetchState(divergenceStateName)
+	if err != nil {
+		return err
+	}
+	next := utils.Max(int64(state.NextDBIndex), c.epochs.First)
+
+	// Check mismatched and recently checked epochs again, the indexed data
+	// could have changed since
+	mismatched, err := c.db.FetchMismatchedEpochs()
+	if err != nil {
+		return err
+	}
+	for _, e := range recheckedEpochs(mismatched, utils.Max(next-c.recheckEpochs, c.epochs.First), next) {
+		if _, err := c.checkEpoch(e); err != nil {
+			return err
+		}
+	}
+
+	epochRange := c.getEpochRange(next, c.time.Now())
+	logger.Debug("Checking merkle roots of epochs [%d, %d]", epochRange.start, epochRange.end)
+	for e := epochRange.start; e <= epochRange.end; e++ {
+		if c.indexerBehind(&idxState, e) {
+			logger.Debug("indexer is behind, skipping merkle root check of epoch %d", e)
+			break
+		}
+
+		finalized, err := c.checkEpoch(e)
+		if err != nil {
+			return err
+		}
+		if !finalized {
+			logger.Debug("merkle root of epoch %d is not finalized yet", e)
+			break
+		}
+
+		state.NextDBIndex = uint64(e + 1)
+		if err := c.db.UpdateState(&state); err != nil {
+			return err
+		}
+		epochRootLastChecked.Set(float64(e))
+	}
+
+	return c.updateMismatchMetrics()
+}
+
+// Compare the local merkle root of the epoch with the finalized root. Returns
+// false if the epoch is not finalized yet.
+func (c *divergenceCronjob) checkEpoch(e int64) (bool, error) {
+	finalizedRoot, err := c.contract.GetMerkleRoot(e)
+	if err != nil {
+		return false, errors.Wrap(err, "GetMerkleRoot")
+	}
+	if finalizedRoot == [32]byte{} {
+		return false, nil
+	}
+
+	start, end := c.epochs.GetTimeRange(e)
+	txs, err := c.db.FetchPChainVotingData(start, end)
+	if err != nil {
+		return false, err
+	}
+	txs = staking.DedupeTxs(txs)
+
+	leaves := make([]*database.EpochRootLeaf, len(txs))
+	for i := range txs {
+		hash, err := staking.HashTransaction(&txs[i])
+		if err != nil {
+			return false, err
+		}
+		leaves[i] = &database.EpochRootLeaf{Epoch: e, TxID: *txs[i].TxID, LeafHash: hash.Hex()}
+	}
+
+	localRoot := staking.EmptyTreeRoot
+	if len(txs) > 0 {
+		localRoot, err = staking.GetMerkleRoot(txs)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	check := &database.EpochRootCheck{
+		Epoch:         e,
+		LocalRoot:     localRoot.Hex(),
+		FinalizedRoot: common.Hash(finalizedRoot).Hex(),
+		Mismatch:      localRoot != common.Hash(finalizedRoot),
+		NumLeaves:     len(leaves),
+		Checked:       time.Now(),
+	}
+	if !check.Mismatch {
+		prev, err := c.db.FetchEpochRootCheck(e)
+		if err != nil {
+			return false, err
+		}
+		// Store the leaves of the matching tree for the diff of later checks,
+		// they cannot change while the root matches
+		if prev == nil || prev.Mismatch {
+			return true, c.db.SaveEpochRootCheck(check, leaves)
+		}
+		return true, c.db.SaveEpochRootCheck(check, nil)
+	}
+
+	verified, err := c.db.FetchEpochRootLeaves(e)
+	if err != nil {
+		return false, err
+	}
+	missing, extra := diffLeaves(verified, leaves)
+	check.MissingLeaves = strings.Join(missing, ",")
+	check.ExtraLeaves = strings.Join(extra, ",")
+	if len(verified) == 0 {
+		logger.Error("local merkle root %s of epoch %d does not match finalized root %s, no matching tree to compare leaves with",
+			check.LocalRoot, e, check.FinalizedRoot)
+	} else {
+		logger.Error("local merkle root %s of epoch %d does not match finalized root %s, missing leaves of txs [%s], extra leaves of txs [%s]",
+			check.LocalRoot, e, check.FinalizedRoot, check.MissingLeaves, check.ExtraLeaves)
+	}
+	return true, c.db.SaveEpochRootCheck(check, nil)
+}
+
+func (c *divergenceCronjob) updateMismatchMetrics() error {
+	mismatched, err := c.db.FetchMismatchedEpochs()
+	if err != nil {
+		return err
+	}
+	epochRootMismatch.Set(float64(len(mismatched)))
+	if len(mismatched) == 0 {
+		epochRootMismatchLast.Set(-1)
+	} else {
+		epochRootMismatchLast.Set(float64(mismatched[len(mismatched)-1]))
+	}
+	return nil
+}
+
+// Sorted union of mismatched epochs before next and the epochs [from, next)
+func recheckedEpochs(mismatched []int64, from, next int64) []int64 {
+	epochs := make(map[int64]bool)
+	for _, e := range mismatched {
+		if e < next {
+			epochs[e] = true
+		}
+	}
+	for e := from; e < next; e++ {
+		epochs[e] = true
+	}
+
+	result := make([]int64, 0, len(epochs))
+	for e := range epochs {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// Ids of transactions whose leaves are in the reference but not in the current
+// leaves (missing) and vice versa (extra). Leaves are compared by hash, a tx
+// with changed data is both missing and extra.
+func diffLeaves(reference []database.EpochRootLeaf, current []*database.EpochRootLeaf) (missing []string, extra []string) {
+	if len(reference) == 0 {
+		return nil, nil
+	}
+
+	currentHashes := make(map[string]bool, len(current))
+	for _, leaf := range current {
+		currentHashes[leaf.LeafHash] = true
+	}
+	referenceHashes := make(map[string]bool, len(reference))
+	for _, leaf := range reference {
+		referenceHashes[leaf.LeafHash] = true
+		if !currentHashes[leaf.LeafHash] {
+			missing = append(missing, leaf.TxID)
+		}
+	}
+	for _, leaf := range current {
+		if !referenceHashes[leaf.LeafHash] {
+			extra = append(extra, leaf.TxID)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
@@ -0,0 +1,68 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"flare-indexer/utils/contracts/voting"
+	"flare-indexer/utils/staking"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"gorm.io/gorm"
+)
+
+type divergenceDBGorm struct {
+	g *gorm.DB
+}
+
+func (db *divergenceDBGorm) FetchState(name string) (database.State, error) {
+	return database.FetchState(db.g, name)
+}
+
+func (db *divergenceDBGorm) UpdateState(state *database.State) error {
+	return database.UpdateState(db.g, state)
+}
+
+func (db *divergenceDBGorm) FetchPChainVotingData(start, end time.Time) ([]database.PChainTxData, error) {
+	return database.FetchPChainVotingData(db.g, start, end)
+}
+
+func (db *divergenceDBGorm) FetchEpochRootCheck(epoch int64) (*database.EpochRootCheck, error) {
+	return database.FetchEpochRootCheck(db.g, epoch)
+}
+
+func (db *divergenceDBGorm) FetchEpochRootLeaves(epoch int64) ([]database.EpochRootLeaf, error) {
+	return database.FetchEpochRootLeaves(db.g, epoch)
+}
+
+func (db *divergenceDBGorm) FetchMismatchedEpochs() ([]int64, error) {
+	return database.FetchMismatchedEpochs(db.g)
+}
+
+func (db *divergenceDBGorm) SaveEpochRootCheck(check *database.EpochRootCheck, leaves []*database.EpochRootLeaf) error {
+	return db.g.Transaction(func(tx *gorm.DB) error {
+		return database.PersistEpochRootCheck(tx, check, leaves)
+	})
+}
+
+// Read-only access to the voting contract, no transactions are sent
+type divergenceContractCChain struct {
+	voting *voting.Voting
+}
+
+func newDivergenceContractCChain(cfg *config.Config, clients *Clients) (*divergenceContractCChain, error) {
+	votingContract, err := newVotingContract(cfg, clients)
+	if err != nil {
+		return nil, err
+	}
+	return &divergenceContractCChain{voting: votingContract}, nil
+}
+
+func (c *divergenceContractCChain) GetMerkleRoot(epoch int64) ([32]byte, error) {
+	return c.voting.GetMerkleRoot(new(bind.CallOpts), big.NewInt(epoch))
+}
+
+func (c *divergenceContractCChain) EpochConfig() (start time.Time, period time.Duration, err error) {
+	return staking.GetEpochConfig(c.voting)
+}
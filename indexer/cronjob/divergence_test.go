@@ -0,0 +1,161 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/pchain"
+	"flare-indexer/utils/staking"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type divergenceDBTest struct {
+	votingDBTest
+
+	checks map[int64]database.EpochRootCheck
+	leaves map[int64][]database.EpochRootLeaf
+}
+
+func (db *divergenceDBTest) FetchEpochRootCheck(epoch int64) (*database.EpochRootCheck, error) {
+	check, ok := db.checks[epoch]
+	if !ok {
+		return nil, nil
+	}
+	return &check, nil
+}
+
+func (db *divergenceDBTest) FetchEpochRootLeaves(epoch int64) ([]database.EpochRootLeaf, error) {
+	return db.leaves[epoch], nil
+}
+
+func (db *divergenceDBTest) FetchMismatchedEpochs() ([]int64, error) {
+	var epochs []int64
+	for e, check := range db.checks {
+		if check.Mismatch {
+			epochs = append(epochs, e)
+		}
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs, nil
+}
+
+func (db *divergenceDBTest) SaveEpochRootCheck(check *database.EpochRootCheck, leaves []*database.EpochRootLeaf) error {
+	db.checks[check.Epoch] = *check
+	if leaves != nil {
+		db.leaves[check.Epoch] = nil
+		for _, leaf := range leaves {
+			db.leaves[check.Epoch] = append(db.leaves[check.Epoch], *leaf)
+		}
+	}
+	return nil
+}
+
+type divergenceContractTest struct {
+	roots map[int64][32]byte
+}
+
+func (c *divergenceContractTest) GetMerkleRoot(epoch int64) ([32]byte, error) {
+	return c.roots[epoch], nil
+}
+
+func (c *divergenceContractTest) EpochConfig() (time.Time, time.Duration, error) {
+	return time.Now(), 180 * time.Second, nil
+}
+
+func newDivergenceTest(t *testing.T) (*divergenceCronjob, *divergenceDBTest, *divergenceContractTest) {
+	epochs := initEpochCronjob()
+
+	db := &divergenceDBTest{
+		votingDBTest: votingDBTest{
+			states: map[string]database.State{
+				pchain.StateName: {
+					Updated:        time.Now(),
+					NextDBIndex:    3,
+					LastChainIndex: 2,
+				},
+			},
+			votingData: map[timeRange][]database.PChainTxData{
+				timeRangeForEpoch(epochs, 1): {newTxData(0)},
+				timeRangeForEpoch(epochs, 2): {newTxData(1), newTxData(2)},
+			},
+		},
+		checks: make(map[int64]database.EpochRootCheck),
+		leaves: make(map[int64][]database.EpochRootLeaf),
+	}
+
+	root1, err := staking.GetMerkleRoot([]database.PChainTxData{newTxData(0)})
+	require.NoError(t, err)
+	root2, err := staking.GetMerkleRoot([]database.PChainTxData{newTxData(1), newTxData(2)})
+	require.NoError(t, err)
+	contract := &divergenceContractTest{
+		roots: map[int64][32]byte{
+			0: staking.EmptyTreeRoot,
+			1: root1,
+			2: root2,
+		},
+	}
+
+	return &divergenceCronjob{
+		epochCronjob:  epochs,
+		db:            db,
+		contract:      contract,
+		recheckEpochs: 1,
+	}, db, contract
+}
+
+func TestDivergenceMatch(t *testing.T) {
+	cronjob, db, _ := newDivergenceTest(t)
+
+	err := cronjob.Call()
+	require.NoError(t, err)
+
+	// Epoch 3 is not finalized
+	require.Equal(t, uint64(3), db.states[divergenceStateName].NextDBIndex)
+	require.Len(t, db.checks, 3)
+	for e, check := range db.checks {
+		require.False(t, check.Mismatch, "epoch %d", e)
+	}
+	require.Equal(t, staking.EmptyTreeRoot.Hex(), db.checks[0].LocalRoot)
+	require.Len(t, db.leaves[2], 2)
+	require.Equal(t, float64(0), testutil.ToFloat64(epochRootMismatch))
+	require.Equal(t, float64(2), testutil.ToFloat64(epochRootLastChecked))
+}
+
+func TestDivergenceMismatch(t *testing.T) {
+	cronjob, db, contract := newDivergenceTest(t)
+
+	// Indexed data of epoch 1 differs from the finalized data
+	db.votingData[timeRangeForEpoch(cronjob.epochCronjob, 1)] = nil
+
+	err := cronjob.Call()
+	require.NoError(t, err)
+	require.True(t, db.checks[1].Mismatch)
+	require.Empty(t, db.checks[1].MissingLeaves)
+	require.Equal(t, float64(1), testutil.ToFloat64(epochRootMismatch))
+	require.Equal(t, float64(1), testutil.ToFloat64(epochRootMismatchLast))
+
+	// Epoch 2 changes after it matched and is checked again
+	db.votingData[timeRangeForEpoch(cronjob.epochCronjob, 2)] = []database.PChainTxData{newTxData(0), newTxData(2)}
+	contract.roots[3] = [32]byte{1}
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.True(t, db.checks[2].Mismatch)
+	require.Equal(t, txIDs[1], db.checks[2].MissingLeaves)
+	require.Equal(t, txIDs[0], db.checks[2].ExtraLeaves)
+	require.True(t, db.checks[3].Mismatch)
+	require.Equal(t, float64(3), testutil.ToFloat64(epochRootMismatch))
+	require.Equal(t, float64(3), testutil.ToFloat64(epochRootMismatchLast))
+
+	// Mismatched epochs are checked again until they match
+	db.votingData[timeRangeForEpoch(cronjob.epochCronjob, 1)] = []database.PChainTxData{newTxData(0)}
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.False(t, db.checks[1].Mismatch)
+	require.Len(t, db.leaves[1], 1)
+	require.Equal(t, float64(2), testutil.ToFloat64(epochRootMismatch))
+}
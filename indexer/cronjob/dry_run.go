@@ -0,0 +1,194 @@
+package cronjob
+
+import (
+	"encoding/json"
+	"flare-indexer/database"
+	"flare-indexer/logger"
+	"flare-indexer/utils"
+	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/voting"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// Records contract calls of cronjobs in dry-run mode instead of sending them
+type dryRun struct {
+	db      dryRunDB
+	cronjob string
+
+	// Address of the contract that would be called
+	contract common.Address
+
+	// Account of the voter, its own indexed submissions are not compared
+	voter common.Address
+
+	time utils.ShiftedTime
+}
+
+type dryRunDB interface {
+	CreateDryRunSubmission(submission *database.DryRunSubmission) error
+	FetchVotingVotes(epoch int64) ([]database.VotingVote, error)
+	FetchVotingFinalized(epoch int64) (*database.VotingFinalized, error)
+	FetchVotingUptimeVotes(epoch int64) ([]database.VotingUptimeVote, error)
+}
+
+// JSON representation of the mirrored stake data and its merkle proof
+type dryRunStakeData struct {
+	TxID         string   `json:"txId"`
+	StakingType  uint8    `json:"stakingType"`
+	InputAddress string   `json:"inputAddress"`
+	NodeID       string   `json:"nodeId"`
+	StartTime    uint64   `json:"startTime"`
+	EndTime      uint64   `json:"endTime"`
+	Weight       uint64   `json:"weight"`
+	MerkleProof  []string `json:"merkleProof"`
+}
+
+// Record the submitVote call, compared with the indexed votes of other voters
+// and the finalized root
+func (d *dryRun) submitVote(epoch int64, merkleRoot common.Hash) error {
+	callData, err := packCall(voting.VotingMetaData.GetAbi, "submitVote", big.NewInt(epoch), [32]byte(merkleRoot))
+	if err != nil {
+		return err
+	}
+	submission := d.newSubmission(epoch, "submitVote", callData)
+	submission.MerkleRoot = merkleRoot.Hex()
+
+	votes, err := d.db.FetchVotingVotes(epoch)
+	if err != nil {
+		return err
+	}
+	for _, v := range votes {
+		if common.HexToAddress(v.Voter) == d.voter {
+			continue
+		}
+		if common.HexToHash(v.MerkleRoot) == merkleRoot {
+			submission.OthersMatching++
+		} else {
+			submission.OthersDifferent++
+		}
+	}
+	finalized, err := d.db.FetchVotingFinalized(epoch)
+	if err != nil {
+		return err
+	}
+	if finalized != nil {
+		submission.FinalizedRoot = finalized.MerkleRoot
+	}
+
+	logger.Info("dry run: vote for epoch %d with merkle root %s (other voters: %d matching, %d different, finalized root: %s)",
+		epoch, submission.MerkleRoot, submission.OthersMatching, submission.OthersDifferent, submission.FinalizedRoot)
+	return d.db.CreateDryRunSubmission(submission)
+}
+
+// Record the submitValidatorUptimeVote call, compared with the indexed uptime
+// votes of other voters (as sets of node ids)
+func (d *dryRun) submitUptimeVote(epoch int64, nodeIDs [][20]byte) error {
+	callData, err := packCall(voting.VotingMetaData.GetAbi, "submitValidatorUptimeVote", big.NewInt(epoch), nodeIDs)
+	if err != nil {
+		return err
+	}
+	submission := d.newSubmission(epoch, "submitValidatorUptimeVote", callData)
+	submission.NodeIDs = formatNodeIDs(nodeIDs)
+
+	votes, err := d.db.FetchVotingUptimeVotes(epoch)
+	if err != nil {
+		return err
+	}
+	for _, v := range votes {
+		if common.HexToAddress(v.Voter) == d.voter {
+			continue
+		}
+		if sortedNodeIDs(v.NodeIDs) == submission.NodeIDs {
+			submission.OthersMatching++
+		} else {
+			submission.OthersDifferent++
+		}
+	}
+
+	logger.Info("dry run: uptime vote for epoch %d with %d node ids [%s] (other voters: %d matching, %d different)",
+		epoch, len(nodeIDs), submission.NodeIDs, submission.OthersMatching, submission.OthersDifferent)
+	return d.db.CreateDryRunSubmission(submission)
+}
+
+// Record the mirrorStake call. Mirroring is not voted on, so there is nothing
+// to compare with.
+func (d *dryRun) mirrorStake(epoch int64, stake *mirrorStakeInput) error {
+	callData, err := packCall(mirroring.MirroringMetaData.GetAbi, "mirrorStake", *stake.stakeData, stake.merkleProof)
+	if err != nil {
+		return err
+	}
+	submission := d.newSubmission(epoch, "mirrorStake", callData)
+
+	data := stake.stakeData
+	stakeJSON := dryRunStakeData{
+		TxID:         ids.ID(data.TxId).String(),
+		StakingType:  data.StakingType,
+		InputAddress: hexutil.Encode(data.InputAddress[:]),
+		NodeID:       ids.NodeID(data.NodeId).String(),
+		StartTime:    data.StartTime,
+		EndTime:      data.EndTime,
+		Weight:       data.Weight,
+	}
+	for _, p := range stake.merkleProof {
+		stakeJSON.MerkleProof = append(stakeJSON.MerkleProof, hexutil.Encode(p[:]))
+	}
+	encoded, err := json.Marshal(stakeJSON)
+	if err != nil {
+		return err
+	}
+	submission.TxID = stakeJSON.TxID
+	submission.StakeData = string(encoded)
+
+	logger.Info("dry run: mirror tx %s of epoch %d: %s", submission.TxID, epoch, submission.StakeData)
+	return d.db.CreateDryRunSubmission(submission)
+}
+
+func (d *dryRun) newSubmission(epoch int64, method string, callData []byte) *database.DryRunSubmission {
+	return &database.DryRunSubmission{
+		Cronjob:   d.cronjob,
+		Epoch:     epoch,
+		Contract:  d.contract.Hex(),
+		Method:    method,
+		CallData:  hexutil.Encode(callData),
+		Timestamp: d.time.Now(),
+	}
+}
+
+func packCall(getAbi func() (*abi.ABI, error), method string, args ...interface{}) ([]byte, error) {
+	contractABI, err := getAbi()
+	if err != nil {
+		return nil, err
+	}
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "pack %s", method)
+	}
+	return data, nil
+}
+
+// Sorted comma separated node ids in the format of the indexed uptime votes
+func formatNodeIDs(nodeIDs [][20]byte) string {
+	formatted := make([]string, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		formatted[i] = ids.NodeID(nodeID).String()
+	}
+	sort.Strings(formatted)
+	return strings.Join(formatted, ",")
+}
+
+func sortedNodeIDs(nodeIDs string) string {
+	if nodeIDs == "" {
+		return ""
+	}
+	split := strings.Split(nodeIDs, ",")
+	sort.Strings(split)
+	return strings.Join(split, ",")
+}
@@ -0,0 +1,51 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+)
+
+type dryRunDBGorm struct {
+	g *gorm.DB
+}
+
+func (db *dryRunDBGorm) CreateDryRunSubmission(submission *database.DryRunSubmission) error {
+	return database.CreateDryRunSubmission(db.g, submission)
+}
+
+func (db *dryRunDBGorm) FetchVotingVotes(epoch int64) ([]database.VotingVote, error) {
+	return database.FetchVotingVotes(db.g, epoch)
+}
+
+func (db *dryRunDBGorm) FetchVotingFinalized(epoch int64) (*database.VotingFinalized, error) {
+	return database.FetchVotingFinalized(db.g, epoch)
+}
+
+func (db *dryRunDBGorm) FetchVotingUptimeVotes(epoch int64) ([]database.VotingUptimeVote, error) {
+	return database.FetchVotingUptimeVotes(db.g, epoch)
+}
+
+// Create the dry-run recorder of the cronjob calling the contract, the voter is
+// the account of the configured signer
+func newDryRun(
+	cfg *config.Config,
+	clients *Clients,
+	db *gorm.DB,
+	cronjob string,
+	contract common.Address,
+) (*dryRun, error) {
+	sender, err := clients.txSender(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRun{
+		db:       &dryRunDBGorm{g: db},
+		cronjob:  cronjob,
+		contract: contract,
+		voter:    sender.from(),
+		time:     clients.time(),
+	}, nil
+}
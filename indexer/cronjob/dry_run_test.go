@@ -0,0 +1,149 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/pchain"
+	"flare-indexer/utils/contracts/voting"
+	"flare-indexer/utils/staking"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type dryRunDBTest struct {
+	submissions []*database.DryRunSubmission
+	votes       map[int64][]database.VotingVote
+	finalized   map[int64]*database.VotingFinalized
+	uptimeVotes map[int64][]database.VotingUptimeVote
+}
+
+func (db *dryRunDBTest) CreateDryRunSubmission(submission *database.DryRunSubmission) error {
+	db.submissions = append(db.submissions, submission)
+	return nil
+}
+
+func (db *dryRunDBTest) FetchVotingVotes(epoch int64) ([]database.VotingVote, error) {
+	return db.votes[epoch], nil
+}
+
+func (db *dryRunDBTest) FetchVotingFinalized(epoch int64) (*database.VotingFinalized, error) {
+	return db.finalized[epoch], nil
+}
+
+func (db *dryRunDBTest) FetchVotingUptimeVotes(epoch int64) ([]database.VotingUptimeVote, error) {
+	return db.uptimeVotes[epoch], nil
+}
+
+var (
+	dryRunVoter = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	otherVoter1 = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	otherVoter2 = common.HexToAddress("0x3000000000000000000000000000000000000003")
+)
+
+func TestDryRunVote(t *testing.T) {
+	epochs := initEpochCronjob()
+	root, err := staking.GetMerkleRoot([]database.PChainTxData{newTxData(0)})
+	require.NoError(t, err)
+
+	dryRunDB := &dryRunDBTest{
+		votes: map[int64][]database.VotingVote{
+			1: {
+				{Voter: dryRunVoter.Hex(), MerkleRoot: common.Hash{1}.Hex()},
+				{Voter: otherVoter1.Hex(), MerkleRoot: root.Hex()},
+				{Voter: otherVoter2.Hex(), MerkleRoot: common.Hash{2}.Hex()},
+			},
+		},
+		finalized: map[int64]*database.VotingFinalized{
+			1: {MerkleRoot: root.Hex()},
+		},
+	}
+	db := &votingDBTest{
+		states: map[string]database.State{
+			pchain.StateName: {
+				Updated:        time.Now(),
+				NextDBIndex:    3,
+				LastChainIndex: 2,
+			},
+		},
+		votingData: map[timeRange][]database.PChainTxData{
+			timeRangeForEpoch(epochs, 1): {newTxData(0)},
+		},
+	}
+	contract := &votingContractTest{submittedVotes: make(map[int64][32]byte)}
+
+	cronjob := &votingCronjob{
+		epochCronjob: epochs,
+		db:           db,
+		contract:     contract,
+		dryRun:       &dryRun{db: dryRunDB, cronjob: votingStateName, voter: dryRunVoter},
+	}
+	cronjob.epochs.First = 1
+	cronjob.batchSize = 1
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+
+	// Vote is recorded although the contract would not accept it
+	require.Empty(t, contract.submittedVotes)
+	require.Len(t, dryRunDB.submissions, 1)
+
+	submission := dryRunDB.submissions[0]
+	require.Equal(t, int64(1), submission.Epoch)
+	require.Equal(t, "submitVote", submission.Method)
+	require.Equal(t, root.Hex(), submission.MerkleRoot)
+	require.Equal(t, root.Hex(), submission.FinalizedRoot)
+	require.Equal(t, 1, submission.OthersMatching)
+	require.Equal(t, 1, submission.OthersDifferent)
+
+	callData, err := packCall(voting.VotingMetaData.GetAbi, "submitVote", big.NewInt(1), [32]byte(root))
+	require.NoError(t, err)
+	require.Equal(t, common.Bytes2Hex(callData), submission.CallData[2:])
+}
+
+func TestDryRunUptimeVote(t *testing.T) {
+	nodeIDs := [][20]byte{{2}, {1}}
+	node1, node2 := ids.NodeID([20]byte{1}).String(), ids.NodeID([20]byte{2}).String()
+
+	db := &dryRunDBTest{
+		uptimeVotes: map[int64][]database.VotingUptimeVote{
+			5: {
+				{Voter: otherVoter1.Hex(), NodeIDs: node2 + "," + node1},
+				{Voter: otherVoter2.Hex(), NodeIDs: node1},
+			},
+		},
+	}
+	dr := &dryRun{db: db, cronjob: "uptime_voting", voter: dryRunVoter}
+
+	require.NoError(t, dr.submitUptimeVote(5, nodeIDs))
+	require.Len(t, db.submissions, 1)
+	require.Equal(t, node1+","+node2, db.submissions[0].NodeIDs)
+	require.Equal(t, 1, db.submissions[0].OthersMatching)
+	require.Equal(t, 1, db.submissions[0].OthersDifferent)
+}
+
+func TestDryRunMirror(t *testing.T) {
+	tx := newTxData(1)
+	tree, err := staking.BuildTree([]database.PChainTxData{newTxData(0), tx})
+	require.NoError(t, err)
+	stake, err := newMirrorStakeInput(&mirrorTxInput{
+		epochID:    big.NewInt(3),
+		merkleTree: tree,
+		tx:         &tx,
+		status:     &database.MirrorStatus{TxID: *tx.TxID},
+	})
+	require.NoError(t, err)
+
+	db := &dryRunDBTest{}
+	dr := &dryRun{db: db, cronjob: mirrorStateName}
+	require.NoError(t, dr.mirrorStake(3, stake))
+
+	require.Len(t, db.submissions, 1)
+	submission := db.submissions[0]
+	require.Equal(t, txIDs[1], submission.TxID)
+	require.Contains(t, submission.StakeData, tx.NodeID)
+	require.Contains(t, submission.StakeData, `"merkleProof":["0x`)
+}
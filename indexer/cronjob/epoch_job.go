@@ -0,0 +1,205 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"flare-indexer/logger"
+	"flare-indexer/utils"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+)
+
+// Max exponent of the retry delay backoff of failed epochs
+const epochJobMaxRetryBackoff = 5
+
+// Error of an epoch job for an epoch that cannot be processed yet, e.g.,
+// because the indexer is behind
+var errEpochSkipped = errors.New("epoch skipped")
+
+// Work of a cronjob done once for each epoch. Epochs are processed in order
+// and the outcome of each epoch is recorded in an epoch job run. Failed epochs
+// are retried with backoff and operators can request a re-run of an epoch, so
+// processing an epoch must be idempotent.
+type epochJob interface {
+	Name() string
+
+	// First epoch that was not processed yet
+	nextEpoch() (int64, error)
+
+	// Last epoch that can be processed at the given time, less than the next
+	// epoch if there is none
+	lastEpoch(now time.Time) (int64, error)
+
+	// Process the epoch, returns errEpochSkipped if it cannot be processed yet
+	runEpoch(epoch int64) error
+
+	// Record that the epochs before the given one are processed
+	setNextEpoch(epoch int64) error
+}
+
+type epochJobDB interface {
+	FetchEpochJobRun(job string, epoch int64) (*database.EpochJobRun, error)
+	FetchEpochJobRetries(job string, before int64, now time.Time) ([]database.EpochJobRun, error)
+	SaveEpochJobRun(run *database.EpochJobRun) error
+}
+
+// Retry settings shared by the epoch jobs
+type epochJobRetry struct {
+	// Delay before retrying a failed epoch, doubled on each failed attempt
+	delay time.Duration
+
+	// Failed epochs are not retried after maxAttempts and the job continues
+	// with the next epoch, 0 for no limit
+	maxAttempts int
+}
+
+func newEpochJobRetry(cfg *config.EpochJobConfig) epochJobRetry {
+	return epochJobRetry{delay: cfg.RetryDelay, maxAttempts: cfg.MaxAttempts}
+}
+
+// Run the re-runs and the retries of the already processed epochs that are due
+// and then the new epochs of the job
+func (c *epochCronjob) runEpochJob(job epochJob, db epochJobDB, clock *utils.ShiftedTime) error {
+	now := clock.Now()
+	next, err := job.nextEpoch()
+	if err != nil {
+		return err
+	}
+
+	retries, err := db.FetchEpochJobRetries(job.Name(), next, now)
+	if err != nil {
+		return err
+	}
+	for i := range retries {
+		logger.Info("%s: running epoch %d again (%s)", job.Name(), retries[i].Epoch, retries[i].Status)
+		if _, err := c.runJobEpoch(job, db, &retries[i], clock); err != nil {
+			return err
+		}
+	}
+
+	last, err := job.lastEpoch(now)
+	if err != nil {
+		return err
+	}
+	if last < next {
+		return nil
+	}
+	epochRange := c.getTrimmedEpochRange(next, last)
+	logger.Debug("%s needed for epochs [%d, %d]", job.Name(), epochRange.start, epochRange.end)
+	for epoch := epochRange.start; epoch <= epochRange.end; epoch++ {
+		run, err := db.FetchEpochJobRun(job.Name(), epoch)
+		if err != nil {
+			return err
+		}
+		if run == nil {
+			run = &database.EpochJobRun{Job: job.Name(), Epoch: epoch}
+		} else if run.Status == database.EpochJobRunFailed && run.NextRetry != nil && run.NextRetry.After(now) {
+			logger.Debug("%s: epoch %d failed, retrying at %v", job.Name(), epoch, *run.NextRetry)
+			return nil
+		}
+
+		done, err := c.runJobEpoch(job, db, run, clock)
+		if err != nil || !done {
+			return err
+		}
+		if run.Status == database.EpochJobRunSucceeded {
+			epochJobLastEpoch.WithLabelValues(job.Name()).Set(float64(epoch))
+		}
+		if err := job.setNextEpoch(epoch + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run the epoch and persist its outcome to the run. Returns true if the epoch
+// succeeded or failed for the last time, errors of the epoch are recorded and
+// only database errors are returned.
+func (c *epochCronjob) runJobEpoch(job epochJob, db epochJobDB, run *database.EpochJobRun, clock *utils.ShiftedTime) (bool, error) {
+	run.Started = clock.Now()
+	err := job.runEpoch(run.Epoch)
+	now := clock.Now()
+	run.Updated = now
+	run.NextRetry = nil
+	run.Error = ""
+
+	done := true
+	switch {
+	case err == nil:
+		run.Status = database.EpochJobRunSucceeded
+		run.Attempts = 0
+		run.TxHashes = ""
+	case errors.Is(err, errEpochSkipped):
+		logger.Debug("%s: skipping epoch %d: %v", job.Name(), run.Epoch, err)
+		run.Status = database.EpochJobRunSkipped
+		done = false
+	default:
+		run.Status = database.EpochJobRunFailed
+		run.Attempts++
+		run.Error = truncateError(err, epochJobRunErrorLength)
+		var notMined *TxNotMinedError
+		if errors.As(err, &notMined) {
+			run.TxHashes = joinTxHashes(notMined.TxHashes)
+		}
+		if c.retry.maxAttempts > 0 && run.Attempts >= c.retry.maxAttempts {
+			logger.Error("%s: epoch %d failed after %d attempts: %v", job.Name(), run.Epoch, run.Attempts, err)
+			break
+		}
+		backoff := utils.Min(run.Attempts-1, epochJobMaxRetryBackoff)
+		nextRetry := now.Add(c.retry.delay << backoff)
+		run.NextRetry = &nextRetry
+		logger.Error("%s: epoch %d failed (attempt %d), retrying at %v: %v", job.Name(), run.Epoch, run.Attempts, nextRetry, err)
+		done = false
+	}
+	return done, db.SaveEpochJobRun(run)
+}
+
+func joinTxHashes(txHashes []common.Hash) string {
+	hexes := make([]string, len(txHashes))
+	for i := range txHashes {
+		hexes[i] = txHashes[i].Hex()
+	}
+	return strings.Join(hexes, ",")
+}
+
+// Transactions of the last attempt of the run that were not mined in time
+func runTxHashes(run *database.EpochJobRun) []common.Hash {
+	if run.TxHashes == "" {
+		return nil
+	}
+	hexes := strings.Split(run.TxHashes, ",")
+	txHashes := make([]common.Hash, len(hexes))
+	for i := range hexes {
+		txHashes[i] = common.HexToHash(hexes[i])
+	}
+	return txHashes
+}
+
+// Max length of the error stored in the epoch job run
+const epochJobRunErrorLength = 256
+
+// Names of the epoch jobs
+var epochJobNames = []string{"voting", "mirror", "uptime_aggregator"}
+
+// Request a re-run of the epoch by the job, the running indexer runs it on the
+// next call of the job
+func requestEpochJobRerun(db epochJobDB, job string, epoch int64, now time.Time) error {
+	if !slices.Contains(epochJobNames, job) {
+		return errors.Errorf("unknown epoch job %q, expected one of %v", job, epochJobNames)
+	}
+	run, err := db.FetchEpochJobRun(job, epoch)
+	if err != nil {
+		return err
+	}
+	if run == nil {
+		run = &database.EpochJobRun{Job: job, Epoch: epoch}
+	}
+	run.Status = database.EpochJobRunPending
+	run.NextRetry = nil
+	run.Updated = now
+	return db.SaveEpochJobRun(run)
+}
@@ -0,0 +1,29 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type epochJobDBGorm struct {
+	g *gorm.DB
+}
+
+func (db *epochJobDBGorm) FetchEpochJobRun(job string, epoch int64) (*database.EpochJobRun, error) {
+	return database.FetchEpochJobRun(db.g, job, epoch)
+}
+
+func (db *epochJobDBGorm) FetchEpochJobRetries(job string, before int64, now time.Time) ([]database.EpochJobRun, error) {
+	return database.FetchEpochJobRetries(db.g, job, before, now)
+}
+
+func (db *epochJobDBGorm) SaveEpochJobRun(run *database.EpochJobRun) error {
+	return database.PersistEpochJobRun(db.g, run)
+}
+
+// Request a re-run of the epoch by the epoch job with the given name
+func RequestEpochJobRerun(g *gorm.DB, job string, epoch int64) error {
+	return requestEpochJobRerun(&epochJobDBGorm{g: g}, job, epoch, time.Now())
+}
@@ -0,0 +1,240 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/utils"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Epoch job runs kept in memory, embedded in the test databases of the epoch jobs
+type epochJobRunsTest struct {
+	runs map[string]map[int64]database.EpochJobRun
+}
+
+func (db *epochJobRunsTest) FetchEpochJobRun(job string, epoch int64) (*database.EpochJobRun, error) {
+	run, ok := db.runs[job][epoch]
+	if !ok {
+		return nil, nil
+	}
+	return &run, nil
+}
+
+func (db *epochJobRunsTest) FetchEpochJobRetries(job string, before int64, now time.Time) ([]database.EpochJobRun, error) {
+	var runs []database.EpochJobRun
+	for _, run := range db.runs[job] {
+		if run.Epoch >= before {
+			continue
+		}
+		pending := run.Status == database.EpochJobRunPending
+		due := run.Status == database.EpochJobRunFailed && run.NextRetry != nil && !run.NextRetry.After(now)
+		if pending || due {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Epoch < runs[j].Epoch })
+	return runs, nil
+}
+
+func (db *epochJobRunsTest) SaveEpochJobRun(run *database.EpochJobRun) error {
+	if db.runs == nil {
+		db.runs = make(map[string]map[int64]database.EpochJobRun)
+	}
+	if db.runs[run.Job] == nil {
+		db.runs[run.Job] = make(map[int64]database.EpochJobRun)
+	}
+	db.runs[run.Job][run.Epoch] = *run
+	return nil
+}
+
+// Epoch job failing or skipping the configured epochs
+type epochJobTest struct {
+	next  int64
+	last  int64
+	fail  map[int64]bool
+	skip  map[int64]bool
+	calls []int64
+
+	// Errors returned for the epochs instead of the test failure
+	errs map[int64]error
+}
+
+func (j *epochJobTest) Name() string {
+	return "voting"
+}
+
+func (j *epochJobTest) nextEpoch() (int64, error) {
+	return j.next, nil
+}
+
+func (j *epochJobTest) lastEpoch(now time.Time) (int64, error) {
+	return j.last, nil
+}
+
+func (j *epochJobTest) runEpoch(epoch int64) error {
+	j.calls = append(j.calls, epoch)
+	if j.skip[epoch] {
+		return errors.Wrap(errEpochSkipped, "test")
+	}
+	if err := j.errs[epoch]; err != nil {
+		return err
+	}
+	if j.fail[epoch] {
+		return errors.New("test failure")
+	}
+	return nil
+}
+
+func (j *epochJobTest) setNextEpoch(epoch int64) error {
+	j.next = epoch
+	return nil
+}
+
+func newEpochJobTest(maxAttempts int) (*epochCronjob, *epochJobTest, *epochJobRunsTest, *utils.ShiftedTime) {
+	c := &epochCronjob{
+		epochs: initEpochCronjob().epochs,
+		retry:  epochJobRetry{delay: time.Minute, maxAttempts: maxAttempts},
+	}
+	job := &epochJobTest{
+		next: 1,
+		last: 3,
+		fail: make(map[int64]bool),
+		skip: make(map[int64]bool),
+		errs: make(map[int64]error),
+	}
+	clock := utils.NewShiftedTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	return c, job, &epochJobRunsTest{}, clock
+}
+
+func TestEpochJobRetry(t *testing.T) {
+	c, job, db, clock := newEpochJobTest(0)
+	job.fail[2] = true
+
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{1, 2}, job.calls)
+	require.Equal(t, int64(2), job.next)
+	require.Equal(t, float64(1), testutil.ToFloat64(epochJobLastEpoch.WithLabelValues("voting")))
+	run := db.runs["voting"][2]
+	require.Equal(t, database.EpochJobRunFailed, run.Status)
+	require.Equal(t, 1, run.Attempts)
+	require.Equal(t, "test failure", run.Error)
+	require.Equal(t, run.Updated.Add(time.Minute), *run.NextRetry)
+
+	// Not retried before the retry time
+	job.calls = nil
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Empty(t, job.calls)
+
+	// Delay is doubled on the next failure
+	clock.AdvanceNow(time.Minute)
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{2}, job.calls)
+	run = db.runs["voting"][2]
+	require.Equal(t, 2, run.Attempts)
+	require.Equal(t, run.Updated.Add(2*time.Minute), *run.NextRetry)
+
+	job.fail[2] = false
+	job.calls = nil
+	clock.AdvanceNow(2 * time.Minute)
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{2, 3}, job.calls)
+	require.Equal(t, int64(4), job.next)
+	require.Equal(t, float64(3), testutil.ToFloat64(epochJobLastEpoch.WithLabelValues("voting")))
+	run = db.runs["voting"][2]
+	require.Equal(t, database.EpochJobRunSucceeded, run.Status)
+	require.Equal(t, 0, run.Attempts)
+	require.Nil(t, run.NextRetry)
+}
+
+func TestEpochJobSkipped(t *testing.T) {
+	c, job, db, clock := newEpochJobTest(0)
+	job.skip[2] = true
+
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{1, 2}, job.calls)
+	require.Equal(t, int64(2), job.next)
+	require.Equal(t, database.EpochJobRunSkipped, db.runs["voting"][2].Status)
+
+	// Skipped epochs are run again on the next call
+	job.skip[2] = false
+	job.calls = nil
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{2, 3}, job.calls)
+	require.Equal(t, int64(4), job.next)
+}
+
+func TestEpochJobMaxAttempts(t *testing.T) {
+	c, job, db, clock := newEpochJobTest(2)
+	job.fail[2] = true
+
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, int64(2), job.next)
+
+	clock.AdvanceNow(time.Minute)
+	job.calls = nil
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{2, 3}, job.calls)
+	require.Equal(t, int64(4), job.next)
+	run := db.runs["voting"][2]
+	require.Equal(t, database.EpochJobRunFailed, run.Status)
+	require.Equal(t, 2, run.Attempts)
+	require.Nil(t, run.NextRetry)
+
+	// Failed epoch is not retried anymore
+	clock.AdvanceNow(time.Hour)
+	job.calls = nil
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Empty(t, job.calls)
+}
+
+func TestEpochJobTxHashes(t *testing.T) {
+	c, job, db, clock := newEpochJobTest(0)
+	txHashes := []common.Hash{{1}, {2}}
+	job.errs[2] = errors.Wrap(&TxNotMinedError{Nonce: 1, TxHashes: txHashes}, "test")
+
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	run := db.runs["voting"][2]
+	require.Equal(t, database.EpochJobRunFailed, run.Status)
+	require.Equal(t, txHashes, runTxHashes(&run))
+
+	// Transactions are kept on other failures and cleared on success
+	job.errs[2] = nil
+	job.fail[2] = true
+	clock.AdvanceNow(time.Minute)
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	run = db.runs["voting"][2]
+	require.Equal(t, 2, run.Attempts)
+	require.Equal(t, txHashes, runTxHashes(&run))
+
+	job.fail[2] = false
+	clock.AdvanceNow(2 * time.Minute)
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	run = db.runs["voting"][2]
+	require.Equal(t, database.EpochJobRunSucceeded, run.Status)
+	require.Empty(t, run.TxHashes)
+}
+
+func TestEpochJobRerun(t *testing.T) {
+	c, job, db, clock := newEpochJobTest(0)
+
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{1, 2, 3}, job.calls)
+
+	require.NoError(t, requestEpochJobRerun(db, "voting", 2, clock.Now()))
+	require.Equal(t, database.EpochJobRunPending, db.runs["voting"][2].Status)
+
+	job.calls = nil
+	require.NoError(t, c.runEpochJob(job, db, clock))
+	require.Equal(t, []int64{2}, job.calls)
+	require.Equal(t, database.EpochJobRunSucceeded, db.runs["voting"][2].Status)
+	require.Equal(t, int64(4), job.next)
+
+	err := requestEpochJobRerun(db, "unknown", 2, clock.Now())
+	require.ErrorContains(t, err, "unknown epoch job")
+}
@@ -0,0 +1,84 @@
+package cronjob
+
+import (
+	indexerctx "flare-indexer/indexer/context"
+	"flare-indexer/logger"
+	"flare-indexer/utils"
+	"flare-indexer/utils/staking"
+	"time"
+)
+
+// Keeps the epoch schedule shared by the voting, mirroring and divergence
+// cronjobs in sync with the epoch configuration of the voting contract
+type epochScheduleCronjob struct {
+	enabled  bool
+	timeout  time.Duration
+	schedule *staking.EpochSchedule
+	db       staking.EpochScheduleDB
+	contract epochConfigContract
+	time     utils.ShiftedTime
+}
+
+type epochConfigContract interface {
+	EpochConfig() (time.Time, time.Duration, error)
+}
+
+func NewEpochScheduleCronjob(ctx indexerctx.IndexerContext, clients *Clients) (*epochScheduleCronjob, error) {
+	cfg := ctx.Config()
+	if !cfg.VotingCronjob.Enabled && !cfg.Mirror.Enabled && !cfg.Divergence.Enabled {
+		return &epochScheduleCronjob{}, nil
+	}
+
+	schedule, err := clients.epochSchedule(cfg, ctx.DB())
+	if err != nil {
+		return nil, err
+	}
+	contract, err := newEpochConfigContractCChain(cfg, clients)
+	if err != nil {
+		return nil, err
+	}
+
+	return &epochScheduleCronjob{
+		enabled:  true,
+		timeout:  cfg.EpochSchedule.RefreshInterval,
+		schedule: schedule,
+		db:       &epochScheduleDBGorm{g: ctx.DB()},
+		contract: contract,
+		time:     clients.time(),
+	}, nil
+}
+
+func (c *epochScheduleCronjob) Name() string {
+	return "epoch_schedule"
+}
+
+func (c *epochScheduleCronjob) Enabled() bool {
+	return c.enabled
+}
+
+func (c *epochScheduleCronjob) Timeout() time.Duration {
+	return c.timeout
+}
+
+func (c *epochScheduleCronjob) OnStart() error {
+	return nil
+}
+
+func (c *epochScheduleCronjob) Call() error {
+	return refreshEpochSchedule(c.schedule, c.db, c.contract, c.time.Now())
+}
+
+func refreshEpochSchedule(
+	schedule *staking.EpochSchedule, db staking.EpochScheduleDB, contract epochConfigContract, now time.Time,
+) error {
+	start, period, err := contract.EpochConfig()
+	if err != nil {
+		return err
+	}
+	segment, err := schedule.Refresh(db, start, period, now)
+	if segment != nil {
+		logger.Info("Epoch configuration from epoch %d: start %s, period %s",
+			segment.FirstEpoch, segment.Start.UTC().Format(time.RFC3339), segment.Period)
+	}
+	return err
+}
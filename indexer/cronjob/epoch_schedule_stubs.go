@@ -0,0 +1,39 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"flare-indexer/utils/contracts/voting"
+	"flare-indexer/utils/staking"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type epochScheduleDBGorm struct {
+	g *gorm.DB
+}
+
+func (db *epochScheduleDBGorm) FetchEpochConfigSegments() ([]database.EpochConfigSegment, error) {
+	return database.FetchEpochConfigSegments(db.g)
+}
+
+func (db *epochScheduleDBGorm) CreateEpochConfigSegment(segment *database.EpochConfigSegment) error {
+	return database.CreateEpochConfigSegment(db.g, segment)
+}
+
+type epochConfigContractCChain struct {
+	voting *voting.Voting
+}
+
+func newEpochConfigContractCChain(cfg *config.Config, clients *Clients) (*epochConfigContractCChain, error) {
+	votingContract, err := newVotingContract(cfg, clients)
+	if err != nil {
+		return nil, err
+	}
+	return &epochConfigContractCChain{voting: votingContract}, nil
+}
+
+func (c *epochConfigContractCChain) EpochConfig() (start time.Time, period time.Duration, err error) {
+	return staking.GetEpochConfig(c.voting)
+}
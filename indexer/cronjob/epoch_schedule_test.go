@@ -0,0 +1,127 @@
+package cronjob
+
+import (
+	globalConfig "flare-indexer/config"
+	"flare-indexer/database"
+	"flare-indexer/utils/staking"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	scheduleStart  = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedulePeriod = 180 * time.Second
+)
+
+type epochScheduleDBTest struct {
+	segments []database.EpochConfigSegment
+}
+
+func (db *epochScheduleDBTest) FetchEpochConfigSegments() ([]database.EpochConfigSegment, error) {
+	return append([]database.EpochConfigSegment(nil), db.segments...), nil
+}
+
+func (db *epochScheduleDBTest) CreateEpochConfigSegment(segment *database.EpochConfigSegment) error {
+	for _, s := range db.segments {
+		if s.FirstEpoch == segment.FirstEpoch {
+			return nil
+		}
+	}
+	db.segments = append(db.segments, *segment)
+	return nil
+}
+
+type epochConfigContractTest struct {
+	start  time.Time
+	period time.Duration
+}
+
+func (c *epochConfigContractTest) EpochConfig() (time.Time, time.Duration, error) {
+	return c.start, c.period, nil
+}
+
+func newEpochScheduleTestCronjob(db *epochScheduleDBTest, contract *epochConfigContractTest, now time.Time) *epochScheduleCronjob {
+	c := &epochScheduleCronjob{
+		enabled:  true,
+		schedule: staking.NewEpochSchedule(),
+		db:       db,
+		contract: contract,
+	}
+	c.time.SetNow(now)
+	return c
+}
+
+func TestEpochScheduleShorterPeriod(t *testing.T) {
+	db := &epochScheduleDBTest{}
+	contract := &epochConfigContractTest{start: scheduleStart, period: schedulePeriod}
+
+	// Configuration is changed in epoch 10
+	now := scheduleStart.Add(10*schedulePeriod + 30*time.Second)
+	cronjob := newEpochScheduleTestCronjob(db, contract, now)
+	require.NoError(t, cronjob.Call())
+	require.Len(t, db.segments, 1)
+
+	epochs := staking.NewScheduledEpochInfo(&globalConfig.EpochConfig{}, cronjob.schedule)
+	require.Equal(t, int64(10), epochs.GetEpochIndex(now))
+
+	contract.period = schedulePeriod / 2
+	require.NoError(t, cronjob.Call())
+	require.Len(t, db.segments, 2)
+	require.Equal(t, int64(22), db.segments[1].FirstEpoch)
+	require.Equal(t, schedulePeriod/2, epochs.CurrentPeriod())
+
+	// Epoch 10 is not changed, epochs 11-21 of the new numbering are empty
+	boundary := scheduleStart.Add(11 * schedulePeriod)
+	start, end := epochs.GetTimeRange(10)
+	require.Equal(t, scheduleStart.Add(10*schedulePeriod), start)
+	require.Equal(t, boundary, end)
+	start, end = epochs.GetTimeRange(15)
+	require.Equal(t, boundary, start)
+	require.Equal(t, boundary, end)
+	start, end = epochs.GetTimeRange(23)
+	require.Equal(t, boundary.Add(schedulePeriod/2), start)
+	require.Equal(t, boundary.Add(schedulePeriod), end)
+
+	require.Equal(t, int64(10), epochs.GetEpochIndex(boundary.Add(-time.Second)))
+	require.Equal(t, int64(22), epochs.GetEpochIndex(boundary))
+	require.Equal(t, int64(23), epochs.GetEpochIndex(boundary.Add(schedulePeriod/2)))
+
+	// Schedule is restored from the database after a restart
+	restarted := newEpochScheduleTestCronjob(db, contract, boundary.Add(time.Hour))
+	require.NoError(t, restarted.Call())
+	require.Len(t, db.segments, 2)
+	require.Equal(t, cronjob.schedule.Segments(), restarted.schedule.Segments())
+}
+
+func TestEpochScheduleLongerPeriod(t *testing.T) {
+	db := &epochScheduleDBTest{}
+	contract := &epochConfigContractTest{start: scheduleStart, period: schedulePeriod}
+
+	now := scheduleStart.Add(10*schedulePeriod + 30*time.Second)
+	cronjob := newEpochScheduleTestCronjob(db, contract, now)
+	require.NoError(t, cronjob.Call())
+	epochs := staking.NewScheduledEpochInfo(&globalConfig.EpochConfig{}, cronjob.schedule)
+
+	contract.period = 2 * schedulePeriod
+	require.NoError(t, cronjob.Call())
+	require.Len(t, db.segments, 2)
+	require.Equal(t, int64(11), db.segments[1].FirstEpoch)
+
+	// Epoch 10 lasts until the first epoch of the new configuration
+	next := scheduleStart.Add(11 * 2 * schedulePeriod)
+	start, end := epochs.GetTimeRange(10)
+	require.Equal(t, scheduleStart.Add(10*schedulePeriod), start)
+	require.Equal(t, next, end)
+	require.Equal(t, int64(10), epochs.GetEpochIndex(next.Add(-time.Second)))
+	require.Equal(t, int64(11), epochs.GetEpochIndex(next))
+
+	// Time ranges and indices of the epochs are consistent
+	for e := int64(0); e < 20; e++ {
+		start, end := epochs.GetTimeRange(e)
+		require.True(t, end.After(start))
+		require.Equal(t, e, epochs.GetEpochIndex(start))
+		require.Equal(t, e, epochs.GetEpochIndex(end.Add(-time.Nanosecond)))
+	}
+}
@@ -0,0 +1,181 @@
+package cronjob
+
+import (
+	"context"
+	"flare-indexer/utils/chain"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	cronjobRunSuccess = "success"
+	cronjobRunFailure = "failure"
+)
+
+var (
+	cronjobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cronjob_runs_total",
+		Help: "Number of calls of the cronjob by result (success or failure)",
+	}, []string{"cronjob", "result"})
+	cronjobRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cronjob_run_duration_seconds",
+		Help:    "Duration of the calls of the cronjob",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"cronjob"})
+
+	epochJobLastEpoch = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "epoch_job_last_epoch",
+		Help: "Last epoch processed successfully by the epoch job (voted, mirrored or aggregated)",
+	}, []string{"job"})
+
+	transactionsSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transactions_sent_total",
+		Help: "Number of transactions of the voting account that were mined",
+	})
+	transactionsReverted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transactions_reverted_total",
+		Help: "Number of transactions of the voting account that reverted when estimating gas or when mined",
+	})
+	transactionsGasUsed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transactions_gas_used_total",
+		Help: "Gas used by the mined transactions of the voting account",
+	})
+
+	votingAccountBalance = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "voting_account_balance",
+		Help: "C-chain balance of the voting account in FLR",
+	})
+	votingAccountBalanceLow = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "voting_account_balance_low",
+		Help: "1 if the balance of the voting account is below the configured low balance, 0 otherwise",
+	})
+)
+
+// Return the call of the cronjob recording its duration and result
+func instrumentedCall(name string, call func() error) func() error {
+	return func() error {
+		start := time.Now()
+		err := call()
+		cronjobRunDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			cronjobRuns.WithLabelValues(name, cronjobRunFailure).Inc()
+		} else {
+			cronjobRuns.WithLabelValues(name, cronjobRunSuccess).Inc()
+		}
+		return err
+	}
+}
+
+// Set the balance metrics of the voting account from its balance in wei
+func updateBalanceMetrics(balance *big.Int, lowBalance float64) {
+	flr, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), big.NewFloat(params.Ether)).Float64()
+	votingAccountBalance.Set(flr)
+	if lowBalance > 0 && flr < lowBalance {
+		votingAccountBalanceLow.Set(1)
+	} else {
+		votingAccountBalanceLow.Set(0)
+	}
+}
+
+// Client of the C-chain node recording the duration and the errors of the
+// calls used by the cronjobs
+type instrumentedBackend struct {
+	*ethclient.Client
+}
+
+func (b *instrumentedBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	start := time.Now()
+	code, err := b.Client.CodeAt(ctx, contract, blockNumber)
+	chain.ObserveRPC("eth_getCode", start, err)
+	return code, err
+}
+
+func (b *instrumentedBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	start := time.Now()
+	result, err := b.Client.CallContract(ctx, call, blockNumber)
+	chain.ObserveRPC("eth_call", start, err)
+	return result, err
+}
+
+func (b *instrumentedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	start := time.Now()
+	header, err := b.Client.HeaderByNumber(ctx, number)
+	chain.ObserveRPC("eth_getBlockByNumber", start, err)
+	return header, err
+}
+
+func (b *instrumentedBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	start := time.Now()
+	code, err := b.Client.PendingCodeAt(ctx, account)
+	chain.ObserveRPC("eth_getCode", start, err)
+	return code, err
+}
+
+func (b *instrumentedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	start := time.Now()
+	nonce, err := b.Client.PendingNonceAt(ctx, account)
+	chain.ObserveRPC("eth_getTransactionCount", start, err)
+	return nonce, err
+}
+
+func (b *instrumentedBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	start := time.Now()
+	price, err := b.Client.SuggestGasPrice(ctx)
+	chain.ObserveRPC("eth_gasPrice", start, err)
+	return price, err
+}
+
+func (b *instrumentedBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	start := time.Now()
+	tip, err := b.Client.SuggestGasTipCap(ctx)
+	chain.ObserveRPC("eth_maxPriorityFeePerGas", start, err)
+	return tip, err
+}
+
+func (b *instrumentedBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	start := time.Now()
+	gas, err := b.Client.EstimateGas(ctx, call)
+	chain.ObserveRPC("eth_estimateGas", start, err)
+	return gas, err
+}
+
+func (b *instrumentedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	start := time.Now()
+	err := b.Client.SendTransaction(ctx, tx)
+	chain.ObserveRPC("eth_sendRawTransaction", start, err)
+	return err
+}
+
+func (b *instrumentedBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	start := time.Now()
+	logs, err := b.Client.FilterLogs(ctx, query)
+	chain.ObserveRPC("eth_getLogs", start, err)
+	return logs, err
+}
+
+func (b *instrumentedBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	start := time.Now()
+	receipt, err := b.Client.TransactionReceipt(ctx, txHash)
+	if err == ethereum.NotFound {
+		// Receipts of pending transactions are polled until they are mined
+		chain.ObserveRPC("eth_getTransactionReceipt", start, nil)
+	} else {
+		chain.ObserveRPC("eth_getTransactionReceipt", start, err)
+	}
+	return receipt, err
+}
+
+func (b *instrumentedBackend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	start := time.Now()
+	balance, err := b.Client.BalanceAt(ctx, account, blockNumber)
+	chain.ObserveRPC("eth_getBalance", start, err)
+	return balance, err
+}
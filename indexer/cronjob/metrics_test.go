@@ -0,0 +1,23 @@
+package cronjob
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedCall(t *testing.T) {
+	var err error
+	call := instrumentedCall("test", func() error { return err })
+
+	require.NoError(t, call())
+	err = errors.New("test failure")
+	require.Error(t, call())
+	require.Error(t, call())
+
+	require.Equal(t, float64(1), testutil.ToFloat64(cronjobRuns.WithLabelValues("test", cronjobRunSuccess)))
+	require.Equal(t, float64(2), testutil.ToFloat64(cronjobRuns.WithLabelValues("test", cronjobRunFailure)))
+	require.Equal(t, 1, testutil.CollectAndCount(cronjobRunDuration))
+}
@@ -11,6 +11,10 @@ import (
 func init() {
 	migrations.Container.Add("2023-08-25-00-00", "Create initial state for voting cronjob", createVotingCronjobState)
 	migrations.Container.Add("2023-08-30-00-00", "Create initial state for mirror cronjob", createMirrorCronjobState)
+	migrations.Container.Add("2023-10-02-00-00", "Create initial state for voting events indexer", createVotingEventsState)
+	migrations.Container.Add("2023-10-05-00-00", "Create initial state for divergence cronjob", createDivergenceCronjobState)
+	migrations.Container.Add("2023-10-10-00-00", "Create initial state for uptime compaction cronjob", createUptimeCompactionState)
+	migrations.Container.Add("2023-10-20-00-00", "Create initial state for uptime voting cronjob", createUptimeVotingState)
 }
 
 func createVotingCronjobState(db *gorm.DB) error {
@@ -30,3 +34,48 @@ func createMirrorCronjobState(db *gorm.DB) error {
 		Updated:        time.Now(),
 	})
 }
+
+func createVotingEventsState(db *gorm.DB) error {
+	return database.CreateState(db, &database.State{
+		Name:           votingEventsStateName,
+		NextDBIndex:    0,
+		LastChainIndex: 0,
+		Updated:        time.Now(),
+	})
+}
+
+func createDivergenceCronjobState(db *gorm.DB) error {
+	return database.CreateState(db, &database.State{
+		Name:           divergenceStateName,
+		NextDBIndex:    0,
+		LastChainIndex: 0,
+		Updated:        time.Now(),
+	})
+}
+
+func createUptimeCompactionState(db *gorm.DB) error {
+	return database.CreateState(db, &database.State{
+		Name:           uptimeCompactionStateName,
+		NextDBIndex:    0,
+		LastChainIndex: 0,
+		Updated:        time.Now(),
+	})
+}
+
+// Uptime voting continues after the last persisted aggregation
+func createUptimeVotingState(db *gorm.DB) error {
+	lastAggregation, err := database.FetchLastUptimeAggregation(db)
+	if err != nil {
+		return err
+	}
+	nextEpoch := uint64(0)
+	if lastAggregation != nil {
+		nextEpoch = uint64(lastAggregation.Epoch) + 1
+	}
+	return database.CreateState(db, &database.State{
+		Name:           uptimeVotingStateName,
+		NextDBIndex:    nextEpoch,
+		LastChainIndex: 0,
+		Updated:        time.Now(),
+	})
+}
@@ -8,30 +8,54 @@ import (
 	"flare-indexer/utils"
 	"flare-indexer/utils/chain"
 	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/reverts"
 	"flare-indexer/utils/merkle"
 	"flare-indexer/utils/staking"
 	"math/big"
-	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/crypto"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 )
 
 const mirrorStateName = "mirror_cronjob"
 
+// Max exponent of the retry delay backoff
+const mirrorMaxRetryBackoff = 5
+
 type mirrorCronJob struct {
 	epochCronjob
 	db        mirrorDB
 	contracts mirrorContracts
 	time      utils.ShiftedTime
+
+	// Delay before retrying a failed transaction, doubled on each failed attempt
+	retryDelay time.Duration
+
+	// Failed transactions are not retried after maxAttempts, 0 for no limit
+	maxAttempts int
+
+	// Number of txs mirrored in a single transaction, txs are mirrored
+	// separately if not greater than 1
+	batchSize int
+
+	// Mirroring transactions are recorded instead of sent if set, mirroring
+	// statuses are not updated
+	dryRun *dryRun
 }
 
 type mirrorDB interface {
+	epochJobDB
 	FetchState(name string) (database.State, error)
 	UpdateJobState(epoch int64, force bool) error
 	GetPChainTxsForEpoch(start, end time.Time) ([]database.PChainTxData, error)
 	GetPChainTx(txID string, address string) (*database.PChainTxData, error)
+	GetMirrorStatuses(txIDs []string) ([]database.MirrorStatus, error)
+	SaveMirrorStatus(status *database.MirrorStatus) error
+	GetMirrorRetryEpochs(now time.Time) ([]int64, error)
 }
 
 type mirrorContracts interface {
@@ -39,35 +63,47 @@ type mirrorContracts interface {
 	MirrorStake(
 		stakeData *mirroring.IPChainStakeMirrorVerifierPChainStake,
 		merkleProof [][32]byte,
-	) error
+	) (common.Hash, error)
+	MirrorStakeBatch(stakes []mirrorStakeInput, publicKeys []crypto.PublicKey) (common.Hash, error)
 	IsAddressRegistered(address string) (bool, error)
 	RegisterPublicKey(publicKey crypto.PublicKey) error
 	EpochConfig() (time.Time, time.Duration, error)
 }
 
-func NewMirrorCronjob(ctx indexerctx.IndexerContext) (Cronjob, error) {
+func NewMirrorCronjob(ctx indexerctx.IndexerContext, clients *Clients) (Cronjob, error) {
 	cfg := ctx.Config()
 
 	if !cfg.Mirror.Enabled {
 		return &mirrorCronJob{}, nil
 	}
 
-	contracts, err := initMirrorJobContracts(cfg)
+	contracts, err := initMirrorJobContracts(cfg, clients)
 	if err != nil {
 		return nil, err
 	}
 
-	start, period, err := contracts.EpochConfig()
+	schedule, err := clients.epochSchedule(cfg, ctx.DB())
 	if err != nil {
 		return nil, err
 	}
-
-	epochs := staking.NewEpochInfo(&cfg.Mirror.EpochConfig, start, period)
+	epochs := staking.NewScheduledEpochInfo(&cfg.Mirror.EpochConfig, schedule)
 
 	mc := &mirrorCronJob{
 		epochCronjob: newEpochCronjob(&cfg.Mirror.CronjobConfig, epochs),
 		db:           NewMirrorDBGorm(ctx.DB()),
 		contracts:    contracts,
+		time:         clients.time(),
+		retryDelay:   cfg.Mirror.RetryDelay,
+		maxAttempts:  cfg.Mirror.MaxAttempts,
+		batchSize:    cfg.Mirror.TxBatchSize,
+	}
+	mc.retry = newEpochJobRetry(&cfg.EpochJobs)
+
+	if cfg.Mirror.DryRun {
+		mc.dryRun, err = newDryRun(cfg, clients, ctx.DB(), mirrorStateName, cfg.ContractAddresses.Mirroring)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	err = mc.reset(ctx.Flags().ResetMirrorCronjob)
@@ -80,7 +116,7 @@ func (c *mirrorCronJob) Name() string {
 }
 
 func (c *mirrorCronJob) Timeout() time.Duration {
-	return c.epochs.Period
+	return c.epochs.CurrentPeriod()
 }
 
 func (c *mirrorCronJob) OnStart() error {
@@ -88,74 +124,52 @@ func (c *mirrorCronJob) OnStart() error {
 }
 
 func (c *mirrorCronJob) Call() error {
-	epochRange, err := c.getEpochRange()
-	if err != nil {
-		if errors.Is(err, errNoEpochsToMirror) {
-			logger.Debug("no epochs to mirror")
-			return nil
-		}
-
+	if err := c.retryFailedTxs(); err != nil {
 		return err
 	}
+	return c.runEpochJob(c, c.db, &c.time)
+}
 
-	logger.Debug("mirroring epochs %d-%d", epochRange.start, epochRange.end)
+// Mirror pending and failed transactions of already processed epochs that are
+// due for retry
+func (c *mirrorCronJob) retryFailedTxs() error {
+	// Statuses remain pending in dry-run mode, they are mirrored once the dry
+	// run is disabled
+	if c.dryRun != nil {
+		return nil
+	}
 
-	idxState, err := c.db.FetchState(pchain.StateName)
+	epochs, err := c.db.GetMirrorRetryEpochs(c.time.Now())
 	if err != nil {
 		return err
 	}
 
-	for epoch := epochRange.start; epoch <= epochRange.end; epoch++ {
-		// Skip updating if indexer is behind
-		if c.indexerBehind(&idxState, epoch) {
-			logger.Debug("indexer is behind, skipping mirror")
-			return nil
-		}
-
-		logger.Debug("mirroring epoch %d", epoch)
+	for _, epoch := range epochs {
+		logger.Debug("retrying mirroring of epoch %d", epoch)
 		if err := c.mirrorEpoch(epoch); err != nil {
 			return err
 		}
 	}
-
-	logger.Debug("successfully mirrored epochs %d-%d", epochRange.start, epochRange.end)
-
-	if err := c.db.UpdateJobState(epochRange.end+1, false); err != nil {
-		return err
-	}
-
 	return nil
 }
 
-var errNoEpochsToMirror = errors.New("no epochs to mirror")
-
-func (c *mirrorCronJob) getEpochRange() (*epochRange, error) {
-	startEpoch, err := c.getStartEpoch()
+func (c *mirrorCronJob) nextEpoch() (int64, error) {
+	jobState, err := c.db.FetchState(mirrorStateName)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	logger.Debug("start epoch: %d", startEpoch)
-
-	endEpoch, err := c.getEndEpoch(startEpoch)
-	if err != nil {
-		return nil, err
-	}
-	logger.Debug("Mirroring needed for epochs [%d, %d]", startEpoch, endEpoch)
-	return c.getTrimmedEpochRange(startEpoch, endEpoch), nil
+	return int64(jobState.NextDBIndex), nil
 }
 
-func (c *mirrorCronJob) getStartEpoch() (int64, error) {
-	jobState, err := c.db.FetchState(mirrorStateName)
+// Last epoch whose merkle root is confirmed on the voting contract
+func (c *mirrorCronJob) lastEpoch(now time.Time) (int64, error) {
+	startEpoch, err := c.nextEpoch()
 	if err != nil {
 		return 0, err
 	}
 
-	return int64(jobState.NextDBIndex), nil
-}
-
-func (c *mirrorCronJob) getEndEpoch(startEpoch int64) (int64, error) {
-	currEpoch := c.epochs.GetEpochIndex(c.time.Now())
+	currEpoch := c.epochs.GetEpochIndex(now)
 	logger.Debug("current epoch: %d", currEpoch)
 
 	for epoch := currEpoch; epoch > startEpoch; epoch-- {
@@ -169,7 +183,26 @@ func (c *mirrorCronJob) getEndEpoch(startEpoch int64) (int64, error) {
 		}
 	}
 
-	return 0, errNoEpochsToMirror
+	logger.Debug("no epochs to mirror")
+	return startEpoch - 1, nil
+}
+
+func (c *mirrorCronJob) setNextEpoch(epoch int64) error {
+	return c.db.UpdateJobState(epoch, false)
+}
+
+func (c *mirrorCronJob) runEpoch(epoch int64) error {
+	// Skip updating if indexer is behind
+	idxState, err := c.db.FetchState(pchain.StateName)
+	if err != nil {
+		return err
+	}
+	if c.indexerBehind(&idxState, epoch) {
+		return errors.Wrap(errEpochSkipped, "indexer is behind")
+	}
+
+	logger.Debug("mirroring epoch %d", epoch)
+	return c.mirrorEpoch(epoch)
 }
 
 func (c *mirrorCronJob) isEpochConfirmed(epoch int64) (bool, error) {
@@ -182,25 +215,41 @@ func (c *mirrorCronJob) isEpochConfirmed(epoch int64) (bool, error) {
 }
 
 func (c *mirrorCronJob) mirrorEpoch(epoch int64) error {
-	txs, err := c.getUnmirroredTxs(epoch)
+	txs, err := c.getEpochTxs(epoch)
 	if err != nil {
 		return err
 	}
 
 	if len(txs) == 0 {
+		logger.Debug("no txs found")
+		return nil
+	}
+
+	statuses, err := c.getMirrorStatuses(txs, epoch)
+	if err != nil {
+		return err
+	}
+
+	numTxs := 0
+	for _, status := range statuses {
+		if c.shouldMirror(status) {
+			numTxs++
+		}
+	}
+	if numTxs == 0 {
 		logger.Debug("no unmirrored txs found")
 		return nil
 	}
 
-	logger.Info("mirroring %d txs", len(txs))
-	if err := c.mirrorTxs(txs, epoch); err != nil {
+	logger.Info("mirroring %d txs", numTxs)
+	if err := c.mirrorTxs(txs, statuses, epoch); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (c *mirrorCronJob) getUnmirroredTxs(epoch int64) ([]database.PChainTxData, error) {
+func (c *mirrorCronJob) getEpochTxs(epoch int64) ([]database.PChainTxData, error) {
 	startTimestamp, endTimestamp := c.epochs.GetTimeRange(epoch)
 
 	txs, err := c.db.GetPChainTxsForEpoch(startTimestamp, endTimestamp)
@@ -211,7 +260,56 @@ func (c *mirrorCronJob) getUnmirroredTxs(epoch int64) ([]database.PChainTxData,
 	return staking.DedupeTxs(txs), nil
 }
 
-func (c *mirrorCronJob) mirrorTxs(txs []database.PChainTxData, epochID int64) error {
+// Return the mirroring status of each tx (in the same order), txs without a
+// status are persisted as pending
+func (c *mirrorCronJob) getMirrorStatuses(txs []database.PChainTxData, epoch int64) ([]*database.MirrorStatus, error) {
+	txIDs := make([]string, len(txs))
+	for i := range txs {
+		txIDs[i] = *txs[i].TxID
+	}
+
+	existing, err := c.db.GetMirrorStatuses(txIDs)
+	if err != nil {
+		return nil, err
+	}
+	statusMap := make(map[string]*database.MirrorStatus, len(existing))
+	for i := range existing {
+		statusMap[existing[i].TxID] = &existing[i]
+	}
+
+	now := c.time.Now()
+	statuses := make([]*database.MirrorStatus, len(txs))
+	for i, txID := range txIDs {
+		status, ok := statusMap[txID]
+		if !ok {
+			status = &database.MirrorStatus{
+				TxID:      txID,
+				Epoch:     epoch,
+				Status:    database.MirrorTxStatusPending,
+				NextRetry: &now,
+				Updated:   now,
+			}
+			if err := c.db.SaveMirrorStatus(status); err != nil {
+				return nil, err
+			}
+		}
+		statuses[i] = status
+	}
+	return statuses, nil
+}
+
+func (c *mirrorCronJob) shouldMirror(status *database.MirrorStatus) bool {
+	switch status.Status {
+	case database.MirrorTxStatusPending:
+		return true
+	case database.MirrorTxStatusFailed:
+		return status.NextRetry != nil && !status.NextRetry.After(c.time.Now())
+	default:
+		return false
+	}
+}
+
+func (c *mirrorCronJob) mirrorTxs(txs []database.PChainTxData, statuses []*database.MirrorStatus, epochID int64) error {
 	merkleTree, err := staking.BuildTree(txs)
 	if err != nil {
 		return err
@@ -221,14 +319,32 @@ func (c *mirrorCronJob) mirrorTxs(txs []database.PChainTxData, epochID int64) er
 		return err
 	}
 
+	var inputs []*mirrorTxInput
 	for i := range txs {
-		in := mirrorTxInput{
+		if !c.shouldMirror(statuses[i]) {
+			continue
+		}
+
+		inputs = append(inputs, &mirrorTxInput{
 			epochID:    big.NewInt(epochID),
 			merkleTree: merkleTree,
 			tx:         &txs[i],
+			status:     statuses[i],
+		})
+	}
+
+	if c.batchSize <= 1 || c.dryRun != nil {
+		for _, in := range inputs {
+			if err := c.mirrorTx(in); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
 
-		if err := c.mirrorTx(&in); err != nil {
+	for start := 0; start < len(inputs); start += c.batchSize {
+		end := utils.Min(start+c.batchSize, len(inputs))
+		if err := c.mirrorBatch(inputs[start:end]); err != nil {
 			return err
 		}
 	}
@@ -254,23 +370,66 @@ func (c *mirrorCronJob) checkMerkleRoot(tree merkle.Tree, epoch int64) error {
 	return nil
 }
 
+// Reverts of the mirroring contract after which the tx is not mirrored
+var mirrorSkipReverts = []struct {
+	revert error
+	reason database.MirrorSkipReason
+}{
+	{reverts.ErrTransactionAlreadyMirrored, database.MirrorSkipAlreadyMirrored},
+	{reverts.ErrStakingEnded, database.MirrorSkipStakingEnded},
+	{reverts.ErrUnknownStakingAddress, database.MirrorSkipUnknownStakingAddress},
+	{reverts.ErrStakingDataInvalid, database.MirrorSkipStakingDataInvalid},
+	{reverts.ErrMaxNodeIDsExceeded, database.MirrorSkipMaxNodeIDsExceeded},
+}
+
+func mirrorSkipReason(err error) (database.MirrorSkipReason, bool) {
+	for _, r := range mirrorSkipReverts {
+		if errors.Is(err, r.revert) {
+			return r.reason, true
+		}
+	}
+	return "", false
+}
+
 type mirrorTxInput struct {
 	epochID    *big.Int
 	merkleTree merkle.Tree
 	tx         *database.PChainTxData
+	status     *database.MirrorStatus
 }
 
-func (c *mirrorCronJob) mirrorTx(in *mirrorTxInput) error {
+// Arguments of the mirrorStake call of the mirroring contract
+type mirrorStakeInput struct {
+	stakeData   *mirroring.IPChainStakeMirrorVerifierPChainStake
+	merkleProof [][32]byte
+}
+
+func newMirrorStakeInput(in *mirrorTxInput) (*mirrorStakeInput, error) {
 	stakeData, err := staking.ToStakeData(in.tx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	merkleProof, err := staking.GetMerkleProof(in.merkleTree, in.tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mirrorStakeInput{stakeData: stakeData, merkleProof: merkleProof}, nil
+}
+
+// Mirror the tx and persist the outcome to its mirroring status. Failures of
+// the mirroring transaction are scheduled for retry and do not return an error.
+func (c *mirrorCronJob) mirrorTx(in *mirrorTxInput) error {
+	stake, err := newMirrorStakeInput(in)
 	if err != nil {
 		return err
 	}
 
+	if c.dryRun != nil {
+		return c.dryRun.mirrorStake(in.epochID.Int64(), stake)
+	}
+
 	// Register addresses if needed, do not fail if not successful
 	if err := c.registerAddress(*in.tx.TxID, in.tx.InputAddress); err != nil {
 		logger.Error("error registering address: %s", err.Error())
@@ -279,66 +438,153 @@ func (c *mirrorCronJob) mirrorTx(in *mirrorTxInput) error {
 	}
 
 	logger.Debug("mirroring tx %s", *in.tx.TxID)
-	err = c.contracts.MirrorStake(stakeData, merkleProof)
-	if err != nil {
-		if strings.Contains(err.Error(), "transaction already mirrored") {
-			logger.Info("tx %s already mirrored", *in.tx.TxID)
-			return nil
-		}
+	txHash, err := c.contracts.MirrorStake(stake.stakeData, stake.merkleProof)
+	c.updateMirrorStatus(in.status, txHash, err)
+	return c.db.SaveMirrorStatus(in.status)
+}
 
-		if strings.Contains(err.Error(), "staking already ended") {
-			logger.Info("staking already ended for tx %s", *in.tx.TxID)
-			return nil
+// Mirror the txs and register their unregistered addresses in a single
+// transaction. If the transaction reverts, the txs are mirrored one by one, so
+// that the failing tx does not block the others and gets its own status.
+func (c *mirrorCronJob) mirrorBatch(batch []*mirrorTxInput) error {
+	stakes := make([]mirrorStakeInput, len(batch))
+	var publicKeys []crypto.PublicKey
+	batchKeys := make(map[ids.ShortID]bool)
+	for i, in := range batch {
+		stake, err := newMirrorStakeInput(in)
+		if err != nil {
+			return err
 		}
+		stakes[i] = *stake
 
-		if strings.Contains(err.Error(), "unknown staking address") {
-			logger.Info("unknown staking address for tx %s", *in.tx.TxID)
-			return nil
+		// Tx is mirrored without the registration (and reverts if its address
+		// is not registered) if the public keys are not available
+		keys, err := c.unregisteredPublicKeys(*in.tx.TxID, in.tx.InputAddress)
+		if err != nil {
+			logger.Error("error getting public keys of address %s: %s", in.tx.InputAddress, err.Error())
 		}
+		for _, k := range keys {
+			if !batchKeys[k.Address()] {
+				batchKeys[k.Address()] = true
+				publicKeys = append(publicKeys, k)
+			}
+		}
+	}
+
+	logger.Debug("mirroring batch of %d txs, registering %d public keys", len(batch), len(publicKeys))
+	txHash, err := c.contracts.MirrorStakeBatch(stakes, publicKeys)
 
-		if strings.Contains(err.Error(), "staking data invalid") {
-			logger.Info("staking data invalid for tx %s", *in.tx.TxID)
-			return nil
+	var revertErr *RevertError
+	if errors.As(err, &revertErr) {
+		logger.Warn("mirroring batch of %d txs reverted, mirroring txs separately: %v", len(batch), err)
+		for _, in := range batch {
+			if err := c.mirrorTx(in); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
 
-		if strings.Contains(err.Error(), "Max node ids exceeded") {
-			logger.Info("Max node ids exceeded for tx %s", *in.tx.TxID)
-			return nil
+	for _, in := range batch {
+		c.updateMirrorStatus(in.status, txHash, err)
+		if err := c.db.SaveMirrorStatus(in.status); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		return errors.Wrap(err, "mirroringContract.MirrorStake")
+func (c *mirrorCronJob) updateMirrorStatus(status *database.MirrorStatus, txHash common.Hash, err error) {
+	now := c.time.Now()
+	status.Attempts++
+	status.Updated = now
+	status.NextRetry = nil
+	status.Error = ""
+
+	var revertErr *RevertError
+	if errors.As(err, &revertErr) && revertErr.TxHash != nil {
+		txHash = *revertErr.TxHash
+	}
+	if txHash != (common.Hash{}) {
+		status.CChainTxHash = txHash.Hex()
 	}
 
-	return nil
+	if err == nil {
+		status.Status = database.MirrorTxStatusMirrored
+		return
+	}
+
+	status.Error = truncateError(err, mirrorStatusErrorLength)
+	if reason, ok := mirrorSkipReason(err); ok {
+		logger.Info("skipping tx %s: %v", status.TxID, err)
+		status.Status = database.MirrorTxStatusSkipped
+		status.SkipReason = reason
+		return
+	}
+
+	status.Status = database.MirrorTxStatusFailed
+	if c.maxAttempts > 0 && status.Attempts >= c.maxAttempts {
+		logger.Error("mirroring tx %s failed after %d attempts: %v", status.TxID, status.Attempts, err)
+		return
+	}
+	backoff := utils.Min(status.Attempts-1, mirrorMaxRetryBackoff)
+	nextRetry := now.Add(c.retryDelay << backoff)
+	status.NextRetry = &nextRetry
+	logger.Error("mirroring tx %s failed (attempt %d), retrying at %v: %v", status.TxID, status.Attempts, nextRetry, err)
+}
+
+// Max length of the error stored in the mirror status
+const mirrorStatusErrorLength = 256
+
+// Truncate the error message to at most length bytes, without splitting a
+// multi-byte character
+func truncateError(err error, length int) string {
+	msg := err.Error()
+	if len(msg) <= length {
+		return msg
+	}
+	for length > 0 && !utf8.RuneStart(msg[length]) {
+		length--
+	}
+	return msg[:length]
 }
 
 func (c *mirrorCronJob) registerAddress(txID string, address string) error {
+	publicKeys, err := c.unregisteredPublicKeys(txID, address)
+	if err != nil {
+		return err
+	}
+	for _, k := range publicKeys {
+		err := c.contracts.RegisterPublicKey(k)
+		if err != nil {
+			return errors.Wrap(err, "mirroringContract.RegisterPublicKey")
+		}
+	}
+	return nil
+}
+
+// Return the public keys of the input address of the tx, or nil if the
+// address is already registered on the address binder contract
+func (c *mirrorCronJob) unregisteredPublicKeys(txID string, address string) ([]crypto.PublicKey, error) {
 	registered, err := c.contracts.IsAddressRegistered(address)
 	if err != nil || registered {
-		return err
+		return nil, err
 	}
 	tx, err := c.db.GetPChainTx(txID, address)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if tx == nil {
-		return errors.New("tx not found")
+		return nil, errors.New("tx not found")
 	}
 	publicKeys, err := chain.PublicKeysFromPChainBlock(tx.Bytes)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if tx.InputIndex >= uint32(len(publicKeys)) {
-		return errors.New("input index out of range")
+		return nil, errors.New("input index out of range")
 	}
-	publicKey := publicKeys[tx.InputIndex]
-	for _, k := range publicKey {
-		err := c.contracts.RegisterPublicKey(k)
-		if err != nil {
-			return errors.Wrap(err, "mirroringContract.RegisterPublicKey")
-		}
-	}
-	return nil
+	return publicKeys[tx.InputIndex], nil
 }
 
 func (c *mirrorCronJob) reset(firstEpoch int64) error {
@@ -9,6 +9,7 @@ import (
 	"flare-indexer/utils/chain"
 	"flare-indexer/utils/contracts/addresses"
 	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/multicall"
 	"flare-indexer/utils/contracts/voting"
 	"flare-indexer/utils/staking"
 	"math/big"
@@ -17,7 +18,7 @@ import (
 	"github.com/ava-labs/avalanchego/utils/crypto"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
@@ -52,6 +53,18 @@ func (m mirrorDBGorm) UpdateJobState(epoch int64, force bool) error {
 	})
 }
 
+func (m mirrorDBGorm) FetchEpochJobRun(job string, epoch int64) (*database.EpochJobRun, error) {
+	return database.FetchEpochJobRun(m.db, job, epoch)
+}
+
+func (m mirrorDBGorm) FetchEpochJobRetries(job string, before int64, now time.Time) ([]database.EpochJobRun, error) {
+	return database.FetchEpochJobRetries(m.db, job, before, now)
+}
+
+func (m mirrorDBGorm) SaveEpochJobRun(run *database.EpochJobRun) error {
+	return database.PersistEpochJobRun(m.db, run)
+}
+
 func (m mirrorDBGorm) GetPChainTxsForEpoch(start, end time.Time) ([]database.PChainTxData, error) {
 	return database.GetPChainTxsForEpoch(&database.GetPChainTxsForEpochInput{
 		DB:             m.db,
@@ -64,14 +77,32 @@ func (m mirrorDBGorm) GetPChainTx(txID string, address string) (*database.PChain
 	return database.FetchPChainTxData(m.db, txID, address)
 }
 
+func (m mirrorDBGorm) GetMirrorStatuses(txIDs []string) ([]database.MirrorStatus, error) {
+	return database.FetchMirrorStatuses(m.db, txIDs)
+}
+
+func (m mirrorDBGorm) SaveMirrorStatus(status *database.MirrorStatus) error {
+	return database.PersistMirrorStatus(m.db, status)
+}
+
+func (m mirrorDBGorm) GetMirrorRetryEpochs(now time.Time) ([]int64, error) {
+	return database.FetchMirrorRetryEpochs(m.db, now)
+}
+
 type mirrorContractsCChain struct {
 	mirroring     *mirroring.Mirroring
 	addressBinder *addresses.Binder
-	txOpts        *bind.TransactOpts
+	sender        *txSender
 	voting        *voting.Voting
+
+	// Multicall contract used to mirror batches of txs, nil if not configured
+	multicall *multicall.Multicall
+
+	mirroringAddress     common.Address
+	addressBinderAddress common.Address
 }
 
-func initMirrorJobContracts(cfg *config.Config) (mirrorContracts, error) {
+func initMirrorJobContracts(cfg *config.Config, clients *Clients) (mirrorContracts, error) {
 	if cfg.ContractAddresses.Mirroring == (common.Address{}) {
 		return nil, errors.New("mirroring contract address not set")
 	}
@@ -80,53 +111,68 @@ func initMirrorJobContracts(cfg *config.Config) (mirrorContracts, error) {
 		return nil, errors.New("voting contract address not set")
 	}
 
-	eth, err := ethclient.Dial(cfg.Chain.EthRPCURL)
+	sender, err := clients.txSender(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	mirroringContract, err := mirroring.NewMirroring(cfg.ContractAddresses.Mirroring, eth)
-	if err != nil {
-		return nil, err
+	if cfg.Mirror.TxBatchSize > 1 && cfg.ContractAddresses.Multicall == (common.Address{}) {
+		return nil, errors.New("multicall contract address not set, required for tx_batch_size > 1")
 	}
 
-	votingContract, err := voting.NewVoting(cfg.ContractAddresses.Voting, eth)
+	return newMirrorContractsCChain(
+		sender, cfg.ContractAddresses.Mirroring, cfg.ContractAddresses.Voting, cfg.ContractAddresses.Multicall,
+	)
+}
+
+// Create mirror contracts stub sending transactions with the given sender (e.g.
+// on the simulated backend in tests). Multicall address may be zero if batches
+// are not mirrored.
+func newMirrorContractsCChain(
+	sender *txSender,
+	mirroringAddress common.Address,
+	votingAddress common.Address,
+	multicallAddress common.Address,
+) (*mirrorContractsCChain, error) {
+	backend := sender.backend
+
+	mirroringContract, err := mirroring.NewMirroring(mirroringAddress, backend)
 	if err != nil {
 		return nil, err
 	}
 
-	addressBinderContract, err := newAddressBinderContract(eth, mirroringContract)
+	votingContract, err := voting.NewVoting(votingAddress, backend)
 	if err != nil {
 		return nil, err
 	}
 
-	privateKey, err := cfg.Chain.GetPrivateKey()
+	addressBinderAddress, err := mirroringContract.AddressBinder(new(bind.CallOpts))
 	if err != nil {
 		return nil, err
 	}
 
-	txOpts, err := TransactOptsFromPrivateKey(privateKey, cfg.Chain.ChainID)
+	addressBinderContract, err := addresses.NewBinder(addressBinderAddress, backend)
 	if err != nil {
 		return nil, err
 	}
 
-	return &mirrorContractsCChain{
-		mirroring:     mirroringContract,
-		addressBinder: addressBinderContract,
-		txOpts:        txOpts,
-		voting:        votingContract,
-	}, nil
-}
-
-func newAddressBinderContract(
-	eth *ethclient.Client, mirroringContract *mirroring.Mirroring,
-) (*addresses.Binder, error) {
-	addressBinderAddress, err := mirroringContract.AddressBinder(new(bind.CallOpts))
-	if err != nil {
-		return nil, err
+	var multicallContract *multicall.Multicall
+	if multicallAddress != (common.Address{}) {
+		multicallContract, err = multicall.NewMulticall(multicallAddress, backend)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return addresses.NewBinder(addressBinderAddress, eth)
+	return &mirrorContractsCChain{
+		mirroring:            mirroringContract,
+		addressBinder:        addressBinderContract,
+		sender:               sender,
+		voting:               votingContract,
+		multicall:            multicallContract,
+		mirroringAddress:     mirroringAddress,
+		addressBinderAddress: addressBinderAddress,
+	}, nil
 }
 
 func (m mirrorContractsCChain) GetMerkleRoot(epoch int64) ([32]byte, error) {
@@ -136,9 +182,62 @@ func (m mirrorContractsCChain) GetMerkleRoot(epoch int64) ([32]byte, error) {
 func (m mirrorContractsCChain) MirrorStake(
 	stakeData *mirroring.IPChainStakeMirrorVerifierPChainStake,
 	merkleProof [][32]byte,
-) error {
-	_, err := m.mirroring.MirrorStake(m.txOpts, *stakeData, merkleProof)
-	return err
+) (common.Hash, error) {
+	receipt, err := m.sender.send(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return m.mirroring.MirrorStake(opts, *stakeData, merkleProof)
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return receipt.TxHash, nil
+}
+
+// Register the public keys and mirror the stakes in a single aggregate3 call of
+// the multicall contract. The transaction reverts if any of the calls fails.
+func (m mirrorContractsCChain) MirrorStakeBatch(
+	stakes []mirrorStakeInput,
+	publicKeys []crypto.PublicKey,
+) (common.Hash, error) {
+	if m.multicall == nil {
+		return common.Hash{}, errors.New("multicall contract address not set")
+	}
+
+	mirroringABI, err := mirroring.MirroringMetaData.GetAbi()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	binderABI, err := addresses.BinderMetaData.GetAbi()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	calls := make([]multicall.Multicall3Call3, 0, len(publicKeys)+len(stakes))
+	for _, publicKey := range publicKeys {
+		ethAddress, err := chain.PublicKeyToEthAddress(publicKey)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		data, err := binderABI.Pack("registerAddresses", publicKey.Bytes(), [20]byte(publicKey.Address()), ethAddress)
+		if err != nil {
+			return common.Hash{}, errors.Wrap(err, "pack registerAddresses")
+		}
+		calls = append(calls, multicall.Multicall3Call3{Target: m.addressBinderAddress, CallData: data})
+	}
+	for _, stake := range stakes {
+		data, err := mirroringABI.Pack("mirrorStake", *stake.stakeData, stake.merkleProof)
+		if err != nil {
+			return common.Hash{}, errors.Wrap(err, "pack mirrorStake")
+		}
+		calls = append(calls, multicall.Multicall3Call3{Target: m.mirroringAddress, CallData: data})
+	}
+
+	receipt, err := m.sender.send(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return m.multicall.Aggregate3(opts, calls)
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return receipt.TxHash, nil
 }
 
 func (m mirrorContractsCChain) IsAddressRegistered(address string) (bool, error) {
@@ -158,7 +257,9 @@ func (m mirrorContractsCChain) RegisterPublicKey(publicKey crypto.PublicKey) err
 	if err != nil {
 		return err
 	}
-	_, err = m.addressBinder.RegisterAddresses(m.txOpts, publicKey.Bytes(), publicKey.Address(), ethAddress)
+	_, err = m.sender.send(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return m.addressBinder.RegisterAddresses(opts, publicKey.Bytes(), publicKey.Address(), ethAddress)
+	})
 	return err
 }
 
@@ -9,7 +9,9 @@ import (
 	"flare-indexer/indexer/config"
 	"flare-indexer/indexer/pchain"
 	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/reverts"
 	"flare-indexer/utils/staking"
+	"sort"
 	"testing"
 	"time"
 
@@ -21,10 +23,11 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-var epochInfo = staking.EpochInfo{
-	Period: 180 * time.Second,
-	Start:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
-}
+var (
+	epochStart  = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	epochPeriod = 180 * time.Second
+	epochInfo   = staking.NewEpochInfo(&globalConfig.EpochConfig{}, epochStart, epochPeriod)
+)
 
 func TestMain(m *testing.M) {
 	cfg := config.Config{
@@ -173,14 +176,240 @@ func TestMultipleTransactionsInSeparateEpochs(t *testing.T) {
 }
 
 func TestAlreadyMirrored(t *testing.T) {
-	testMirrorErrors(t, "transaction already mirrored")
+	testMirrorErrors(t, reverts.ErrTransactionAlreadyMirrored, database.MirrorSkipAlreadyMirrored)
 }
 
 func TestStakingEnded(t *testing.T) {
-	testMirrorErrors(t, "staking already ended")
+	testMirrorErrors(t, reverts.ErrStakingEnded, database.MirrorSkipStakingEnded)
+}
+
+func TestUnknownStakingAddress(t *testing.T) {
+	testMirrorErrors(t, reverts.ErrUnknownStakingAddress, database.MirrorSkipUnknownStakingAddress)
+}
+
+func TestStakingDataInvalid(t *testing.T) {
+	testMirrorErrors(t, reverts.ErrStakingDataInvalid, database.MirrorSkipStakingDataInvalid)
+}
+
+func TestMaxNodeIDsExceeded(t *testing.T) {
+	testMirrorErrors(t, &RevertError{Decoded: reverts.ErrMaxNodeIDsExceeded}, database.MirrorSkipMaxNodeIDsExceeded)
+}
+
+func TestTruncateError(t *testing.T) {
+	require.Equal(t, "short", truncateError(errors.New("short"), 5))
+	require.Equal(t, "trunc", truncateError(errors.New("truncated"), 5))
+
+	// Multi-byte characters are not split
+	require.Equal(t, "ab", truncateError(errors.New("abč"), 3))
+	require.Equal(t, "abč", truncateError(errors.New("abčd"), 4))
+	require.Equal(t, "", truncateError(errors.New("€"), 2))
+}
+
+func TestUnknownRevert(t *testing.T) {
+	tx := newMirrorTestTx(t, "5uZETr5SUKqGJLzFP5BeGxbXU5CFcCBQYPu288eX9R1QDQMjn")
+	txHash, err := staking.HashTransaction(&tx)
+	require.NoError(t, err)
+
+	// Message containing a known revert reason is not a skip decision
+	contracts := testContracts{
+		merkleRoots: map[int64][32]byte{3: txHash},
+		mirrorErrors: map[[32]byte]error{
+			mustTxIDBytes(t, &tx): errors.New("rpc error: transaction already mirrored"),
+		},
+	}
+	db := newTestDB(map[int64][]database.PChainTxData{3: {tx}})
+	j := newTestMirrorCronjob(db, &contracts)
+	j.retryDelay = time.Minute
+
+	err = j.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+
+	status := db.statuses[*tx.TxID]
+	require.Equal(t, database.MirrorTxStatusFailed, status.Status)
+	require.Empty(t, status.SkipReason)
+	require.Equal(t, 1, status.Attempts)
+	require.WithinDuration(t, j.time.Now().Add(time.Minute), *status.NextRetry, time.Second)
+}
+
+func TestMirrorFailedTxRetried(t *testing.T) {
+	txs := []database.PChainTxData{
+		newMirrorTestTx(t, "XnfV79XVMyuXbTw8iNreQ9FrUgy9csYBJp1xRscay3oDzhyq8"),
+		newMirrorTestTx(t, "nsPmyQbm4oo77jyykxbjf7s4Zp4urNptkyAouxVWZ2EB2kw1z"),
+		newMirrorTestTx(t, "2p32tpqNrfzP3SStbP9bQGHZtJkCxjV3iHNssVnkcpUWxHMSuj"),
+	}
+	root, err := staking.GetMerkleRoot(txs)
+	require.NoError(t, err)
+
+	// Mirroring of the second tx fails midway through the epoch
+	failedTxID := mustTxIDBytes(t, &txs[1])
+	contracts := testContracts{
+		merkleRoots:  map[int64][32]byte{3: root},
+		mirrorErrors: map[[32]byte]error{failedTxID: errors.New("connection refused")},
+	}
+	db := newTestDB(map[int64][]database.PChainTxData{3: txs})
+	j := newTestMirrorCronjob(db, &contracts)
+	j.retryDelay = time.Minute
+	now := j.time.Now()
+
+	err = j.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+	require.Len(t, contracts.mirroredStakes, 2)
+	require.Equal(t, database.MirrorTxStatusMirrored, db.statuses[*txs[0].TxID].Status)
+	require.Equal(t, database.MirrorTxStatusFailed, db.statuses[*txs[1].TxID].Status)
+	require.Equal(t, database.MirrorTxStatusMirrored, db.statuses[*txs[2].TxID].Status)
+
+	// Not retried before the retry time
+	delete(contracts.mirrorErrors, failedTxID)
+	err = j.Call()
+	require.NoError(t, err)
+	require.Len(t, contracts.mirroredStakes, 2)
+
+	// Only the failed tx is mirrored again
+	j.time.SetNow(now.Add(time.Minute + time.Second))
+	err = j.Call()
+	require.NoError(t, err)
+	require.Len(t, contracts.mirroredStakes, 3)
+	require.Equal(t, failedTxID, contracts.mirroredStakes[2].stakeData.TxId)
+
+	status := db.statuses[*txs[1].TxID]
+	require.Equal(t, database.MirrorTxStatusMirrored, status.Status)
+	require.Equal(t, 2, status.Attempts)
+	require.Nil(t, status.NextRetry)
+	require.Empty(t, status.Error)
+	require.Equal(t, 1, db.statuses[*txs[0].TxID].Attempts)
+}
+
+func TestMirrorMaxAttempts(t *testing.T) {
+	tx := newMirrorTestTx(t, "5uZETr5SUKqGJLzFP5BeGxbXU5CFcCBQYPu288eX9R1QDQMjn")
+	txHash, err := staking.HashTransaction(&tx)
+	require.NoError(t, err)
+
+	contracts := testContracts{
+		merkleRoots:  map[int64][32]byte{3: txHash},
+		mirrorErrors: map[[32]byte]error{mustTxIDBytes(t, &tx): errors.New("connection refused")},
+	}
+	db := newTestDB(map[int64][]database.PChainTxData{3: {tx}})
+	j := newTestMirrorCronjob(db, &contracts)
+	j.maxAttempts = 2
+
+	for i := 0; i < 3; i++ {
+		err = j.Call()
+		require.NoError(t, err)
+	}
+
+	status := db.statuses[*tx.TxID]
+	require.Equal(t, database.MirrorTxStatusFailed, status.Status)
+	require.Equal(t, 2, status.Attempts)
+	require.Nil(t, status.NextRetry)
+	require.Equal(t, "connection refused", status.Error)
+}
+
+func TestMirrorBatch(t *testing.T) {
+	txs := []database.PChainTxData{
+		newMirrorTestTx(t, "XnfV79XVMyuXbTw8iNreQ9FrUgy9csYBJp1xRscay3oDzhyq8"),
+		newMirrorTestTx(t, "nsPmyQbm4oo77jyykxbjf7s4Zp4urNptkyAouxVWZ2EB2kw1z"),
+		newMirrorTestTx(t, "2p32tpqNrfzP3SStbP9bQGHZtJkCxjV3iHNssVnkcpUWxHMSuj"),
+	}
+	root, err := staking.GetMerkleRoot(txs)
+	require.NoError(t, err)
+
+	contracts := testContracts{
+		merkleRoots: map[int64][32]byte{3: root},
+	}
+	db := newTestDB(map[int64][]database.PChainTxData{3: txs})
+	j := newTestMirrorCronjob(db, &contracts)
+	j.batchSize = 2
+
+	err = j.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+
+	require.Len(t, contracts.batches, 2)
+	require.Len(t, contracts.batches[0], 2)
+	require.Len(t, contracts.batches[1], 1)
+
+	for i, batch := range contracts.batches {
+		for _, stake := range batch {
+			status := db.statuses[ids.ID(stake.stakeData.TxId).String()]
+			require.Equal(t, database.MirrorTxStatusMirrored, status.Status)
+			require.Equal(t, 1, status.Attempts)
+			require.Equal(t, common.Hash{byte(i + 1)}.Hex(), status.CChainTxHash)
+		}
+	}
+}
+
+func TestMirrorBatchRevertFallback(t *testing.T) {
+	txs := []database.PChainTxData{
+		newMirrorTestTx(t, "XnfV79XVMyuXbTw8iNreQ9FrUgy9csYBJp1xRscay3oDzhyq8"),
+		newMirrorTestTx(t, "nsPmyQbm4oo77jyykxbjf7s4Zp4urNptkyAouxVWZ2EB2kw1z"),
+		newMirrorTestTx(t, "2p32tpqNrfzP3SStbP9bQGHZtJkCxjV3iHNssVnkcpUWxHMSuj"),
+	}
+	root, err := staking.GetMerkleRoot(txs)
+	require.NoError(t, err)
+
+	// Second tx reverts the batch, it is skipped when mirrored separately
+	contracts := testContracts{
+		merkleRoots:  map[int64][32]byte{3: root},
+		mirrorErrors: map[[32]byte]error{mustTxIDBytes(t, &txs[1]): reverts.ErrStakingEnded},
+	}
+	db := newTestDB(map[int64][]database.PChainTxData{3: txs})
+	j := newTestMirrorCronjob(db, &contracts)
+	j.batchSize = 3
+
+	err = j.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+
+	require.Empty(t, contracts.batches)
+	require.Len(t, contracts.mirroredStakes, 2)
+	require.Equal(t, database.MirrorTxStatusMirrored, db.statuses[*txs[0].TxID].Status)
+	require.Equal(t, database.MirrorTxStatusSkipped, db.statuses[*txs[1].TxID].Status)
+	require.Equal(t, database.MirrorSkipStakingEnded, db.statuses[*txs[1].TxID].SkipReason)
+	require.Equal(t, database.MirrorTxStatusMirrored, db.statuses[*txs[2].TxID].Status)
+	for _, tx := range txs {
+		require.Equal(t, 1, db.statuses[*tx.TxID].Attempts)
+	}
 }
 
-func testMirrorErrors(t *testing.T, errorMsg string) {
+func newMirrorTestTx(t *testing.T, txID string) database.PChainTxData {
+	startTime := epochInfo.GetStartTime(3)
+	endTime := epochInfo.GetEndTime(999)
+	return database.PChainTxData{
+		PChainTx: database.PChainTx{
+			ChainID:   "costwo",
+			NodeID:    "NodeID-CZYx3on11wwYXFoHwZtAQZT5unZ9JHMf6",
+			StartTime: &startTime,
+			EndTime:   &endTime,
+			TxID:      &txID,
+			Type:      database.PChainAddDelegatorTx,
+		},
+		InputAddress: "costwo18atl0e95w5ym6t8u5yrjpz35vqqzxfzrrsnq8u",
+		InputIndex:   0,
+	}
+}
+
+func mustTxIDBytes(t *testing.T, tx *database.PChainTxData) [32]byte {
+	txID, err := ids.FromString(*tx.TxID)
+	require.NoError(t, err)
+	return txID
+}
+
+func newTestMirrorCronjob(db testDB, contracts *testContracts) *mirrorCronJob {
+	j := &mirrorCronJob{
+		db:        db,
+		contracts: contracts,
+		epochCronjob: epochCronjob{
+			enabled: true,
+			epochs:  epochInfo,
+		},
+	}
+	j.time.SetNow(epochInfo.GetStartTime(5))
+	return j
+}
+
+func testMirrorErrors(t *testing.T, revert error, reason database.MirrorSkipReason) {
 	startTime := epochInfo.GetStartTime(3)
 	endTime := epochInfo.GetEndTime(999)
 
@@ -215,13 +444,20 @@ func testMirrorErrors(t *testing.T, errorMsg string) {
 	contracts := testContracts{
 		merkleRoots: merkleRoots,
 		mirrorErrors: map[[32]byte]error{
-			txidBytes: errors.New(errorMsg),
+			txidBytes: revert,
 		},
 	}
 
 	db := testMirror(t, txs, contracts)
 
 	require.Equal(t, db.states[mirrorStateName].NextDBIndex, uint64(4))
+	require.Len(t, db.statuses, 1)
+	status := db.statuses[txid]
+	require.Equal(t, int64(3), status.Epoch)
+	require.Equal(t, database.MirrorTxStatusSkipped, status.Status)
+	require.Equal(t, reason, status.SkipReason)
+	require.Equal(t, revert.Error(), status.Error)
+	require.Nil(t, status.NextRetry)
 }
 
 func testMirror(
@@ -229,18 +465,7 @@ func testMirror(
 	txs map[int64][]database.PChainTxData,
 	contracts testContracts,
 ) *testDB {
-	db := testDB{
-		epochs: epochInfo,
-		states: map[string]database.State{
-			pchain.StateName: {
-				Updated:        epochInfo.GetEndTime(999),
-				NextDBIndex:    3,
-				LastChainIndex: 2,
-			},
-			mirrorStateName: {},
-		},
-		txs: txs,
-	}
+	db := newTestDB(txs)
 
 	j := mirrorCronJob{
 		db:        db,
@@ -260,9 +485,30 @@ func testMirror(
 }
 
 type testDB struct {
+	*epochJobRunsTest
+
 	epochs staking.EpochInfo
 	states map[string]database.State
 	txs    map[int64][]database.PChainTxData
+	// Statuses by tx id
+	statuses map[string]*database.MirrorStatus
+}
+
+func newTestDB(txs map[int64][]database.PChainTxData) testDB {
+	return testDB{
+		epochJobRunsTest: &epochJobRunsTest{},
+		epochs:           epochInfo,
+		states: map[string]database.State{
+			pchain.StateName: {
+				Updated:        epochInfo.GetEndTime(999),
+				NextDBIndex:    3,
+				LastChainIndex: 2,
+			},
+			mirrorStateName: {},
+		},
+		txs:      txs,
+		statuses: make(map[string]*database.MirrorStatus),
+	}
 }
 
 func (db testDB) FetchState(name string) (database.State, error) {
@@ -291,15 +537,46 @@ func (db testDB) GetPChainTx(txID string, address string) (*database.PChainTxDat
 	return nil, nil
 }
 
+func (db testDB) GetMirrorStatuses(txIDs []string) ([]database.MirrorStatus, error) {
+	var statuses []database.MirrorStatus
+	for _, txID := range txIDs {
+		if status, ok := db.statuses[txID]; ok {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses, nil
+}
+
+func (db testDB) SaveMirrorStatus(status *database.MirrorStatus) error {
+	saved := *status
+	db.statuses[status.TxID] = &saved
+	return nil
+}
+
+func (db testDB) GetMirrorRetryEpochs(now time.Time) ([]int64, error) {
+	epochSet := make(map[int64]bool)
+	for _, status := range db.statuses {
+		if status.Status != database.MirrorTxStatusPending && status.Status != database.MirrorTxStatusFailed {
+			continue
+		}
+		if status.NextRetry != nil && !status.NextRetry.After(now) {
+			epochSet[status.Epoch] = true
+		}
+	}
+	epochs := make([]int64, 0, len(epochSet))
+	for epoch := range epochSet {
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs, nil
+}
+
 type testContracts struct {
 	merkleRoots    map[int64][32]byte
 	mirroredStakes []mirrorStakeInput
 	mirrorErrors   map[[32]byte]error
-}
-
-type mirrorStakeInput struct {
-	stakeData   *mirroring.IPChainStakeMirrorVerifierPChainStake
-	merkleProof [][32]byte
+	// Stakes of the successfully mirrored batches
+	batches [][]mirrorStakeInput
 }
 
 func (c testContracts) GetMerkleRoot(epoch int64) ([32]byte, error) {
@@ -309,16 +586,35 @@ func (c testContracts) GetMerkleRoot(epoch int64) ([32]byte, error) {
 func (c *testContracts) MirrorStake(
 	stakeData *mirroring.IPChainStakeMirrorVerifierPChainStake,
 	merkleProof [][32]byte,
-) error {
+) (common.Hash, error) {
 	if err := c.mirrorErrors[stakeData.TxId]; err != nil {
-		return err
+		return common.Hash{}, err
 	}
 
 	c.mirroredStakes = append(c.mirroredStakes, mirrorStakeInput{
 		stakeData:   stakeData,
 		merkleProof: merkleProof,
 	})
-	return nil
+	return common.Hash(stakeData.TxId), nil
+}
+
+// Batch reverts if mirroring of any of the stakes fails
+func (c *testContracts) MirrorStakeBatch(
+	stakes []mirrorStakeInput,
+	publicKeys []crypto.PublicKey,
+) (common.Hash, error) {
+	for _, stake := range stakes {
+		if err := c.mirrorErrors[stake.stakeData.TxId]; err != nil {
+			return common.Hash{}, &RevertError{
+				Reason:  "Multicall3: call failed",
+				Decoded: reverts.FromMessage("Multicall3: call failed"),
+			}
+		}
+	}
+
+	c.batches = append(c.batches, stakes)
+	c.mirroredStakes = append(c.mirroredStakes, stakes...)
+	return common.Hash{byte(len(c.batches))}, nil
 }
 
 func (c testContracts) IsAddressRegistered(address string) (bool, error) {
@@ -330,5 +626,5 @@ func (c testContracts) RegisterPublicKey(publicKey crypto.PublicKey) error {
 }
 
 func (c testContracts) EpochConfig() (time.Time, time.Duration, error) {
-	return epochInfo.Start, epochInfo.Period, nil
+	return epochStart, epochPeriod, nil
 }
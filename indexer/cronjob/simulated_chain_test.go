@@ -0,0 +1,75 @@
+package cronjob
+
+import (
+	"crypto/ecdsa"
+	"flare-indexer/indexer/config"
+	"flare-indexer/utils/contracts/simulated"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+type simulatedChain struct {
+	backend   *simulated.Backend
+	contracts *simulated.Contracts
+	voters    []*bind.TransactOpts
+	senders   map[int]*txSender
+}
+
+// Settings of transactions on the simulated chain, where each transaction is
+// mined immediately
+var simulatedTxConfig = config.TxConfig{
+	ReceiptTimeout:   time.Second,
+	PollInterval:     10 * time.Millisecond,
+	FeeBumpPercent:   20,
+	MaxResubmissions: 1,
+}
+
+// Deploy the simulated contracts with the given number of voters, all of
+// which need to vote for the same root to finalize an epoch
+func newSimulatedChain(t *testing.T, numVoters int, epochStart time.Time, epochPeriod time.Duration) *simulatedChain {
+	keys := make([]*ecdsa.PrivateKey, numVoters)
+	addresses := make([]common.Address, numVoters)
+	for i := range keys {
+		var err error
+		keys[i], addresses[i], err = simulated.NewAccount()
+		require.NoError(t, err)
+	}
+
+	backend := simulated.NewBackend(addresses...)
+	t.Cleanup(func() { backend.Close() })
+
+	voters := make([]*bind.TransactOpts, numVoters)
+	for i, key := range keys {
+		var err error
+		voters[i], err = simulated.TransactOpts(key)
+		require.NoError(t, err)
+	}
+
+	contracts, err := simulated.DeployContracts(backend, voters[0], &simulated.VotingParams{
+		FirstEpochStart: epochStart,
+		EpochPeriod:     epochPeriod,
+		Voters:          addresses,
+	})
+	require.NoError(t, err)
+
+	return &simulatedChain{
+		backend:   backend,
+		contracts: contracts,
+		voters:    voters,
+		senders:   make(map[int]*txSender),
+	}
+}
+
+// Return the transaction sender of the voter, shared by all cronjobs of the voter
+func (c *simulatedChain) sender(voter int) *txSender {
+	if s, ok := c.senders[voter]; ok {
+		return s
+	}
+	s := newTxSender(c.backend, c.voters[voter], &simulatedTxConfig)
+	c.senders[voter] = s
+	return s
+}
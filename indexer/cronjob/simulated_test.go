@@ -0,0 +1,411 @@
+//go:build !integration
+// +build !integration
+
+package cronjob
+
+import (
+	"context"
+	globalConfig "flare-indexer/config"
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"flare-indexer/indexer/pchain"
+	"flare-indexer/utils/chain"
+	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/reverts"
+	"flare-indexer/utils/staking"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/crypto"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func (c *simulatedChain) newVotingCronjob(t *testing.T, voter int, db votingDB) *votingCronjob {
+	contract, err := newVotingContractCChainWithSender(c.sender(voter), c.contracts.VotingAddress)
+	require.NoError(t, err)
+
+	start, period, err := contract.EpochConfig()
+	require.NoError(t, err)
+
+	cronjobCfg := config.CronjobConfig{Enabled: true, BatchSize: 10}
+	cronjob := &votingCronjob{
+		epochCronjob: newEpochCronjob(&cronjobCfg, staking.NewEpochInfo(&globalConfig.EpochConfig{}, start.UTC(), period)),
+		db:           db,
+		contract:     contract,
+	}
+	cronjob.time.SetNow(epochInfo.GetStartTime(5))
+	return cronjob
+}
+
+func (c *simulatedChain) newMirrorCronjob(t *testing.T, db mirrorDB) (*mirrorCronJob, *mirrorContractsCChain) {
+	contracts, err := newMirrorContractsCChain(
+		c.sender(0), c.contracts.MirroringAddress, c.contracts.VotingAddress, c.contracts.MulticallAddress,
+	)
+	require.NoError(t, err)
+
+	cronjob := &mirrorCronJob{
+		epochCronjob: epochCronjob{
+			enabled: true,
+			epochs:  epochInfo,
+		},
+		db:        db,
+		contracts: contracts,
+	}
+	cronjob.time.SetNow(epochInfo.GetStartTime(5))
+	return cronjob, contracts
+}
+
+// Submit the merkle root of the txs for the epoch with all voters
+func (c *simulatedChain) finalizeEpoch(t *testing.T, epoch int64, txs []database.PChainTxData) [32]byte {
+	root, err := staking.GetMerkleRoot(txs)
+	require.NoError(t, err)
+
+	for _, voter := range c.voters {
+		_, err := c.contracts.Voting.SubmitVote(voter, big.NewInt(epoch), root)
+		require.NoError(t, err)
+	}
+	return root
+}
+
+func (c *simulatedChain) isMirrored(t *testing.T, tx *database.PChainTxData) bool {
+	txID, err := ids.FromString(*tx.TxID)
+	require.NoError(t, err)
+
+	nodeID, err := ids.NodeIDFromString(tx.NodeID)
+	require.NoError(t, err)
+
+	mirrored, err := c.contracts.Mirroring.IsActiveStakeMirrored(new(bind.CallOpts), txID, nodeID)
+	require.NoError(t, err)
+	return mirrored
+}
+
+func newSimulatedVotingDB(txs map[int64][]database.PChainTxData) *votingDBTest {
+	db := &votingDBTest{
+		states: map[string]database.State{
+			pchain.StateName: {
+				Updated:        epochInfo.GetEndTime(999),
+				NextDBIndex:    3,
+				LastChainIndex: 2,
+			},
+		},
+		votingData: make(map[timeRange][]database.PChainTxData),
+	}
+	for epoch, epochTxs := range txs {
+		start, end := epochInfo.GetTimeRange(epoch)
+		db.votingData[timeRange{start, end}] = epochTxs
+	}
+	return db
+}
+
+func newSimulatedMirrorDB(txs map[int64][]database.PChainTxData) testDB {
+	return newTestDB(txs)
+}
+
+// Create a tx in the epoch staked from a newly generated p-chain address, the
+// private key of the address is returned to allow its registration
+func newSimulatedTx(t *testing.T, epoch int64, txID string) (database.PChainTxData, crypto.PrivateKey) {
+	factory := crypto.FactorySECP256K1R{}
+	privateKey, err := factory.NewPrivateKey()
+	require.NoError(t, err)
+
+	address, err := chain.FormatAddressBytes(privateKey.PublicKey().Address().Bytes())
+	require.NoError(t, err)
+
+	startTime := epochInfo.GetStartTime(epoch)
+	endTime := epochInfo.GetEndTime(999)
+	return database.PChainTxData{
+		PChainTx: database.PChainTx{
+			ChainID:   "costwo",
+			NodeID:    "NodeID-CZYx3on11wwYXFoHwZtAQZT5unZ9JHMf6",
+			StartTime: &startTime,
+			EndTime:   &endTime,
+			TxID:      &txID,
+			Type:      database.PChainAddDelegatorTx,
+			Weight:    1_000_000,
+		},
+		InputAddress: address,
+	}, privateKey
+}
+
+func TestSimulatedVoting(t *testing.T) {
+	chain := newSimulatedChain(t, 2, epochStart, epochPeriod)
+
+	tx0, _ := newSimulatedTx(t, 3, txIDs[0])
+	tx1, _ := newSimulatedTx(t, 3, txIDs[1])
+	txs := map[int64][]database.PChainTxData{
+		3: {tx0, tx1},
+	}
+	db1 := newSimulatedVotingDB(txs)
+	db2 := newSimulatedVotingDB(txs)
+	cronjob1 := chain.newVotingCronjob(t, 0, db1)
+	cronjob2 := chain.newVotingCronjob(t, 1, db2)
+
+	require.Equal(t, epochInfo.GetStartTime(0).Unix(), cronjob1.epochs.GetStartTime(0).Unix())
+	require.Equal(t, epochInfo.CurrentPeriod(), cronjob1.epochs.CurrentPeriod())
+
+	err := cronjob1.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), db1.states[votingStateName].NextDBIndex)
+
+	// Threshold is not reached with a single vote
+	root, err := chain.contracts.Voting.GetMerkleRoot(new(bind.CallOpts), big.NewInt(3))
+	require.NoError(t, err)
+	require.Equal(t, [32]byte{}, root)
+
+	shouldVote, err := cronjob1.contract.ShouldVote(big.NewInt(3))
+	require.NoError(t, err)
+	require.False(t, shouldVote)
+
+	shouldVote, err = cronjob2.contract.ShouldVote(big.NewInt(3))
+	require.NoError(t, err)
+	require.True(t, shouldVote)
+
+	err = cronjob2.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), db2.states[votingStateName].NextDBIndex)
+
+	expectedRoot, err := staking.GetMerkleRoot(txs[3])
+	require.NoError(t, err)
+
+	for epoch := int64(0); epoch < 5; epoch++ {
+		root, err := chain.contracts.Voting.GetMerkleRoot(new(bind.CallOpts), big.NewInt(epoch))
+		require.NoError(t, err)
+		if epoch == 3 {
+			require.Equal(t, [32]byte(expectedRoot), root)
+		} else {
+			require.Equal(t, [32]byte(staking.EmptyTreeRoot), root)
+		}
+	}
+
+	votes, err := chain.contracts.Voting.FilterPChainStakeMirrorVoteSubmitted(&bind.FilterOpts{})
+	require.NoError(t, err)
+	numVotes := 0
+	for votes.Next() {
+		numVotes++
+	}
+	require.NoError(t, votes.Error())
+	require.Equal(t, 10, numVotes)
+
+	finalized, err := chain.contracts.Voting.FilterPChainStakeMirrorVotingFinalized(
+		&bind.FilterOpts{}, []*big.Int{big.NewInt(3)},
+	)
+	require.NoError(t, err)
+	require.True(t, finalized.Next())
+	require.Equal(t, [32]byte(expectedRoot), finalized.Event.MerkleRoot)
+	require.False(t, finalized.Next())
+
+	// Votes for a finalized epoch are rejected by the contract
+	err = cronjob1.contract.SubmitVote(big.NewInt(3), expectedRoot)
+	require.ErrorContains(t, err, "epoch already finalized")
+}
+
+func TestSimulatedMirroring(t *testing.T) {
+	chain := newSimulatedChain(t, 1, epochStart, epochPeriod)
+
+	tx, privateKey := newSimulatedTx(t, 3, txIDs[0])
+	txs := map[int64][]database.PChainTxData{
+		3: {tx},
+	}
+	chain.finalizeEpoch(t, 3, txs[3])
+
+	db := newSimulatedMirrorDB(txs)
+	cronjob, contracts := chain.newMirrorCronjob(t, db)
+
+	registered, err := contracts.IsAddressRegistered(tx.InputAddress)
+	require.NoError(t, err)
+	require.False(t, registered)
+
+	err = contracts.RegisterPublicKey(privateKey.PublicKey())
+	require.NoError(t, err)
+
+	registered, err = contracts.IsAddressRegistered(tx.InputAddress)
+	require.NoError(t, err)
+	require.True(t, registered)
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+	require.True(t, chain.isMirrored(t, &tx))
+
+	confirmed, err := chain.contracts.Mirroring.FilterStakeConfirmed(&bind.FilterOpts{}, nil, nil, nil)
+	require.NoError(t, err)
+	require.True(t, confirmed.Next())
+	require.Equal(t, big.NewInt(1_000_000_000_000_000), confirmed.Event.AmountWei)
+	require.False(t, confirmed.Next())
+
+	// Mirroring the same tx again reverts with "transaction already mirrored"
+	_, err = contracts.MirrorStake(mustStakeData(t, &tx), mustMerkleProof(t, txs[3], &tx))
+	require.ErrorIs(t, err, reverts.ErrTransactionAlreadyMirrored)
+
+	err = db.UpdateJobState(0, true)
+	require.NoError(t, err)
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+
+	// Mirrored tx is not submitted again
+	status := db.statuses[*tx.TxID]
+	require.Equal(t, database.MirrorTxStatusMirrored, status.Status)
+	require.Equal(t, 1, status.Attempts)
+	receipt, err := chain.backend.TransactionReceipt(context.Background(), common.HexToHash(status.CChainTxHash))
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+}
+
+func TestSimulatedMirroringUnknownAddress(t *testing.T) {
+	chain := newSimulatedChain(t, 1, epochStart, epochPeriod)
+
+	tx, _ := newSimulatedTx(t, 3, txIDs[0])
+	txs := map[int64][]database.PChainTxData{
+		3: {tx},
+	}
+	chain.finalizeEpoch(t, 3, txs[3])
+
+	db := newSimulatedMirrorDB(txs)
+	cronjob, contracts := chain.newMirrorCronjob(t, db)
+
+	_, err := contracts.MirrorStake(mustStakeData(t, &tx), mustMerkleProof(t, txs[3], &tx))
+	require.ErrorIs(t, err, reverts.ErrUnknownStakingAddress)
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+	require.False(t, chain.isMirrored(t, &tx))
+	require.Equal(t, database.MirrorSkipUnknownStakingAddress, db.statuses[*tx.TxID].SkipReason)
+}
+
+func TestSimulatedMirroringStakingEnded(t *testing.T) {
+	chain := newSimulatedChain(t, 1, epochStart, epochPeriod)
+
+	tx, privateKey := newSimulatedTx(t, 3, txIDs[0])
+	txs := map[int64][]database.PChainTxData{
+		3: {tx},
+	}
+	chain.finalizeEpoch(t, 3, txs[3])
+
+	db := newSimulatedMirrorDB(txs)
+	cronjob, contracts := chain.newMirrorCronjob(t, db)
+
+	err := contracts.RegisterPublicKey(privateKey.PublicKey())
+	require.NoError(t, err)
+
+	// Move the chain time past the end of staking
+	err = chain.backend.SetTime(*tx.EndTime)
+	require.NoError(t, err)
+
+	_, err = contracts.MirrorStake(mustStakeData(t, &tx), mustMerkleProof(t, txs[3], &tx))
+	require.ErrorIs(t, err, reverts.ErrStakingEnded)
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+	require.False(t, chain.isMirrored(t, &tx))
+	require.Equal(t, database.MirrorSkipStakingEnded, db.statuses[*tx.TxID].SkipReason)
+}
+
+func TestSimulatedMirroringInvalidData(t *testing.T) {
+	chain := newSimulatedChain(t, 1, epochStart, epochPeriod)
+
+	tx, privateKey := newSimulatedTx(t, 3, txIDs[0])
+	otherTx, _ := newSimulatedTx(t, 3, txIDs[1])
+
+	// Finalized root does not include the tx
+	chain.finalizeEpoch(t, 3, []database.PChainTxData{otherTx})
+
+	_, contracts := chain.newMirrorCronjob(t, newSimulatedMirrorDB(nil))
+
+	err := contracts.RegisterPublicKey(privateKey.PublicKey())
+	require.NoError(t, err)
+
+	txs := []database.PChainTxData{tx, otherTx}
+	_, err = contracts.MirrorStake(mustStakeData(t, &tx), mustMerkleProof(t, txs, &tx))
+	require.ErrorIs(t, err, reverts.ErrStakingDataInvalid)
+	require.False(t, chain.isMirrored(t, &tx))
+}
+
+func TestSimulatedMirroringBatch(t *testing.T) {
+	chain := newSimulatedChain(t, 1, epochStart, epochPeriod)
+
+	tx0, privateKey0 := newSimulatedTx(t, 3, txIDs[0])
+	tx1, privateKey1 := newSimulatedTx(t, 3, txIDs[1])
+	txs := []database.PChainTxData{tx0, tx1}
+	chain.finalizeEpoch(t, 3, txs)
+
+	_, contracts := chain.newMirrorCronjob(t, newSimulatedMirrorDB(nil))
+
+	// Addresses are registered before the stakes are mirrored
+	stakes := []mirrorStakeInput{
+		{mustStakeData(t, &tx0), mustMerkleProof(t, txs, &tx0)},
+		{mustStakeData(t, &tx1), mustMerkleProof(t, txs, &tx1)},
+	}
+	publicKeys := []crypto.PublicKey{privateKey0.PublicKey(), privateKey1.PublicKey()}
+	txHash, err := contracts.MirrorStakeBatch(stakes, publicKeys)
+	require.NoError(t, err)
+	require.True(t, chain.isMirrored(t, &tx0))
+	require.True(t, chain.isMirrored(t, &tx1))
+
+	receipt, err := chain.backend.TransactionReceipt(context.Background(), txHash)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	registered, err := contracts.IsAddressRegistered(tx1.InputAddress)
+	require.NoError(t, err)
+	require.True(t, registered)
+
+	// Batch reverts as a whole if any of the calls fails
+	_, err = contracts.MirrorStakeBatch(stakes[:1], nil)
+	require.ErrorContains(t, err, "Multicall3: call failed")
+}
+
+func TestSimulatedMirroringBatchFallback(t *testing.T) {
+	chain := newSimulatedChain(t, 1, epochStart, epochPeriod)
+
+	tx0, privateKey0 := newSimulatedTx(t, 3, txIDs[0])
+	tx1, _ := newSimulatedTx(t, 3, txIDs[1])
+	tx2, privateKey2 := newSimulatedTx(t, 3, txIDs[2])
+	txs := map[int64][]database.PChainTxData{
+		3: {tx0, tx1, tx2},
+	}
+	chain.finalizeEpoch(t, 3, txs[3])
+
+	db := newSimulatedMirrorDB(txs)
+	cronjob, contracts := chain.newMirrorCronjob(t, db)
+	cronjob.batchSize = 3
+
+	for _, privateKey := range []crypto.PrivateKey{privateKey0, privateKey2} {
+		err := contracts.RegisterPublicKey(privateKey.PublicKey())
+		require.NoError(t, err)
+	}
+
+	// Address of the second tx is not registered, so the batch reverts and
+	// the txs are mirrored separately
+	err := cronjob.Call()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), db.states[mirrorStateName].NextDBIndex)
+
+	require.True(t, chain.isMirrored(t, &tx0))
+	require.False(t, chain.isMirrored(t, &tx1))
+	require.True(t, chain.isMirrored(t, &tx2))
+	require.Equal(t, database.MirrorTxStatusMirrored, db.statuses[*tx0.TxID].Status)
+	require.Equal(t, database.MirrorSkipUnknownStakingAddress, db.statuses[*tx1.TxID].SkipReason)
+	require.Equal(t, database.MirrorTxStatusMirrored, db.statuses[*tx2.TxID].Status)
+}
+
+func mustStakeData(t *testing.T, tx *database.PChainTxData) *mirroring.IPChainStakeMirrorVerifierPChainStake {
+	stakeData, err := staking.ToStakeData(tx)
+	require.NoError(t, err)
+	return stakeData
+}
+
+func mustMerkleProof(t *testing.T, txs []database.PChainTxData, tx *database.PChainTxData) [][32]byte {
+	tree, err := staking.BuildTree(txs)
+	require.NoError(t, err)
+
+	proof, err := staking.GetMerkleProof(tree, tx)
+	require.NoError(t, err)
+	return proof
+}
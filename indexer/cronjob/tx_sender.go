@@ -0,0 +1,555 @@
+package cronjob
+
+import (
+	"context"
+	"flare-indexer/indexer/config"
+	"flare-indexer/logger"
+	"flare-indexer/utils/contracts/reverts"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Min fee increase of a replacement transaction accepted by the nodes
+	minFeeBumpPercent = 10
+
+	revertedPrefix = "execution reverted"
+
+	// Max duration of each request to the node if rpc_timeout is not set
+	defaultRPCTimeout = 10 * time.Second
+)
+
+var (
+	ErrTxNotMined = errors.New("transaction not mined")
+
+	// Returned if a transaction sent by an earlier call can still be mined
+	ErrTxPending = errors.New("transaction pending")
+
+	// Returned if the instance lost the leadership, transactions are then left
+	// to the new leader
+	ErrNotLeader = errors.New("not the leader")
+
+	errStaleNonce = errors.New("stale nonce")
+)
+
+// Backend used to send transactions and wait for their receipts, implemented
+// by ethclient.Client and the simulated backend
+type EthBackend interface {
+	bind.ContractBackend
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+}
+
+// Backend with the balance of the sender account, implemented by
+// ethclient.Client and the simulated backend
+type balanceBackend interface {
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// Error returned if a transaction and its replacements are not mined within
+// the receipt timeout, matches ErrTxNotMined. They can still be mined later,
+// so they are checked with sentStatus before the transaction is sent again.
+type TxNotMinedError struct {
+	Nonce uint64
+
+	// Hashes of the transaction and its replacements
+	TxHashes []common.Hash
+}
+
+func (e *TxNotMinedError) Error() string {
+	return fmt.Sprintf("%v: nonce %d", ErrTxNotMined, e.Nonce)
+}
+
+func (e *TxNotMinedError) Is(target error) bool {
+	return target == ErrTxNotMined
+}
+
+// Error returned if a transaction reverts, either when estimating gas (the
+// transaction is not sent) or when it is mined
+type RevertError struct {
+	// Decoded revert reason, empty if not available
+	Reason string
+
+	// Raw revert data, nil if not available
+	Data []byte
+
+	// Revert decoded against the contract ABIs, nil if not available
+	Decoded *reverts.Error
+
+	// Hash of the mined transaction, nil if the transaction was not sent
+	TxHash *common.Hash
+}
+
+func (e *RevertError) Error() string {
+	if len(e.Reason) == 0 {
+		return revertedPrefix
+	}
+	return revertedPrefix + ": " + e.Reason
+}
+
+// Unwrap to the decoded revert, so it can be matched with errors.Is
+func (e *RevertError) Unwrap() error {
+	if e.Decoded == nil {
+		return nil
+	}
+	return e.Decoded
+}
+
+// Convert the error returned by the node to RevertError, return false if the
+// error is not a revert
+func asRevertError(err error) (*RevertError, bool) {
+	var revertErr *RevertError
+	if errors.As(err, &revertErr) {
+		return revertErr, true
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if hexData, ok := dataErr.ErrorData().(string); ok {
+			data, decodeErr := hexutil.Decode(hexData)
+			if decodeErr == nil {
+				revertErr := &RevertError{Data: data}
+				if decoded, err := reverts.Decode(data); err == nil {
+					revertErr.Decoded = decoded
+					revertErr.Reason = decoded.Error()
+				} else {
+					logger.Debug("Failed decoding revert data %s: %v", hexData, err)
+				}
+				return revertErr, true
+			}
+		}
+	}
+
+	msg := err.Error()
+	if i := strings.Index(msg, revertedPrefix); i >= 0 {
+		revertErr := &RevertError{}
+		reason := strings.TrimSpace(strings.TrimPrefix(msg[i+len(revertedPrefix):], ":"))
+		if len(reason) > 0 {
+			revertErr.Reason = reason
+			revertErr.Decoded = reverts.FromMessage(reason)
+		}
+		return revertErr, true
+	}
+	return nil, false
+}
+
+// Sends transactions of one account and waits until they are mined. Nonces
+// are managed locally, so transactions of cronjobs sharing the sender do not
+// conflict. Transactions are sent one at a time.
+type txSender struct {
+	backend EthBackend
+	opts    *bind.TransactOpts
+	cfg     *config.TxConfig
+
+	// Transactions are only sent and resubmitted while leader returns true,
+	// if set
+	leader func() bool
+
+	mu sync.Mutex
+
+	// Next nonce, nil if it should be fetched from the node
+	nonce *uint64
+}
+
+func newTxSender(backend EthBackend, opts *bind.TransactOpts, cfg *config.TxConfig) *txSender {
+	return &txSender{
+		backend: backend,
+		opts:    opts,
+		cfg:     cfg,
+	}
+}
+
+func (s *txSender) from() common.Address {
+	return s.opts.From
+}
+
+// Context of a single request to the node, bounded so that an unresponsive
+// node does not stall the cronjob past the leader lease
+func (s *txSender) rpcContext() (context.Context, context.CancelFunc) {
+	timeout := s.cfg.RPCTimeout
+	if timeout <= 0 {
+		timeout = defaultRPCTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// Check the leadership right before sending, a call of a cronjob can outlive
+// the leader lease
+func (s *txSender) checkLeader() error {
+	if s.leader != nil && !s.leader() {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+// Create the transaction with fn (a binding method called with the given
+// options), send it and wait until it is mined. Reverts are returned as
+// RevertError, both if the transaction fails to estimate gas and if it fails
+// when mined. Error is returned unless the transaction is mined successfully.
+func (s *txSender) send(fn func(opts *bind.TransactOpts) (*types.Transaction, error)) (*types.Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.sendTx(fn)
+	if errors.Is(err, errStaleNonce) {
+		// Account was used outside of this sender, retry with the nonce from the node
+		logger.Warn("%v, retrying", err)
+		tx, err = s.sendTx(fn)
+	}
+	if err != nil {
+		var revertErr *RevertError
+		if errors.As(err, &revertErr) {
+			transactionsReverted.Inc()
+		}
+		return nil, err
+	}
+
+	receipt, err := s.waitMined(tx)
+	if err != nil {
+		s.nonce = nil
+		return nil, err
+	}
+	transactionsSent.Inc()
+	transactionsGasUsed.Add(float64(receipt.GasUsed))
+	s.updateBalance()
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		transactionsReverted.Inc()
+		return nil, s.minedRevertError(tx, receipt)
+	}
+	return receipt, nil
+}
+
+// Update the balance metrics of the sender account, errors are only logged
+func (s *txSender) updateBalance() {
+	backend, ok := s.backend.(balanceBackend)
+	if !ok {
+		return
+	}
+	ctx, cancel := s.rpcContext()
+	defer cancel()
+	balance, err := backend.BalanceAt(ctx, s.from(), nil)
+	if err != nil {
+		logger.Warn("Failed fetching balance of %s: %v", s.from(), err)
+		return
+	}
+	updateBalanceMetrics(balance, s.cfg.LowBalance)
+}
+
+// Create the transaction with the next nonce and send it
+func (s *txSender) sendTx(fn func(opts *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	opts, err := s.nextOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	// Bindings estimate the gas (and check the contract code) with the context
+	// of the options
+	ctx, cancel := s.rpcContext()
+	opts.Context = ctx
+	tx, err := fn(opts)
+	cancel()
+	if err != nil {
+		if revertErr, ok := asRevertError(err); ok {
+			return nil, revertErr
+		}
+		return nil, err
+	}
+
+	if err := s.checkLeader(); err != nil {
+		// Nonces are used by the new leader in the meantime
+		s.nonce = nil
+		return nil, err
+	}
+
+	if err := s.sendTransaction(tx); err != nil {
+		s.nonce = nil
+		if pending, nonceErr := s.pendingNonce(); nonceErr == nil && pending != tx.Nonce() {
+			s.nonce = &pending
+			return nil, errors.Wrapf(errStaleNonce, "nonce %d, pending nonce %d: %v", tx.Nonce(), pending, err)
+		}
+		return nil, errors.Wrap(err, "SendTransaction")
+	}
+	*s.nonce++
+	return tx, nil
+}
+
+func (s *txSender) sendTransaction(tx *types.Transaction) error {
+	ctx, cancel := s.rpcContext()
+	defer cancel()
+	return s.backend.SendTransaction(ctx, tx)
+}
+
+func (s *txSender) pendingNonce() (uint64, error) {
+	ctx, cancel := s.rpcContext()
+	defer cancel()
+	return s.backend.PendingNonceAt(ctx, s.from())
+}
+
+// Return transact options with the next nonce and capped fees. Transaction is
+// signed but not sent by the bindings.
+func (s *txSender) nextOpts() (*bind.TransactOpts, error) {
+	if s.nonce == nil {
+		nonce, err := s.pendingNonce()
+		if err != nil {
+			return nil, errors.Wrap(err, "PendingNonceAt")
+		}
+		s.nonce = &nonce
+	}
+
+	opts := *s.opts
+	opts.NoSend = true
+	opts.Nonce = new(big.Int).SetUint64(*s.nonce)
+
+	ctx, cancel := s.rpcContext()
+	defer cancel()
+	head, err := s.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "HeaderByNumber")
+	}
+	if head.BaseFee == nil {
+		ctx, cancel := s.rpcContext()
+		defer cancel()
+		gasPrice, err := s.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "SuggestGasPrice")
+		}
+		opts.GasPrice = s.capFee(gasPrice, s.cfg.MaxFeeGwei)
+		return &opts, nil
+	}
+
+	ctx, cancel = s.rpcContext()
+	defer cancel()
+	tip, err := s.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "SuggestGasTipCap")
+	}
+	tip = s.capFee(tip, s.cfg.MaxPriorityFeeGwei)
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	feeCap = s.capFee(feeCap, s.cfg.MaxFeeGwei)
+	if tip.Cmp(feeCap) > 0 {
+		tip = feeCap
+	}
+	opts.GasTipCap = tip
+	opts.GasFeeCap = feeCap
+	return &opts, nil
+}
+
+func (s *txSender) capFee(fee *big.Int, maxGwei uint64) *big.Int {
+	if maxGwei == 0 {
+		return fee
+	}
+	maxFee := new(big.Int).Mul(new(big.Int).SetUint64(maxGwei), big.NewInt(params.GWei))
+	if fee.Cmp(maxFee) > 0 {
+		return maxFee
+	}
+	return fee
+}
+
+// Wait for the receipt of the transaction or of one of its replacements. If the
+// transaction is not mined within the receipt timeout, it is resubmitted with
+// increased fees.
+func (s *txSender) waitMined(tx *types.Transaction) (*types.Receipt, error) {
+	sent := []*types.Transaction{tx}
+	for resubmissions := 0; ; resubmissions++ {
+		receipt, err := s.waitReceipt(sent)
+		if err != nil || receipt != nil {
+			return receipt, err
+		}
+		if resubmissions >= s.cfg.MaxResubmissions {
+			break
+		}
+
+		last := sent[len(sent)-1]
+		if err := s.checkLeader(); err != nil {
+			return nil, errors.Wrapf(err, "transaction %s not mined, not resubmitted", last.Hash())
+		}
+		replacement, err := s.bumpFees(last)
+		if err != nil {
+			return nil, err
+		}
+		if replacement == nil {
+			logger.Warn("Transaction %s not mined, fees cannot be increased above the cap", last.Hash())
+			continue
+		}
+		logger.Warn("Transaction %s not mined in %v, resubmitting as %s", last.Hash(), s.cfg.ReceiptTimeout, replacement.Hash())
+		if err := s.sendTransaction(replacement); err != nil {
+			// The original transaction may have been mined in the meantime
+			logger.Warn("Failed resubmitting transaction %s: %v", last.Hash(), err)
+			continue
+		}
+		sent = append(sent, replacement)
+	}
+	txHashes := make([]common.Hash, len(sent))
+	for i := range sent {
+		txHashes[i] = sent[i].Hash()
+	}
+	return nil, &TxNotMinedError{Nonce: tx.Nonce(), TxHashes: txHashes}
+}
+
+// Check the transactions of an earlier call that were not mined in time (see
+// TxNotMinedError). Returns true if one of them is mined successfully and
+// ErrTxPending if one of them can still be mined. At most one of them is mined,
+// since they have the same nonce.
+func (s *txSender) sentStatus(txHashes []common.Hash) (bool, error) {
+	for _, txHash := range txHashes {
+		receipt, err := s.receipt(txHash)
+		if err == nil {
+			return receipt.Status == types.ReceiptStatusSuccessful, nil
+		}
+		if err != ethereum.NotFound {
+			return false, errors.Wrap(err, "TransactionReceipt")
+		}
+	}
+	for _, txHash := range txHashes {
+		ctx, cancel := s.rpcContext()
+		_, pending, err := s.backend.TransactionByHash(ctx, txHash)
+		cancel()
+		if err == ethereum.NotFound {
+			continue
+		}
+		if err != nil {
+			return false, errors.Wrap(err, "TransactionByHash")
+		}
+		if pending {
+			return false, errors.Wrapf(ErrTxPending, "transaction %s", txHash)
+		}
+
+		// Mined after its receipt was checked
+		receipt, err := s.receipt(txHash)
+		if err != nil {
+			return false, errors.Wrap(err, "TransactionReceipt")
+		}
+		return receipt.Status == types.ReceiptStatusSuccessful, nil
+	}
+	// Dropped by the nodes
+	return false, nil
+}
+
+// Poll for the receipt of any of the transactions until the receipt timeout,
+// return nil receipt on timeout
+func (s *txSender) waitReceipt(txs []*types.Transaction) (*types.Receipt, error) {
+	deadline := time.Now().Add(s.cfg.ReceiptTimeout)
+	for {
+		for _, tx := range txs {
+			receipt, err := s.receipt(tx.Hash())
+			if err == nil {
+				return receipt, nil
+			}
+			if err != ethereum.NotFound {
+				logger.Debug("Failed fetching receipt of transaction %s: %v", tx.Hash(), err)
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+		time.Sleep(s.cfg.PollInterval)
+	}
+}
+
+func (s *txSender) receipt(txHash common.Hash) (*types.Receipt, error) {
+	ctx, cancel := s.rpcContext()
+	defer cancel()
+	return s.backend.TransactionReceipt(ctx, txHash)
+}
+
+// Return the transaction with the same nonce and increased fees signed by the
+// sender, nil if the fees are already at the cap
+func (s *txSender) bumpFees(tx *types.Transaction) (*types.Transaction, error) {
+	percent := s.cfg.FeeBumpPercent
+	if percent < minFeeBumpPercent {
+		percent = minFeeBumpPercent
+	}
+	bump := func(fee *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(fee, big.NewInt(int64(100+percent)))
+		bumped.Div(bumped, big.NewInt(100))
+		if bumped.Cmp(fee) <= 0 {
+			bumped.Add(fee, big.NewInt(1))
+		}
+		return bumped
+	}
+	aboveCap := func(fee *big.Int, maxGwei uint64) bool {
+		return s.capFee(fee, maxGwei).Cmp(fee) < 0
+	}
+
+	var txData types.TxData
+	if tx.Type() == types.LegacyTxType {
+		gasPrice := bump(tx.GasPrice())
+		if aboveCap(gasPrice, s.cfg.MaxFeeGwei) {
+			return nil, nil
+		}
+		txData = &types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		}
+	} else {
+		tip := bump(tx.GasTipCap())
+		feeCap := bump(tx.GasFeeCap())
+		if aboveCap(tip, s.cfg.MaxPriorityFeeGwei) ||
+			aboveCap(feeCap, s.cfg.MaxFeeGwei) {
+			return nil, nil
+		}
+		txData = &types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tip,
+			GasFeeCap:  feeCap,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}
+	}
+	signed, err := s.opts.Signer(s.from(), types.NewTx(txData))
+	if err != nil {
+		return nil, errors.Wrap(err, "Signer")
+	}
+	return signed, nil
+}
+
+// Replay the reverted transaction to get the revert reason. The transaction is
+// replayed on the state before its block, since transactions mined before it
+// in the same block (the cause of the revert) would otherwise be applied.
+func (s *txSender) minedRevertError(tx *types.Transaction, receipt *types.Receipt) error {
+	txHash := receipt.TxHash
+	revertErr := &RevertError{TxHash: &txHash}
+
+	msg := ethereum.CallMsg{
+		From:      s.from(),
+		To:        tx.To(),
+		Gas:       tx.Gas(),
+		GasFeeCap: tx.GasFeeCap(),
+		GasTipCap: tx.GasTipCap(),
+		Value:     tx.Value(),
+		Data:      tx.Data(),
+	}
+	ctx, cancel := s.rpcContext()
+	defer cancel()
+	parent := new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	_, err := s.backend.CallContract(ctx, msg, parent)
+	if err != nil {
+		if callErr, ok := asRevertError(err); ok {
+			revertErr.Reason = callErr.Reason
+			revertErr.Data = callErr.Data
+			revertErr.Decoded = callErr.Decoded
+		}
+	}
+	return fmt.Errorf("transaction %s failed: %w", txHash, revertErr)
+}
@@ -0,0 +1,336 @@
+package cronjob
+
+import (
+	"context"
+	"flare-indexer/utils/contracts/simulated"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Backend that drops the given number of transactions, as if they were stuck
+// in the mempool
+type droppingBackend struct {
+	*simulated.Backend
+
+	drop int
+	sent []*types.Transaction
+
+	// Called after each sent transaction if set
+	onSend func()
+
+	// Dropped transactions are reported as pending if set
+	pending bool
+}
+
+func (b *droppingBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	if b.onSend != nil {
+		b.onSend()
+	}
+	if b.drop > 0 {
+		b.drop--
+		return nil
+	}
+	return b.Backend.SendTransaction(ctx, tx)
+}
+
+func (b *droppingBackend) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	tx, pending, err := b.Backend.TransactionByHash(ctx, txHash)
+	if err == ethereum.NotFound && b.pending {
+		for _, sent := range b.sent {
+			if sent.Hash() == txHash {
+				return sent, true, nil
+			}
+		}
+	}
+	return tx, pending, err
+}
+
+// Backend recording the blocks of the contract calls
+type callRecordingBackend struct {
+	*simulated.Backend
+
+	blocks []*big.Int
+}
+
+func (b *callRecordingBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.blocks = append(b.blocks, blockNumber)
+	return b.Backend.CallContract(ctx, call, blockNumber)
+}
+
+func submitUptimeVote(c *simulatedChain, epoch int64) func(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.contracts.Voting.SubmitValidatorUptimeVote(opts, big.NewInt(epoch), [][20]byte{{1}})
+	}
+}
+
+func TestTxSenderNonces(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+	sender := chain.sender(0)
+
+	for epoch := int64(1); epoch <= 3; epoch++ {
+		receipt, err := sender.send(submitUptimeVote(chain, epoch))
+		require.NoError(t, err)
+		require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	}
+
+	// Transaction sent outside of the sender makes the local nonce stale
+	_, err := chain.contracts.Voting.SubmitValidatorUptimeVote(chain.voters[0], big.NewInt(4), nil)
+	require.NoError(t, err)
+
+	receipt, err := sender.send(submitUptimeVote(chain, 5))
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	nonce, err := chain.backend.NonceAt(context.Background(), chain.voters[0].From, nil)
+	require.NoError(t, err)
+	require.Equal(t, *sender.nonce, nonce)
+}
+
+func TestTxSenderMetrics(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+
+	cfg := simulatedTxConfig
+	cfg.LowBalance = 1
+	sender := newTxSender(chain.backend, chain.voters[0], &cfg)
+	sent, gasUsed := testutil.ToFloat64(transactionsSent), testutil.ToFloat64(transactionsGasUsed)
+
+	receipt, err := sender.send(submitUptimeVote(chain, 1))
+	require.NoError(t, err)
+	require.Equal(t, sent+1, testutil.ToFloat64(transactionsSent))
+	require.Equal(t, gasUsed+float64(receipt.GasUsed), testutil.ToFloat64(transactionsGasUsed))
+
+	balance, err := chain.backend.BalanceAt(context.Background(), chain.voters[0].From, nil)
+	require.NoError(t, err)
+	flr, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), big.NewFloat(params.Ether)).Float64()
+	require.Equal(t, flr, testutil.ToFloat64(votingAccountBalance))
+	require.Equal(t, float64(0), testutil.ToFloat64(votingAccountBalanceLow))
+
+	// Low balance is set once the balance drops below the threshold
+	cfg.LowBalance = flr + 1
+	_, err = sender.send(submitUptimeVote(chain, 2))
+	require.NoError(t, err)
+	require.Equal(t, float64(1), testutil.ToFloat64(votingAccountBalanceLow))
+}
+
+func TestTxSenderEstimationRevert(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+
+	otherKey, other, err := simulated.NewAccount()
+	require.NoError(t, err)
+	otherOpts, err := simulated.TransactOpts(otherKey)
+	require.NoError(t, err)
+	fund(t, chain, other)
+
+	sender := newTxSender(chain.backend, otherOpts, &simulatedTxConfig)
+	sent, reverted := testutil.ToFloat64(transactionsSent), testutil.ToFloat64(transactionsReverted)
+	_, err = sender.send(submitUptimeVote(chain, 1))
+
+	var revertErr *RevertError
+	require.True(t, errors.As(err, &revertErr))
+	require.Contains(t, revertErr.Reason, "only voters")
+	require.Nil(t, revertErr.TxHash)
+	require.Equal(t, sent, testutil.ToFloat64(transactionsSent))
+	require.Equal(t, reverted+1, testutil.ToFloat64(transactionsReverted))
+
+	// Nonce is not used by the failed transaction
+	require.Equal(t, uint64(0), *sender.nonce)
+}
+
+func TestTxSenderMinedRevert(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+
+	otherKey, other, err := simulated.NewAccount()
+	require.NoError(t, err)
+	otherOpts, err := simulated.TransactOpts(otherKey)
+	require.NoError(t, err)
+	fund(t, chain, other)
+
+	// Skip gas estimation, so the transaction is sent and reverts when mined
+	otherOpts.GasLimit = 100_000
+	backend := &callRecordingBackend{Backend: chain.backend}
+	sender := newTxSender(backend, otherOpts, &simulatedTxConfig)
+	sent, reverted := testutil.ToFloat64(transactionsSent), testutil.ToFloat64(transactionsReverted)
+	_, err = sender.send(submitUptimeVote(chain, 1))
+
+	var revertErr *RevertError
+	require.True(t, errors.As(err, &revertErr))
+	require.Contains(t, revertErr.Reason, "only voters")
+	require.NotNil(t, revertErr.TxHash)
+	require.Equal(t, sent+1, testutil.ToFloat64(transactionsSent))
+	require.Equal(t, reverted+1, testutil.ToFloat64(transactionsReverted))
+
+	receipt, err := chain.backend.TransactionReceipt(context.Background(), *revertErr.TxHash)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusFailed, receipt.Status)
+
+	// Replayed on the state before the block of the transaction
+	require.Len(t, backend.blocks, 1)
+	require.Equal(t, new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1)), backend.blocks[0])
+}
+
+func TestTxSenderResubmit(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+	backend := &droppingBackend{Backend: chain.backend, drop: 1}
+
+	cfg := simulatedTxConfig
+	cfg.ReceiptTimeout = 50 * time.Millisecond
+	sender := newTxSender(backend, chain.voters[0], &cfg)
+
+	receipt, err := sender.send(submitUptimeVote(chain, 1))
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	require.Len(t, backend.sent, 2)
+	original, replacement := backend.sent[0], backend.sent[1]
+	require.Equal(t, replacement.Hash(), receipt.TxHash)
+	require.Equal(t, original.Nonce(), replacement.Nonce())
+	require.Equal(t, original.Data(), replacement.Data())
+	require.Equal(t, 1, replacement.GasFeeCap().Cmp(original.GasFeeCap()))
+	require.Equal(t, 1, replacement.GasTipCap().Cmp(original.GasTipCap()))
+}
+
+func TestTxSenderNotMined(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+	backend := &droppingBackend{Backend: chain.backend, drop: 10}
+
+	cfg := simulatedTxConfig
+	cfg.ReceiptTimeout = 20 * time.Millisecond
+	cfg.MaxResubmissions = 2
+	sender := newTxSender(backend, chain.voters[0], &cfg)
+
+	_, err := sender.send(submitUptimeVote(chain, 1))
+	require.ErrorIs(t, err, ErrTxNotMined)
+	require.Len(t, backend.sent, 3)
+
+	// Nonce is fetched from the node again
+	require.Nil(t, sender.nonce)
+}
+
+func TestTxSenderSentStatus(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+	backend := &droppingBackend{Backend: chain.backend, drop: 2}
+
+	cfg := simulatedTxConfig
+	cfg.ReceiptTimeout = 20 * time.Millisecond
+	sender := newTxSender(backend, chain.voters[0], &cfg)
+
+	_, err := sender.send(submitUptimeVote(chain, 1))
+	var notMined *TxNotMinedError
+	require.True(t, errors.As(err, &notMined))
+	require.Equal(t, []common.Hash{backend.sent[0].Hash(), backend.sent[1].Hash()}, notMined.TxHashes)
+
+	// Dropped transactions can be sent again
+	sent, err := sender.sentStatus(notMined.TxHashes)
+	require.NoError(t, err)
+	require.False(t, sent)
+
+	backend.pending = true
+	_, err = sender.sentStatus(notMined.TxHashes)
+	require.ErrorIs(t, err, ErrTxPending)
+
+	// Replacement is mined after the receipt timeout
+	require.NoError(t, chain.backend.SendTransaction(context.Background(), backend.sent[1]))
+	sent, err = sender.sentStatus(notMined.TxHashes)
+	require.NoError(t, err)
+	require.True(t, sent)
+}
+
+func TestTxSenderLeadershipLost(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+	backend := &droppingBackend{Backend: chain.backend, drop: 1}
+
+	cfg := simulatedTxConfig
+	cfg.ReceiptTimeout = 20 * time.Millisecond
+	sender := newTxSender(backend, chain.voters[0], &cfg)
+	leader := true
+	sender.leader = func() bool { return leader }
+
+	// Leadership is lost while waiting for the receipt of the first transaction
+	// of a call, it is not resubmitted and no further transactions are sent
+	backend.onSend = func() { leader = false }
+	_, err := sender.send(submitUptimeVote(chain, 1))
+	require.ErrorIs(t, err, ErrNotLeader)
+	require.Len(t, backend.sent, 1)
+
+	_, err = sender.send(submitUptimeVote(chain, 2))
+	require.ErrorIs(t, err, ErrNotLeader)
+	require.Len(t, backend.sent, 1)
+	require.Nil(t, sender.nonce)
+
+	// Sending continues once the leadership is acquired again
+	leader = true
+	backend.onSend = nil
+	receipt, err := sender.send(submitUptimeVote(chain, 3))
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+}
+
+func TestTxSenderFeeCaps(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+	backend := &droppingBackend{Backend: chain.backend}
+
+	cfg := simulatedTxConfig
+	cfg.MaxFeeGwei = 1
+	cfg.MaxPriorityFeeGwei = 1
+	sender := newTxSender(backend, chain.voters[0], &cfg)
+
+	_, err := sender.send(submitUptimeVote(chain, 1))
+	require.NoError(t, err)
+
+	require.Len(t, backend.sent, 1)
+	tx := backend.sent[0]
+	require.LessOrEqual(t, tx.GasFeeCap().Cmp(big.NewInt(params.GWei)), 0)
+	require.LessOrEqual(t, tx.GasTipCap().Cmp(tx.GasFeeCap()), 0)
+}
+
+func TestTxSenderBumpFees(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(simulated.ChainID),
+		Nonce:     3,
+		GasTipCap: big.NewInt(100),
+		GasFeeCap: big.NewInt(params.GWei),
+		Gas:       100_000,
+	})
+
+	cfg := simulatedTxConfig
+	cfg.FeeBumpPercent = 5
+	cfg.MaxFeeGwei = 2
+	sender := newTxSender(chain.backend, chain.voters[0], &cfg)
+
+	// Bump is at least 10%
+	bumped, err := sender.bumpFees(tx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), bumped.Nonce())
+	require.Equal(t, int64(110), bumped.GasTipCap().Int64())
+	require.Equal(t, int64(params.GWei*11/10), bumped.GasFeeCap().Int64())
+
+	// Fees are not increased above the cap
+	cfg.MaxFeeGwei = 1
+	bumped, err = sender.bumpFees(tx)
+	require.NoError(t, err)
+	require.Nil(t, bumped)
+}
+
+// Transfer ether from the first voter to the account
+func fund(t *testing.T, chain *simulatedChain, account common.Address) {
+	opts := chain.voters[0]
+	nonce, err := chain.backend.PendingNonceAt(context.Background(), opts.From)
+	require.NoError(t, err)
+	tx := types.NewTransaction(nonce, account, big.NewInt(params.Ether), params.TxGas, big.NewInt(params.GWei), nil)
+	signed, err := opts.Signer(opts.From, tx)
+	require.NoError(t, err)
+	require.NoError(t, chain.backend.SendTransaction(context.Background(), signed))
+}
@@ -4,10 +4,14 @@ import (
 	"flare-indexer/database"
 	"flare-indexer/indexer/config"
 	"flare-indexer/indexer/context"
-	"flare-indexer/utils"
+	"flare-indexer/logger"
 	"flare-indexer/utils/chain"
+	"math"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
 
@@ -15,16 +19,44 @@ type uptimeCronjob struct {
 	config config.UptimeConfig
 	db     *gorm.DB
 
+	// Nodes polled in parallel on each call, the time of the first one is used
+	// for the timestamps of the observations
+	observers []uptimeObserver
+}
+
+type uptimeObserver struct {
+	id     string
 	client chain.UptimeClient
 }
 
-func NewUptimeCronjob(ctx context.IndexerContext) Cronjob {
-	endpoint := utils.JoinPaths(ctx.Config().Chain.NodeURL, "ext/bc/P"+chain.RPCClientOptions(ctx.Config().Chain.ApiKey))
+func NewUptimeCronjob(ctx context.IndexerContext, clients *Clients) (Cronjob, error) {
+	cfg := ctx.Config()
+	if _, err := newUptimeQuorum(cfg.UptimeCronjob.Quorum); err != nil {
+		return nil, err
+	}
+	observers := clients.uptimeObservers(cfg)
+	if err := checkUptimeObservers(observers); err != nil {
+		return nil, err
+	}
 	return &uptimeCronjob{
-		config: ctx.Config().UptimeCronjob,
-		db:     ctx.DB(),
-		client: chain.NewAvalancheUptimeClient(endpoint),
+		config:    cfg.UptimeCronjob,
+		db:        ctx.DB(),
+		observers: observers,
+	}, nil
+}
+
+func checkUptimeObservers(observers []uptimeObserver) error {
+	ids := make(map[string]bool, len(observers))
+	for _, o := range observers {
+		if len(o.id) > 60 {
+			return errors.Errorf("uptime observer id %q is longer than 60 characters", o.id)
+		}
+		if ids[o.id] {
+			return errors.Errorf("duplicate uptime observer id %q", o.id)
+		}
+		ids[o.id] = true
 	}
+	return nil
 }
 
 func (c *uptimeCronjob) Name() string {
@@ -43,27 +75,63 @@ func (c *uptimeCronjob) OnStart() error {
 	entities := []*database.UptimeCronjob{&database.UptimeCronjob{
 		NodeID:    nil,
 		Status:    database.UptimeCronjobStatusIndexerStarted,
-		Timestamp: c.client.Now(),
+		Timestamp: c.observers[0].client.Now(),
 	}}
 	return database.CreateUptimeCronjobEntry(c.db, entities)
 }
 
 func (c *uptimeCronjob) Call() error {
-	validators, status, err := c.client.GetValidatorStatus()
+	entities, err := observeUptimes(c.observers)
 	if err != nil {
 		return err
 	}
-	now := c.client.Now()
+	return database.CreateUptimeCronjobEntry(c.db, entities)
+}
+
+type uptimeObservation struct {
+	validators []*chain.ValidatorStatus
+	status     database.UptimeCronjobStatus
+	err        error
+}
+
+// Ask all observers for the connected validators in parallel. Observers that
+// fail are recorded with the service error status, an error is returned only
+// if all of them fail.
+func observeUptimes(observers []uptimeObserver) ([]*database.UptimeCronjob, error) {
+	observations := make([]uptimeObservation, len(observers))
+	var wg sync.WaitGroup
+	for i := range observers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			o := &observations[i]
+			o.validators, o.status, o.err = observers[i].client.GetValidatorStatus()
+		}(i)
+	}
+	wg.Wait()
+
+	if err := allUptimeObserversFailed(observations); err != nil {
+		return nil, err
+	}
+
+	now := observers[0].client.Now()
 	var entities []*database.UptimeCronjob
-	if status < 0 {
-		entities = []*database.UptimeCronjob{&database.UptimeCronjob{
-			NodeID:    nil,
-			Status:    status,
-			Timestamp: now,
-		}}
-	} else {
-		entities = make([]*database.UptimeCronjob, len(validators))
-		for i, v := range validators {
+	for i, o := range observations {
+		observer := observers[i].id
+		if o.err != nil {
+			logger.Warn("Uptime observer %q failed: %v", observer, o.err)
+			o.status = database.UptimeCronjobStatusServiceError
+		}
+		if o.status < 0 {
+			entities = append(entities, &database.UptimeCronjob{
+				NodeID:    nil,
+				Status:    o.status,
+				Timestamp: now,
+				Observer:  observer,
+			})
+			continue
+		}
+		for _, v := range o.validators {
 			nodeID := v.NodeID
 			var status database.UptimeCronjobStatus
 			if v.Connected {
@@ -71,12 +139,61 @@ func (c *uptimeCronjob) Call() error {
 			} else {
 				status = database.UptimeCronjobStatusDisconnected
 			}
-			entities[i] = &database.UptimeCronjob{
-				NodeID:    &nodeID,
-				Status:    status,
-				Timestamp: now,
-			}
+			entities = append(entities, &database.UptimeCronjob{
+				NodeID:         &nodeID,
+				Status:         status,
+				Timestamp:      now,
+				Observer:       observer,
+				ReportedUptime: v.Uptime,
+			})
 		}
 	}
-	return database.CreateUptimeCronjobEntry(c.db, entities)
+	return entities, nil
+}
+
+// Error of the first observer if all of them failed
+func allUptimeObserversFailed(observations []uptimeObservation) error {
+	for _, o := range observations {
+		if o.err == nil {
+			return nil
+		}
+	}
+	return observations[0].err
+}
+
+// Rule deciding whether a node is connected from the observations of the
+// observers that reported it at the same time
+type uptimeQuorum struct {
+	// Minimum number of observers reporting the node connected, 0 for a
+	// majority of the reporting observers. All reporting observers are
+	// required if less of them reported the node.
+	min int
+}
+
+func newUptimeQuorum(rule string) (uptimeQuorum, error) {
+	switch rule {
+	case "", "majority":
+		return uptimeQuorum{}, nil
+	case "any":
+		return uptimeQuorum{min: 1}, nil
+	case "all":
+		return uptimeQuorum{min: math.MaxInt32}, nil
+	}
+	min, err := strconv.Atoi(rule)
+	if err != nil || min < 1 {
+		return uptimeQuorum{}, errors.Errorf("invalid uptime quorum %q", rule)
+	}
+	return uptimeQuorum{min: min}, nil
+}
+
+// Connected if enough of the reporting observers report the node connected
+func (q uptimeQuorum) connected(connected int, reported int) bool {
+	required := q.min
+	if required == 0 {
+		required = reported/2 + 1
+	}
+	if required > reported {
+		required = reported
+	}
+	return connected >= required
 }
@@ -0,0 +1,340 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	indexerctx "flare-indexer/indexer/context"
+	"flare-indexer/logger"
+	"flare-indexer/utils"
+	"sort"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+const (
+	uptimeCompactionStateName string = "uptime_compaction_cronjob"
+
+	// Samples are compacted in windows of at most this length, each in its own
+	// transaction
+	uptimeCompactionWindow = time.Hour
+)
+
+// Cronjob compacting consecutive uptime samples of a node with the same
+// (quorum) status into uptime intervals. The next db index of its state is
+// the unix time until which the samples are compacted (exclusive).
+type uptimeCompactionCronjob struct {
+	enabled bool
+	timeout time.Duration
+	delay   time.Duration
+
+	rawRetention      time.Duration
+	intervalRetention time.Duration
+
+	db     uptimeCompactionDB
+	quorum uptimeQuorum
+
+	// Time without samples of a node longer than this is compacted into a gap
+	// interval, 0 to disable
+	maxGap time.Duration
+
+	// For testing to set "now" to some past date
+	time utils.ShiftedTime
+}
+
+type uptimeCompactionDB interface {
+	FetchState(name string) (database.State, error)
+	FetchFirstUptimeTimestamp() (time.Time, error)
+	FetchUptimeSamples(start, end time.Time) ([]database.UptimeCronjob, error)
+	FetchUptimeErrors(start, end time.Time) ([]database.UptimeCronjob, error)
+	FetchActiveUptimeNodes(since time.Time) ([]string, error)
+	FetchLastUptimeIntervals(nodeIDs []string) ([]database.UptimeInterval, error)
+	DeleteUptimesBefore(timestamp time.Time) error
+	DeleteUptimeIntervalsBefore(timestamp time.Time) error
+
+	// Persist the intervals and the state at once if the next db index of the
+	// stored state is still compactedUntil, returns false otherwise
+	SaveUptimeIntervals(intervals []*database.UptimeInterval, state *database.State, compactedUntil uint64) (bool, error)
+}
+
+func NewUptimeCompactionCronjob(ctx indexerctx.IndexerContext, clients *Clients) (*uptimeCompactionCronjob, error) {
+	cfg := ctx.Config().UptimeCronjob
+	if !cfg.Enabled {
+		return &uptimeCompactionCronjob{}, nil
+	}
+
+	quorum, err := newUptimeQuorum(cfg.Quorum)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := newUptimePolicy(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uptimeCompactionCronjob{
+		enabled:           true,
+		timeout:           cfg.Compaction.Interval,
+		delay:             cfg.Compaction.Delay,
+		rawRetention:      cfg.Compaction.RawRetention,
+		intervalRetention: cfg.Compaction.IntervalRetention,
+		db:                &uptimeCompactionDBGorm{g: ctx.DB()},
+		quorum:            quorum,
+		maxGap:            policy.maxGap,
+		time:              clients.time(),
+	}, nil
+}
+
+func (c *uptimeCompactionCronjob) Name() string {
+	return "uptime_compaction"
+}
+
+func (c *uptimeCompactionCronjob) Enabled() bool {
+	return c.enabled
+}
+
+func (c *uptimeCompactionCronjob) Timeout() time.Duration {
+	return c.timeout
+}
+
+func (c *uptimeCompactionCronjob) OnStart() error {
+	return nil
+}
+
+func (c *uptimeCompactionCronjob) Call() error {
+	now := c.time.Now()
+	compactedUntil, err := c.compact(now.Add(-c.delay).Truncate(time.Second))
+	if err != nil {
+		return err
+	}
+	return c.deleteOld(now, compactedUntil)
+}
+
+// Compact the samples before the cutoff, returns the time until which the
+// samples are compacted
+func (c *uptimeCompactionCronjob) compact(cutoff time.Time) (time.Time, error) {
+	state, err := c.db.FetchState(uptimeCompactionStateName)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	start := uptimeCompactedUntil(state)
+	if start.IsZero() {
+		// Nothing is compacted yet, start with the first sample
+		first, err := c.db.FetchFirstUptimeTimestamp()
+		if err != nil || first.IsZero() {
+			return start, err
+		}
+		start = first.Truncate(time.Second)
+	}
+
+	for start.Before(cutoff) {
+		end := start.Add(uptimeCompactionWindow)
+		if end.After(cutoff) {
+			end = cutoff
+		}
+		saved, err := c.compactWindow(&state, start, end)
+		if err != nil {
+			return start, err
+		}
+		if !saved {
+			// Only the leader compacts, but leadership can change during a call
+			logger.Warn("Uptime samples before %s were compacted by another instance", end.Format(time.RFC3339))
+			return start, nil
+		}
+		logger.Debug("Compacted uptime samples before %s", end.Format(time.RFC3339))
+		start = end
+	}
+	return start, nil
+}
+
+// Compact the samples in [start, end), returns false if the window was
+// compacted by another instance in the meantime
+func (c *uptimeCompactionCronjob) compactWindow(state *database.State, start, end time.Time) (bool, error) {
+	samples, err := c.db.FetchUptimeSamples(start, end)
+	if err != nil {
+		return false, err
+	}
+	errorSamples, err := c.db.FetchUptimeErrors(start, end)
+	if err != nil {
+		return false, err
+	}
+
+	// Errors also apply to the nodes that have recent intervals but no samples
+	// in the window
+	lookback := c.maxGap
+	if lookback == 0 {
+		lookback = uptimeCompactionWindow
+	}
+	activeNodes, err := c.db.FetchActiveUptimeNodes(start.Add(-lookback))
+	if err != nil {
+		return false, err
+	}
+	nodeIDs := mapset.NewSet(activeNodes...)
+	for i := range samples {
+		nodeIDs.Add(*samples[i].NodeID)
+	}
+	stored, err := c.db.FetchLastUptimeIntervals(nodeIDs.ToSlice())
+	if err != nil {
+		return false, err
+	}
+	last := make(map[string]*database.UptimeInterval, len(stored))
+	for i := range stored {
+		last[stored[i].NodeID] = &stored[i]
+	}
+
+	intervals := compactUptimeSamples(last, samples, errorSamples, c.quorum, c.maxGap)
+	compactedUntil := state.NextDBIndex
+	state.NextDBIndex = uint64(end.Unix())
+	state.UpdateTime()
+	return c.db.SaveUptimeIntervals(intervals, state, compactedUntil)
+}
+
+// Status of a node at a sample time
+type uptimeEvent struct {
+	timestamp time.Time
+	status    database.UptimeCronjobStatus
+}
+
+// Extend the last intervals of the nodes with their samples (sorted by node id
+// and timestamp) and start new intervals when the status changes. Error
+// samples (sorted by timestamp) give the status of the nodes with an interval
+// that were not reported at their time. Returns the new and the extended
+// intervals, new intervals of a node in the order of their time, so the last
+// one gets the highest id.
+func compactUptimeSamples(
+	last map[string]*database.UptimeInterval,
+	samples []database.UptimeCronjob,
+	errorSamples []database.UptimeCronjob,
+	quorum uptimeQuorum,
+	maxGap time.Duration,
+) []*database.UptimeInterval {
+	nodeEvents := make(map[string][]uptimeEvent)
+	for i := 0; i < len(samples); {
+		nodeID := *samples[i].NodeID
+		timestamp := samples[i].Timestamp
+
+		// Observations of all observers at the same timestamp form a sample
+		connected, reported := 0, 0
+		for ; i < len(samples) && *samples[i].NodeID == nodeID && samples[i].Timestamp.Equal(timestamp); i++ {
+			if samples[i].Status != database.UptimeCronjobStatusDisconnected {
+				connected++
+			}
+			reported++
+		}
+		status := database.UptimeCronjobStatusDisconnected
+		if quorum.connected(connected, reported) {
+			status = database.UptimeCronjobStatusConnected
+		}
+		nodeEvents[nodeID] = append(nodeEvents[nodeID], uptimeEvent{timestamp, status})
+	}
+
+	// Status of the first observer failing at each time
+	var errorEvents []uptimeEvent
+	for i := range errorSamples {
+		if i == 0 || !errorSamples[i].Timestamp.Equal(errorSamples[i-1].Timestamp) {
+			errorEvents = append(errorEvents, uptimeEvent{errorSamples[i].Timestamp, errorSamples[i].Status})
+		}
+	}
+
+	nodeIDs := utils.Keys(last)
+	for nodeID := range nodeEvents {
+		if last[nodeID] == nil {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+	sort.Strings(nodeIDs)
+
+	var intervals []*database.UptimeInterval
+	changed := make(map[*database.UptimeInterval]bool)
+	update := func(interval *database.UptimeInterval) {
+		if !changed[interval] {
+			changed[interval] = true
+			intervals = append(intervals, interval)
+		}
+	}
+	for _, nodeID := range nodeIDs {
+		for _, event := range mergeUptimeEvents(nodeEvents[nodeID], errorEvents) {
+			interval := last[nodeID]
+			if interval == nil && event.status < 0 {
+				// Errors before the first sample of the node
+				continue
+			}
+			if interval != nil && maxGap > 0 && event.timestamp.Sub(interval.EndTime) > maxGap {
+				interval = &database.UptimeInterval{
+					NodeID:    nodeID,
+					Status:    database.UptimeCronjobStatusGap,
+					StartTime: interval.EndTime,
+					EndTime:   event.timestamp,
+				}
+				update(interval)
+			}
+			if interval != nil && interval.Status == event.status {
+				interval.EndTime = event.timestamp
+			} else {
+				start := event.timestamp
+				if interval != nil {
+					start = interval.EndTime
+				}
+				interval = &database.UptimeInterval{
+					NodeID:    nodeID,
+					Status:    event.status,
+					StartTime: start,
+					EndTime:   event.timestamp,
+				}
+			}
+			last[nodeID] = interval
+			update(interval)
+		}
+	}
+	return intervals
+}
+
+// Merge the samples of a node with the errors at the times the node was not
+// reported, both sorted by timestamp
+func mergeUptimeEvents(samples []uptimeEvent, errors []uptimeEvent) []uptimeEvent {
+	events := make([]uptimeEvent, 0, len(samples)+len(errors))
+	i, j := 0, 0
+	for i < len(samples) || j < len(errors) {
+		switch {
+		case j == len(errors) || (i < len(samples) && samples[i].timestamp.Before(errors[j].timestamp)):
+			events = append(events, samples[i])
+			i++
+		case i < len(samples) && samples[i].timestamp.Equal(errors[j].timestamp):
+			// Node reported by some observers
+			j++
+		default:
+			events = append(events, errors[j])
+			j++
+		}
+	}
+	return events
+}
+
+// Delete samples and intervals older than their retention, samples are kept
+// until they are compacted
+func (c *uptimeCompactionCronjob) deleteOld(now time.Time, compactedUntil time.Time) error {
+	if c.rawRetention > 0 {
+		before := now.Add(-c.rawRetention)
+		if compactedUntil.Before(before) {
+			before = compactedUntil
+		}
+		if err := c.db.DeleteUptimesBefore(before); err != nil {
+			return err
+		}
+	}
+	if c.intervalRetention > 0 {
+		if err := c.db.DeleteUptimeIntervalsBefore(now.Add(-c.intervalRetention)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Time until which the uptime samples are compacted into intervals, zero time
+// if nothing is compacted yet
+func uptimeCompactedUntil(state database.State) time.Time {
+	if state.NextDBIndex == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(state.NextDBIndex), 0)
+}
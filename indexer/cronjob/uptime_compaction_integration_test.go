@@ -0,0 +1,51 @@
+//go:build integration
+// +build integration
+
+package cronjob
+
+import (
+	"flare-indexer/database"
+	indexerctx "flare-indexer/indexer/context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Two compaction jobs saving the same window to one database, the row lock of
+// the state lets only the first one persist its intervals
+func TestUptimeCompactionConcurrentDB(t *testing.T) {
+	ctx, err := indexerctx.BuildTestContext(uptimeVotingCronjobTestConfig(time.Unix(1000, 0)))
+	require.NoError(t, err)
+	db := &uptimeCompactionDBGorm{g: ctx.DB()}
+
+	samples := []database.UptimeCronjob{
+		uptimeSample(1000, "node1", "a", true),
+		uptimeSample(1010, "node1", "a", false),
+		uptimeSample(1020, "node1", "a", false),
+	}
+	entries := make([]*database.UptimeCronjob, len(samples))
+	for i := range samples {
+		entries[i] = &samples[i]
+	}
+	require.NoError(t, database.CreateUptimeCronjobEntry(ctx.DB(), entries))
+
+	other := &uptimeCompactionCronjob{db: db}
+	racing := &racingUptimeCompactionDB{uptimeCompactionDB: db}
+	racing.before = func() {
+		_, err := other.compact(time.Unix(1025, 0))
+		require.NoError(t, err)
+	}
+	cronjob := &uptimeCompactionCronjob{db: racing}
+
+	compactedUntil, err := cronjob.compact(time.Unix(1025, 0))
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1000, 0), compactedUntil)
+
+	state, err := database.FetchState(ctx.DB(), uptimeCompactionStateName)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1025), state.NextDBIndex)
+	intervals, err := database.FetchNodeUptimeIntervals(ctx.DB(), "node1", time.Unix(0, 0), time.Unix(2000, 0))
+	require.NoError(t, err)
+	require.Len(t, intervals, 2)
+}
@@ -0,0 +1,63 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type uptimeCompactionDBGorm struct {
+	g *gorm.DB
+}
+
+func (db *uptimeCompactionDBGorm) FetchState(name string) (database.State, error) {
+	return database.FetchState(db.g, name)
+}
+
+func (db *uptimeCompactionDBGorm) FetchFirstUptimeTimestamp() (time.Time, error) {
+	return database.FetchFirstUptimeTimestamp(db.g)
+}
+
+func (db *uptimeCompactionDBGorm) FetchUptimeSamples(start, end time.Time) ([]database.UptimeCronjob, error) {
+	return database.FetchUptimeSamples(db.g, start, end)
+}
+
+func (db *uptimeCompactionDBGorm) FetchUptimeErrors(start, end time.Time) ([]database.UptimeCronjob, error) {
+	return database.FetchUptimeErrors(db.g, start, end)
+}
+
+func (db *uptimeCompactionDBGorm) FetchActiveUptimeNodes(since time.Time) ([]string, error) {
+	return database.FetchActiveUptimeNodes(db.g, since)
+}
+
+func (db *uptimeCompactionDBGorm) FetchLastUptimeIntervals(nodeIDs []string) ([]database.UptimeInterval, error) {
+	return database.FetchLastUptimeIntervals(db.g, nodeIDs)
+}
+
+func (db *uptimeCompactionDBGorm) DeleteUptimesBefore(timestamp time.Time) error {
+	return database.DeleteUptimesBefore(db.g, timestamp)
+}
+
+func (db *uptimeCompactionDBGorm) DeleteUptimeIntervalsBefore(timestamp time.Time) error {
+	return database.DeleteUptimeIntervalsBefore(db.g, timestamp)
+}
+
+func (db *uptimeCompactionDBGorm) SaveUptimeIntervals(
+	intervals []*database.UptimeInterval, state *database.State, compactedUntil uint64,
+) (bool, error) {
+	saved := false
+	err := db.g.Transaction(func(tx *gorm.DB) error {
+		// Locked state orders the transactions of concurrent compactions
+		current, err := database.FetchStateForUpdate(tx, state.Name)
+		if err != nil || current.NextDBIndex != compactedUntil {
+			return err
+		}
+		if err := database.PersistUptimeIntervals(tx, intervals); err != nil {
+			return err
+		}
+		saved = true
+		return database.UpdateState(tx, state)
+	})
+	return saved && err == nil, err
+}
@@ -0,0 +1,392 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type uptimeCompactionDBTest struct {
+	state     database.State
+	samples   []database.UptimeCronjob
+	intervals []*database.UptimeInterval
+}
+
+func (db *uptimeCompactionDBTest) FetchState(name string) (database.State, error) {
+	return db.state, nil
+}
+
+func (db *uptimeCompactionDBTest) FetchFirstUptimeTimestamp() (time.Time, error) {
+	var first time.Time
+	for _, s := range db.samples {
+		if first.IsZero() || s.Timestamp.Before(first) {
+			first = s.Timestamp
+		}
+	}
+	return first, nil
+}
+
+func (db *uptimeCompactionDBTest) FetchUptimeSamples(start, end time.Time) ([]database.UptimeCronjob, error) {
+	var samples []database.UptimeCronjob
+	for _, s := range db.samples {
+		if s.NodeID != nil && !s.Timestamp.Before(start) && s.Timestamp.Before(end) {
+			samples = append(samples, s)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		if *samples[i].NodeID != *samples[j].NodeID {
+			return *samples[i].NodeID < *samples[j].NodeID
+		}
+		return samples[i].Timestamp.Before(samples[j].Timestamp)
+	})
+	return samples, nil
+}
+
+func (db *uptimeCompactionDBTest) FetchUptimeErrors(start, end time.Time) ([]database.UptimeCronjob, error) {
+	var samples []database.UptimeCronjob
+	for _, s := range db.samples {
+		if s.NodeID == nil && !s.Timestamp.Before(start) && s.Timestamp.Before(end) {
+			samples = append(samples, s)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Timestamp.Before(samples[j].Timestamp)
+	})
+	return samples, nil
+}
+
+func (db *uptimeCompactionDBTest) FetchActiveUptimeNodes(since time.Time) ([]string, error) {
+	var nodeIDs []string
+	for _, i := range db.intervals {
+		if !i.EndTime.Before(since) {
+			nodeIDs = append(nodeIDs, i.NodeID)
+		}
+	}
+	return nodeIDs, nil
+}
+
+func (db *uptimeCompactionDBTest) FetchLastUptimeIntervals(nodeIDs []string) ([]database.UptimeInterval, error) {
+	last := make(map[string]database.UptimeInterval)
+	for _, i := range db.intervals {
+		last[i.NodeID] = *i
+	}
+	var intervals []database.UptimeInterval
+	for _, nodeID := range nodeIDs {
+		if i, ok := last[nodeID]; ok {
+			intervals = append(intervals, i)
+		}
+	}
+	return intervals, nil
+}
+
+func (db *uptimeCompactionDBTest) DeleteUptimesBefore(timestamp time.Time) error {
+	var samples []database.UptimeCronjob
+	for _, s := range db.samples {
+		if !s.Timestamp.Before(timestamp) {
+			samples = append(samples, s)
+		}
+	}
+	db.samples = samples
+	return nil
+}
+
+func (db *uptimeCompactionDBTest) DeleteUptimeIntervalsBefore(timestamp time.Time) error {
+	var intervals []*database.UptimeInterval
+	for _, i := range db.intervals {
+		if !i.EndTime.Before(timestamp) {
+			intervals = append(intervals, i)
+		}
+	}
+	db.intervals = intervals
+	return nil
+}
+
+func (db *uptimeCompactionDBTest) SaveUptimeIntervals(
+	intervals []*database.UptimeInterval, state *database.State, compactedUntil uint64,
+) (bool, error) {
+	if db.state.NextDBIndex != compactedUntil {
+		return false, nil
+	}
+	for _, i := range intervals {
+		if i.ID == 0 {
+			i.ID = uint64(len(db.intervals) + 1)
+			db.intervals = append(db.intervals, i)
+			continue
+		}
+		for j := range db.intervals {
+			if db.intervals[j].ID == i.ID {
+				db.intervals[j] = i
+			}
+		}
+	}
+	db.state = *state
+	return true, nil
+}
+
+// Database running another compaction right before the first save, as if two
+// instances compacted at the same time
+type racingUptimeCompactionDB struct {
+	uptimeCompactionDB
+	before func()
+}
+
+func (db *racingUptimeCompactionDB) SaveUptimeIntervals(
+	intervals []*database.UptimeInterval, state *database.State, compactedUntil uint64,
+) (bool, error) {
+	if before := db.before; before != nil {
+		db.before = nil
+		before()
+	}
+	return db.uptimeCompactionDB.SaveUptimeIntervals(intervals, state, compactedUntil)
+}
+
+func uptimeSample(ts int64, nodeID string, observer string, connected bool) database.UptimeCronjob {
+	status := database.UptimeCronjobStatusDisconnected
+	if connected {
+		status = database.UptimeCronjobStatusConnected
+	}
+	return database.UptimeCronjob{Timestamp: time.Unix(ts, 0), NodeID: &nodeID, Observer: observer, Status: status}
+}
+
+func TestUptimeCompaction(t *testing.T) {
+	db := &uptimeCompactionDBTest{
+		samples: []database.UptimeCronjob{
+			uptimeSample(1000, "node1", "a", true),
+			uptimeSample(1000, "node1", "b", true),
+			uptimeSample(1010, "node1", "a", true),
+			// Only observer b lost the connection to the node
+			uptimeSample(1010, "node1", "b", false),
+			uptimeSample(1010, "node1", "c", true),
+			uptimeSample(1020, "node1", "a", false),
+			uptimeSample(1020, "node1", "b", false),
+			uptimeSample(1030, "node1", "a", false),
+			uptimeSample(1040, "node1", "a", true),
+			uptimeSample(1010, "node2", "a", false),
+			uptimeSample(1020, "node2", "a", false),
+		},
+	}
+	cronjob := &uptimeCompactionCronjob{db: db}
+
+	// Samples are compacted in two runs
+	compactedUntil, err := cronjob.compact(time.Unix(1025, 0))
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1025, 0), compactedUntil)
+	_, err = cronjob.compact(time.Unix(1045, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1045), db.state.NextDBIndex)
+
+	type interval struct {
+		nodeID     string
+		status     database.UptimeCronjobStatus
+		start, end int64
+	}
+	var intervals []interval
+	for _, i := range db.intervals {
+		intervals = append(intervals, interval{i.NodeID, i.Status, i.StartTime.Unix(), i.EndTime.Unix()})
+	}
+	connected, disconnected := database.UptimeCronjobStatusConnected, database.UptimeCronjobStatusDisconnected
+	require.Equal(t, []interval{
+		{"node1", connected, 1000, 1010},
+		// Extended by the second run
+		{"node1", disconnected, 1010, 1030},
+		{"node2", disconnected, 1010, 1020},
+		{"node1", connected, 1030, 1040},
+	}, intervals)
+
+	var node1 []database.UptimeInterval
+	for _, i := range db.intervals {
+		if i.NodeID == "node1" {
+			node1 = append(node1, *i)
+		}
+	}
+	// Connected after the last sample
+	policy := uptimePolicy{}
+	require.Equal(t, int64(100-20), nodeUptimeFromIntervals(node1, policy, 1000, 1100).connected)
+	require.Equal(t, int64(15-10), nodeUptimeFromIntervals(node1, policy, 1005, 1020).connected)
+
+	// Samples are deleted only after they are compacted
+	cronjob.rawRetention = time.Second
+	cronjob.intervalRetention = 25 * time.Second
+	require.NoError(t, cronjob.deleteOld(time.Unix(1050, 0), time.Unix(1025, 0)))
+	for _, s := range db.samples {
+		require.False(t, s.Timestamp.Before(time.Unix(1025, 0)))
+	}
+	require.Len(t, db.samples, 2)
+	require.Len(t, db.intervals, 2)
+}
+
+func TestUptimeCompactionConcurrent(t *testing.T) {
+	db := &uptimeCompactionDBTest{
+		samples: []database.UptimeCronjob{
+			uptimeSample(1000, "node1", "a", true),
+			uptimeSample(1010, "node1", "a", false),
+			uptimeSample(1020, "node1", "a", false),
+			uptimeSample(1030, "node1", "a", true),
+		},
+	}
+	other := &uptimeCompactionCronjob{db: db}
+	racing := &racingUptimeCompactionDB{uptimeCompactionDB: db}
+	racing.before = func() {
+		_, err := other.compact(time.Unix(1025, 0))
+		require.NoError(t, err)
+	}
+	cronjob := &uptimeCompactionCronjob{db: racing}
+
+	// Window compacted by the other job is not saved again
+	compactedUntil, err := cronjob.compact(time.Unix(1025, 0))
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1000, 0), compactedUntil)
+	require.Equal(t, uint64(1025), db.state.NextDBIndex)
+	require.Len(t, db.intervals, 2)
+
+	// Both jobs continue after the compacted window
+	_, err = cronjob.compact(time.Unix(1035, 0))
+	require.NoError(t, err)
+	_, err = other.compact(time.Unix(1035, 0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1035), db.state.NextDBIndex)
+	require.Len(t, db.intervals, 3)
+	require.Equal(t, time.Unix(1020, 0), db.intervals[1].EndTime)
+	require.Equal(t, time.Unix(1030, 0), db.intervals[2].EndTime)
+}
+
+func TestUptimeCompactionQuorum(t *testing.T) {
+	db := &uptimeCompactionDBTest{
+		samples: []database.UptimeCronjob{
+			uptimeSample(1000, "node1", "a", true),
+			uptimeSample(1010, "node1", "a", true),
+			uptimeSample(1010, "node1", "b", false),
+		},
+	}
+	quorum, err := newUptimeQuorum("all")
+	require.NoError(t, err)
+	cronjob := &uptimeCompactionCronjob{db: db, quorum: quorum}
+
+	_, err = cronjob.compact(time.Unix(1020, 0))
+	require.NoError(t, err)
+	require.Len(t, db.intervals, 2)
+	require.Equal(t, database.UptimeCronjobStatusDisconnected, db.intervals[1].Status)
+}
+
+func uptimeErrorSample(ts int64, observer string, status database.UptimeCronjobStatus) database.UptimeCronjob {
+	return database.UptimeCronjob{Timestamp: time.Unix(ts, 0), Observer: observer, Status: status}
+}
+
+func TestUptimeCompactionErrorsAndGaps(t *testing.T) {
+	db := &uptimeCompactionDBTest{
+		samples: []database.UptimeCronjob{
+			uptimeSample(1000, "node1", "a", true),
+			uptimeSample(1010, "node1", "a", true),
+			// All observers failed
+			uptimeErrorSample(1020, "a", database.UptimeCronjobStatusTimeout),
+			uptimeErrorSample(1030, "a", database.UptimeCronjobStatusTimeout),
+			// Observer a failed, b reported the node
+			uptimeErrorSample(1040, "a", database.UptimeCronjobStatusServiceError),
+			uptimeSample(1040, "node1", "b", true),
+			// Indexer was down
+			uptimeErrorSample(1100, "", database.UptimeCronjobStatusIndexerStarted),
+			uptimeSample(1110, "node1", "a", false),
+		},
+	}
+	cronjob := &uptimeCompactionCronjob{db: db, maxGap: 30 * time.Second}
+	_, err := cronjob.compact(time.Unix(1120, 0))
+	require.NoError(t, err)
+
+	type interval struct {
+		status     database.UptimeCronjobStatus
+		start, end int64
+	}
+	var intervals []interval
+	for _, i := range db.intervals {
+		intervals = append(intervals, interval{i.Status, i.StartTime.Unix(), i.EndTime.Unix()})
+	}
+	require.Equal(t, []interval{
+		{database.UptimeCronjobStatusConnected, 1000, 1010},
+		{database.UptimeCronjobStatusTimeout, 1010, 1030},
+		{database.UptimeCronjobStatusConnected, 1030, 1040},
+		{database.UptimeCronjobStatusGap, 1040, 1100},
+		{database.UptimeCronjobStatusIndexerStarted, 1100, 1100},
+		{database.UptimeCronjobStatusDisconnected, 1100, 1110},
+	}, intervals)
+
+	var stored []database.UptimeInterval
+	for _, i := range db.intervals {
+		stored = append(stored, *i)
+	}
+
+	// Ambiguous time counts as connected by default
+	policy := uptimePolicy{maxGap: 30 * time.Second}
+	require.Equal(t, nodeUptime{connected: 10 + 20 + 10 + 60 + 40, ambiguous: 20 + 60 + 40},
+		nodeUptimeFromIntervals(stored, policy, 1000, 1150))
+
+	policy.treatments = map[database.UptimeCronjobStatus]uptimeTreatment{
+		database.UptimeCronjobStatusTimeout: uptimeCountDisconnected,
+		database.UptimeCronjobStatusGap:     uptimeExcluded,
+	}
+	require.Equal(t, nodeUptime{connected: 10 + 10, ambiguous: 20 + 60 + 40, excluded: 60 + 40},
+		nodeUptimeFromIntervals(stored, policy, 1000, 1150))
+
+	// Time after the last sample shorter than the max gap is connected
+	require.Equal(t, nodeUptime{connected: 20}, nodeUptimeFromIntervals(stored, policy, 1110, 1130))
+}
+
+func TestUptimePolicyConfig(t *testing.T) {
+	cfg := &config.UptimeConfig{
+		CronjobConfig: config.CronjobConfig{Timeout: 10 * time.Second},
+		Policy: config.UptimePolicyConfig{
+			Timeout:    "disconnected",
+			Gap:        "excluded",
+			GapPeriods: 3,
+		},
+	}
+	policy, err := newUptimePolicy(cfg)
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, policy.maxGap)
+	require.Equal(t, uptimeCountDisconnected, policy.treatments[database.UptimeCronjobStatusTimeout])
+	require.Equal(t, uptimeCountConnected, policy.treatments[database.UptimeCronjobStatusServiceError])
+	require.Equal(t, uptimeExcluded, policy.treatments[database.UptimeCronjobStatusGap])
+
+	cfg.Policy.ServiceError = "unknown"
+	_, err = newUptimePolicy(cfg)
+	require.Error(t, err)
+}
+
+func TestUptimeSource(t *testing.T) {
+	sampledOnly := &database.UptimeAggregation{Value: 85, StakingDuration: 100}
+	reportedLow := &database.UptimeAggregation{Value: 85, StakingDuration: 100, ReportedUptime: 0.7, ReportedSamples: 10}
+	reportedHigh := &database.UptimeAggregation{Value: 50, StakingDuration: 100, ReportedUptime: 0.9, ReportedSamples: 10}
+
+	tests := []struct {
+		source      string
+		aggregation *database.UptimeAggregation
+		expected    bool
+	}{
+		{"sampled", reportedLow, true},
+		{"sampled", reportedHigh, false},
+		{"reported", reportedLow, false},
+		{"reported", reportedHigh, true},
+		{"both", reportedLow, false},
+		{"both", reportedHigh, false},
+		// Sampled ratio is used if no uptimes are reported
+		{"reported", sampledOnly, true},
+		{"both", sampledOnly, true},
+	}
+	for _, test := range tests {
+		source, err := parseUptimeSource(test.source)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, source.passed(test.aggregation, 0.8), "%s %+v", test.source, test.aggregation)
+	}
+
+	_, err := parseUptimeSource("observed")
+	require.Error(t, err)
+
+	divergence, ok := reportedLow.ReportedUptimeDivergence()
+	require.True(t, ok)
+	require.InDelta(t, 0.15, divergence, 1e-9)
+	_, ok = sampledOnly.ReportedUptimeDivergence()
+	require.False(t, ok)
+}
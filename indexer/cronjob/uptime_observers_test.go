@@ -0,0 +1,95 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/utils/chain"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type uptimeClientTest struct {
+	validators []*chain.ValidatorStatus
+	status     database.UptimeCronjobStatus
+	err        error
+	now        time.Time
+}
+
+func (c *uptimeClientTest) GetValidatorStatus() ([]*chain.ValidatorStatus, database.UptimeCronjobStatus, error) {
+	return c.validators, c.status, c.err
+}
+
+func (c *uptimeClientTest) Now() time.Time {
+	return c.now
+}
+
+func TestUptimeQuorum(t *testing.T) {
+	tests := []struct {
+		rule      string
+		connected int
+		reported  int
+		expected  bool
+	}{
+		{"majority", 2, 3, true},
+		{"majority", 1, 2, false},
+		{"majority", 1, 1, true},
+		{"any", 1, 3, true},
+		{"any", 0, 3, false},
+		{"all", 2, 3, false},
+		{"all", 3, 3, true},
+		{"2", 2, 3, true},
+		{"2", 1, 3, false},
+		// Fewer observers reported the node than required
+		{"2", 1, 1, true},
+	}
+	for _, test := range tests {
+		quorum, err := newUptimeQuorum(test.rule)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, quorum.connected(test.connected, test.reported),
+			"%d of %d connected with quorum %s", test.connected, test.reported, test.rule)
+	}
+
+	for _, rule := range []string{"0", "-1", "some"} {
+		_, err := newUptimeQuorum(rule)
+		require.Error(t, err, rule)
+	}
+}
+
+func TestObserveUptimes(t *testing.T) {
+	now := time.Unix(1675348249, 0)
+	reportedUptime := 99.5
+	observers := []uptimeObserver{
+		{id: "a", client: &uptimeClientTest{
+			validators: []*chain.ValidatorStatus{{NodeID: "node1", Connected: true, Uptime: &reportedUptime}, {NodeID: "node2", Connected: false}},
+			now:        now,
+		}},
+		{id: "b", client: &uptimeClientTest{status: database.UptimeCronjobStatusTimeout}},
+		{id: "c", client: &uptimeClientTest{err: errors.New("unexpected response")}},
+	}
+
+	entities, err := observeUptimes(observers)
+	require.NoError(t, err)
+	require.Len(t, entities, 4)
+	for _, e := range entities {
+		require.Equal(t, now, e.Timestamp)
+	}
+	require.Equal(t, "a", entities[0].Observer)
+	require.Equal(t, "node1", *entities[0].NodeID)
+	require.Equal(t, database.UptimeCronjobStatusConnected, entities[0].Status)
+	require.Equal(t, reportedUptime, *entities[0].ReportedUptime)
+	require.Nil(t, entities[1].ReportedUptime)
+	require.Equal(t, database.UptimeCronjobStatusDisconnected, entities[1].Status)
+	require.Equal(t, "b", entities[2].Observer)
+	require.Nil(t, entities[2].NodeID)
+	require.Equal(t, database.UptimeCronjobStatusTimeout, entities[2].Status)
+	require.Equal(t, "c", entities[3].Observer)
+	require.Equal(t, database.UptimeCronjobStatusServiceError, entities[3].Status)
+
+	// Nothing is recorded if all observers fail
+	_, err = observeUptimes(observers[2:])
+	require.Error(t, err)
+
+	require.Error(t, checkUptimeObservers([]uptimeObserver{{id: "a"}, {id: "a"}}))
+}
@@ -0,0 +1,171 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"flare-indexer/utils"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Treatment of time with an ambiguous status in the uptime aggregation
+type uptimeTreatment int
+
+const (
+	uptimeCountConnected uptimeTreatment = iota
+	uptimeCountDisconnected
+	uptimeExcluded
+)
+
+func parseUptimeTreatment(s string) (uptimeTreatment, error) {
+	switch s {
+	case "", "connected":
+		return uptimeCountConnected, nil
+	case "disconnected":
+		return uptimeCountDisconnected, nil
+	case "excluded":
+		return uptimeExcluded, nil
+	}
+	return 0, errors.Errorf("invalid uptime policy %q", s)
+}
+
+// Treatment of the error statuses and of gaps in the samples of a node, the
+// zero value counts them as connected
+type uptimePolicy struct {
+	treatments map[database.UptimeCronjobStatus]uptimeTreatment
+
+	// Time without samples longer than this is a gap, 0 if gaps are not
+	// detected
+	maxGap time.Duration
+}
+
+func newUptimePolicy(cfg *config.UptimeConfig) (uptimePolicy, error) {
+	policy := uptimePolicy{
+		treatments: make(map[database.UptimeCronjobStatus]uptimeTreatment),
+		maxGap:     time.Duration(cfg.Policy.GapPeriods) * cfg.Timeout,
+	}
+	for status, s := range map[database.UptimeCronjobStatus]string{
+		database.UptimeCronjobStatusTimeout:        cfg.Policy.Timeout,
+		database.UptimeCronjobStatusServiceError:   cfg.Policy.ServiceError,
+		database.UptimeCronjobStatusIndexerStarted: cfg.Policy.IndexerStarted,
+		database.UptimeCronjobStatusGap:            cfg.Policy.Gap,
+	} {
+		treatment, err := parseUptimeTreatment(s)
+		if err != nil {
+			return uptimePolicy{}, err
+		}
+		policy.treatments[status] = treatment
+	}
+	return policy, nil
+}
+
+// Uptime ratio compared with the uptime threshold
+type uptimeSource int
+
+const (
+	uptimeSourceSampled uptimeSource = iota
+	uptimeSourceReported
+	uptimeSourceBoth
+)
+
+func parseUptimeSource(s string) (uptimeSource, error) {
+	switch s {
+	case "", "sampled":
+		return uptimeSourceSampled, nil
+	case "reported":
+		return uptimeSourceReported, nil
+	case "both":
+		return uptimeSourceBoth, nil
+	}
+	return 0, errors.Errorf("invalid uptime source %q", s)
+}
+
+// Whether the uptime of the aggregation passes the threshold. The sampled
+// ratio is used for the nodes without reported uptimes.
+func (s uptimeSource) passed(a *database.UptimeAggregation, threshold float64) bool {
+	sampled, sampledOk := a.UptimeRatio()
+	sampledPassed := sampledOk && sampled >= threshold
+	reported, ok := a.ReportedUptimeRatio()
+	if !ok {
+		return sampledPassed
+	}
+	switch s {
+	case uptimeSourceReported:
+		return reported >= threshold
+	case uptimeSourceBoth:
+		return sampledPassed && reported >= threshold
+	}
+	return sampledPassed
+}
+
+// Uptime of a node in a time range, in seconds
+type nodeUptime struct {
+	connected int64
+	ambiguous int64
+	excluded  int64
+}
+
+func (u *nodeUptime) add(o nodeUptime) {
+	u.connected += o.connected
+	u.ambiguous += o.ambiguous
+	u.excluded += o.excluded
+}
+
+func (u *nodeUptime) count(p uptimePolicy, status database.UptimeCronjobStatus, duration int64) {
+	switch status {
+	case database.UptimeCronjobStatusConnected:
+		u.connected += duration
+		return
+	case database.UptimeCronjobStatusDisconnected:
+		return
+	}
+	u.ambiguous += duration
+	switch p.treatments[status] {
+	case uptimeCountConnected:
+		u.connected += duration
+	case uptimeExcluded:
+		u.excluded += duration
+	}
+}
+
+// Uptime of the node in the time range from its uptime intervals. Time not
+// covered by an interval, e.g., after the last compacted sample, counts as
+// connected unless it is longer than the max gap.
+func nodeUptimeFromIntervals(
+	intervals []database.UptimeInterval, policy uptimePolicy, startTimestamp int64, endTimestamp int64,
+) nodeUptime {
+	intervals = append([]database.UptimeInterval(nil), intervals...)
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].StartTime.Before(intervals[j].StartTime)
+	})
+
+	var uptime nodeUptime
+	uncovered := func(start, end int64) {
+		if end <= start {
+			return
+		}
+		if policy.maxGap > 0 && time.Duration(end-start)*time.Second > policy.maxGap {
+			uptime.count(policy, database.UptimeCronjobStatusGap, end-start)
+		} else {
+			uptime.connected += end - start
+		}
+	}
+
+	covered := startTimestamp
+	for _, interval := range intervals {
+		start, end := utils.IntervalIntersection(interval.StartTime.Unix(), interval.EndTime.Unix(), startTimestamp, endTimestamp)
+		if end <= start {
+			continue
+		}
+		uncovered(covered, start)
+		start = utils.Max(start, covered)
+		if end > start {
+			uptime.count(policy, interval.Status, end-start)
+			covered = end
+		}
+	}
+	uncovered(covered, endTimestamp)
+	return uptime
+}
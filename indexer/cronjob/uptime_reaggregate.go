@@ -0,0 +1,208 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/indexer/context"
+	"flare-indexer/logger"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Change of the uptime aggregation of a node in an epoch, old or new is nil if
+// the node was not (or is no longer) staking in the epoch
+type uptimeAggregationDiff struct {
+	nodeID string
+	old    *database.UptimeAggregation
+	new    *database.UptimeAggregation
+}
+
+// Recompute the uptime aggregations of the epochs in [from, to] from the
+// uptime intervals, write the differences to the stored aggregations to out
+// and replace the stored aggregations in a single transaction. Votes of the
+// epochs are submitted again if resubmit is set. Only epochs that were already
+// aggregated can be recomputed, since the uptime voting cronjob does not vote
+// for epochs with stored aggregations.
+func ReaggregateUptimes(ctx context.IndexerContext, clients *Clients, from, to int64, resubmit bool, out io.Writer) error {
+	c, err := newUptimeReaggregation(ctx, clients, resubmit)
+	if err != nil {
+		return err
+	}
+	if err := c.checkReaggregationRange(from, to, ctx.Config().UptimeCronjob.Compaction.IntervalRetention); err != nil {
+		return err
+	}
+
+	// Raw samples before this time are deleted (or were not sampled)
+	firstSample, err := database.FetchFirstUptimeTimestamp(c.db)
+	if err != nil {
+		return fmt.Errorf("failed fetching first uptime sample %w", err)
+	}
+
+	epochAggregations := make(map[int64][]*database.UptimeAggregation)
+	var aggregations []*database.UptimeAggregation
+	for epoch := from; epoch <= to; epoch++ {
+		recomputed, err := c.aggregateEpoch(epoch)
+		if err != nil {
+			return err
+		}
+		stored, err := database.FetchEpochUptimeAggregations(c.db, epoch)
+		if err != nil {
+			return fmt.Errorf("failed fetching uptime aggregations %w", err)
+		}
+		if epochStart, _ := c.epochs.GetTimeRange(epoch); firstSample.IsZero() || firstSample.After(epochStart) {
+			keepReportedUptimes(stored, recomputed)
+		}
+		printUptimeAggregationDiffs(out, epoch, diffUptimeAggregations(stored, recomputed), c.uptimeSource, c.uptimeThreshold)
+
+		epochAggregations[epoch] = recomputed
+		aggregations = append(aggregations, recomputed...)
+	}
+
+	if err := database.ReplaceUptimeAggregations(c.db, from, to, aggregations); err != nil {
+		return fmt.Errorf("failed replacing uptime aggregations %w", err)
+	}
+	logger.Info("Replaced uptime aggregations of epochs [%d, %d]", from, to)
+
+	if !resubmit {
+		return nil
+	}
+	for epoch := from; epoch <= to; epoch++ {
+		if err := c.submitVotes(epoch, epochAggregations[epoch]); err != nil {
+			return fmt.Errorf("failed submitting uptime votes for epoch %d %w", epoch, err)
+		}
+		logger.Info("Resubmitted uptime votes for epoch %d", epoch)
+	}
+	return nil
+}
+
+// Uptime voting cronjob used for the reaggregation, it can submit votes only
+// if resubmit is set
+func newUptimeReaggregation(ctx context.IndexerContext, clients *Clients, resubmit bool) (*uptimeVotingCronjob, error) {
+	cfg := ctx.Config().UptimeCronjob
+	if resubmit {
+		if !cfg.Enabled || !cfg.EnableVoting {
+			return nil, errors.New("uptime voting is not enabled, votes cannot be resubmitted")
+		}
+		return NewUptimeVotingCronjob(ctx, clients)
+	}
+
+	policy, err := newUptimePolicy(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	source, err := parseUptimeSource(cfg.UptimeSource)
+	if err != nil {
+		return nil, err
+	}
+	return &uptimeVotingCronjob{
+		epochCronjob:    epochCronjob{epochs: uptimeVotingEpochs(&cfg)},
+		uptimeThreshold: cfg.UptimeThreshold,
+		uptimeSource:    source,
+		policy:          policy,
+		db:              ctx.DB(),
+		time:            clients.time(),
+	}, nil
+}
+
+// Epochs can be recomputed if they are aggregated and their uptime intervals
+// are not deleted yet
+func (c *uptimeVotingCronjob) checkReaggregationRange(from, to int64, intervalRetention time.Duration) error {
+	if from < 0 || to < from {
+		return errors.Errorf("invalid epoch range [%d, %d]", from, to)
+	}
+	next, err := c.nextEpoch()
+	if err != nil {
+		return err
+	}
+	if to >= next {
+		return errors.Errorf("epoch %d is not aggregated yet", to)
+	}
+	if intervalRetention > 0 {
+		epochStart, _ := c.epochs.GetTimeRange(from)
+		if epochStart.Before(c.time.Now().Add(-intervalRetention)) {
+			return errors.Errorf("uptime intervals of epoch %d are deleted", from)
+		}
+	}
+	return nil
+}
+
+// Reported uptimes are aggregated from the raw samples, which are deleted
+// after raw_retention, so the stored reported uptimes are kept for epochs whose
+// samples may be deleted
+func keepReportedUptimes(stored []database.UptimeAggregation, recomputed []*database.UptimeAggregation) {
+	storedByNode := make(map[string]*database.UptimeAggregation, len(stored))
+	for i := range stored {
+		storedByNode[stored[i].NodeID] = &stored[i]
+	}
+	for _, a := range recomputed {
+		if s, ok := storedByNode[a.NodeID]; ok {
+			a.ReportedUptime = s.ReportedUptime
+			a.ReportedSamples = s.ReportedSamples
+		}
+	}
+}
+
+// Differences between the stored and the recomputed aggregations of an epoch,
+// sorted by node id
+func diffUptimeAggregations(stored []database.UptimeAggregation, recomputed []*database.UptimeAggregation) []uptimeAggregationDiff {
+	diffs := make(map[string]*uptimeAggregationDiff)
+	for i := range stored {
+		diffs[stored[i].NodeID] = &uptimeAggregationDiff{nodeID: stored[i].NodeID, old: &stored[i]}
+	}
+	for _, a := range recomputed {
+		if d, ok := diffs[a.NodeID]; ok {
+			d.new = a
+		} else {
+			diffs[a.NodeID] = &uptimeAggregationDiff{nodeID: a.NodeID, new: a}
+		}
+	}
+
+	var result []uptimeAggregationDiff
+	for _, d := range diffs {
+		if d.old != nil && d.new != nil && sameUptimeAggregation(d.old, d.new) {
+			continue
+		}
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].nodeID < result[j].nodeID
+	})
+	return result
+}
+
+func sameUptimeAggregation(a, b *database.UptimeAggregation) bool {
+	return a.Value == b.Value &&
+		a.StakingDuration == b.StakingDuration &&
+		a.AmbiguousDuration == b.AmbiguousDuration &&
+		a.ExcludedDuration == b.ExcludedDuration &&
+		a.ReportedUptime == b.ReportedUptime &&
+		a.ReportedSamples == b.ReportedSamples
+}
+
+func printUptimeAggregationDiffs(out io.Writer, epoch int64, diffs []uptimeAggregationDiff, source uptimeSource, threshold float64) {
+	if len(diffs) == 0 {
+		fmt.Fprintf(out, "epoch %d: no changes\n", epoch)
+		return
+	}
+	fmt.Fprintf(out, "epoch %d: %d changed\n", epoch, len(diffs))
+	for _, d := range diffs {
+		fmt.Fprintf(out, "  %s: %s -> %s\n", d.nodeID,
+			formatUptimeAggregation(d.old, source, threshold), formatUptimeAggregation(d.new, source, threshold))
+	}
+}
+
+func formatUptimeAggregation(a *database.UptimeAggregation, source uptimeSource, threshold float64) string {
+	if a == nil {
+		return "none"
+	}
+	ratio, _ := a.UptimeRatio()
+	s := fmt.Sprintf("value=%d staking=%d ambiguous=%d excluded=%d ratio=%.4f",
+		a.Value, a.StakingDuration, a.AmbiguousDuration, a.ExcludedDuration, ratio)
+	if reported, ok := a.ReportedUptimeRatio(); ok {
+		s += fmt.Sprintf(" reported=%.4f", reported)
+	}
+	return s + fmt.Sprintf(" passed=%t", source.passed(a, threshold))
+}
@@ -0,0 +1,62 @@
+package cronjob
+
+import (
+	"bytes"
+	"flare-indexer/database"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepReportedUptimes(t *testing.T) {
+	stored := []database.UptimeAggregation{
+		{NodeID: "node1", Value: 90, StakingDuration: 90, ReportedUptime: 0.95, ReportedSamples: 9},
+		{NodeID: "node2", Value: 40, StakingDuration: 90, ReportedUptime: 0.5, ReportedSamples: 9},
+	}
+	recomputed := []*database.UptimeAggregation{
+		{NodeID: "node1", Value: 90, StakingDuration: 90},
+		{NodeID: "node3", Value: 10, StakingDuration: 10},
+	}
+
+	keepReportedUptimes(stored, recomputed)
+	require.Equal(t, 0.95, recomputed[0].ReportedUptime)
+	require.Equal(t, int64(9), recomputed[0].ReportedSamples)
+	require.Zero(t, recomputed[1].ReportedSamples)
+
+	// Unchanged aggregation is not a difference
+	diffs := diffUptimeAggregations(stored, recomputed)
+	require.Len(t, diffs, 2)
+	require.Equal(t, "node2", diffs[0].nodeID)
+	require.Equal(t, "node3", diffs[1].nodeID)
+}
+
+func TestDiffUptimeAggregations(t *testing.T) {
+	stored := []database.UptimeAggregation{
+		{NodeID: "node1", Value: 90, StakingDuration: 90},
+		{NodeID: "node2", Value: 40, StakingDuration: 90},
+		{NodeID: "node3", Value: 90, StakingDuration: 90},
+	}
+	recomputed := []*database.UptimeAggregation{
+		{NodeID: "node4", Value: 10, StakingDuration: 10},
+		{NodeID: "node2", Value: 80, StakingDuration: 90, AmbiguousDuration: 40},
+		{NodeID: "node1", Value: 90, StakingDuration: 90},
+	}
+
+	diffs := diffUptimeAggregations(stored, recomputed)
+	require.Len(t, diffs, 3)
+	require.Equal(t, "node2", diffs[0].nodeID)
+	require.Equal(t, int64(40), diffs[0].old.Value)
+	require.Equal(t, int64(80), diffs[0].new.Value)
+	require.Equal(t, "node3", diffs[1].nodeID)
+	require.Nil(t, diffs[1].new)
+	require.Equal(t, "node4", diffs[2].nodeID)
+	require.Nil(t, diffs[2].old)
+
+	var out bytes.Buffer
+	printUptimeAggregationDiffs(&out, 5, diffs[:1], uptimeSourceSampled, 0.8)
+	printUptimeAggregationDiffs(&out, 6, nil, uptimeSourceSampled, 0.8)
+	require.Equal(t, "epoch 5: 1 changed\n"+
+		"  node2: value=40 staking=90 ambiguous=0 excluded=0 ratio=0.4444 passed=false -> "+
+		"value=80 staking=90 ambiguous=40 excluded=0 ratio=0.8889 passed=true\n"+
+		"epoch 6: no changes\n", out.String())
+}
@@ -43,9 +43,9 @@ func createTestUptimeCronjob() (*uptimeCronjob, error) {
 		return nil, err
 	}
 	return &uptimeCronjob{
-		config: ctx.Config().UptimeCronjob,
-		db:     ctx.DB(),
-		client: testUptimeClient,
+		config:    ctx.Config().UptimeCronjob,
+		db:        ctx.DB(),
+		observers: []uptimeObserver{{client: testUptimeClient}},
 	}, nil
 }
 
@@ -3,12 +3,14 @@ package cronjob
 import (
 	globalConfig "flare-indexer/config"
 	"flare-indexer/database"
+	"flare-indexer/indexer/config"
 	"flare-indexer/indexer/context"
 	"flare-indexer/logger"
 	"flare-indexer/utils"
 	"flare-indexer/utils/contracts/voting"
 	"flare-indexer/utils/staking"
 	"fmt"
+	"math"
 	"math/big"
 	"sort"
 	"time"
@@ -16,12 +18,24 @@ import (
 	"github.com/ava-labs/avalanchego/ids"
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"gorm.io/gorm"
 )
 
+const uptimeVotingStateName = "uptime_voting_cronjob"
+
 var (
-	errNoEpochsToAggregate = errors.New("no epochs to aggregate")
+	uptimeReportedDivergenceMax = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "uptime_reported_divergence_max",
+		Help: "Max absolute difference between the sampled and the reported uptime ratio of the nodes in the last aggregated epoch",
+	})
+	uptimeReportedDivergenceMean = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "uptime_reported_divergence_mean",
+		Help: "Mean absolute difference between the sampled and the reported uptime ratio of the nodes in the last aggregated epoch",
+	})
 )
 
 type uptimeVotingCronjob struct {
@@ -37,9 +51,16 @@ type uptimeVotingCronjob struct {
 	deleteOldUptimesEpochThreshold int64
 
 	uptimeThreshold float64
+	uptimeSource    uptimeSource
+
+	// Treatment of error statuses and gaps in the uptime samples
+	policy uptimePolicy
 
 	votingContract *voting.Voting
-	txOpts         *bind.TransactOpts
+	sender         *txSender
+
+	// Votes are recorded instead of submitted if set
+	dryRun *dryRun
 
 	db *gorm.DB
 
@@ -47,45 +68,65 @@ type uptimeVotingCronjob struct {
 	time utils.ShiftedTime
 }
 
-func NewUptimeVotingCronjob(ctx context.IndexerContext) (*uptimeVotingCronjob, error) {
+func NewUptimeVotingCronjob(ctx context.IndexerContext, clients *Clients) (*uptimeVotingCronjob, error) {
 	cfg := ctx.Config()
 
 	if !cfg.UptimeCronjob.Enabled || !cfg.UptimeCronjob.EnableVoting {
 		return &uptimeVotingCronjob{}, nil
 	}
 
-	votingContract, err := newVotingContract(cfg)
+	votingContract, err := newVotingContract(cfg, clients)
 	if err != nil {
 		return nil, err
 	}
 
-	privateKey, err := cfg.Chain.GetPrivateKey()
+	sender, err := clients.txSender(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	txOpts, err := TransactOptsFromPrivateKey(privateKey, cfg.Chain.ChainID)
+	config := ctx.Config().UptimeCronjob
+	policy, err := newUptimePolicy(&config)
 	if err != nil {
 		return nil, err
 	}
+	source, err := parseUptimeSource(config.UptimeSource)
+	if err != nil {
+		return nil, err
+	}
+	var dryRun *dryRun
+	if config.DryRun {
+		dryRun, err = newDryRun(cfg, clients, ctx.DB(), "uptime_voting", cfg.ContractAddresses.Voting)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	config := ctx.Config().UptimeCronjob
 	return &uptimeVotingCronjob{
 		epochCronjob: epochCronjob{
 			enabled: config.EnableVoting,
 			timeout: config.Timeout,
-			epochs:  staking.NewEpochInfo(&globalConfig.EpochConfig{First: config.First}, config.Start.Time, config.Period),
+			epochs:  uptimeVotingEpochs(&config),
+			retry:   newEpochJobRetry(&cfg.EpochJobs),
 		},
 		lastAggregatedEpoch:            -1,
 		deleteOldUptimesEpochThreshold: config.DeleteOldUptimesEpochThreshold,
 		uptimeThreshold:                config.UptimeThreshold,
+		uptimeSource:                   source,
+		policy:                         policy,
 		votingContract:                 votingContract,
-		txOpts:                         txOpts,
+		sender:                         sender,
+		dryRun:                         dryRun,
 		db:                             ctx.DB(),
+		time:                           clients.time(),
 	}, nil
 
 }
 
+func uptimeVotingEpochs(cfg *config.UptimeConfig) staking.EpochInfo {
+	return staking.NewEpochInfo(&globalConfig.EpochConfig{First: cfg.First}, cfg.Start.Time, cfg.Period)
+}
+
 func (c *uptimeVotingCronjob) Name() string {
 	return "uptime_aggregator"
 }
@@ -103,79 +144,102 @@ func (c *uptimeVotingCronjob) OnStart() error {
 }
 
 func (c *uptimeVotingCronjob) Call() error {
-	now := c.time.Now()
-	epochRange, err := c.aggregationRange(now)
+	err := c.runEpochJob(c, &epochJobDBGorm{g: c.db}, &c.time)
 	if err != nil {
-		if err == errNoEpochsToAggregate {
-			return nil
-		}
 		return err
 	}
 
-	var aggregations []*database.UptimeAggregation
-	lastAggregatedEpoch := c.lastAggregatedEpoch
-
-	// Aggregate missing epochs for all nodes
-	for epoch := epochRange.start; epoch <= epochRange.end; epoch++ {
-		nodeAggregations, err := c.aggregateEpoch(epoch)
-		if err != nil {
-			return err
-		}
-
-		// One can submit votes even if they were submitted before, so we do not need to
-		// handle potential errors when persisting the aggregations
-		submitErr := c.submitVotes(epoch, nodeAggregations)
-		if submitErr != nil {
-			logger.Error("Failed submitting uptime votes for epoch %d: %v", epoch, submitErr)
-			break
-		}
-
-		aggregations = append(aggregations, nodeAggregations...)
-		lastAggregatedEpoch = epoch
-		logger.Info("Aggregated uptime for epoch %d", epoch)
+	err = c.deleteOldUptimes()
+	if err != nil {
+		// Error is non-fatal, we only log it
+		logger.Error("Failed deleting old uptimes: %v", err)
 	}
+	return nil
+}
 
-	// Persist all aggregations at once, so we have a complete set of aggregations for each epoch
-	// TODO: at the same time, remove uptimes that are not needed anymore to prevent the database
-	//       from growing too large
-	err = database.PersistUptimeAggregations(c.db, aggregations)
+// Epoch after the last aggregated epoch. The cursor is kept in a state row
+// since epochs without staking nodes persist no aggregations.
+func (c *uptimeVotingCronjob) nextEpoch() (int64, error) {
+	state, err := database.FetchState(c.db, uptimeVotingStateName)
 	if err != nil {
-		return fmt.Errorf("failed persisting uptime aggregations %w", err)
+		return 0, fmt.Errorf("failed fetching uptime voting state %w", err)
 	}
-	c.lastAggregatedEpoch = lastAggregatedEpoch
+	return int64(state.NextDBIndex), nil
+}
 
-	err = c.deleteOldUptimes()
+func (c *uptimeVotingCronjob) lastEpoch(now time.Time) (int64, error) {
+	lastEpochToAggregate := c.epochs.GetEpochIndex(now.Add(-c.delay)) - 1
+
+	// Only epochs whose uptime samples are compacted into intervals can be aggregated
+	compactionState, err := database.FetchState(c.db, uptimeCompactionStateName)
 	if err != nil {
-		// Error is non-fatal, we only log it
-		logger.Error("Failed deleting old uptimes: %v", err)
+		return 0, fmt.Errorf("failed fetching uptime compaction state %w", err)
 	}
-	return nil
+	compactedUntil := uptimeCompactedUntil(compactionState)
+	if compactedUntil.IsZero() {
+		return -1, nil
+	}
+	return utils.Min(lastEpochToAggregate, c.epochs.GetEpochIndex(compactedUntil)-1), nil
 }
 
-func (c *uptimeVotingCronjob) aggregationRange(now time.Time) (*epochRange, error) {
-	currentAggregationEpoch := c.epochs.GetEpochIndex(now.Add(-c.delay))
-	lastEpochToAggregate := currentAggregationEpoch - 1
+// The next epoch is also advanced by runEpoch together with the persisted
+// aggregations, before the votes are submitted
+func (c *uptimeVotingCronjob) setNextEpoch(epoch int64) error {
+	return advanceUptimeVotingState(c.db, epoch)
+}
+
+// Set the next epoch of the uptime voting state, re-runs of earlier epochs do
+// not move it back
+func advanceUptimeVotingState(db *gorm.DB, epoch int64) error {
+	state, err := database.FetchState(db, uptimeVotingStateName)
+	if err != nil {
+		return err
+	}
+	if uint64(epoch) <= state.NextDBIndex {
+		return nil
+	}
+	state.NextDBIndex = uint64(epoch)
+	return database.UpdateState(db, &state)
+}
 
-	// If we are sure that we have aggregated all the epochs up to lastEpochToAggregate, we can skip
-	if lastEpochToAggregate < 0 || lastEpochToAggregate <= c.lastAggregatedEpoch {
-		return nil, errNoEpochsToAggregate
+// Aggregate the epoch for all nodes, replace its stored aggregations and submit
+// the votes. Votes can be submitted again, so a failed vote is retried as a
+// re-run of the epoch, while the next epochs are aggregated. The contract
+// accepts repeated votes, so a vote that was not mined in time is sent again
+// only once it cannot be mined anymore.
+func (c *uptimeVotingCronjob) runEpoch(epoch int64) error {
+	nodeAggregations, err := c.aggregateEpoch(epoch)
+	if err != nil {
+		return err
 	}
+	updateUptimeDivergenceMetrics(nodeAggregations)
 
-	// Last aggregation epoch (epoch of the last persisted aggregation of any node since we
-	// store all epoch aggregations at once)
-	lastAggregation, dbErr := database.FetchLastUptimeAggregation(c.db)
-	if dbErr != nil {
-		return nil, fmt.Errorf("failed fetching last uptime aggregation %w", dbErr)
+	// Next epoch is advanced before submitting the votes, so a failed vote does
+	// not hold back the aggregation of the next epochs
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		if err := database.ReplaceUptimeAggregations(tx, epoch, epoch, nodeAggregations); err != nil {
+			return err
+		}
+		return advanceUptimeVotingState(tx, epoch+1)
+	})
+	if err != nil {
+		return fmt.Errorf("failed persisting uptime aggregations %w", err)
 	}
+	c.lastAggregatedEpoch = utils.Max(c.lastAggregatedEpoch, epoch)
+	logger.Info("Aggregated uptime for epoch %d", epoch)
 
-	var firstEpochToAggregate int64
-	if lastAggregation == nil {
-		firstEpochToAggregate = 0
-	} else {
-		firstEpochToAggregate = int64(lastAggregation.Epoch) + 1
+	sent, err := c.voteSent(epoch)
+	if err != nil {
+		return fmt.Errorf("failed checking earlier uptime vote %w", err)
+	}
+	if sent {
+		logger.Info("Uptime vote for epoch %d was mined after the receipt timeout", epoch)
+		return nil
 	}
-	logger.Debug("Aggregating needed for epochs [%d, %d]", firstEpochToAggregate, lastEpochToAggregate)
-	return c.getTrimmedEpochRange(firstEpochToAggregate, lastEpochToAggregate), nil
+	if err := c.submitVotes(epoch, nodeAggregations); err != nil {
+		return fmt.Errorf("failed submitting uptime votes %w", err)
+	}
+	return nil
 }
 
 func (c *uptimeVotingCronjob) aggregateEpoch(epoch int64) ([]*database.UptimeAggregation, error) {
@@ -214,40 +278,71 @@ func (c *uptimeVotingCronjob) aggregateNode(epoch int64, nodeID string, stakingI
 	})
 
 	epochStart, epochEnd := c.epochs.GetTimeRange(epoch)
-	nodeConnectedTime := int64(0)
+	var uptime nodeUptime
 	stakingDuration := int64(0)
+	reportedSum, reportedCount := 0.0, int64(0)
 	for ; idx < len(stakingIntervals) && stakingIntervals[idx].nodeID == nodeID; idx++ {
 		start, end := utils.IntervalIntersection(stakingIntervals[idx].start, stakingIntervals[idx].end, epochStart.Unix(), epochEnd.Unix())
 		if end <= start {
 			continue
 		}
-		ct, err := aggregateNodeUptime(c.db, nodeID, start, end)
+		u, err := aggregateNodeUptime(c.db, c.policy, nodeID, start, end)
 		if err != nil {
 			return nil, fmt.Errorf("failed aggregating node uptime %w", err)
 		}
-		nodeConnectedTime += ct
+		uptime.add(u)
 		stakingDuration += end - start
+
+		sum, count, err := database.FetchNodeReportedUptimes(c.db, nodeID, time.Unix(start, 0), time.Unix(end, 0))
+		if err != nil {
+			return nil, fmt.Errorf("failed fetching reported node uptimes %w", err)
+		}
+		reportedSum += sum
+		reportedCount += count
+	}
+
+	// Reported uptimes are percentages
+	reportedUptime := 0.0
+	if reportedCount > 0 {
+		reportedUptime = reportedSum / float64(reportedCount) / 100
 	}
 
 	return &database.UptimeAggregation{
-		NodeID:          nodeID,
-		Epoch:           int(epoch),
-		StartTime:       epochStart,
-		EndTime:         epochEnd,
-		Value:           nodeConnectedTime,
-		StakingDuration: stakingDuration,
+		NodeID:            nodeID,
+		Epoch:             int(epoch),
+		StartTime:         epochStart,
+		EndTime:           epochEnd,
+		Value:             uptime.connected,
+		StakingDuration:   stakingDuration,
+		AmbiguousDuration: uptime.ambiguous,
+		ExcludedDuration:  uptime.excluded,
+		ReportedUptime:    reportedUptime,
+		ReportedSamples:   reportedCount,
 	}, nil
 }
 
+// Whether the vote of an earlier attempt that was not mined in time is mined
+// successfully since. Returns ErrTxPending if it can still be mined.
+func (c *uptimeVotingCronjob) voteSent(epoch int64) (bool, error) {
+	if c.dryRun != nil {
+		return false, nil
+	}
+	run, err := database.FetchEpochJobRun(c.db, c.Name(), epoch)
+	if err != nil || run == nil {
+		return false, err
+	}
+	txHashes := runTxHashes(run)
+	if len(txHashes) == 0 {
+		return false, nil
+	}
+	return c.sender.sentStatus(txHashes)
+}
+
 func (c *uptimeVotingCronjob) submitVotes(epoch int64, nodeAggregations []*database.UptimeAggregation) error {
 	nodeIDs := make([][20]byte, 0, len(nodeAggregations))
 	for _, a := range nodeAggregations {
-		if a.StakingDuration == 0 {
-			continue
-		}
-
-		uptimePercent := float64(a.Value) / float64(a.StakingDuration)
-		if uptimePercent < c.uptimeThreshold {
+		// Excluded time counts neither as connected nor as disconnected
+		if !c.uptimeSource.passed(a, c.uptimeThreshold) {
 			continue
 		}
 
@@ -257,10 +352,36 @@ func (c *uptimeVotingCronjob) submitVotes(epoch int64, nodeAggregations []*datab
 		}
 		nodeIDs = append(nodeIDs, nodeID)
 	}
-	_, err := c.votingContract.SubmitValidatorUptimeVote(c.txOpts, big.NewInt(epoch), nodeIDs)
+	if c.dryRun != nil {
+		return c.dryRun.submitUptimeVote(epoch, nodeIDs)
+	}
+	_, err := c.sender.send(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.votingContract.SubmitValidatorUptimeVote(opts, big.NewInt(epoch), nodeIDs)
+	})
 	return err
 }
 
+// Set the divergence metrics from the aggregations of the nodes with both a
+// sampled and a reported uptime
+func updateUptimeDivergenceMetrics(aggregations []*database.UptimeAggregation) {
+	maxDivergence, sum, count := 0.0, 0.0, 0
+	for _, a := range aggregations {
+		divergence, ok := a.ReportedUptimeDivergence()
+		if !ok {
+			continue
+		}
+		divergence = math.Abs(divergence)
+		maxDivergence = math.Max(maxDivergence, divergence)
+		sum += divergence
+		count++
+	}
+	if count == 0 {
+		return
+	}
+	uptimeReportedDivergenceMax.Set(maxDivergence)
+	uptimeReportedDivergenceMean.Set(sum / float64(count))
+}
+
 func (c *uptimeVotingCronjob) deleteOldUptimes() error {
 	if c.deleteOldUptimesEpochThreshold <= 0 {
 		return nil
@@ -309,28 +430,14 @@ func fetchNodeStakingIntervals(db *gorm.DB, start time.Time, end time.Time) ([]n
 
 func aggregateNodeUptime(
 	db *gorm.DB,
+	policy uptimePolicy,
 	nodeID string,
 	startTimestamp int64,
 	endTimestamp int64,
-) (int64, error) {
-	// uptimes are sorted by timestamp
-	uptimes, err := database.FetchNodeUptimes(db, nodeID, time.Unix(startTimestamp, 0), time.Unix(endTimestamp, 0))
+) (nodeUptime, error) {
+	intervals, err := database.FetchNodeUptimeIntervals(db, nodeID, time.Unix(startTimestamp, 0), time.Unix(endTimestamp, 0))
 	if err != nil {
-		return 0, err
-	}
-	connectedTime := int64(0)
-	prev := startTimestamp
-	for _, uptime := range uptimes {
-		curr := uptime.Timestamp.Unix()
-		// Consider all states (connected, errors) as connected
-		if uptime.Status != database.UptimeCronjobStatusDisconnected {
-			connectedTime += curr - prev
-		}
-		prev = curr
-	}
-	if prev < endTimestamp {
-		// Assume that the node is connected until the end of the epoch
-		connectedTime += endTimestamp - prev
+		return nodeUptime{}, err
 	}
-	return connectedTime, nil
+	return nodeUptimeFromIntervals(intervals, policy, startTimestamp, endTimestamp), nil
 }
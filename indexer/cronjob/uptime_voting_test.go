@@ -4,19 +4,21 @@
 package cronjob
 
 import (
+	"context"
 	globalConfig "flare-indexer/config"
 	"flare-indexer/database"
 	"flare-indexer/indexer/config"
-	"flare-indexer/indexer/context"
+	indexerctx "flare-indexer/indexer/context"
 	"flare-indexer/indexer/pchain"
 	"flare-indexer/indexer/shared"
 	"flare-indexer/utils"
+	"flare-indexer/utils/contracts/simulated"
+	"flare-indexer/utils/staking"
 	"sort"
 	"testing"
 	"time"
 
 	"github.com/bradleyjkemp/cupaloy"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,26 +27,18 @@ func uptimeVotingCronjobTestConfig(epochStart time.Time) *config.Config {
 	cfg := &config.Config{
 		Chain: globalConfig.ChainConfig{
 			ChainAddressHRP: "localflare",
-			ChainID:         31337,
-			EthRPCURL:       "http://127.0.0.1:8545",
-			PrivateKey:      "0xd49743deccbccc5dc7baa8e69e5be03298da8688a15dd202e20f15d5e0e9a9fb",
+			ChainID:         simulated.ChainID,
 		},
 		UptimeCronjob: config.UptimeConfig{
 			CronjobConfig: config.CronjobConfig{
 				Enabled: true,
 				Timeout: 30 * time.Second,
 			},
-			EpochConfig: globalConfig.EpochConfig{
-				Start:  utils.Timestamp{Time: epochStart},
-				Period: 90 * time.Second,
-			},
-			VotingInterval:  60 * time.Second,
+			Start:           utils.Timestamp{Time: epochStart},
+			Period:          90 * time.Second,
 			EnableVoting:    true,
 			UptimeThreshold: 0.8,
 		},
-		VotingCronjob: config.VotingConfig{
-			ContractAddress: common.HexToAddress("0x7c2C195CD6D34B8F845992d380aADB2730bB9C6F"),
-		},
 		PChainIndexer: config.IndexerConfig{
 			Enabled:    true,
 			Timeout:    3000 * time.Millisecond,
@@ -64,14 +58,24 @@ func uptimeVotingCronjobTestConfig(epochStart time.Time) *config.Config {
 
 }
 
-func createTestUptimeVotingCronjob(epochStart time.Time) (*uptimeVotingCronjob, *shared.ChainIndexerBase, error) {
-	ctx, err := context.BuildTestContext(uptimeVotingCronjobTestConfig(epochStart))
+// Uptime votes are submitted to the voting contract deployed on the simulated chain
+func createTestUptimeVotingCronjob(chain *simulatedChain, epochStart time.Time) (*uptimeVotingCronjob, *shared.ChainIndexerBase, error) {
+	ctx, err := indexerctx.BuildTestContext(uptimeVotingCronjobTestConfig(epochStart))
 	if err != nil {
 		return nil, nil, err
 	}
-	cronjob, err := NewUptimeVotingCronjob(ctx)
-	if err != nil {
-		return nil, nil, err
+	config := ctx.Config().UptimeCronjob
+	cronjob := &uptimeVotingCronjob{
+		epochCronjob: epochCronjob{
+			enabled: config.EnableVoting,
+			timeout: config.Timeout,
+			epochs:  staking.NewEpochInfo(&globalConfig.EpochConfig{First: config.First}, config.Start.Time, config.Period),
+		},
+		lastAggregatedEpoch: -1,
+		uptimeThreshold:     config.UptimeThreshold,
+		votingContract:      chain.contracts.Voting,
+		sender:              chain.sender(0),
+		db:                  ctx.DB(),
 	}
 
 	indexer := &shared.ChainIndexerBase{
@@ -85,14 +89,12 @@ func createTestUptimeVotingCronjob(epochStart time.Time) (*uptimeVotingCronjob,
 	return cronjob, indexer, nil
 }
 
-// Requires a running hardhat node
-// from the flare-smart-contracts project, branch origin/staking-tests
-// with yarn staking_test
 func TestUptimeVoting(t *testing.T) {
 	now := time.Unix(1675348249, 0)
+	chain := newSimulatedChain(t, 1, now, 90*time.Second)
 
 	// Epoch starts "now"
-	votingCronjob, indexer, err := createTestUptimeVotingCronjob(now)
+	votingCronjob, indexer, err := createTestUptimeVotingCronjob(chain, now)
 	require.NoError(t, err)
 
 	uptimeCronjob, err := createTestUptimeCronjob()
@@ -102,17 +104,27 @@ func TestUptimeVoting(t *testing.T) {
 	err = indexer.IndexBatch()
 	require.NoError(t, err)
 
+	compactionCronjob := &uptimeCompactionCronjob{
+		enabled: true,
+		db:      &uptimeCompactionDBGorm{g: votingCronjob.db},
+	}
+
 	testUptimeClient.SetNow(now)
 	votingCronjob.time.SetNow(now)
+	compactionCronjob.time.SetNow(now)
 	for i := 0; i < 10; i++ {
 		if err := uptimeCronjob.Call(); err != nil {
 			t.Fatal(err)
 		}
+		if err := compactionCronjob.Call(); err != nil {
+			t.Fatal(err)
+		}
 		if err := votingCronjob.Call(); err != nil {
 			t.Fatal(err)
 		}
 		testUptimeClient.Time.AdvanceNow(10 * time.Second)
 		votingCronjob.time.AdvanceNow(10 * time.Second)
+		compactionCronjob.time.AdvanceNow(10 * time.Second)
 	}
 	aggr, err := database.FetchAggregations(votingCronjob.db)
 	require.NoError(t, err)
@@ -130,3 +142,80 @@ func TestUptimeVoting(t *testing.T) {
 	})
 	cupaloy.SnapshotT(t, aggrNodeIDs, aggrValue)
 }
+
+// Epochs without staking nodes persist no aggregations, their (empty) votes
+// are still submitted exactly once
+func TestUptimeVotingNoNodes(t *testing.T) {
+	now := time.Unix(1675348249, 0)
+	chain := newSimulatedChain(t, 1, now, 90*time.Second)
+
+	votingCronjob, _, err := createTestUptimeVotingCronjob(chain, now)
+	require.NoError(t, err)
+	backend := &droppingBackend{Backend: chain.backend}
+	votingCronjob.sender = newTxSender(backend, chain.voters[0], &simulatedTxConfig)
+
+	// Uptimes are compacted until after epoch 2
+	later := now.Add(300 * time.Second)
+	compactionState, err := database.FetchState(votingCronjob.db, uptimeCompactionStateName)
+	require.NoError(t, err)
+	compactionState.NextDBIndex = uint64(later.Unix())
+	require.NoError(t, database.UpdateState(votingCronjob.db, &compactionState))
+
+	votingCronjob.time.SetNow(later)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, votingCronjob.Call())
+	}
+	require.Len(t, backend.sent, 3)
+
+	aggr, err := database.FetchAggregations(votingCronjob.db)
+	require.NoError(t, err)
+	require.Empty(t, aggr)
+
+	state, err := database.FetchState(votingCronjob.db, uptimeVotingStateName)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), state.NextDBIndex)
+}
+
+// Vote that was not mined in time is not sent again while it can still be
+// mined and after it is mined
+func TestUptimeVotingNotMined(t *testing.T) {
+	now := time.Unix(1675348249, 0)
+	chain := newSimulatedChain(t, 1, now, 90*time.Second)
+
+	votingCronjob, _, err := createTestUptimeVotingCronjob(chain, now)
+	require.NoError(t, err)
+	backend := &droppingBackend{Backend: chain.backend, drop: 2}
+	cfg := simulatedTxConfig
+	cfg.ReceiptTimeout = 20 * time.Millisecond
+	votingCronjob.sender = newTxSender(backend, chain.voters[0], &cfg)
+
+	// Uptimes are compacted until after epoch 0
+	later := now.Add(100 * time.Second)
+	compactionState, err := database.FetchState(votingCronjob.db, uptimeCompactionStateName)
+	require.NoError(t, err)
+	compactionState.NextDBIndex = uint64(later.Unix())
+	require.NoError(t, database.UpdateState(votingCronjob.db, &compactionState))
+	votingCronjob.time.SetNow(later)
+
+	require.NoError(t, votingCronjob.Call())
+	require.Len(t, backend.sent, 2)
+	run, err := database.FetchEpochJobRun(votingCronjob.db, votingCronjob.Name(), 0)
+	require.NoError(t, err)
+	require.Equal(t, database.EpochJobRunFailed, run.Status)
+	require.Len(t, runTxHashes(run), 2)
+
+	backend.pending = true
+	require.NoError(t, votingCronjob.Call())
+	require.Len(t, backend.sent, 2)
+	run, err = database.FetchEpochJobRun(votingCronjob.db, votingCronjob.Name(), 0)
+	require.NoError(t, err)
+	require.Contains(t, run.Error, ErrTxPending.Error())
+
+	require.NoError(t, chain.backend.SendTransaction(context.Background(), backend.sent[1]))
+	require.NoError(t, votingCronjob.Call())
+	require.Len(t, backend.sent, 2)
+	run, err = database.FetchEpochJobRun(votingCronjob.db, votingCronjob.Name(), 0)
+	require.NoError(t, err)
+	require.Equal(t, database.EpochJobRunSucceeded, run.Status)
+	require.Empty(t, run.TxHashes)
+}
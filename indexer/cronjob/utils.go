@@ -1,33 +1,18 @@
 package cronjob
 
 import (
-	"math/big"
+	"flare-indexer/indexer/config"
+	"flare-indexer/utils/signer"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/pkg/errors"
 )
 
-func TransactOptsFromPrivateKey(privateKey string, chainID int) (*bind.TransactOpts, error) {
-	if len(privateKey) < 2 {
-		return nil, errors.New("privateKey is too short")
-	}
-
-	if privateKey[:2] == "0x" {
-		privateKey = privateKey[2:]
-	}
-
-	pk, err := crypto.HexToECDSA(privateKey)
-	if err != nil {
-		return nil, errors.Wrap(err, "crypto.HexToECDSA")
-	}
-
-	opts, err := bind.NewKeyedTransactorWithChainID(
-		pk, big.NewInt(int64(chainID)),
-	)
+// Create transact options using the configured signer
+func newTransactOpts(cfg *config.Config) (*bind.TransactOpts, error) {
+	s, err := signer.New(&cfg.Signer, &cfg.Chain)
 	if err != nil {
-		return nil, errors.Wrap(err, "bind.NewKeyedTransactorWithChainID")
+		return nil, errors.Wrap(err, "signer.New")
 	}
-	// bind.N
-	return opts, nil
+	return signer.TransactOpts(s, cfg.Chain.ChainID), nil
 }
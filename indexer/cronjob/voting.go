@@ -10,8 +10,6 @@ import (
 	"math/big"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/pkg/errors"
 )
 
@@ -20,9 +18,6 @@ const (
 )
 
 var (
-	zeroBytes     [32]byte    = [32]byte{}
-	zeroBytesHash common.Hash = crypto.Keccak256Hash(zeroBytes[:])
-
 	ErrEpochConfig = errors.New("epoch config mismatch")
 )
 
@@ -32,14 +27,19 @@ type votingCronjob struct {
 	db       votingDB
 	contract votingContract
 
+	// Votes are recorded instead of submitted if set
+	dryRun *dryRun
+
 	// For testing to set "now" to some past date
 	time utils.ShiftedTime
 }
 
 type votingDB interface {
+	epochJobDB
 	FetchState(name string) (database.State, error)
 	FetchPChainVotingData(start, end time.Time) ([]database.PChainTxData, error)
 	UpdateState(state *database.State) error
+	SaveEpochMerkleTree(tree *database.EpochMerkleTree) error
 }
 
 type votingContract interface {
@@ -48,29 +48,37 @@ type votingContract interface {
 	EpochConfig() (time.Time, time.Duration, error)
 }
 
-func NewVotingCronjob(ctx indexerctx.IndexerContext) (*votingCronjob, error) {
+func NewVotingCronjob(ctx indexerctx.IndexerContext, clients *Clients) (*votingCronjob, error) {
 	cfg := ctx.Config()
 	if !cfg.VotingCronjob.Enabled {
 		return &votingCronjob{}, nil
 	}
 
 	db := &votingDBGorm{g: ctx.DB()}
-	contract, err := newVotingContractCChain(cfg)
+	contract, err := newVotingContractCChain(cfg, clients)
 	if err != nil {
 		return nil, err
 	}
 
-	start, period, err := contract.EpochConfig()
+	schedule, err := clients.epochSchedule(cfg, ctx.DB())
 	if err != nil {
 		return nil, err
 	}
-
-	epochs := staking.NewEpochInfo(&cfg.VotingCronjob.EpochConfig, start, period)
+	epochs := staking.NewScheduledEpochInfo(&cfg.VotingCronjob.EpochConfig, schedule)
 
 	vc := &votingCronjob{
 		epochCronjob: newEpochCronjob(&cfg.VotingCronjob.CronjobConfig, epochs),
 		db:           db,
 		contract:     contract,
+		time:         clients.time(),
+	}
+	vc.retry = newEpochJobRetry(&cfg.EpochJobs)
+
+	if cfg.VotingCronjob.DryRun {
+		vc.dryRun, err = newDryRun(cfg, clients, ctx.DB(), votingStateName, cfg.ContractAddresses.Voting)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	err = vc.reset(ctx.Flags().ResetVotingCronjob)
@@ -89,69 +97,80 @@ func (c *votingCronjob) OnStart() error {
 }
 
 func (c *votingCronjob) Call() error {
-	idxState, err := c.db.FetchState(pchain.StateName)
-	if err != nil {
-		return err
-	}
+	return c.runEpochJob(c, c.db, &c.time)
+}
 
+func (c *votingCronjob) nextEpoch() (int64, error) {
 	state, err := c.db.FetchState(votingStateName)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return int64(state.NextDBIndex), nil
+}
 
-	now := c.time.Now()
-
-	// Last epoch that was submitted to the contract
-	epochRange := c.getEpochRange(int64(state.NextDBIndex), now)
-	logger.Debug("Voting needed for epochs [%d, %d]", epochRange.start, epochRange.end)
-	for e := epochRange.start; e <= epochRange.end; e++ {
-		start, end := c.epochs.GetTimeRange(e)
-
-		if c.indexerBehind(&idxState, e) {
-			logger.Debug("indexer is behind, skipping voting for epoch %d", e)
-			return nil
-		}
-
-		votingData, err := c.db.FetchPChainVotingData(start, end)
-		if err != nil {
-			return err
-		}
-		err = c.submitVotes(e, votingData)
-		if err != nil {
-			return err
-		}
-		logger.Info("Submitted votes for epoch %d", e)
+func (c *votingCronjob) lastEpoch(now time.Time) (int64, error) {
+	return c.epochs.GetEpochIndex(now) - 1, nil
+}
 
-		// Update state
-		state.NextDBIndex = uint64(e + 1)
-		if err := c.db.UpdateState(&state); err != nil {
-			return err
-		}
+func (c *votingCronjob) setNextEpoch(epoch int64) error {
+	state, err := c.db.FetchState(votingStateName)
+	if err != nil {
+		return err
 	}
-	return nil
+	state.NextDBIndex = uint64(epoch)
+	return c.db.UpdateState(&state)
 }
 
-func (c *votingCronjob) submitVotes(e int64, votingData []database.PChainTxData) error {
-	votingData = staking.DedupeTxs(votingData)
+func (c *votingCronjob) runEpoch(e int64) error {
+	idxState, err := c.db.FetchState(pchain.StateName)
+	if err != nil {
+		return err
+	}
+	if c.indexerBehind(&idxState, e) {
+		return errors.Wrap(errEpochSkipped, "indexer is behind")
+	}
 
-	shouldVote, err := c.contract.ShouldVote(big.NewInt(e))
+	start, end := c.epochs.GetTimeRange(e)
+	votingData, err := c.db.FetchPChainVotingData(start, end)
 	if err != nil {
 		return err
 	}
-	if !shouldVote {
-		return nil
+	tree, err := staking.NewEpochTree(e, votingData)
+	if err != nil {
+		return err
+	}
+	err = c.submitVotes(e, tree)
+	if err != nil {
+		return err
 	}
+	logger.Info("Submitted votes for epoch %d", e)
 
-	var merkleRoot common.Hash
-	if len(votingData) == 0 {
-		merkleRoot = zeroBytesHash
-	} else {
-		merkleRoot, err = staking.GetMerkleRoot(votingData)
+	// Store the tree for serving proofs
+	treeEntity, err := tree.Entity(c.time.Now())
+	if err != nil {
+		return err
+	}
+	return c.db.SaveEpochMerkleTree(treeEntity)
+}
+
+func (c *votingCronjob) submitVotes(e int64, tree *staking.EpochTree) error {
+	// Account in dry-run mode need not be a voter yet, so the vote is always
+	// recorded
+	if c.dryRun == nil {
+		shouldVote, err := c.contract.ShouldVote(big.NewInt(e))
 		if err != nil {
 			return err
 		}
+		if !shouldVote {
+			return nil
+		}
+	}
+
+	merkleRoot := tree.Root()
+	if c.dryRun != nil {
+		return c.dryRun.submitVote(e, merkleRoot)
 	}
-	err = c.contract.SubmitVote(big.NewInt(e), [32]byte(merkleRoot))
+	err := c.contract.SubmitVote(big.NewInt(e), [32]byte(merkleRoot))
 	return err
 }
 
@@ -0,0 +1,125 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	indexerctx "flare-indexer/indexer/context"
+	"flare-indexer/logger"
+	"flare-indexer/utils"
+	"time"
+)
+
+const votingEventsStateName = "voting_events"
+
+// Indexer of the voting contract logs. Votes, finalized merkle roots and
+// uptime votes are persisted, the state keeps the next c-chain block to index.
+type votingEventsCronjob struct {
+	enabled bool
+	timeout time.Duration
+
+	db       votingEventsDB
+	contract votingEventsContract
+
+	// First block to index and number of blocks queried for logs at once
+	startBlock uint64
+	batchSize  uint64
+}
+
+type votingEventsDB interface {
+	FetchState(name string) (database.State, error)
+
+	// Persist the events and the state in a single transaction
+	SaveVotingEvents(events *votingEvents, state *database.State) error
+}
+
+type votingEventsContract interface {
+	LastBlock() (uint64, error)
+
+	// Fetch events of the voting contract in blocks [from, to]
+	FetchEvents(from, to uint64) (*votingEvents, error)
+}
+
+// Events of the voting contract in a range of blocks
+type votingEvents struct {
+	votes       []*database.VotingVote
+	finalized   []*database.VotingFinalized
+	uptimeVotes []*database.VotingUptimeVote
+}
+
+func NewVotingEventsCronjob(ctx indexerctx.IndexerContext, clients *Clients) (Cronjob, error) {
+	cfg := ctx.Config()
+
+	if !cfg.VotingEvents.Enabled {
+		return &votingEventsCronjob{}, nil
+	}
+
+	contract, err := newVotingEventsContractCChain(cfg, clients)
+	if err != nil {
+		return nil, err
+	}
+
+	return &votingEventsCronjob{
+		enabled:    cfg.VotingEvents.Enabled,
+		timeout:    cfg.VotingEvents.Timeout,
+		db:         newVotingEventsDBGorm(ctx.DB()),
+		contract:   contract,
+		startBlock: cfg.VotingEvents.StartIndex,
+		batchSize:  uint64(utils.Max(cfg.VotingEvents.BatchSize, 1)),
+	}, nil
+}
+
+func (c *votingEventsCronjob) Name() string {
+	return "voting_events"
+}
+
+func (c *votingEventsCronjob) Enabled() bool {
+	return c.enabled
+}
+
+func (c *votingEventsCronjob) Timeout() time.Duration {
+	return c.timeout
+}
+
+func (c *votingEventsCronjob) OnStart() error {
+	return nil
+}
+
+// Index all blocks up to the last block, each batch of blocks is persisted
+// separately
+func (c *votingEventsCronjob) Call() error {
+	state, err := c.db.FetchState(votingEventsStateName)
+	if err != nil {
+		return err
+	}
+
+	lastBlock, err := c.contract.LastBlock()
+	if err != nil {
+		return err
+	}
+
+	next := utils.Max(state.NextDBIndex, c.startBlock)
+	if next > lastBlock {
+		logger.Debug("no new blocks with voting events, last block %d", lastBlock)
+
+		// Update time of last run (for other clients to know that the indexer is running)
+		state.Update(next, lastBlock)
+		return c.db.SaveVotingEvents(&votingEvents{}, &state)
+	}
+
+	for next <= lastBlock {
+		end := utils.Min(next+c.batchSize-1, lastBlock)
+		events, err := c.contract.FetchEvents(next, end)
+		if err != nil {
+			return err
+		}
+
+		state.Update(end+1, lastBlock)
+		if err := c.db.SaveVotingEvents(events, &state); err != nil {
+			return err
+		}
+		logger.Debug("indexed voting events in blocks %d-%d: %d votes, %d finalized epochs, %d uptime votes",
+			next, end, len(events.votes), len(events.finalized), len(events.uptimeVotes))
+
+		next = end + 1
+	}
+	return nil
+}
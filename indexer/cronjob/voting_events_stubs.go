@@ -0,0 +1,168 @@
+// Stubs for the voting events cronjob. These handle the direct interactions
+// with DB and the c-chain. The actual logic is in voting_events.go.
+package cronjob
+
+import (
+	"context"
+	"flare-indexer/database"
+	"flare-indexer/indexer/config"
+	"flare-indexer/utils/contracts/voting"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type votingEventsDBGorm struct {
+	db *gorm.DB
+}
+
+func newVotingEventsDBGorm(db *gorm.DB) votingEventsDB {
+	return votingEventsDBGorm{db: db}
+}
+
+func (m votingEventsDBGorm) FetchState(name string) (database.State, error) {
+	return database.FetchState(m.db, name)
+}
+
+func (m votingEventsDBGorm) SaveVotingEvents(events *votingEvents, state *database.State) error {
+	return database.DoInTransaction(m.db,
+		func(db *gorm.DB) error {
+			return database.CreateVotingEvents(db, events.votes, events.finalized, events.uptimeVotes)
+		},
+		func(db *gorm.DB) error { return database.UpdateState(db, state) },
+	)
+}
+
+type votingEventsContractCChain struct {
+	backend EthBackend
+	address common.Address
+	voting  *voting.Voting
+
+	// Topics of the indexed events
+	voteTopic       common.Hash
+	finalizedTopic  common.Hash
+	uptimeVoteTopic common.Hash
+}
+
+func newVotingEventsContractCChain(cfg *config.Config, clients *Clients) (*votingEventsContractCChain, error) {
+	if cfg.ContractAddresses.Voting == (common.Address{}) {
+		return nil, errors.New("voting contract address not set")
+	}
+
+	eth, err := clients.ethBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newVotingEventsContract(eth, cfg.ContractAddresses.Voting)
+}
+
+func newVotingEventsContract(backend EthBackend, address common.Address) (*votingEventsContractCChain, error) {
+	votingContract, err := voting.NewVoting(address, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	votingABI, err := voting.VotingMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	return &votingEventsContractCChain{
+		backend:         backend,
+		address:         address,
+		voting:          votingContract,
+		voteTopic:       votingABI.Events["PChainStakeMirrorVoteSubmitted"].ID,
+		finalizedTopic:  votingABI.Events["PChainStakeMirrorVotingFinalized"].ID,
+		uptimeVoteTopic: votingABI.Events["PChainStakeMirrorValidatorUptimeVoteSubmitted"].ID,
+	}, nil
+}
+
+func (c *votingEventsContractCChain) LastBlock() (uint64, error) {
+	header, err := c.backend.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "HeaderByNumber")
+	}
+	return header.Number.Uint64(), nil
+}
+
+func (c *votingEventsContractCChain) FetchEvents(from, to uint64) (*votingEvents, error) {
+	logs, err := c.backend.FilterLogs(context.Background(), ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{c.address},
+		Topics:    [][]common.Hash{{c.voteTopic, c.finalizedTopic, c.uptimeVoteTopic}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "FilterLogs")
+	}
+
+	events := &votingEvents{}
+	for _, log := range logs {
+		if log.Removed || len(log.Topics) == 0 {
+			continue
+		}
+		if err := c.addEvent(events, log); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func (c *votingEventsContractCChain) addEvent(events *votingEvents, log types.Log) error {
+	switch log.Topics[0] {
+	case c.voteTopic:
+		vote, err := c.voting.ParsePChainStakeMirrorVoteSubmitted(log)
+		if err != nil {
+			return err
+		}
+		events.votes = append(events.votes, &database.VotingVote{
+			ContractLog: contractLog(&log),
+			Epoch:       vote.EpochId.Int64(),
+			Voter:       vote.Voter.Hex(),
+			MerkleRoot:  hexutil.Encode(vote.MerkleRoot[:]),
+		})
+	case c.finalizedTopic:
+		finalized, err := c.voting.ParsePChainStakeMirrorVotingFinalized(log)
+		if err != nil {
+			return err
+		}
+		events.finalized = append(events.finalized, &database.VotingFinalized{
+			ContractLog: contractLog(&log),
+			Epoch:       finalized.EpochId.Int64(),
+			MerkleRoot:  hexutil.Encode(finalized.MerkleRoot[:]),
+		})
+	case c.uptimeVoteTopic:
+		vote, err := c.voting.ParsePChainStakeMirrorValidatorUptimeVoteSubmitted(log)
+		if err != nil {
+			return err
+		}
+		nodeIDs := make([]string, len(vote.NodeIds))
+		for i, nodeID := range vote.NodeIds {
+			nodeIDs[i] = ids.NodeID(nodeID).String()
+		}
+		events.uptimeVotes = append(events.uptimeVotes, &database.VotingUptimeVote{
+			ContractLog: contractLog(&log),
+			RewardEpoch: vote.RewardEpochId.Int64(),
+			Timestamp:   time.Unix(vote.Timestamp.Int64(), 0).UTC(),
+			Voter:       vote.Voter.Hex(),
+			NodeIDs:     strings.Join(nodeIDs, ","),
+		})
+	}
+	return nil
+}
+
+func contractLog(log *types.Log) database.ContractLog {
+	return database.ContractLog{
+		BlockNumber: log.BlockNumber,
+		TxHash:      log.TxHash.Hex(),
+		LogIndex:    log.Index,
+	}
+}
@@ -0,0 +1,126 @@
+package cronjob
+
+import (
+	"flare-indexer/database"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+type votingEventsTestDB struct {
+	state  database.State
+	events votingEvents
+}
+
+func (db *votingEventsTestDB) FetchState(name string) (database.State, error) {
+	return db.state, nil
+}
+
+func (db *votingEventsTestDB) SaveVotingEvents(events *votingEvents, state *database.State) error {
+	db.events.votes = append(db.events.votes, events.votes...)
+	db.events.finalized = append(db.events.finalized, events.finalized...)
+	db.events.uptimeVotes = append(db.events.uptimeVotes, events.uptimeVotes...)
+	db.state = *state
+	return nil
+}
+
+func newTestVotingEventsCronjob(t *testing.T, chain *simulatedChain, db votingEventsDB) *votingEventsCronjob {
+	contract, err := newVotingEventsContract(chain.backend, chain.contracts.VotingAddress)
+	require.NoError(t, err)
+
+	return &votingEventsCronjob{
+		enabled:   true,
+		db:        db,
+		contract:  contract,
+		batchSize: 2,
+	}
+}
+
+func TestVotingEvents(t *testing.T) {
+	chain := newSimulatedChain(t, 2, time.Unix(1000, 0), 90*time.Second)
+	db := &votingEventsTestDB{}
+	cronjob := newTestVotingEventsCronjob(t, chain, db)
+
+	root := [32]byte{1, 2, 3}
+	otherRoot := [32]byte{4, 5, 6}
+	_, err := chain.contracts.Voting.SubmitVote(chain.voters[0], big.NewInt(2), otherRoot)
+	require.NoError(t, err)
+	_, err = chain.contracts.Voting.SubmitVote(chain.voters[1], big.NewInt(3), root)
+	require.NoError(t, err)
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.Len(t, db.events.votes, 2)
+	require.Empty(t, db.events.finalized)
+
+	lastBlock, err := cronjob.contract.LastBlock()
+	require.NoError(t, err)
+	require.Equal(t, lastBlock+1, db.state.NextDBIndex)
+	require.Equal(t, lastBlock, db.state.LastChainIndex)
+
+	// Vote of the first voter for the same root finalizes the epoch, already
+	// indexed blocks are not indexed again
+	_, err = chain.contracts.Voting.SubmitVote(chain.voters[0], big.NewInt(3), root)
+	require.NoError(t, err)
+	nodeID := ids.GenerateTestNodeID()
+	_, err = chain.contracts.Voting.SubmitValidatorUptimeVote(chain.voters[1], big.NewInt(7), [][20]byte{nodeID})
+	require.NoError(t, err)
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+
+	require.Len(t, db.events.votes, 3)
+	votes := db.events.votes
+	require.Equal(t, int64(2), votes[0].Epoch)
+	require.Equal(t, chain.voters[0].From.Hex(), votes[0].Voter)
+	require.Equal(t, hexutil.Encode(otherRoot[:]), votes[0].MerkleRoot)
+	require.Equal(t, chain.voters[1].From.Hex(), votes[1].Voter)
+	require.Equal(t, hexutil.Encode(root[:]), votes[1].MerkleRoot)
+	require.Equal(t, int64(3), votes[2].Epoch)
+	require.Equal(t, chain.voters[0].From.Hex(), votes[2].Voter)
+	require.Equal(t, hexutil.Encode(root[:]), votes[2].MerkleRoot)
+	require.NotEmpty(t, votes[2].TxHash)
+	require.Less(t, votes[1].BlockNumber, votes[2].BlockNumber)
+
+	require.Len(t, db.events.finalized, 1)
+	require.Equal(t, int64(3), db.events.finalized[0].Epoch)
+	require.Equal(t, hexutil.Encode(root[:]), db.events.finalized[0].MerkleRoot)
+	require.Equal(t, votes[2].TxHash, db.events.finalized[0].TxHash)
+
+	require.Len(t, db.events.uptimeVotes, 1)
+	uptimeVote := db.events.uptimeVotes[0]
+	require.Equal(t, int64(7), uptimeVote.RewardEpoch)
+	require.Equal(t, chain.voters[1].From.Hex(), uptimeVote.Voter)
+	require.Equal(t, nodeID.String(), uptimeVote.NodeIDs)
+}
+
+func TestVotingEventsStartBlock(t *testing.T) {
+	chain := newSimulatedChain(t, 1, time.Unix(1000, 0), 90*time.Second)
+	_, err := chain.contracts.Voting.SubmitVote(chain.voters[0], big.NewInt(3), [32]byte{1})
+	require.NoError(t, err)
+
+	db := &votingEventsTestDB{}
+	cronjob := newTestVotingEventsCronjob(t, chain, db)
+	lastBlock, err := cronjob.contract.LastBlock()
+	require.NoError(t, err)
+	cronjob.startBlock = lastBlock + 1
+
+	// Events before the start block are not indexed
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.Empty(t, db.events.votes)
+	require.Empty(t, db.events.finalized)
+	require.Equal(t, lastBlock+1, db.state.NextDBIndex)
+
+	_, err = chain.contracts.Voting.SubmitVote(chain.voters[0], big.NewInt(4), [32]byte{2})
+	require.NoError(t, err)
+
+	err = cronjob.Call()
+	require.NoError(t, err)
+	require.Len(t, db.events.votes, 1)
+	require.Equal(t, int64(4), db.events.votes[0].Epoch)
+}
@@ -4,50 +4,40 @@
 package cronjob
 
 import (
-	sysContext "context"
 	globalConfig "flare-indexer/config"
 	"flare-indexer/database"
 	"flare-indexer/indexer/config"
 	"flare-indexer/indexer/context"
 	"flare-indexer/indexer/pchain"
 	"flare-indexer/indexer/shared"
-	"flare-indexer/utils"
-	"flare-indexer/utils/contracts/voting"
+	"flare-indexer/utils/contracts/simulated"
+	"flare-indexer/utils/staking"
 	"math/big"
 	"testing"
 	"time"
 
 	"github.com/bradleyjkemp/cupaloy"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/require"
 )
 
-func votingCronjobTestConfig(epochStart time.Time, dbName string, privateKey string) *config.Config {
+func votingCronjobTestConfig(dbName string) *config.Config {
 	cfg := &config.Config{
 		Chain: globalConfig.ChainConfig{
 			ChainAddressHRP: "localflare",
-			ChainID:         31337,
-			EthRPCURL:       "http://127.0.0.1:8545",
-			PrivateKey:      privateKey,
+			ChainID:         simulated.ChainID,
 		},
 		VotingCronjob: config.VotingConfig{
 			CronjobConfig: config.CronjobConfig{
 				Enabled: true,
 				Timeout: 30 * time.Second,
 			},
-			ContractAddress: common.HexToAddress("0x7c2C195CD6D34B8F845992d380aADB2730bB9C6F"),
 		},
 		Mirror: config.MirrorConfig{
 			CronjobConfig: config.CronjobConfig{
 				Enabled: true,
 				Timeout: 30 * time.Second,
 			},
-			MirroringContract: common.HexToAddress("0x8858eeB3DfffA017D4BCE9801D340D36Cf895CCf"),
-		},
-		Epochs: config.EpochConfig{
-			Start:  utils.Timestamp{Time: epochStart},
-			Period: 90 * time.Second,
 		},
 		PChainIndexer: config.IndexerConfig{
 			Enabled:    true,
@@ -80,27 +70,36 @@ func transformPChainTx(tx *database.PChainTx) *database.PChainTx {
 	return tx
 }
 
-func createTestVotingClients(epochStart time.Time) (*votingCronjob, *votingCronjob, *mirrorCronJob, *shared.ChainIndexerBase, *shared.ChainIndexerBase, error) {
-	ctx1, err := context.BuildTestContext(votingCronjobTestConfig(epochStart, "flare_indexer_indexer", privateKey1))
+// Voting clients use the contracts deployed on the simulated chain, the first
+// voter also runs the mirroring client
+func createTestVotingClients(chain *simulatedChain) (*votingCronjob, *votingCronjob, *mirrorCronJob, *shared.ChainIndexerBase, *shared.ChainIndexerBase, error) {
+	ctx1, err := context.BuildTestContext(votingCronjobTestConfig("flare_indexer_indexer"))
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
 	}
-	cronjob1, err := NewVotingCronjob(ctx1)
+	ctx2, err := context.BuildTestContext(votingCronjobTestConfig("flare_indexer_indexer_2"))
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
 	}
-	ctx2, err := context.BuildTestContext(votingCronjobTestConfig(epochStart, "flare_indexer_indexer_2", privateKey2))
+	cronjob1, err := newSimulatedVotingCronjob(ctx1, chain, 0)
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
 	}
-	cronjob2, err := NewVotingCronjob(ctx2)
+	cronjob2, err := newSimulatedVotingCronjob(ctx2, chain, 1)
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
 	}
-	mirror, err := NewMirrorCronjob(ctx1)
+	contracts, err := newMirrorContractsCChain(
+		chain.sender(0), chain.contracts.MirroringAddress, chain.contracts.VotingAddress, chain.contracts.MulticallAddress,
+	)
 	if err != nil {
 		return nil, nil, nil, nil, nil, err
 	}
+	mirror := &mirrorCronJob{
+		epochCronjob: newEpochCronjob(&ctx1.Config().Mirror.CronjobConfig, cronjob1.epochs),
+		db:           NewMirrorDBGorm(ctx1.DB()),
+		contracts:    contracts,
+	}
 
 	indexer1 := &shared.ChainIndexerBase{
 		StateName:   pchain.StateName,
@@ -124,22 +123,31 @@ func createTestVotingClients(epochStart time.Time) (*votingCronjob, *votingCronj
 			pchain.NewPChainDataTransformer(transformPChainTx),
 		),
 	}
-	return cronjob1, cronjob2, mirror.(*mirrorCronJob), indexer1, indexer2, nil
+	return cronjob1, cronjob2, mirror, indexer1, indexer2, nil
 }
 
-func getMerkleRootFromContract(votingContract *voting.Voting, epoch int64) ([32]byte, error) {
-	ctx := sysContext.Background()
-	opts := &bind.CallOpts{Context: ctx}
-	merkleRoot, err := votingContract.GetMerkleRoot(opts, big.NewInt(epoch))
+func newSimulatedVotingCronjob(ctx context.IndexerContext, chain *simulatedChain, voter int) (*votingCronjob, error) {
+	contract, err := newVotingContractCChainWithSender(chain.sender(voter), chain.contracts.VotingAddress)
+	if err != nil {
+		return nil, err
+	}
+	start, period, err := contract.EpochConfig()
 	if err != nil {
-		return [32]byte{}, err
+		return nil, err
 	}
-	return merkleRoot, nil
+	cfg := ctx.Config()
+	epochs := staking.NewEpochInfo(&cfg.VotingCronjob.EpochConfig, start, period)
+	return &votingCronjob{
+		epochCronjob: newEpochCronjob(&cfg.VotingCronjob.CronjobConfig, epochs),
+		db:           &votingDBGorm{g: ctx.DB()},
+		contract:     contract,
+	}, nil
 }
 
 func TestVoting(t *testing.T) {
 	now := time.Unix(1675349340, 0) // 2023-02-02 14:49:00 UTC
-	vCronjob1, vCronjob2, mCronjob, indexer1, indexer2, err := createTestVotingClients(now)
+	chain := newSimulatedChain(t, 2, now, 90*time.Second)
+	vCronjob1, vCronjob2, mCronjob, indexer1, indexer2, err := createTestVotingClients(chain)
 	require.NoError(t, err)
 
 	// Run indexer to allow voting client test to fetch validator data
@@ -167,7 +175,7 @@ func TestVoting(t *testing.T) {
 		}
 	})
 	t.Run("Verify merkle root", func(t *testing.T) {
-		root, err := getMerkleRootFromContract(vCronjob1.votingContract, 0)
+		root, err := chain.contracts.Voting.GetMerkleRoot(new(bind.CallOpts), big.NewInt(0))
 		require.NoError(t, err)
 		cupaloy.SnapshotT(t, root)
 	})
@@ -9,7 +9,8 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"gorm.io/gorm"
 )
 
@@ -29,39 +30,59 @@ func (db *votingDBGorm) UpdateState(state *database.State) error {
 	return database.UpdateState(db.g, state)
 }
 
+func (db *votingDBGorm) FetchEpochJobRun(job string, epoch int64) (*database.EpochJobRun, error) {
+	return database.FetchEpochJobRun(db.g, job, epoch)
+}
+
+func (db *votingDBGorm) FetchEpochJobRetries(job string, before int64, now time.Time) ([]database.EpochJobRun, error) {
+	return database.FetchEpochJobRetries(db.g, job, before, now)
+}
+
+func (db *votingDBGorm) SaveEpochJobRun(run *database.EpochJobRun) error {
+	return database.PersistEpochJobRun(db.g, run)
+}
+
+func (db *votingDBGorm) SaveEpochMerkleTree(tree *database.EpochMerkleTree) error {
+	return database.PersistEpochMerkleTree(db.g, tree)
+}
+
 type votingContractCChain struct {
 	callOpts *bind.CallOpts
-	txOpts   *bind.TransactOpts
+	sender   *txSender
 	voting   *voting.Voting
 }
 
-func newVotingContractCChain(cfg *config.Config) (votingContract, error) {
-	votingContract, err := newVotingContract(cfg)
+func newVotingContractCChain(cfg *config.Config, clients *Clients) (votingContract, error) {
+	sender, err := clients.txSender(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	privateKey, err := cfg.Chain.GetPrivateKey()
-	if err != nil {
-		return nil, err
-	}
+	return newVotingContractCChainWithSender(sender, cfg.ContractAddresses.Voting)
+}
 
-	txOpts, err := TransactOptsFromPrivateKey(privateKey, cfg.Chain.ChainID)
+// Create voting contract stub sending transactions with the given sender (e.g.
+// on the simulated backend in tests)
+func newVotingContractCChainWithSender(
+	sender *txSender,
+	address common.Address,
+) (*votingContractCChain, error) {
+	votingContract, err := voting.NewVoting(address, sender.backend)
 	if err != nil {
 		return nil, err
 	}
 
-	callOpts := &bind.CallOpts{From: txOpts.From}
+	callOpts := &bind.CallOpts{From: sender.from()}
 
 	return &votingContractCChain{
 		callOpts: callOpts,
-		txOpts:   txOpts,
+		sender:   sender,
 		voting:   votingContract,
 	}, nil
 }
 
-func newVotingContract(cfg *config.Config) (*voting.Voting, error) {
-	eth, err := ethclient.Dial(cfg.Chain.EthRPCURL)
+func newVotingContract(cfg *config.Config, clients *Clients) (*voting.Voting, error) {
+	eth, err := clients.ethBackend(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +94,9 @@ func (c *votingContractCChain) ShouldVote(epoch *big.Int) (bool, error) {
 }
 
 func (c *votingContractCChain) SubmitVote(epoch *big.Int, merkleRoot [32]byte) error {
-	_, err := c.voting.SubmitVote(c.txOpts, epoch, merkleRoot)
+	_, err := c.sender.send(func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.voting.SubmitVote(opts, epoch, merkleRoot)
+	})
 	return err
 }
 
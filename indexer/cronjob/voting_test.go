@@ -1,6 +1,7 @@
 package cronjob
 
 import (
+	globalConfig "flare-indexer/config"
 	"flare-indexer/database"
 	"flare-indexer/indexer/config"
 	"flare-indexer/indexer/pchain"
@@ -10,13 +11,17 @@ import (
 	"time"
 
 	"github.com/bradleyjkemp/cupaloy"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
 type votingDBTest struct {
+	epochJobRunsTest
+
 	states     map[string]database.State
 	votingData map[timeRange][]database.PChainTxData
+	trees      map[int64]*database.EpochMerkleTree
 }
 
 type timeRange struct {
@@ -42,6 +47,14 @@ func (db *votingDBTest) UpdateState(state *database.State) error {
 	return nil
 }
 
+func (db *votingDBTest) SaveEpochMerkleTree(tree *database.EpochMerkleTree) error {
+	if db.trees == nil {
+		db.trees = make(map[int64]*database.EpochMerkleTree)
+	}
+	db.trees[tree.Epoch] = tree
+	return nil
+}
+
 type votingContractTest struct {
 	shouldVote     map[int64]bool
 	submittedVotes map[int64][32]byte
@@ -119,6 +132,14 @@ func TestVotes(t *testing.T) {
 
 	updatedState := db.states[votingStateName]
 	require.Equal(t, updatedState.NextDBIndex, uint64(5))
+
+	// Trees of the voted epochs are stored
+	require.Len(t, db.trees, 5)
+	require.Equal(t, staking.EmptyTreeRoot.Hex(), db.trees[3].Root)
+	require.Equal(t, 2, db.trees[2].NumLeaves)
+	tree, err := staking.EpochTreeFromEntity(db.trees[2])
+	require.NoError(t, err)
+	require.Equal(t, common.Hash(contract.submittedVotes[2]), tree.Root())
 }
 
 func timeRangeForEpoch(cj epochCronjob, epoch int64) timeRange {
@@ -157,10 +178,7 @@ func initEpochCronjob() epochCronjob {
 		BatchSize: 5,
 	}
 
-	epochInfo := staking.EpochInfo{
-		Period: 180 * time.Second,
-		Start:  time.Now().Add(-time.Hour),
-	}
+	epochInfo := staking.NewEpochInfo(&globalConfig.EpochConfig{}, time.Now().Add(-time.Hour), 180*time.Second)
 
 	return newEpochCronjob(&cronjobCfg, epochInfo)
 }
@@ -0,0 +1,148 @@
+package leader
+
+import (
+	"flare-indexer/indexer/config"
+	"flare-indexer/logger"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	leaderIsLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "leader_election_is_leader",
+		Help: "1 if this instance holds the leader lease, 0 otherwise",
+	})
+	leaderChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "leader_election_changes_total",
+		Help: "Number of times this instance acquired or lost the leader lease",
+	})
+	leaderRenewErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "leader_election_renew_errors_total",
+		Help: "Number of failed attempts to acquire or renew the leader lease",
+	})
+)
+
+// Elector acquires and renews the leader lease in the database. An instance is
+// the leader while its last renewal of the lease has not expired, so a standby
+// instance takes over at most one lease duration after the leader stops
+// renewing it.
+type Elector struct {
+	db       leaseDB
+	lease    string
+	holder   string
+	duration time.Duration
+	interval time.Duration
+
+	// For testing to set "now" to some past date
+	now func() time.Time
+
+	mu      sync.Mutex
+	leader  bool
+	expires time.Time
+}
+
+type leaseDB interface {
+	AcquireLease(name string, holder string, now time.Time, duration time.Duration) (bool, error)
+	ReleaseLease(name string, holder string, now time.Time) error
+}
+
+// Returns nil if the leader election is disabled
+func NewElector(cfg *config.LeaderElectionConfig, db *gorm.DB) *Elector {
+	if !cfg.Enabled {
+		return nil
+	}
+	holder := cfg.InstanceID
+	if len(holder) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		holder = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	return newElector(&leaseDBGorm{g: db}, cfg.Lease, holder, cfg.LeaseDuration, cfg.RenewInterval, time.Now)
+}
+
+func newElector(db leaseDB, lease string, holder string, duration, interval time.Duration, now func() time.Time) *Elector {
+	return &Elector{
+		db:       db,
+		lease:    lease,
+		holder:   holder,
+		duration: duration,
+		interval: interval,
+		now:      now,
+	}
+}
+
+// Acquire or renew the lease every renew interval until the process exits
+func (e *Elector) Run() {
+	logger.Info("Leader election: running as %s for lease %s", e.holder, e.lease)
+	e.renew()
+	ticker := time.NewTicker(e.interval)
+	for range ticker.C {
+		e.renew()
+	}
+}
+
+func (e *Elector) renew() {
+	now := e.now()
+	acquired, err := e.db.AcquireLease(e.lease, e.holder, now, e.duration)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err != nil:
+		// Leadership is kept until the last renewal expires, the lease cannot
+		// be taken over before
+		logger.Error("Leader election: failed renewing lease %s: %v", e.lease, err)
+		leaderRenewErrors.Inc()
+	case acquired:
+		e.expires = now.Add(e.duration)
+	default:
+		e.expires = time.Time{}
+	}
+	e.update(now)
+}
+
+// Update the leadership at the given time, mu must be held
+func (e *Elector) update(now time.Time) {
+	leader := e.expires.After(now)
+	if leader == e.leader {
+		return
+	}
+	e.leader = leader
+	leaderChanges.Inc()
+	if leader {
+		logger.Info("Leader election: %s acquired lease %s", e.holder, e.lease)
+		leaderIsLeader.Set(1)
+	} else {
+		logger.Warn("Leader election: %s lost lease %s", e.holder, e.lease)
+		leaderIsLeader.Set(0)
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.update(e.now())
+	return e.leader
+}
+
+// Give up the leadership so that a standby instance can take over without
+// waiting for the lease to expire
+func (e *Elector) Release() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	if err := e.db.ReleaseLease(e.lease, e.holder, now); err != nil {
+		return err
+	}
+	e.expires = time.Time{}
+	e.update(now)
+	return nil
+}
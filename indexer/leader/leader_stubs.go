@@ -0,0 +1,20 @@
+package leader
+
+import (
+	"flare-indexer/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type leaseDBGorm struct {
+	g *gorm.DB
+}
+
+func (db *leaseDBGorm) AcquireLease(name string, holder string, now time.Time, duration time.Duration) (bool, error) {
+	return database.AcquireLeaderLease(db.g, name, holder, now, duration)
+}
+
+func (db *leaseDBGorm) ReleaseLease(name string, holder string, now time.Time) error {
+	return database.ReleaseLeaderLease(db.g, name, holder, now)
+}
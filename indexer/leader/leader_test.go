@@ -0,0 +1,142 @@
+package leader
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Lease rows shared by the electors of a test
+type testLeaseDB struct {
+	holders map[string]string
+	expires map[string]time.Time
+
+	// Error returned to all electors if set
+	err error
+}
+
+func newTestLeaseDB() *testLeaseDB {
+	return &testLeaseDB{
+		holders: make(map[string]string),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (db *testLeaseDB) AcquireLease(name string, holder string, now time.Time, duration time.Duration) (bool, error) {
+	if db.err != nil {
+		return false, db.err
+	}
+	if h, ok := db.holders[name]; ok && h != holder && db.expires[name].After(now) {
+		return false, nil
+	}
+	db.holders[name] = holder
+	db.expires[name] = now.Add(duration)
+	return true, nil
+}
+
+func (db *testLeaseDB) ReleaseLease(name string, holder string, now time.Time) error {
+	if db.holders[name] == holder {
+		db.expires[name] = now
+	}
+	return nil
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestElectors(db *testLeaseDB, clock *testClock) (*Elector, *Elector) {
+	primary := newElector(db, "voter", "primary", time.Minute, 10*time.Second, clock.Now)
+	standby := newElector(db, "voter", "standby", time.Minute, 10*time.Second, clock.Now)
+	return primary, standby
+}
+
+func TestStandbyTakeover(t *testing.T) {
+	db := newTestLeaseDB()
+	clock := &testClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	primary, standby := newTestElectors(db, clock)
+	changes := testutil.ToFloat64(leaderChanges)
+
+	primary.renew()
+	standby.renew()
+	require.True(t, primary.IsLeader())
+	require.False(t, standby.IsLeader())
+	require.Equal(t, float64(1), testutil.ToFloat64(leaderIsLeader))
+
+	// Leader keeps the lease while renewing it
+	for i := 0; i < 10; i++ {
+		clock.now = clock.now.Add(10 * time.Second)
+		primary.renew()
+		standby.renew()
+		require.True(t, primary.IsLeader())
+		require.False(t, standby.IsLeader())
+	}
+
+	// Primary stops renewing, the standby takes over after the lease expires
+	clock.now = clock.now.Add(50 * time.Second)
+	standby.renew()
+	require.False(t, standby.IsLeader())
+	require.True(t, primary.IsLeader())
+
+	clock.now = clock.now.Add(10 * time.Second)
+	require.False(t, primary.IsLeader())
+	standby.renew()
+	require.True(t, standby.IsLeader())
+
+	// Primary does not get the lease back while the standby renews it
+	primary.renew()
+	require.False(t, primary.IsLeader())
+	require.Equal(t, "standby", db.holders["voter"])
+
+	require.Equal(t, changes+3, testutil.ToFloat64(leaderChanges))
+}
+
+func TestRelease(t *testing.T) {
+	db := newTestLeaseDB()
+	clock := &testClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	primary, standby := newTestElectors(db, clock)
+
+	primary.renew()
+	require.True(t, primary.IsLeader())
+
+	require.NoError(t, primary.Release())
+	require.False(t, primary.IsLeader())
+	require.Equal(t, float64(0), testutil.ToFloat64(leaderIsLeader))
+
+	// Standby takes over without waiting for the lease to expire
+	standby.renew()
+	require.True(t, standby.IsLeader())
+}
+
+func TestRenewError(t *testing.T) {
+	db := newTestLeaseDB()
+	clock := &testClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	primary, standby := newTestElectors(db, clock)
+	errors0 := testutil.ToFloat64(leaderRenewErrors)
+
+	primary.renew()
+	db.err = errors.New("connection lost")
+
+	// Leadership is kept until the last renewal expires
+	clock.now = clock.now.Add(30 * time.Second)
+	primary.renew()
+	standby.renew()
+	require.True(t, primary.IsLeader())
+	require.False(t, standby.IsLeader())
+
+	clock.now = clock.now.Add(30 * time.Second)
+	primary.renew()
+	require.False(t, primary.IsLeader())
+	require.Equal(t, errors0+3, testutil.ToFloat64(leaderRenewErrors))
+
+	db.err = nil
+	standby.renew()
+	require.True(t, standby.IsLeader())
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"flare-indexer/indexer/context"
+	"flare-indexer/indexer/cronjob"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Run an admin command given after the flags of the indexer, e.g.,
+// `indexer --config config.toml uptime-reaggregate --from 10 --to 12`
+func runCommand(ctx context.IndexerContext, args []string) error {
+	switch args[0] {
+	case "uptime-reaggregate":
+		return uptimeReaggregateCommand(ctx, args[1:])
+	case "rerun-epoch":
+		return rerunEpochCommand(ctx, args[1:])
+	}
+	return errors.Errorf("unknown command %q", args[0])
+}
+
+func uptimeReaggregateCommand(ctx context.IndexerContext, args []string) error {
+	flags := flag.NewFlagSet("uptime-reaggregate", flag.ContinueOnError)
+	fromFlag := flags.Int64("from", -1, "First epoch to recompute")
+	toFlag := flags.Int64("to", -1, "Last epoch to recompute (inclusive), defaults to the first epoch")
+	resubmitFlag := flags.Bool("resubmit", false, "Submit the uptime votes of the recomputed epochs again")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *fromFlag < 0 {
+		return errors.New("--from is required")
+	}
+	if *toFlag < 0 {
+		*toFlag = *fromFlag
+	}
+	return cronjob.ReaggregateUptimes(ctx, &cronjob.Clients{}, *fromFlag, *toFlag, *resubmitFlag, os.Stdout)
+}
+
+func rerunEpochCommand(ctx context.IndexerContext, args []string) error {
+	flags := flag.NewFlagSet("rerun-epoch", flag.ContinueOnError)
+	jobFlag := flags.String("job", "", "Epoch job running the epoch again: voting, mirror or uptime_aggregator")
+	epochFlag := flags.Int64("epoch", -1, "Epoch to run again")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *epochFlag < 0 {
+		return errors.New("--epoch is required")
+	}
+	if err := cronjob.RequestEpochJobRerun(ctx.DB(), *jobFlag, *epochFlag); err != nil {
+		return err
+	}
+	fmt.Printf("epoch %d of job %s will be run again on the next call of the job\n", *epochFlag, *jobFlag)
+	return nil
+}
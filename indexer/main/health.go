@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flare-indexer/indexer/context"
+	"flare-indexer/indexer/pchain"
+	"flare-indexer/indexer/xchain"
+	"flare-indexer/utils"
+	"flare-indexer/utils/chain"
+	"flare-indexer/utils/health"
+)
+
+// Health checks of the database, the states of the enabled indexers and the
+// node, served with the metrics
+func newHealthChecker(ctx context.IndexerContext) *health.Checker {
+	cfg := ctx.Config()
+	checks := []health.Check{health.DBCheck(ctx.DB())}
+	if cfg.PChainIndexer.Enabled {
+		checks = append(checks, health.StateCheck(ctx.DB(), pchain.StateName, &cfg.Health))
+	}
+	if cfg.XChainIndexer.Enabled {
+		checks = append(checks, health.StateCheck(ctx.DB(), xchain.StateName, &cfg.Health))
+	}
+	nodeHealthURL := utils.JoinPaths(cfg.Chain.NodeURL, "ext/health") + chain.RPCClientOptions(cfg.Chain.ApiKey)
+	checks = append(checks, health.NodeCheck(nodeHealthURL))
+	return health.NewChecker(&cfg.Health, checks...)
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"flare-indexer/indexer/admin"
 	"flare-indexer/indexer/context"
+	"flare-indexer/indexer/leader"
 	"flare-indexer/indexer/migrations"
 	"flare-indexer/indexer/runner"
 	"flare-indexer/indexer/shared"
+	"flare-indexer/indexer/simulation"
 	"flare-indexer/logger"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/gorilla/mux"
 )
 
 func main() {
@@ -24,15 +29,44 @@ func main() {
 		return
 	}
 
+	if command := ctx.Flags().Command; len(command) > 0 {
+		if err := runCommand(ctx, command); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cancelChan := make(chan os.Signal, 1)
 	signal.Notify(cancelChan, os.Interrupt, syscall.SIGTERM)
 
-	// Prometheus metrics
-	shared.InitMetricsServer(&ctx.Config().Metrics)
+	clients := &runner.Clients{}
+	if ctx.Flags().Simulate {
+		clients, err = simulation.NewClients(ctx)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+	}
+	elector := leader.NewElector(&ctx.Config().LeaderElection, ctx.DB())
+	controls := runner.Start(ctx, clients, elector)
 
-	runner.Start(ctx)
+	// Prometheus metrics, health checks and the admin API
+	routes := []func(r *mux.Router){newHealthChecker(ctx).Register}
+	if adminCfg := &ctx.Config().Admin; len(adminCfg.ApiKey) > 0 {
+		if len(ctx.Config().Metrics.PrometheusAddress) == 0 {
+			logger.Warn("Admin API is not served without the prometheus_address of the metrics config")
+		}
+		routes = append(routes, admin.NewServer(adminCfg, ctx.DB(), controls).Register)
+	}
+	shared.InitMetricsServer(&ctx.Config().Metrics, routes...)
 
 	<-cancelChan
+	if elector != nil {
+		if err := elector.Release(); err != nil {
+			logger.Error("Failed releasing leader lease: %v", err)
+		}
+	}
 	logger.Info("Stopped flare indexer")
 
 }
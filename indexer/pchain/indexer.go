@@ -2,6 +2,7 @@ package pchain
 
 import (
 	"flare-indexer/config"
+	"flare-indexer/database"
 	"flare-indexer/indexer/context"
 	"flare-indexer/indexer/shared"
 	"flare-indexer/utils"
@@ -9,17 +10,26 @@ import (
 )
 
 const (
-	StateName string = "p_chain_block"
+	StateName string = database.PChainBlockStateName
 )
 
 type pChainBlockIndexer struct {
 	shared.ChainIndexerBase
 }
 
-func CreatePChainBlockIndexer(ctx context.IndexerContext) *pChainBlockIndexer {
+// Create p-chain block indexer, clients that are nil are created from the configuration
+func CreatePChainBlockIndexer(
+	ctx context.IndexerContext,
+	client chain.IndexerClient,
+	rpcClient chain.RPCClient,
+) *pChainBlockIndexer {
 	config := ctx.Config().PChainIndexer
-	client := newIndexerClient(&ctx.Config().Chain)
-	rpcClient := newJsonRpcClient(&ctx.Config().Chain)
+	if client == nil {
+		client = newIndexerClient(&ctx.Config().Chain)
+	}
+	if rpcClient == nil {
+		rpcClient = newJsonRpcClient(&ctx.Config().Chain)
+	}
 
 	idxr := pChainBlockIndexer{}
 	idxr.StateName = StateName
@@ -28,6 +38,7 @@ func CreatePChainBlockIndexer(ctx context.IndexerContext) *pChainBlockIndexer {
 	idxr.DB = ctx.DB()
 	idxr.Config = config
 	idxr.InitMetrics(StateName)
+	idxr.InitControl(StateName)
 
 	idxr.BatchIndexer = NewPChainBatchIndexer(ctx, client, rpcClient, nil)
 
@@ -3,34 +3,140 @@ package runner
 import (
 	"flare-indexer/indexer/context"
 	"flare-indexer/indexer/cronjob"
+	"flare-indexer/indexer/leader"
 	"flare-indexer/indexer/pchain"
+	"flare-indexer/indexer/shared"
 	"flare-indexer/indexer/xchain"
+	"flare-indexer/logger"
+	"flare-indexer/utils/chain"
 	"log"
+	"time"
+
+	"golang.org/x/exp/slices"
 )
 
-func Start(ctx context.IndexerContext) {
+// Clients used by the indexers and cronjobs. Clients that are not set are
+// created from the configuration.
+type Clients struct {
+	cronjob.Clients
+
+	PChainIndexer chain.IndexerClient
+	PChainRPC     chain.RPCClient
+}
+
+// Start the indexers and cronjobs, returns their controls for the admin API.
+// If elector is set, the cronjobs sending transactions and the uptime sampling
+// and compaction only run on the leader.
+func Start(ctx context.IndexerContext, clients *Clients, elector *leader.Elector) []*shared.JobControl {
+	if elector != nil {
+		clients.Leader = elector.IsLeader
+	}
+
 	xIndexer := xchain.CreateXChainTxIndexer(ctx)
-	pIndexer := pchain.CreatePChainBlockIndexer(ctx)
+	pIndexer := pchain.CreatePChainBlockIndexer(ctx, clients.PChainIndexer, clients.PChainRPC)
+
+	votingCronjob, err := cronjob.NewVotingCronjob(ctx, &clients.Clients)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mirrorCronjob, err := cronjob.NewMirrorCronjob(ctx, &clients.Clients)
+	if err != nil {
+		log.Fatal(err)
+	}
+	uptimeCronjob, err := cronjob.NewUptimeCronjob(ctx, &clients.Clients)
+	if err != nil {
+		log.Fatal(err)
+	}
+	uptimeCompactionCronjob, err := cronjob.NewUptimeCompactionCronjob(ctx, &clients.Clients)
+	if err != nil {
+		log.Fatal(err)
+	}
+	uptimeVotingCronjob, err := cronjob.NewUptimeVotingCronjob(ctx, &clients.Clients)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	votingCronjob, err := cronjob.NewVotingCronjob(ctx)
+	votingEventsCronjob, err := cronjob.NewVotingEventsCronjob(ctx, &clients.Clients)
 	if err != nil {
 		log.Fatal(err)
 	}
-	mirrorCronjob, err := cronjob.NewMirrorCronjob(ctx)
+	divergenceCronjob, err := cronjob.NewDivergenceCronjob(ctx, &clients.Clients)
 	if err != nil {
 		log.Fatal(err)
 	}
-	uptimeCronjob := cronjob.NewUptimeCronjob(ctx)
-	uptimeVotingCronjob, err := cronjob.NewUptimeVotingCronjob(ctx)
+	epochScheduleCronjob, err := cronjob.NewEpochScheduleCronjob(ctx, &clients.Clients)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// Run indexers and cronjobs more often if time is accelerated
+	if clients.Time != nil {
+		xIndexer.Config.Timeout = clients.Time.RealDuration(xIndexer.Config.Timeout)
+		pIndexer.Config.Timeout = clients.Time.RealDuration(pIndexer.Config.Timeout)
+	}
+
+	controls := []*shared.JobControl{xIndexer.Control(), pIndexer.Control()}
 	go xIndexer.Run()
 	go pIndexer.Run()
 
-	go cronjob.RunCronjob(uptimeCronjob)
-	go cronjob.RunCronjob(votingCronjob)
-	go cronjob.RunCronjob(mirrorCronjob)
-	go cronjob.RunCronjob(uptimeVotingCronjob)
+	if elector != nil {
+		go elector.Run()
+	}
+
+	for _, c := range []cronjob.Cronjob{
+		uptimeCronjob,
+		votingCronjob,
+		mirrorCronjob,
+		uptimeCompactionCronjob,
+		uptimeVotingCronjob,
+		votingEventsCronjob,
+		divergenceCronjob,
+		epochScheduleCronjob,
+	} {
+		control := cronjob.NewCronjobControl(c)
+		controls = append(controls, control)
+		go cronjob.RunCronjob(accelerated(leaderOnly(c, elector), clients), control)
+	}
+	return controls
+}
+
+// Cronjobs sending transactions with the key shared by the instances and the
+// uptime cronjobs, since samples of several instances with the same observers
+// would be counted repeatedly and concurrent compactions would overlap
+var leaderCronjobs = []string{"voting", "mirror", "uptime_aggregator", "uptime", "uptime_compaction"}
+
+type leaderCronjob struct {
+	cronjob.Cronjob
+	elector *leader.Elector
+}
+
+func leaderOnly(c cronjob.Cronjob, elector *leader.Elector) cronjob.Cronjob {
+	if elector == nil || !slices.Contains(leaderCronjobs, c.Name()) {
+		return c
+	}
+	return &leaderCronjob{Cronjob: c, elector: elector}
+}
+
+func (c *leaderCronjob) Call() error {
+	if !c.elector.IsLeader() {
+		logger.Debug("%s cronjob skipped, not the leader", c.Name())
+		return nil
+	}
+	return c.Cronjob.Call()
+}
+
+type acceleratedCronjob struct {
+	cronjob.Cronjob
+	clients *Clients
+}
+
+func accelerated(c cronjob.Cronjob, clients *Clients) cronjob.Cronjob {
+	if clients.Time == nil {
+		return c
+	}
+	return &acceleratedCronjob{Cronjob: c, clients: clients}
+}
+
+func (c *acceleratedCronjob) Timeout() time.Duration {
+	return c.clients.Time.RealDuration(c.Cronjob.Timeout())
 }
@@ -0,0 +1,121 @@
+package shared
+
+import (
+	"context"
+	"flare-indexer/logger"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	JobKindIndexer = "indexer"
+	JobKindCronjob = "cronjob"
+)
+
+var ErrJobNotRunning = errors.New("job is not running")
+
+// Control of a running indexer or cronjob by the admin API. The job is called
+// periodically unless paused, while actions requested by the admin API are run
+// in the goroutine of the job between the calls.
+type JobControl struct {
+	Name string
+	Kind string
+
+	// Names of the state rows of the job
+	StateNames []string
+
+	// Set the epoch cursor of the job, nil if the job has none
+	ResetEpoch func(epoch int64) error
+
+	mu      sync.Mutex
+	paused  bool
+	running bool
+	call    func() error
+	actions chan func()
+}
+
+func NewJobControl(name string, kind string, stateNames ...string) *JobControl {
+	return &JobControl{
+		Name:       name,
+		Kind:       kind,
+		StateNames: stateNames,
+		actions:    make(chan func()),
+	}
+}
+
+// Call the job every interval until the process exits
+func (c *JobControl) Run(interval time.Duration, call func() error) {
+	c.mu.Lock()
+	c.call = call
+	c.running = true
+	c.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-ticker.C:
+			if c.Paused() {
+				continue
+			}
+			if err := call(); err != nil {
+				logger.Error("%s %s error %v", c.Name, c.Kind, err)
+			}
+		case action := <-c.actions:
+			action()
+		}
+	}
+}
+
+func (c *JobControl) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Stop or continue the periodic calls of the job, admin actions are run while
+// the job is paused
+func (c *JobControl) SetPaused(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = paused
+}
+
+// Jobs that are disabled in the config are not running
+func (c *JobControl) Running() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running
+}
+
+// Call the job immediately and return its error
+func (c *JobControl) Trigger(ctx context.Context) error {
+	c.mu.Lock()
+	call := c.call
+	c.mu.Unlock()
+	if call == nil {
+		return ErrJobNotRunning
+	}
+	return c.Do(ctx, call)
+}
+
+// Run f in the goroutine of the job after its current call and wait for it
+func (c *JobControl) Do(ctx context.Context, f func() error) error {
+	if !c.Running() {
+		return ErrJobNotRunning
+	}
+
+	done := make(chan error, 1)
+	select {
+	case c.actions <- func() { done <- f() }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
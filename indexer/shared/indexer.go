@@ -29,6 +29,7 @@ type ChainIndexerBase struct {
 	BatchIndexer ContainerBatchIndexer
 
 	metrics *metrics
+	control *JobControl
 }
 
 func (ci *ChainIndexerBase) IndexBatch() error {
@@ -124,15 +125,22 @@ func (ci *ChainIndexerBase) Run() {
 	if !ci.Config.Enabled {
 		return
 	}
-	ticker := time.NewTicker(ci.Config.Timeout)
-	for range ticker.C {
-		err := ci.IndexBatch()
-		if err != nil {
-			logger.Error("%s indexer error %v", ci.IndexerName, err)
-		}
-	}
+	ci.Control().Run(ci.Config.Timeout, ci.IndexBatch)
 }
 
 func (ci *ChainIndexerBase) InitMetrics(namespace string) {
 	ci.metrics = newMetrics(namespace)
 }
+
+func (ci *ChainIndexerBase) InitControl(name string) {
+	ci.control = NewJobControl(name, JobKindIndexer, ci.StateName)
+}
+
+// Control of the indexer by the admin API, named after the state if
+// InitControl was not called
+func (ci *ChainIndexerBase) Control() *JobControl {
+	if ci.control == nil {
+		ci.InitControl(ci.StateName)
+	}
+	return ci.control
+}
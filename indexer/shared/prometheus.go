@@ -4,19 +4,35 @@ import (
 	"flare-indexer/indexer/config"
 	"log"
 	"net/http"
+	"sort"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
-func InitMetricsServer(cfg *config.MetricsConfig) {
+const networkLabel = "network"
+
+// Serve the Prometheus metrics and the routes added by the given functions,
+// e.g., the admin API
+func InitMetricsServer(cfg *config.MetricsConfig, routes ...func(r *mux.Router)) {
 	if len(cfg.PrometheusAddress) == 0 {
 		return
 	}
 
 	r := mux.NewRouter()
 
-	r.Path("/metrics").Handler(promhttp.Handler())
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if len(cfg.Network) > 0 {
+		gatherer = &networkGatherer{Gatherer: gatherer, network: cfg.Network}
+	}
+	r.Path("/metrics").Handler(promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+	))
+	for _, addRoutes := range routes {
+		addRoutes(r)
+	}
 
 	srv := &http.Server{
 		Addr:    cfg.PrometheusAddress,
@@ -27,3 +43,26 @@ func InitMetricsServer(cfg *config.MetricsConfig) {
 		log.Fatal(err)
 	}()
 }
+
+// Gatherer adding the network label to all metrics, so that metrics of
+// indexers of different networks can be told apart. Metrics are registered
+// at package initialization, before the network is known, so the label cannot
+// be added by the registerer.
+type networkGatherer struct {
+	prometheus.Gatherer
+	network string
+}
+
+func (g *networkGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			name, value := networkLabel, g.network
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: &name, Value: &value})
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+	}
+	return families, err
+}
@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	runs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "runs_total",
+		Help: "Number of runs",
+	}, []string{"job", "result"})
+	registry.MustRegister(runs)
+	runs.WithLabelValues("voting", "success").Inc()
+	runs.WithLabelValues("mirror", "failure").Inc()
+
+	gatherer := &networkGatherer{Gatherer: registry, network: "costwo"}
+	families, err := gatherer.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	require.Len(t, families[0].Metric, 2)
+	for _, metric := range families[0].Metric {
+		var names []string
+		for _, label := range metric.Label {
+			names = append(names, label.GetName())
+		}
+		require.Equal(t, []string{"job", "network", "result"}, names)
+		require.Equal(t, "costwo", metric.Label[1].GetValue())
+	}
+}
@@ -0,0 +1,130 @@
+// Simulation mode of the indexer: p-chain data and uptimes are read from
+// recordings, votes are submitted to simulated contracts and time runs faster
+// than the real time, so the behaviour of the voting and uptime clients over
+// long periods can be reproduced quickly.
+package simulation
+
+import (
+	"flare-indexer/config"
+	"flare-indexer/indexer/context"
+	"flare-indexer/indexer/cronjob"
+	"flare-indexer/indexer/runner"
+	"flare-indexer/logger"
+	"flare-indexer/utils"
+	"flare-indexer/utils/chain"
+	"flare-indexer/utils/contracts/simulated"
+	"flare-indexer/utils/signer"
+	"path"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// Create recorded clients, accelerated time and simulated contracts. The
+// configuration is updated to use the simulated chain and contracts.
+func NewClients(ctx context.IndexerContext) (*runner.Clients, error) {
+	flags := ctx.Flags()
+	cfg := ctx.Config()
+
+	if flags.SimulateSpeed <= 0 {
+		return nil, errors.New("simulation speed must be positive")
+	}
+
+	dir := flags.SimulateData
+	if dir == "" {
+		dir = chain.TestRecordingsDir()
+	}
+	indexerClient, err := chain.NewRecordedIndexerClient(path.Join(dir, chain.PChainBlocksRecordingFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading p-chain blocks recording")
+	}
+	rpcClient, err := chain.NewRecordedRPCClient(path.Join(dir, chain.PChainRPCRecordingFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading p-chain rpc recording")
+	}
+	uptimeClient, err := chain.NewRecordedUptimeClient(path.Join(dir, chain.UptimeRecordingFile), time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "reading uptime recording")
+	}
+
+	start := uptimeClient.FirstTimestamp()
+	if flags.SimulateStart != "" {
+		start, err = time.Parse(time.RFC3339, flags.SimulateStart)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid simulation start")
+		}
+	}
+	if start.IsZero() {
+		return nil, errors.New("simulation start not set and there are no recorded uptimes")
+	}
+
+	clock := utils.NewAcceleratedTime(start, flags.SimulateSpeed)
+	uptimeClient.Time = clock
+
+	backend, err := deployContracts(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	backend.SetClock(clock)
+
+	// There is no recorded x-chain data
+	cfg.XChainIndexer.Enabled = false
+	if cfg.UptimeCronjob.Start.IsZero() {
+		cfg.UptimeCronjob.Start = utils.Timestamp{Time: start}
+	}
+
+	logger.Info("Starting simulation at %s, %.1fx speed", start.Format(time.RFC3339), flags.SimulateSpeed)
+
+	return &runner.Clients{
+		Clients: cronjob.Clients{
+			Eth:    backend,
+			Uptime: uptimeClient,
+			Time:   clock,
+		},
+		PChainIndexer: indexerClient,
+		PChainRPC:     rpcClient,
+	}, nil
+}
+
+// Deploy simulated contracts with epochs starting at start. A new voting account
+// is created and set in the configuration, so the configured key is never used.
+func deployContracts(ctx context.IndexerContext, start time.Time) (*simulated.Backend, error) {
+	cfg := ctx.Config()
+
+	privateKey, address, err := simulated.NewAccount()
+	if err != nil {
+		return nil, err
+	}
+
+	backend := simulated.NewBackend(address)
+	if err := backend.SetTime(start); err != nil {
+		return nil, err
+	}
+
+	opts, err := simulated.TransactOpts(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	contracts, err := simulated.DeployContracts(backend, opts, &simulated.VotingParams{
+		FirstEpochStart: start,
+		EpochPeriod:     ctx.Flags().SimulateEpochPeriod,
+		Voters:          []common.Address{address},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Chain.ChainID = simulated.ChainID
+	cfg.Chain.PrivateKey = common.Bytes2Hex(crypto.FromECDSA(privateKey))
+	cfg.Chain.PrivateKeyFile = ""
+	cfg.Signer = config.SignerConfig{Type: signer.TypePrivateKey}
+	cfg.ContractAddresses.Voting = contracts.VotingAddress
+	cfg.ContractAddresses.Mirroring = contracts.MirroringAddress
+	cfg.ContractAddresses.Multicall = contracts.MulticallAddress
+
+	logger.Info("Deployed simulated voting contract at %s, voter %s", contracts.VotingAddress, address)
+
+	return backend, nil
+}
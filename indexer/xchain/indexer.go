@@ -28,6 +28,7 @@ func CreateXChainTxIndexer(ctx context.IndexerContext) *xChainTxIndexer {
 	idxr.DB = ctx.DB()
 	idxr.Config = config
 	idxr.InitMetrics(StateName)
+	idxr.InitControl(StateName)
 
 	idxr.BatchIndexer = NewXChainBatchIndexer(ctx, client, txClient)
 
@@ -12,6 +12,9 @@ import (
 
 var (
 	sugar *zap.SugaredLogger
+
+	// Level of the current logger, can be changed at runtime
+	atomLevel zap.AtomicLevel
 )
 
 const (
@@ -58,9 +61,24 @@ func createSugaredLogger(config config.LoggerConfig) *zap.SugaredLogger {
 		sugar.Errorf("Wrong level %s", config.Level)
 	}
 	atom.SetLevel(level)
+	atomLevel = atom
 	return sugar
 }
 
+// Change the level of the logger, valid values are the same as in the logger config
+func SetLevel(level string) error {
+	l, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomLevel.SetLevel(l)
+	return nil
+}
+
+func Level() string {
+	return atomLevel.Level().CapitalString()
+}
+
 func createFileLoggerCore(config config.LoggerConfig, atom zap.AtomicLevel) zapcore.Core {
 	w := zapcore.AddSync(&lumberjack.Logger{
 		Filename: config.File,
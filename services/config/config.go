@@ -2,16 +2,20 @@ package config
 
 import (
 	"flare-indexer/config"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
 type Config struct {
-	DB                config.DBConfig          `toml:"db"`
-	Logger            config.LoggerConfig      `toml:"logger"`
-	Chain             config.ChainConfig       `toml:"chain"`
-	Services          ServicesConfig           `toml:"services"`
-	ContractAddresses config.ContractAddresses `toml:"contract_addresses"`
+	DB                config.DBConfig            `toml:"db"`
+	Logger            config.LoggerConfig        `toml:"logger"`
+	Chain             config.ChainConfig         `toml:"chain"`
+	Services          ServicesConfig             `toml:"services"`
+	ContractAddresses config.ContractAddresses   `toml:"contract_addresses"`
+	EpochSchedule     config.EpochScheduleConfig `toml:"epoch_schedule"`
+	Uptime            UptimeConfig               `toml:"uptime"`
+	Health            HealthConfig               `toml:"health"`
 }
 
 type ServicesConfig struct {
@@ -19,11 +23,40 @@ type ServicesConfig struct {
 	VotingContract common.Address `toml:"votingContract"`
 }
 
+type UptimeConfig struct {
+	// Min uptime ratio of a validator to be voted, as configured for the
+	// uptime voting of the indexer
+	UptimeThreshold float64 `toml:"uptime_threshold"`
+
+	// Voter of the indexer, its uptime votes give the voted node ids
+	Voter common.Address `toml:"voter"`
+}
+
+type HealthConfig struct {
+	config.HealthConfig
+
+	// Verification routes respond with 503 if the indexed P-chain data is
+	// older than this, 0 to disable
+	MaxDataAge time.Duration `toml:"max_data_age"`
+	// Set the X-Data-Stale header instead of responding with 503
+	StaleDataHeader bool `toml:"stale_data_header"`
+}
+
 func newConfig() *Config {
 	return &Config{
 		Services: ServicesConfig{
 			Address: "localhost:8000",
 		},
+		EpochSchedule: config.EpochScheduleConfig{
+			RefreshInterval: 5 * time.Minute,
+		},
+		Health: HealthConfig{
+			HealthConfig: config.HealthConfig{
+				MaxIndexLag:  100,
+				MaxStateAge:  5 * time.Minute,
+				CheckTimeout: 5 * time.Second,
+			},
+		},
 	}
 }
 
@@ -26,13 +26,22 @@ func main() {
 	routes.AddStakerRoutes(router, ctx)
 	routes.AddTransactionRoutes(router, ctx)
 	routes.AddQueryRoutes(router, ctx)
+	routes.AddVotingRoutes(router, ctx)
+	routes.AddUptimeRoutes(router, ctx)
 
-	if err := routes.AddMirroringRoutes(router, ctx); err != nil {
-		logger.Fatal("Failed to add mirroring routes: %v", err)
+	epochSchedule, err := routes.NewEpochSchedule(ctx)
+	if err != nil {
+		logger.Fatal("Failed to load epoch configuration: %v", err)
+	}
+	routes.AddMirroringRoutes(router, ctx, epochSchedule)
+	if err := routes.AddEpochRoutes(router, ctx, epochSchedule); err != nil {
+		logger.Fatal("Failed to add epoch routes: %v", err)
 	}
 
 	router.Finalize()
 
+	routes.AddHealthRoutes(muxRouter, ctx)
+
 	address := ctx.Config().Services.Address
 	srv := &http.Server{
 		Handler: muxRouter,
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/logger"
+	"flare-indexer/services/context"
+	"flare-indexer/utils/contracts/voting"
+	"flare-indexer/utils/staking"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Epoch schedule shared by the routes, loaded from the database and the voting
+// contract and refreshed periodically. The schedule persisted by the indexer
+// takes precedence; changes of the configuration seen first by the services
+// are persisted only if the user has write permission for the
+// epoch_config_segments table.
+func NewEpochSchedule(ctx context.ServicesContext) (*staking.EpochSchedule, error) {
+	cfg := ctx.Config()
+	votingContract, err := newVotingContract(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := epochScheduleDBGorm{db: ctx.DB()}
+
+	schedule := staking.NewEpochSchedule()
+	if err := refreshEpochSchedule(schedule, db, votingContract); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.EpochSchedule.RefreshInterval)
+		for range ticker.C {
+			if err := refreshEpochSchedule(schedule, db, votingContract); err != nil {
+				logger.Error("Failed to refresh epoch configuration: %v", err)
+			}
+		}
+	}()
+	return schedule, nil
+}
+
+func refreshEpochSchedule(schedule *staking.EpochSchedule, db staking.EpochScheduleDB, votingContract *voting.Voting) error {
+	start, period, err := staking.GetEpochConfig(votingContract)
+	if err != nil {
+		return err
+	}
+	segment, err := schedule.Refresh(db, start, period, time.Now())
+	if segment == nil {
+		return err
+	}
+	logger.Info("Epoch configuration from epoch %d: start %s, period %s",
+		segment.FirstEpoch, segment.Start.UTC().Format(time.RFC3339), segment.Period)
+	if err != nil {
+		// The schedule is updated anyway
+		logger.Warn("Failed to store epoch configuration: %v", err)
+	}
+	return nil
+}
+
+type epochScheduleDBGorm struct {
+	db *gorm.DB
+}
+
+func (m epochScheduleDBGorm) FetchEpochConfigSegments() ([]database.EpochConfigSegment, error) {
+	return database.FetchEpochConfigSegments(m.db)
+}
+
+func (m epochScheduleDBGorm) CreateEpochConfigSegment(segment *database.EpochConfigSegment) error {
+	return database.CreateEpochConfigSegment(m.db, segment)
+}
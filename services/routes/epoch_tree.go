@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/logger"
+	"flare-indexer/utils/staking"
+	"time"
+)
+
+type epochTreeDB interface {
+	GetPChainTxsForEpoch(start, end time.Time) ([]database.PChainTxData, error)
+	FetchPChainTxsFingerprint(start, end time.Time) (database.PChainTxsFingerprint, error)
+	FetchEpochMerkleTree(epoch int64) (*database.EpochMerkleTree, error)
+	SaveEpochMerkleTree(tree *database.EpochMerkleTree) error
+}
+
+// Merkle tree of the epoch. The stored tree is used if the p-chain data of the
+// epoch did not change since the tree was stored, otherwise the tree is
+// recomputed and stored again.
+func getEpochTree(db epochTreeDB, epochs staking.EpochInfo, epoch int64) (*staking.EpochTree, error) {
+	start, end := epochs.GetTimeRange(epoch)
+	fingerprint, err := db.FetchPChainTxsFingerprint(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := db.FetchEpochMerkleTree(epoch)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		tree, err := staking.EpochTreeFromEntity(stored)
+		if err != nil {
+			logger.Warn("invalid stored merkle tree of epoch %d: %v", epoch, err)
+		} else if tree.IsCurrent(fingerprint) {
+			return tree, nil
+		}
+	}
+
+	txs, err := db.GetPChainTxsForEpoch(start, end)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := staking.NewEpochTree(epoch, txs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serve the recomputed tree even if it cannot be stored, e.g., if the
+	// database user has no write permission
+	entity, err := tree.Entity(time.Now())
+	if err == nil {
+		err = db.SaveEpochMerkleTree(entity)
+	}
+	if err != nil {
+		logger.Warn("failed storing merkle tree of epoch %d: %v", epoch, err)
+	}
+	return tree, nil
+}
@@ -0,0 +1,210 @@
+package routes
+
+import (
+	globalConfig "flare-indexer/config"
+	"flare-indexer/database"
+	"flare-indexer/services/context"
+	"flare-indexer/services/utils"
+	"flare-indexer/utils/contracts/voting"
+	"flare-indexer/utils/staking"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type EpochLeafResponse struct {
+	StakeData MirroringStakeData `json:"stakeData"`
+	LeafHash  string             `json:"leafHash"`
+}
+
+type GetEpochResponse struct {
+	Epoch     int64     `json:"epoch"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	// Merkle root computed from the indexed data
+	MerkleRoot string `json:"merkleRoot"`
+
+	// Merkle root finalized on the voting contract, empty if the epoch is not
+	// finalized (yet)
+	FinalizedRoot string `json:"finalizedRoot,omitempty"`
+
+	// Deduplicated stakes in the order of the leaves of the merkle tree
+	Leaves []EpochLeafResponse `json:"leaves"`
+}
+
+type EpochProofResponse struct {
+	TxID        string   `json:"txId"`
+	LeafHash    string   `json:"leafHash"`
+	MerkleProof []string `json:"merkleProof"`
+}
+
+type GetEpochProofsResponse struct {
+	Epoch      int64                `json:"epoch"`
+	MerkleRoot string               `json:"merkleRoot"`
+	Proofs     []EpochProofResponse `json:"proofs"`
+}
+
+type finalizedRootSource interface {
+	GetMerkleRoot(epoch int64) ([32]byte, error)
+}
+
+type epochsRouteHandlers struct {
+	db        epochTreeDB
+	finalized finalizedRootSource
+	epochs    staking.EpochInfo
+}
+
+func newEpochsRouteHandlers(ctx context.ServicesContext, schedule *staking.EpochSchedule) (*epochsRouteHandlers, error) {
+	votingContract, err := newVotingContract(ctx.Config())
+	if err != nil {
+		return nil, err
+	}
+
+	return &epochsRouteHandlers{
+		db:        NewMirrorDBGorm(ctx.DB()),
+		finalized: votingContractRoots{votingContract},
+		epochs:    staking.NewScheduledEpochInfo(&globalConfig.EpochConfig{}, schedule),
+	}, nil
+}
+
+// Parse the epoch parameter, only epochs that already ended are served since
+// the data of the current epoch is not complete
+func (rh *epochsRouteHandlers) parseEpoch(params map[string]string) (int64, *utils.ErrorHandler) {
+	epoch, err := strconv.ParseInt(params["epoch"], 10, 64)
+	if err != nil {
+		return 0, utils.HttpErrorHandler(http.StatusBadRequest, "invalid epoch")
+	}
+	return epoch, rh.checkEpochEnded(epoch)
+}
+
+func (rh *epochsRouteHandlers) checkEpochEnded(epoch int64) *utils.ErrorHandler {
+	if epoch >= rh.epochs.GetEpochIndex(time.Now()) {
+		return utils.HttpErrorHandler(http.StatusBadRequest, "epoch has not ended yet")
+	}
+	return nil
+}
+
+func (rh *epochsRouteHandlers) getEpoch() utils.RouteHandler {
+	handler := func(params map[string]string) (GetEpochResponse, *utils.ErrorHandler) {
+		epoch, errHandler := rh.parseEpoch(params)
+		if errHandler != nil {
+			return GetEpochResponse{}, errHandler
+		}
+
+		start, end := rh.epochs.GetTimeRange(epoch)
+		txs, err := rh.db.GetPChainTxsForEpoch(start, end)
+		if err != nil {
+			return GetEpochResponse{}, utils.InternalServerErrorHandler(err)
+		}
+		tree, err := staking.NewEpochTree(epoch, txs)
+		if err != nil {
+			return GetEpochResponse{}, utils.InternalServerErrorHandler(err)
+		}
+		finalizedRoot, err := rh.finalized.GetMerkleRoot(epoch)
+		if err != nil {
+			return GetEpochResponse{}, utils.InternalServerErrorHandler(err)
+		}
+
+		dedupedTxs := make(map[string]*database.PChainTxData)
+		for _, tx := range staking.DedupeTxs(txs) {
+			tx := tx
+			dedupedTxs[*tx.TxID] = &tx
+		}
+
+		response := GetEpochResponse{
+			Epoch:      epoch,
+			StartTime:  start,
+			EndTime:    end,
+			MerkleRoot: tree.Root().Hex(),
+			Leaves:     make([]EpochLeafResponse, len(tree.Leaves)),
+		}
+		if finalizedRoot != [32]byte{} {
+			response.FinalizedRoot = common.Hash(finalizedRoot).Hex()
+		}
+		for i, leaf := range tree.Leaves {
+			stakeData, err := staking.ToStakeData(dedupedTxs[leaf.TxID])
+			if err != nil {
+				return GetEpochResponse{}, utils.InternalServerErrorHandler(err)
+			}
+			response.Leaves[i] = EpochLeafResponse{
+				StakeData: newMirroringStakeData(stakeData),
+				LeafHash:  leaf.Hash.Hex(),
+			}
+		}
+		return response, nil
+	}
+
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{"epoch:[0-9]+": "Epoch"},
+		GetEpochResponse{})
+}
+
+func (rh *epochsRouteHandlers) getEpochProofs() utils.RouteHandler {
+	handler := func(params map[string]string) (GetEpochProofsResponse, *utils.ErrorHandler) {
+		epoch, errHandler := rh.parseEpoch(params)
+		if errHandler != nil {
+			return GetEpochProofsResponse{}, errHandler
+		}
+
+		tree, err := getEpochTree(rh.db, rh.epochs, epoch)
+		if err != nil {
+			return GetEpochProofsResponse{}, utils.InternalServerErrorHandler(err)
+		}
+
+		response := GetEpochProofsResponse{
+			Epoch:      epoch,
+			MerkleRoot: tree.Root().Hex(),
+			Proofs:     make([]EpochProofResponse, len(tree.Leaves)),
+		}
+		for i, leaf := range tree.Leaves {
+			proof, err := tree.ProofAt(i)
+			if err != nil {
+				return GetEpochProofsResponse{}, utils.InternalServerErrorHandler(err)
+			}
+			response.Proofs[i] = EpochProofResponse{
+				TxID:        leaf.TxID,
+				LeafHash:    leaf.Hash.Hex(),
+				MerkleProof: encodeMerkleProof(proof),
+			}
+		}
+		return response, nil
+	}
+
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{"epoch:[0-9]+": "Epoch"},
+		GetEpochProofsResponse{})
+}
+
+func AddEpochRoutes(router utils.Router, ctx context.ServicesContext, schedule *staking.EpochSchedule) error {
+	rh, err := newEpochsRouteHandlers(ctx, schedule)
+	if err != nil {
+		return err
+	}
+
+	epochsSubrouter := router.WithPrefix("/epochs", "Epochs")
+	epochsSubrouter.Use(NewStaleDataMiddleware(ctx))
+	epochsSubrouter.AddRoute("/{epoch:[0-9]+}", rh.getEpoch(),
+		"Epoch stakes", "Time range, deduplicated stakes with their leaf hashes, the merkle root computed from the indexed data and the finalized merkle root of the epoch")
+	epochsSubrouter.AddRoute("/{epoch:[0-9]+}/proofs", rh.getEpochProofs(),
+		"Epoch merkle proofs", "Merkle proofs of all stakes of the epoch")
+
+	merkleSubrouter := router.WithPrefix("/merkle", "Merkle")
+	merkleSubrouter.Use(NewStaleDataMiddleware(ctx))
+	merkleSubrouter.AddRoute("/verify", rh.verifyMerkleProof(),
+		"Verify merkle proof", "Verify a merkle proof or a multi-proof (OpenZeppelin MerkleProof.multiProofVerify) of stakes against the merkle root computed from the indexed data and the finalized merkle root of the epoch")
+
+	return nil
+}
+
+type votingContractRoots struct {
+	voting *voting.Voting
+}
+
+func (v votingContractRoots) GetMerkleRoot(epoch int64) ([32]byte, error) {
+	return v.voting.GetMerkleRoot(new(bind.CallOpts), big.NewInt(epoch))
+}
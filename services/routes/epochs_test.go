@@ -0,0 +1,124 @@
+package routes
+
+import (
+	globalConfig "flare-indexer/config"
+	"flare-indexer/database"
+	"flare-indexer/services/api"
+	serviceUtils "flare-indexer/services/utils"
+	"flare-indexer/utils/merkle"
+	"flare-indexer/utils/staking"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type testFinalizedRoots map[int64][32]byte
+
+func (r testFinalizedRoots) GetMerkleRoot(epoch int64) ([32]byte, error) {
+	return r[epoch], nil
+}
+
+func newEpochsTestRouteHandlers() (*epochsRouteHandlers, *testDB) {
+	txs := map[string]database.PChainTxData{}
+	for txID, tx := range testMirroringData {
+		tx.ID = 1
+		txs[txID] = tx
+	}
+	tx := testMirroringData["2NuEmDJopBVunGZym7pcYjfuWTPaoWuHSnSvxiqdFdvDY7TGqQ"]
+	tx.ID = 2
+	tx.TxID = pString("XnfV79XVMyuXbTw8iNreQ9FrUgy9csYBJp1xRscay3oDzhyq8")
+	tx.Type = database.PChainAddValidatorTx
+	tx.Weight = 10000000000000
+	txs[*tx.TxID] = tx
+
+	db := newTestDB(txs).(*testDB)
+	return &epochsRouteHandlers{
+		db:        db,
+		finalized: testFinalizedRoots{},
+		epochs: staking.NewEpochInfo(
+			&globalConfig.EpochConfig{}, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 180*time.Second,
+		),
+	}, db
+}
+
+func TestGetEpoch(t *testing.T) {
+	rh, _ := newEpochsTestRouteHandlers()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/epochs/{epoch}", rh.getEpoch().Handler)
+	get := func() GetEpochResponse {
+		r, err := http.NewRequest(http.MethodGet, "/epochs/0", nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		var wResponse api.ApiResponseWrapper[GetEpochResponse]
+		serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+		return wResponse.Data
+	}
+
+	notFinalized := get()
+	require.Empty(t, notFinalized.FinalizedRoot)
+	require.Len(t, notFinalized.Leaves, 2)
+
+	rh.finalized.(testFinalizedRoots)[0] = common.HexToHash(notFinalized.MerkleRoot)
+	finalized := get()
+	require.Equal(t, finalized.MerkleRoot, finalized.FinalizedRoot)
+
+	cupaloy.SnapshotT(t, []GetEpochResponse{notFinalized, finalized})
+}
+
+func TestGetEpochProofs(t *testing.T) {
+	rh, db := newEpochsTestRouteHandlers()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/epochs/{epoch}/proofs", rh.getEpochProofs().Handler)
+
+	r, err := http.NewRequest(http.MethodGet, "/epochs/0/proofs", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	var wResponse api.ApiResponseWrapper[GetEpochProofsResponse]
+	serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+	response := wResponse.Data
+
+	require.Len(t, response.Proofs, 2)
+	root := common.HexToHash(response.MerkleRoot)
+	for _, proof := range response.Proofs {
+		hashes := make([]common.Hash, len(proof.MerkleProof))
+		for i, p := range proof.MerkleProof {
+			hashes[i] = common.HexToHash(p)
+		}
+		require.True(t, merkle.VerifyProof(common.HexToHash(proof.LeafHash), hashes, root))
+	}
+
+	// Proofs are served from the stored tree
+	require.Len(t, db.trees, 1)
+	require.Equal(t, response.MerkleRoot, db.trees[0].Root)
+
+	cupaloy.SnapshotT(t, response)
+}
+
+func TestGetEpochNotEnded(t *testing.T) {
+	rh, _ := newEpochsTestRouteHandlers()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/epochs/{epoch}", rh.getEpoch().Handler)
+
+	current := rh.epochs.GetEpochIndex(time.Now())
+	r, err := http.NewRequest(http.MethodGet, "/epochs/"+strconv.FormatInt(current, 10), nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/logger"
+	"flare-indexer/services/config"
+	"flare-indexer/services/context"
+	"flare-indexer/utils/health"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	dataAgeHeader   = "X-Data-Age"
+	dataStaleHeader = "X-Data-Stale"
+)
+
+// Add /healthz and /readyz checking the database, the P-chain indexer state
+// and the C-chain node
+func AddHealthRoutes(router *mux.Router, ctx context.ServicesContext) {
+	cfg := &ctx.Config().Health.HealthConfig
+	checker := health.NewChecker(cfg,
+		health.DBCheck(ctx.DB()),
+		health.StateCheck(ctx.DB(), database.PChainBlockStateName, cfg),
+		health.EthNodeCheck(ctx.EthRPCClient()),
+	)
+	checker.Register(router)
+}
+
+// Middleware responding with 503 or setting the stale data header if the last
+// update of the P-chain indexer is older than max_data_age. It is added to the
+// subrouters of the routes serving data for verifications.
+func NewStaleDataMiddleware(ctx context.ServicesContext) mux.MiddlewareFunc {
+	db := ctx.DB()
+	return newStaleDataMiddleware(&ctx.Config().Health, func() (database.State, error) {
+		return database.FetchState(db, database.PChainBlockStateName)
+	}, time.Now)
+}
+
+func newStaleDataMiddleware(cfg *config.HealthConfig, fetchState func() (database.State, error), now func() time.Time) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.MaxDataAge <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			state, err := fetchState()
+			if err != nil {
+				// Errors of the database are handled by the route
+				logger.Error("Failed fetching the P-chain indexer state: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			age := now().Sub(state.Updated)
+			w.Header().Set(dataAgeHeader, strconv.FormatInt(int64(age.Seconds()), 10))
+			if age > cfg.MaxDataAge {
+				if !cfg.StaleDataHeader {
+					msg := fmt.Sprintf("indexed data is stale, last update %v ago", age.Truncate(time.Second))
+					http.Error(w, msg, http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set(dataStaleHeader, "true")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"errors"
+	"flare-indexer/database"
+	"flare-indexer/services/config"
+	serviceUtils "flare-indexer/services/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleDataMiddleware(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	state := database.State{Name: database.PChainBlockStateName, Updated: now.Add(-90 * time.Second)}
+	var stateErr error
+	cfg := &config.HealthConfig{MaxDataAge: time.Minute}
+
+	// Middleware is added to the subrouters of the verification routes
+	r := mux.NewRouter()
+	router := serviceUtils.NewSwaggerRouter(r, "Test", "0.1.0")
+	ok := serviceUtils.RouteHandler{
+		Handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) },
+		Method:  http.MethodGet,
+	}
+	querySubrouter := router.WithPrefix("/query", "Query")
+	querySubrouter.Use(newStaleDataMiddleware(cfg, func() (database.State, error) {
+		return state, stateErr
+	}, func() time.Time { return now }))
+	querySubrouter.AddRoute("/prepare", ok)
+	router.WithPrefix("/validators", "Validators").AddRoute("/list", ok)
+
+	get := func(path string, code int) http.Header {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, code, rr.Code)
+		return rr.Header()
+	}
+
+	header := get("/query/prepare", http.StatusServiceUnavailable)
+	require.Equal(t, "90", header.Get(dataAgeHeader))
+
+	// Only verification routes are checked
+	header = get("/validators/list", http.StatusOK)
+	require.Empty(t, header.Get(dataAgeHeader))
+
+	cfg.StaleDataHeader = true
+	header = get("/query/prepare", http.StatusOK)
+	require.Equal(t, "true", header.Get(dataStaleHeader))
+
+	state.Updated = now.Add(-30 * time.Second)
+	header = get("/query/prepare", http.StatusOK)
+	require.Equal(t, "30", header.Get(dataAgeHeader))
+	require.Empty(t, header.Get(dataStaleHeader))
+
+	// Requests are served if the state cannot be fetched
+	stateErr = errors.New("db error")
+	cfg.StaleDataHeader = false
+	get("/query/prepare", http.StatusOK)
+}
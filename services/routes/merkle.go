@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"flare-indexer/services/utils"
+	"flare-indexer/utils/merkle"
+	"flare-indexer/utils/staking"
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type MerkleVerifyRequest struct {
+	Epoch int64 `json:"epoch" validate:"gte=0"`
+
+	// Stake proven by a single-leaf proof
+	StakeData *MirroringStakeData `json:"stakeData,omitempty"`
+
+	// Stakes proven by a multi-proof, in the order of its leaves
+	Stakes []MirroringStakeData `json:"stakes,omitempty"`
+
+	MerkleProof []string `json:"merkleProof"`
+
+	// Flags of a multi-proof, as in OpenZeppelin's MerkleProof.multiProofVerify
+	ProofFlags []bool `json:"proofFlags,omitempty"`
+}
+
+type MerkleVerifyResponse struct {
+	Epoch      int64    `json:"epoch"`
+	LeafHashes []string `json:"leafHashes"`
+
+	// Merkle root computed from the stakes and the proof
+	ComputedRoot string `json:"computedRoot"`
+
+	// Merkle root computed from the indexed data
+	MerkleRoot string `json:"merkleRoot"`
+
+	// Merkle root finalized on the voting contract, empty if the epoch is not
+	// finalized (yet)
+	FinalizedRoot string `json:"finalizedRoot,omitempty"`
+
+	// The proof is valid for the finalized root, or for the root computed from
+	// the indexed data if the epoch is not finalized
+	Valid bool `json:"valid"`
+}
+
+func (rh *epochsRouteHandlers) verifyMerkleProof() utils.RouteHandler {
+	handler := func(request MerkleVerifyRequest) (MerkleVerifyResponse, *utils.ErrorHandler) {
+		if errHandler := rh.checkEpochEnded(request.Epoch); errHandler != nil {
+			return MerkleVerifyResponse{}, errHandler
+		}
+
+		leaves, proof, err := parseMerkleVerifyRequest(&request)
+		if err != nil {
+			return MerkleVerifyResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, err.Error())
+		}
+
+		var computedRoot common.Hash
+		if request.StakeData != nil {
+			computedRoot = merkle.ProcessProof(leaves[0], proof)
+		} else {
+			computedRoot, err = merkle.ProcessMultiProof(&merkle.MultiProof{
+				Leaves:     leaves,
+				Proof:      proof,
+				ProofFlags: request.ProofFlags,
+			})
+			if err != nil {
+				return MerkleVerifyResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, err.Error())
+			}
+		}
+
+		tree, err := getEpochTree(rh.db, rh.epochs, request.Epoch)
+		if err != nil {
+			return MerkleVerifyResponse{}, utils.InternalServerErrorHandler(err)
+		}
+		finalizedRoot, err := rh.finalized.GetMerkleRoot(request.Epoch)
+		if err != nil {
+			return MerkleVerifyResponse{}, utils.InternalServerErrorHandler(err)
+		}
+
+		response := MerkleVerifyResponse{
+			Epoch:        request.Epoch,
+			LeafHashes:   make([]string, len(leaves)),
+			ComputedRoot: computedRoot.Hex(),
+			MerkleRoot:   tree.Root().Hex(),
+		}
+		for i := range leaves {
+			response.LeafHashes[i] = leaves[i].Hex()
+		}
+		if finalizedRoot != [32]byte{} {
+			response.FinalizedRoot = common.Hash(finalizedRoot).Hex()
+			response.Valid = computedRoot == finalizedRoot
+		} else {
+			response.Valid = computedRoot == tree.Root()
+		}
+		return response, nil
+	}
+
+	return utils.NewRouteHandler(handler, http.MethodPost, MerkleVerifyRequest{}, MerkleVerifyResponse{})
+}
+
+// Leaf hashes of the stakes and the decoded proof of the request
+func parseMerkleVerifyRequest(request *MerkleVerifyRequest) ([]common.Hash, []common.Hash, error) {
+	var stakes []MirroringStakeData
+	switch {
+	case request.StakeData != nil && request.Stakes != nil:
+		return nil, nil, fmt.Errorf("only one of stakeData and stakes can be set")
+	case request.StakeData != nil:
+		if len(request.ProofFlags) > 0 {
+			return nil, nil, fmt.Errorf("proofFlags can only be set with stakes")
+		}
+		stakes = []MirroringStakeData{*request.StakeData}
+	case request.Stakes != nil:
+		stakes = request.Stakes
+	default:
+		return nil, nil, fmt.Errorf("one of stakeData and stakes must be set")
+	}
+
+	leaves := make([]common.Hash, len(stakes))
+	for i := range stakes {
+		stakeData, err := parseMirroringStakeData(&stakes[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		leaves[i], err = staking.HashStakeData(stakeData)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	proof := make([]common.Hash, len(request.MerkleProof))
+	for i, p := range request.MerkleProof {
+		var hash common.Hash
+		if err := decodeFixedHex(p, hash[:]); err != nil {
+			return nil, nil, fmt.Errorf("invalid merkleProof: %w", err)
+		}
+		proof[i] = hash
+	}
+	return leaves, proof, nil
+}
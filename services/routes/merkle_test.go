@@ -0,0 +1,219 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"flare-indexer/database"
+	"flare-indexer/services/api"
+	serviceUtils "flare-indexer/services/utils"
+	"flare-indexer/utils/merkle"
+	"flare-indexer/utils/staking"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// Route handlers with n random stakes in epoch 0
+func newMerkleTestRouteHandlers(r *rand.Rand, n int) *epochsRouteHandlers {
+	template := testMirroringData["2NuEmDJopBVunGZym7pcYjfuWTPaoWuHSnSvxiqdFdvDY7TGqQ"]
+	txs := make(map[string]database.PChainTxData, n)
+	for i := 0; i < n; i++ {
+		var txID ids.ID
+		r.Read(txID[:])
+		tx := template
+		tx.ID = uint64(i + 1)
+		tx.TxID = pString(txID.String())
+		tx.Weight = uint64(r.Int63())
+		txs[*tx.TxID] = tx
+	}
+	rh, _ := newEpochsTestRouteHandlers()
+	rh.db = newTestDB(txs).(*testDB)
+	return rh
+}
+
+func postMerkleVerify(t *testing.T, rh *epochsRouteHandlers, request MerkleVerifyRequest) (int, MerkleVerifyResponse) {
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+	r, err := http.NewRequest(http.MethodPost, "/merkle/verify", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/merkle/verify", rh.verifyMerkleProof().Handler)
+	router.ServeHTTP(w, r)
+
+	var wResponse api.ApiResponseWrapper[MerkleVerifyResponse]
+	if w.Result().StatusCode == http.StatusOK {
+		serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+	}
+	return w.Result().StatusCode, wResponse.Data
+}
+
+// Stake data of the leaves of the tree of epoch 0, in the order of the tree
+func merkleTestStakes(t *testing.T, rh *epochsRouteHandlers) (*staking.EpochTree, []MirroringStakeData) {
+	tree, err := getEpochTree(rh.db, rh.epochs, 0)
+	require.NoError(t, err)
+	txs := rh.db.(*testDB).txs
+	stakes := make([]MirroringStakeData, len(tree.Leaves))
+	for i, leaf := range tree.Leaves {
+		tx := txs[leaf.TxID]
+		stakeData, err := staking.ToStakeData(&tx)
+		require.NoError(t, err)
+		stakes[i] = newMirroringStakeData(stakeData)
+	}
+	return tree, stakes
+}
+
+func encodeHashes(hashes []common.Hash) []string {
+	encoded := make([]string, len(hashes))
+	for i := range hashes {
+		encoded[i] = hexutil.Encode(hashes[i][:])
+	}
+	return encoded
+}
+
+func TestVerifyMerkleProof(t *testing.T) {
+	rh := newMerkleTestRouteHandlers(rand.New(rand.NewSource(1)), 3)
+	tree, stakes := merkleTestStakes(t, rh)
+	proof, err := tree.ProofAt(1)
+	require.NoError(t, err)
+
+	request := MerkleVerifyRequest{
+		Epoch:       0,
+		StakeData:   &stakes[1],
+		MerkleProof: encodeMerkleProof(proof),
+	}
+	var responses []MerkleVerifyResponse
+
+	// Not finalized, verified against the root computed from the indexed data
+	status, response := postMerkleVerify(t, rh, request)
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, response.Valid)
+	require.Equal(t, response.MerkleRoot, response.ComputedRoot)
+	responses = append(responses, response)
+
+	// Finalized with a different root
+	rh.finalized.(testFinalizedRoots)[0] = common.Hash{1}
+	status, response = postMerkleVerify(t, rh, request)
+	require.Equal(t, http.StatusOK, status)
+	require.False(t, response.Valid)
+	responses = append(responses, response)
+
+	// Finalized
+	rh.finalized.(testFinalizedRoots)[0] = tree.Root()
+	tampered := stakes[1]
+	tampered.Weight++
+	for _, stake := range []MirroringStakeData{stakes[1], tampered} {
+		stake := stake
+		request.StakeData = &stake
+		status, response = postMerkleVerify(t, rh, request)
+		require.Equal(t, http.StatusOK, status)
+		responses = append(responses, response)
+	}
+	require.True(t, responses[2].Valid)
+	require.False(t, responses[3].Valid)
+
+	cupaloy.SnapshotT(t, responses)
+}
+
+func TestVerifyMerkleProofBadRequest(t *testing.T) {
+	rh := newMerkleTestRouteHandlers(rand.New(rand.NewSource(1)), 3)
+	_, stakes := merkleTestStakes(t, rh)
+
+	invalidStake := stakes[0]
+	invalidStake.NodeId = "0x01"
+	current := rh.epochs.GetEpochIndex(time.Now())
+
+	for _, request := range []MerkleVerifyRequest{
+		{Epoch: current, StakeData: &stakes[0]},
+		{Epoch: 0},
+		{Epoch: 0, StakeData: &stakes[0], Stakes: stakes},
+		{Epoch: 0, StakeData: &stakes[0], ProofFlags: []bool{true}},
+		{Epoch: 0, StakeData: &invalidStake},
+		{Epoch: 0, StakeData: &stakes[0], MerkleProof: []string{"0x01"}},
+		{Epoch: 0, Stakes: stakes, ProofFlags: []bool{true}},
+	} {
+		status, _ := postMerkleVerify(t, rh, request)
+		require.Equal(t, http.StatusBadRequest, status)
+	}
+}
+
+// Random epoch with a random non-empty subset of its stakes
+type merkleVerifyInput struct {
+	Seed    int64
+	Stakes  int
+	Indices []int
+}
+
+func (merkleVerifyInput) Generate(r *rand.Rand, size int) reflect.Value {
+	input := merkleVerifyInput{Seed: r.Int63(), Stakes: r.Intn(size%16+1) + 1}
+	for j, i := range r.Perm(input.Stakes) {
+		if j == 0 || r.Intn(2) == 0 {
+			input.Indices = append(input.Indices, i)
+		}
+	}
+	return reflect.ValueOf(input)
+}
+
+func TestVerifyMerkleMultiProofProperties(t *testing.T) {
+	request := func(in merkleVerifyInput) (*epochsRouteHandlers, MerkleVerifyRequest) {
+		rh := newMerkleTestRouteHandlers(rand.New(rand.NewSource(in.Seed)), in.Stakes)
+		tree, stakes := merkleTestStakes(t, rh)
+		proof, err := tree.Tree.GetMultiProof(in.Indices)
+		require.NoError(t, err)
+
+		request := MerkleVerifyRequest{
+			Epoch:       0,
+			Stakes:      make([]MirroringStakeData, len(proof.Leaves)),
+			MerkleProof: encodeHashes(proof.Proof),
+			ProofFlags:  proof.ProofFlags,
+		}
+		for i, leaf := range proof.Leaves {
+			for j := range tree.Leaves {
+				if tree.Leaves[j].Hash == leaf {
+					request.Stakes[i] = stakes[j]
+				}
+			}
+		}
+		return rh, request
+	}
+
+	valid := func(in merkleVerifyInput) bool {
+		rh, request := request(in)
+		status, response := postMerkleVerify(t, rh, request)
+		return status == http.StatusOK && response.Valid && merkle.VerifyMultiProof(&merkle.MultiProof{
+			Leaves:     hexToHashes(response.LeafHashes),
+			Proof:      hexToHashes(request.MerkleProof),
+			ProofFlags: request.ProofFlags,
+		}, common.HexToHash(response.MerkleRoot))
+	}
+	require.NoError(t, quick.Check(valid, nil))
+
+	// Changing any of the proven stakes invalidates the proof
+	tampered := func(in merkleVerifyInput, k int) bool {
+		rh, request := request(in)
+		request.Stakes[int(uint(k)%uint(len(request.Stakes)))].EndTime++
+		status, response := postMerkleVerify(t, rh, request)
+		return status == http.StatusOK && !response.Valid
+	}
+	require.NoError(t, quick.Check(tampered, nil))
+}
+
+func hexToHashes(hexValues []string) []common.Hash {
+	hashes := make([]common.Hash, len(hexValues))
+	for i := range hexValues {
+		hashes[i] = common.HexToHash(hexValues[i])
+	}
+	return hashes
+}
@@ -41,9 +41,22 @@ type MirroringResponse struct {
 
 type GetMirroringResponse []MirroringResponse
 
+type GetMirrorStatusResponse struct {
+	TxID         string     `json:"txId"`
+	Epoch        int64      `json:"epoch"`
+	Status       string     `json:"status"`
+	SkipReason   string     `json:"skipReason,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CChainTxHash string     `json:"cChainTxHash,omitempty"`
+	Attempts     int        `json:"attempts"`
+	NextRetry    *time.Time `json:"nextRetry,omitempty"`
+	Updated      time.Time  `json:"updated"`
+}
+
 type mirrorDB interface {
-	GetPChainTxsForEpoch(start, end time.Time) ([]database.PChainTxData, error)
+	epochTreeDB
 	GetPChainTx(txID string) (*database.PChainTx, error)
+	GetMirrorStatus(txID string) (*database.MirrorStatus, error)
 }
 
 type mirroringRouteHandlers struct {
@@ -51,32 +64,20 @@ type mirroringRouteHandlers struct {
 	epochs staking.EpochInfo
 }
 
-func newMirroringRouteHandlers(ctx context.ServicesContext) (*mirroringRouteHandlers, error) {
-	cfg := ctx.Config()
-
-	start, period, err := getEpochStartAndPeriod(cfg)
-	if err != nil {
-		return nil, err
-	}
-
+func newMirroringRouteHandlers(ctx context.ServicesContext, schedule *staking.EpochSchedule) *mirroringRouteHandlers {
 	return &mirroringRouteHandlers{
 		db:     NewMirrorDBGorm(ctx.DB()),
-		epochs: staking.NewEpochInfo(&globalConfig.EpochConfig{}, start, period),
-	}, nil
+		epochs: staking.NewScheduledEpochInfo(&globalConfig.EpochConfig{}, schedule),
+	}
 }
 
-func getEpochStartAndPeriod(cfg *config.Config) (time.Time, time.Duration, error) {
+func newVotingContract(cfg *config.Config) (*voting.Voting, error) {
 	eth, err := ethclient.Dial(cfg.Chain.EthRPCURL)
 	if err != nil {
-		return time.Time{}, 0, err
-	}
-
-	votingContract, err := voting.NewVoting(cfg.ContractAddresses.Voting, eth)
-	if err != nil {
-		return time.Time{}, 0, err
+		return nil, err
 	}
 
-	return staking.GetEpochConfig(votingContract)
+	return voting.NewVoting(cfg.ContractAddresses.Voting, eth)
 }
 
 func (rh *mirroringRouteHandlers) listMirroringTransactions() utils.RouteHandler {
@@ -102,70 +103,127 @@ func (rh *mirroringRouteHandlers) listMirroringTransactions() utils.RouteHandler
 		GetMirroringResponse{})
 }
 
-func AddMirroringRoutes(router utils.Router, ctx context.ServicesContext) error {
-	rh, err := newMirroringRouteHandlers(ctx)
-	if err != nil {
-		return err
+func (rh *mirroringRouteHandlers) getMirrorStatus() utils.RouteHandler {
+	handler := func(params map[string]string) (GetMirrorStatusResponse, *utils.ErrorHandler) {
+		txID := params["tx_id"]
+		status, err := rh.db.GetMirrorStatus(txID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return GetMirrorStatusResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, "tx not processed by mirroring")
+			} else {
+				return GetMirrorStatusResponse{}, utils.InternalServerErrorHandler(err)
+			}
+		}
+		return GetMirrorStatusResponse{
+			TxID:         status.TxID,
+			Epoch:        status.Epoch,
+			Status:       string(status.Status),
+			SkipReason:   string(status.SkipReason),
+			Error:        status.Error,
+			CChainTxHash: status.CChainTxHash,
+			Attempts:     status.Attempts,
+			NextRetry:    status.NextRetry,
+			Updated:      status.Updated,
+		}, nil
 	}
 
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{"tx_id:[0-9a-zA-Z]+": "Transaction ID"},
+		GetMirrorStatusResponse{})
+}
+
+func AddMirroringRoutes(router utils.Router, ctx context.ServicesContext, schedule *staking.EpochSchedule) {
+	rh := newMirroringRouteHandlers(ctx, schedule)
+
 	mirroringSubrouter := router.WithPrefix("/mirroring", "Mirroring")
+	mirroringSubrouter.Use(NewStaleDataMiddleware(ctx))
 	mirroringSubrouter.AddRoute("/tx_data/{tx_id:[0-9a-zA-Z]+}", rh.listMirroringTransactions())
-
-	return nil
+	mirroringSubrouter.AddRoute("/status/{tx_id:[0-9a-zA-Z]+}", rh.getMirrorStatus(),
+		"Mirroring status", "Status of the transaction in the mirroring client: pending, mirrored, skipped or failed")
 }
 
 func (rh *mirroringRouteHandlers) createMirroringData(tx *database.PChainTx) ([]MirroringResponse, error) {
 	epoch := rh.epochs.GetEpochIndex(*tx.StartTime)
-	startTimestamp, endTimestamp := rh.epochs.GetTimeRange(epoch)
-	txs, err := rh.db.GetPChainTxsForEpoch(startTimestamp, endTimestamp)
+	tree, err := getEpochTree(rh.db, rh.epochs, epoch)
 	if err != nil {
 		return nil, err
 	}
-	txs = staking.DedupeTxs(txs)
-	merkleTree, err := staking.BuildTree(txs)
+
+	leaf, merkleProof, err := tree.Proof(*tx.TxID)
+	if errors.Is(err, staking.ErrTxNotInTree) {
+		return nil, fmt.Errorf("no mirroring data found for tx %s", *tx.TxID)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var mirroringData []MirroringResponse
-	for _, txData := range txs {
-		if txData.ID != tx.ID {
-			continue
-		}
-		stakeData, err := staking.ToStakeData(&txData)
-		if err != nil {
-			return nil, err
-		}
-		merkleProof, err := staking.GetMerkleProof(merkleTree, &txData)
-		if err != nil {
-			return nil, err
-		}
-		txDataBytes, err := createMirrorTransactionBytes(stakeData, merkleProof)
-		if err != nil {
-			return nil, err
-		}
-		merkleProofStrings := make([]string, len(merkleProof))
-		for i, proof := range merkleProof {
-			merkleProofStrings[i] = hexutil.Encode(proof[:])
-		}
-		mirroringData = append(mirroringData, MirroringResponse{
-			StakeData: MirroringStakeData{
-				TxID:         hexutil.Encode(stakeData.TxId[:]),
-				StakingType:  stakeData.StakingType,
-				InputAddress: hexutil.Encode(stakeData.InputAddress[:]),
-				NodeId:       hexutil.Encode(stakeData.NodeId[:]),
-				StartTime:    stakeData.StartTime,
-				EndTime:      stakeData.EndTime,
-				Weight:       stakeData.Weight,
-			},
-			MerkleProof: merkleProofStrings,
-			TxInput:     hexutil.Encode(txDataBytes),
-		})
-	}
-	if len(mirroringData) == 0 {
-		return nil, fmt.Errorf("no mirroring data found for tx %s", *tx.TxID)
+	stakeData, err := staking.ToStakeData(&database.PChainTxData{PChainTx: *tx, InputAddress: leaf.InputAddress})
+	if err != nil {
+		return nil, err
+	}
+	txDataBytes, err := createMirrorTransactionBytes(stakeData, merkleProof)
+	if err != nil {
+		return nil, err
+	}
+	return []MirroringResponse{{
+		StakeData:   newMirroringStakeData(stakeData),
+		MerkleProof: encodeMerkleProof(merkleProof),
+		TxInput:     hexutil.Encode(txDataBytes),
+	}}, nil
+}
+
+func encodeMerkleProof(proof [][32]byte) []string {
+	encoded := make([]string, len(proof))
+	for i := range proof {
+		encoded[i] = hexutil.Encode(proof[i][:])
+	}
+	return encoded
+}
+
+func newMirroringStakeData(stakeData *mirroring.IPChainStakeMirrorVerifierPChainStake) MirroringStakeData {
+	return MirroringStakeData{
+		TxID:         hexutil.Encode(stakeData.TxId[:]),
+		StakingType:  stakeData.StakingType,
+		InputAddress: hexutil.Encode(stakeData.InputAddress[:]),
+		NodeId:       hexutil.Encode(stakeData.NodeId[:]),
+		StartTime:    stakeData.StartTime,
+		EndTime:      stakeData.EndTime,
+		Weight:       stakeData.Weight,
 	}
-	return mirroringData, nil
+}
+
+// Stake data in the format of the mirroring contract, inverse of
+// newMirroringStakeData
+func parseMirroringStakeData(data *MirroringStakeData) (*mirroring.IPChainStakeMirrorVerifierPChainStake, error) {
+	stakeData := &mirroring.IPChainStakeMirrorVerifierPChainStake{
+		StakingType: data.StakingType,
+		StartTime:   data.StartTime,
+		EndTime:     data.EndTime,
+		Weight:      data.Weight,
+	}
+	if err := decodeFixedHex(data.TxID, stakeData.TxId[:]); err != nil {
+		return nil, fmt.Errorf("invalid txId: %w", err)
+	}
+	if err := decodeFixedHex(data.InputAddress, stakeData.InputAddress[:]); err != nil {
+		return nil, fmt.Errorf("invalid inputAddress: %w", err)
+	}
+	if err := decodeFixedHex(data.NodeId, stakeData.NodeId[:]); err != nil {
+		return nil, fmt.Errorf("invalid nodeId: %w", err)
+	}
+	return stakeData, nil
+}
+
+// Decode the 0x-prefixed hex string into dst, which it must fill exactly
+func decodeFixedHex(s string, dst []byte) error {
+	decoded, err := hexutil.Decode(s)
+	if err != nil {
+		return err
+	}
+	if len(decoded) != len(dst) {
+		return fmt.Errorf("expected %d bytes, got %d", len(dst), len(decoded))
+	}
+	copy(dst, decoded)
+	return nil
 }
 
 func createMirrorTransactionBytes(stakeData *mirroring.IPChainStakeMirrorVerifierPChainStake, merkleProof [][32]byte) ([]byte, error) {
@@ -196,6 +254,22 @@ func (m mirrorDBGorm) GetPChainTxsForEpoch(start, end time.Time) ([]database.PCh
 	})
 }
 
+func (m mirrorDBGorm) FetchPChainTxsFingerprint(start, end time.Time) (database.PChainTxsFingerprint, error) {
+	return database.FetchPChainTxsFingerprint(m.db, start, end)
+}
+
+func (m mirrorDBGorm) FetchEpochMerkleTree(epoch int64) (*database.EpochMerkleTree, error) {
+	return database.FetchEpochMerkleTree(m.db, epoch)
+}
+
+func (m mirrorDBGorm) SaveEpochMerkleTree(tree *database.EpochMerkleTree) error {
+	return database.PersistEpochMerkleTree(m.db, tree)
+}
+
 func (m mirrorDBGorm) GetPChainTx(txID string) (*database.PChainTx, error) {
 	return database.FetchPChainTx(m.db, txID)
 }
+
+func (m mirrorDBGorm) GetMirrorStatus(txID string) (*database.MirrorStatus, error) {
+	return database.FetchMirrorStatus(m.db, txID)
+}
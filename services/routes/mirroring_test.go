@@ -61,25 +61,115 @@ func TestGetMirroringData(t *testing.T) {
 	cupaloy.SnapshotT(t, wResponse)
 }
 
+func TestGetMirroringDataStoredTree(t *testing.T) {
+	txs := map[string]database.PChainTxData{}
+	for txID, tx := range testMirroringData {
+		tx.ID = 1
+		txs[txID] = tx
+	}
+	mh := newMirroringTestRouteHandlers(txs)
+	db := mh.db.(*testDB)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/tx_data/{tx_id}", mh.listMirroringTransactions().Handler)
+	get := func(txID string) GetMirroringResponse {
+		r, err := http.NewRequest(http.MethodGet, "/tx_data/"+txID, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		var wResponse api.ApiResponseWrapper[GetMirroringResponse]
+		serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+		return wResponse.Data
+	}
+
+	// Tree is computed once and stored
+	txID := "2NuEmDJopBVunGZym7pcYjfuWTPaoWuHSnSvxiqdFdvDY7TGqQ"
+	first := get(txID)
+	require.Equal(t, 1, db.epochQueries)
+	require.Len(t, db.trees, 1)
+	require.Equal(t, first, get(txID))
+	require.Equal(t, 1, db.epochQueries)
+
+	// New tx of the same epoch invalidates the tree
+	newTx := testMirroringData[txID]
+	newTx.ID = 2
+	newTx.TxID = pString("XnfV79XVMyuXbTw8iNreQ9FrUgy9csYBJp1xRscay3oDzhyq8")
+	txs[*newTx.TxID] = newTx
+
+	second := get(txID)
+	require.Equal(t, 2, db.epochQueries)
+	require.Len(t, second[0].MerkleProof, 1)
+	require.NotEqual(t, first[0].TxInput, second[0].TxInput)
+	for _, tree := range db.trees {
+		require.Equal(t, 2, tree.NumLeaves)
+		require.Equal(t, int64(2), tree.TxCount)
+	}
+}
+
+func TestGetMirrorStatus(t *testing.T) {
+	nextRetry := time.Date(2023, time.January, 1, 0, 10, 0, 0, time.UTC)
+	mh := newMirroringTestRouteHandlers(testMirroringData)
+	mh.db.(*testDB).statuses["2NuEmDJopBVunGZym7pcYjfuWTPaoWuHSnSvxiqdFdvDY7TGqQ"] = database.MirrorStatus{
+		TxID:         "2NuEmDJopBVunGZym7pcYjfuWTPaoWuHSnSvxiqdFdvDY7TGqQ",
+		Epoch:        3,
+		Status:       database.MirrorTxStatusFailed,
+		Error:        "transaction not mined",
+		CChainTxHash: "0x9b1f3b5a4e0c6f6c8f0b1b3a6d2e7c4f5a8d9e0f1a2b3c4d5e6f708192a3b4c5",
+		Attempts:     2,
+		NextRetry:    &nextRetry,
+		Updated:      time.Date(2023, time.January, 1, 0, 8, 0, 0, time.UTC),
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/status/{tx_id}", mh.getMirrorStatus().Handler)
+
+	r, err := http.NewRequest(http.MethodGet, "/status/2NuEmDJopBVunGZym7pcYjfuWTPaoWuHSnSvxiqdFdvDY7TGqQ", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	var wResponse api.ApiResponseWrapper[GetMirrorStatusResponse]
+	serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+	cupaloy.SnapshotT(t, wResponse)
+
+	r, err = http.NewRequest(http.MethodGet, "/status/unknown", nil)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
 func newMirroringTestRouteHandlers(txs map[string]database.PChainTxData) *mirroringRouteHandlers {
 	return &mirroringRouteHandlers{
 		db: newTestDB(txs),
-		epochs: staking.EpochInfo{
-			Period: 180 * time.Second,
-			Start:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
-		},
+		epochs: staking.NewEpochInfo(
+			&globalConfig.EpochConfig{}, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 180*time.Second,
+		),
 	}
 }
 
 type testDB struct {
-	txs map[string]database.PChainTxData
+	txs      map[string]database.PChainTxData
+	statuses map[string]database.MirrorStatus
+	trees    map[int64]database.EpochMerkleTree
+
+	// Number of queries of the epoch data
+	epochQueries int
 }
 
 func newTestDB(txs map[string]database.PChainTxData) mirrorDB {
-	return &testDB{txs}
+	return &testDB{
+		txs:      txs,
+		statuses: make(map[string]database.MirrorStatus),
+		trees:    make(map[int64]database.EpochMerkleTree),
+	}
 }
 
-func (db testDB) GetPChainTxsForEpoch(start, end time.Time) ([]database.PChainTxData, error) {
+func (db *testDB) GetPChainTxsForEpoch(start, end time.Time) ([]database.PChainTxData, error) {
+	db.epochQueries++
 	var txs []database.PChainTxData
 	for _, tx := range db.txs {
 		if !tx.StartTime.Before(start) && tx.StartTime.Before(end) {
@@ -89,13 +179,45 @@ func (db testDB) GetPChainTxsForEpoch(start, end time.Time) ([]database.PChainTx
 	return txs, nil
 }
 
-func (db testDB) GetPChainTx(txID string) (*database.PChainTx, error) {
+func (db *testDB) FetchPChainTxsFingerprint(start, end time.Time) (database.PChainTxsFingerprint, error) {
+	var fingerprint database.PChainTxsFingerprint
+	for _, tx := range db.txs {
+		if !tx.StartTime.Before(start) && tx.StartTime.Before(end) {
+			fingerprint.TxCount++
+			if tx.ID > fingerprint.MaxTxID {
+				fingerprint.MaxTxID = tx.ID
+			}
+		}
+	}
+	return fingerprint, nil
+}
+
+func (db *testDB) FetchEpochMerkleTree(epoch int64) (*database.EpochMerkleTree, error) {
+	if tree, ok := db.trees[epoch]; ok {
+		return &tree, nil
+	}
+	return nil, nil
+}
+
+func (db *testDB) SaveEpochMerkleTree(tree *database.EpochMerkleTree) error {
+	db.trees[tree.Epoch] = *tree
+	return nil
+}
+
+func (db *testDB) GetPChainTx(txID string) (*database.PChainTx, error) {
 	if tx, ok := db.txs[txID]; ok {
 		return &tx.PChainTx, nil
 	}
 	return nil, gorm.ErrRecordNotFound
 }
 
+func (db *testDB) GetMirrorStatus(txID string) (*database.MirrorStatus, error) {
+	if status, ok := db.statuses[txID]; ok {
+		return &status, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
 func pString(s string) *string { return &s }
 
 func pTime(year int, month time.Month, day, hour, min, sec, nsec int, loc *time.Location) *time.Time {
@@ -17,6 +17,7 @@ import (
 func AddQueryRoutes(router utils.Router, ctx context.ServicesContext) {
 	qr := newQueryRouteHandlers(ctx)
 	subrouter := router.WithPrefix("/query", "Query")
+	subrouter.Use(NewStaleDataMiddleware(ctx))
 
 	subrouter.AddRoute("", qr.processAttestationRequest(), "",
 		"Verifies attestation request")
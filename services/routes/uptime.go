@@ -0,0 +1,335 @@
+package routes
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/services/context"
+	"flare-indexer/services/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+)
+
+const (
+	// Number of the latest epochs returned for a node
+	nodeUptimeEpochs = 100
+
+	// Max length of the time range of a connectivity timeline
+	maxUptimeTimelineRange = 24 * time.Hour
+)
+
+type UptimeAggregationResponse struct {
+	Epoch     int64     `json:"epoch"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	// Connected time and length of the staking interval(s) in the epoch in
+	// seconds
+	Value           int64 `json:"value"`
+	StakingDuration int64 `json:"stakingDuration"`
+
+	// Time with an error status or without samples and the part of it that is
+	// excluded from the uptime ratio, in seconds
+	AmbiguousDuration int64 `json:"ambiguousDuration"`
+	ExcludedDuration  int64 `json:"excludedDuration"`
+
+	Ratio           float64 `json:"ratio"`
+	PassedThreshold bool    `json:"passedThreshold"`
+
+	// Mean uptime ratio reported by the observers and its difference to the
+	// sampled ratio, nil if the observers did not report the uptime of the node
+	ReportedRatio      *float64 `json:"reportedRatio"`
+	ReportedDivergence *float64 `json:"reportedDivergence"`
+}
+
+type NodeUptimeAggregationResponse struct {
+	NodeID string `json:"nodeId"`
+	UptimeAggregationResponse
+
+	// Node is in the uptime vote of the voter of the indexer
+	Voted bool `json:"voted"`
+}
+
+type GetNodeUptimeResponse struct {
+	NodeID    string  `json:"nodeId"`
+	Threshold float64 `json:"threshold"`
+
+	// Latest aggregations, sorted by epoch descending
+	Aggregations []UptimeAggregationResponse `json:"aggregations"`
+}
+
+type UptimeIntervalResponse struct {
+	Status    string    `json:"status"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+}
+
+type UptimeSampleResponse struct {
+	Timestamp time.Time `json:"timestamp"`
+	Observer  string    `json:"observer"`
+	Status    string    `json:"status"`
+}
+
+type GetNodeUptimeTimelineResponse struct {
+	NodeID    string    `json:"nodeId"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	// Compacted intervals overlapping with the time range
+	Intervals []UptimeIntervalResponse `json:"intervals"`
+
+	// Samples of the observers in the time range that are not deleted yet,
+	// failed calls of the observers and indexer starts have no node
+	Samples []UptimeSampleResponse `json:"samples"`
+}
+
+type GetUptimeEpochResponse struct {
+	Epoch     int64   `json:"epoch"`
+	Threshold float64 `json:"threshold"`
+
+	Nodes []NodeUptimeAggregationResponse `json:"nodes"`
+
+	// Node ids in the uptime vote of the voter of the indexer, empty if the
+	// voter is not configured or did not vote
+	VotedNodeIDs []string `json:"votedNodeIds"`
+}
+
+type uptimeDB interface {
+	GetNodeUptimeAggregations(nodeID string, limit int) ([]database.UptimeAggregation, error)
+	GetEpochUptimeAggregations(epoch int64) ([]database.UptimeAggregation, error)
+	GetNodeUptimeIntervals(nodeID string, start, end time.Time) ([]database.UptimeInterval, error)
+	GetNodeUptimeSamples(nodeID string, start, end time.Time) ([]database.UptimeCronjob, error)
+	GetUptimeVotes(rewardEpoch int64) ([]database.VotingUptimeVote, error)
+}
+
+type uptimeRouteHandlers struct {
+	db        uptimeDB
+	threshold float64
+	voter     common.Address
+}
+
+func newUptimeRouteHandlers(ctx context.ServicesContext) *uptimeRouteHandlers {
+	cfg := ctx.Config().Uptime
+	return &uptimeRouteHandlers{
+		db:        uptimeDBGorm{db: ctx.DB()},
+		threshold: cfg.UptimeThreshold,
+		voter:     cfg.Voter,
+	}
+}
+
+func (rh *uptimeRouteHandlers) newUptimeAggregationResponse(a *database.UptimeAggregation) UptimeAggregationResponse {
+	ratio, ok := a.UptimeRatio()
+	response := UptimeAggregationResponse{
+		Epoch:             int64(a.Epoch),
+		StartTime:         a.StartTime,
+		EndTime:           a.EndTime,
+		Value:             a.Value,
+		StakingDuration:   a.StakingDuration,
+		AmbiguousDuration: a.AmbiguousDuration,
+		ExcludedDuration:  a.ExcludedDuration,
+		Ratio:             ratio,
+		PassedThreshold:   ok && ratio >= rh.threshold,
+	}
+	if reported, ok := a.ReportedUptimeRatio(); ok {
+		response.ReportedRatio = &reported
+	}
+	if divergence, ok := a.ReportedUptimeDivergence(); ok {
+		response.ReportedDivergence = &divergence
+	}
+	return response
+}
+
+func (rh *uptimeRouteHandlers) getNodeUptime() utils.RouteHandler {
+	handler := func(params map[string]string) (GetNodeUptimeResponse, *utils.ErrorHandler) {
+		nodeID := params["nodeId"]
+		aggregations, err := rh.db.GetNodeUptimeAggregations(nodeID, nodeUptimeEpochs)
+		if err != nil {
+			return GetNodeUptimeResponse{}, utils.InternalServerErrorHandler(err)
+		}
+
+		response := GetNodeUptimeResponse{
+			NodeID:       nodeID,
+			Threshold:    rh.threshold,
+			Aggregations: make([]UptimeAggregationResponse, len(aggregations)),
+		}
+		for i := range aggregations {
+			response.Aggregations[i] = rh.newUptimeAggregationResponse(&aggregations[i])
+		}
+		return response, nil
+	}
+
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{"nodeId:NodeID-[0-9a-zA-Z]+": "Node ID"},
+		GetNodeUptimeResponse{})
+}
+
+func (rh *uptimeRouteHandlers) getNodeUptimeTimeline() utils.RouteHandler {
+	handler := func(params map[string]string) (GetNodeUptimeTimelineResponse, *utils.ErrorHandler) {
+		nodeID := params["nodeId"]
+		start, err1 := strconv.ParseInt(params["start"], 10, 64)
+		end, err2 := strconv.ParseInt(params["end"], 10, 64)
+		if err1 != nil || err2 != nil || end <= start {
+			return GetNodeUptimeTimelineResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, "invalid time range")
+		}
+		startTime, endTime := time.Unix(start, 0).UTC(), time.Unix(end, 0).UTC()
+		if endTime.Sub(startTime) > maxUptimeTimelineRange {
+			return GetNodeUptimeTimelineResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, "time range is too long")
+		}
+
+		intervals, err := rh.db.GetNodeUptimeIntervals(nodeID, startTime, endTime)
+		if err != nil {
+			return GetNodeUptimeTimelineResponse{}, utils.InternalServerErrorHandler(err)
+		}
+		samples, err := rh.db.GetNodeUptimeSamples(nodeID, startTime, endTime)
+		if err != nil {
+			return GetNodeUptimeTimelineResponse{}, utils.InternalServerErrorHandler(err)
+		}
+
+		response := GetNodeUptimeTimelineResponse{
+			NodeID:    nodeID,
+			StartTime: startTime,
+			EndTime:   endTime,
+			Intervals: make([]UptimeIntervalResponse, len(intervals)),
+			Samples:   make([]UptimeSampleResponse, len(samples)),
+		}
+		for i, interval := range intervals {
+			response.Intervals[i] = UptimeIntervalResponse{
+				Status:    interval.Status.String(),
+				StartTime: interval.StartTime,
+				EndTime:   interval.EndTime,
+			}
+		}
+		for i, sample := range samples {
+			response.Samples[i] = UptimeSampleResponse{
+				Timestamp: sample.Timestamp,
+				Observer:  sample.Observer,
+				Status:    sample.Status.String(),
+			}
+		}
+		return response, nil
+	}
+
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{
+			"nodeId:NodeID-[0-9a-zA-Z]+": "Node ID",
+			"start:[0-9]+":               "Start of the time range (unix timestamp)",
+			"end:[0-9]+":                 "End of the time range (unix timestamp)",
+		},
+		GetNodeUptimeTimelineResponse{})
+}
+
+func (rh *uptimeRouteHandlers) getUptimeEpoch() utils.RouteHandler {
+	handler := func(params map[string]string) (GetUptimeEpochResponse, *utils.ErrorHandler) {
+		epoch, err := strconv.ParseInt(params["epoch"], 10, 64)
+		if err != nil {
+			return GetUptimeEpochResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, "invalid epoch")
+		}
+		aggregations, err := rh.db.GetEpochUptimeAggregations(epoch)
+		if err != nil {
+			return GetUptimeEpochResponse{}, utils.InternalServerErrorHandler(err)
+		}
+		votedNodeIDs, err := rh.votedNodeIDs(epoch)
+		if err != nil {
+			return GetUptimeEpochResponse{}, utils.InternalServerErrorHandler(err)
+		}
+		voted := make(map[string]bool, len(votedNodeIDs))
+		for _, nodeID := range votedNodeIDs {
+			voted[nodeID] = true
+		}
+
+		response := GetUptimeEpochResponse{
+			Epoch:        epoch,
+			Threshold:    rh.threshold,
+			Nodes:        make([]NodeUptimeAggregationResponse, len(aggregations)),
+			VotedNodeIDs: votedNodeIDs,
+		}
+		for i := range aggregations {
+			response.Nodes[i] = NodeUptimeAggregationResponse{
+				NodeID:                    aggregations[i].NodeID,
+				UptimeAggregationResponse: rh.newUptimeAggregationResponse(&aggregations[i]),
+				Voted:                     voted[aggregations[i].NodeID],
+			}
+		}
+		return response, nil
+	}
+
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{"epoch:[0-9]+": "Epoch"},
+		GetUptimeEpochResponse{})
+}
+
+// Node ids in the last uptime vote of the voter for the epoch, sorted
+func (rh *uptimeRouteHandlers) votedNodeIDs(epoch int64) ([]string, error) {
+	nodeIDs := []string{}
+	if rh.voter == (common.Address{}) {
+		return nodeIDs, nil
+	}
+	votes, err := rh.db.GetUptimeVotes(epoch)
+	if err != nil {
+		return nil, err
+	}
+	for _, vote := range votes {
+		if common.HexToAddress(vote.Voter) != rh.voter {
+			continue
+		}
+		nodeIDs = []string{}
+		if len(vote.NodeIDs) > 0 {
+			nodeIDs = strings.Split(vote.NodeIDs, ",")
+		}
+	}
+	sort.Strings(nodeIDs)
+	return nodeIDs, nil
+}
+
+func AddUptimeRoutes(router utils.Router, ctx context.ServicesContext) {
+	rh := newUptimeRouteHandlers(ctx)
+
+	uptimeSubrouter := router.WithPrefix("/uptime", "Uptime")
+	uptimeSubrouter.AddRoute("/{nodeId:NodeID-[0-9a-zA-Z]+}", rh.getNodeUptime(),
+		"Node uptime", "Uptime aggregations of the latest epochs of the node with the uptime ratio and whether it passed the uptime threshold")
+	uptimeSubrouter.AddRoute("/{nodeId:NodeID-[0-9a-zA-Z]+}/timeline/{start:[0-9]+}/{end:[0-9]+}", rh.getNodeUptimeTimeline(),
+		"Node connectivity timeline", "Uptime intervals and samples of the observers of the node in the time range (at most one day)")
+	uptimeSubrouter.AddRoute("/epochs/{epoch:[0-9]+}", rh.getUptimeEpoch(),
+		"Epoch uptimes", "Uptime aggregations of all nodes in the epoch and the node ids in the uptime vote of the voter of the indexer")
+}
+
+type uptimeDBGorm struct {
+	db *gorm.DB
+}
+
+func (u uptimeDBGorm) GetNodeUptimeAggregations(nodeID string, limit int) ([]database.UptimeAggregation, error) {
+	return database.FetchNodeUptimeAggregations(u.db, nodeID, limit)
+}
+
+func (u uptimeDBGorm) GetEpochUptimeAggregations(epoch int64) ([]database.UptimeAggregation, error) {
+	return database.FetchEpochUptimeAggregations(u.db, epoch)
+}
+
+func (u uptimeDBGorm) GetNodeUptimeIntervals(nodeID string, start, end time.Time) ([]database.UptimeInterval, error) {
+	return database.FetchNodeUptimeIntervals(u.db, nodeID, start, end)
+}
+
+// Samples of the node and samples without a node, sorted by timestamp
+func (u uptimeDBGorm) GetNodeUptimeSamples(nodeID string, start, end time.Time) ([]database.UptimeCronjob, error) {
+	samples, err := database.FetchNodeUptimes(u.db, nodeID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	errorSamples, err := database.FetchUptimeErrors(u.db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	samples = append(samples, errorSamples...)
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Timestamp.Before(samples[j].Timestamp)
+	})
+	return samples, nil
+}
+
+func (u uptimeDBGorm) GetUptimeVotes(rewardEpoch int64) ([]database.VotingUptimeVote, error) {
+	return database.FetchVotingUptimeVotes(u.db, rewardEpoch)
+}
@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/services/api"
+	serviceUtils "flare-indexer/services/utils"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type testUptimeDB struct {
+	aggregations []database.UptimeAggregation
+	intervals    []database.UptimeInterval
+	samples      []database.UptimeCronjob
+	uptimeVotes  []database.VotingUptimeVote
+}
+
+func (db *testUptimeDB) GetNodeUptimeAggregations(nodeID string, limit int) ([]database.UptimeAggregation, error) {
+	var aggregations []database.UptimeAggregation
+	for i := len(db.aggregations) - 1; i >= 0 && len(aggregations) < limit; i-- {
+		if db.aggregations[i].NodeID == nodeID {
+			aggregations = append(aggregations, db.aggregations[i])
+		}
+	}
+	return aggregations, nil
+}
+
+func (db *testUptimeDB) GetEpochUptimeAggregations(epoch int64) ([]database.UptimeAggregation, error) {
+	var aggregations []database.UptimeAggregation
+	for _, a := range db.aggregations {
+		if int64(a.Epoch) == epoch {
+			aggregations = append(aggregations, a)
+		}
+	}
+	return aggregations, nil
+}
+
+func (db *testUptimeDB) GetNodeUptimeIntervals(nodeID string, start, end time.Time) ([]database.UptimeInterval, error) {
+	var intervals []database.UptimeInterval
+	for _, i := range db.intervals {
+		if i.NodeID == nodeID && i.EndTime.After(start) && i.StartTime.Before(end) {
+			intervals = append(intervals, i)
+		}
+	}
+	return intervals, nil
+}
+
+func (db *testUptimeDB) GetNodeUptimeSamples(nodeID string, start, end time.Time) ([]database.UptimeCronjob, error) {
+	var samples []database.UptimeCronjob
+	for _, s := range db.samples {
+		if (s.NodeID == nil || *s.NodeID == nodeID) && !s.Timestamp.Before(start) && s.Timestamp.Before(end) {
+			samples = append(samples, s)
+		}
+	}
+	return samples, nil
+}
+
+func (db *testUptimeDB) GetUptimeVotes(rewardEpoch int64) ([]database.VotingUptimeVote, error) {
+	var votes []database.VotingUptimeVote
+	for _, vote := range db.uptimeVotes {
+		if vote.RewardEpoch == rewardEpoch {
+			votes = append(votes, vote)
+		}
+	}
+	return votes, nil
+}
+
+const (
+	testUptimeNode1 = "NodeID-FQKTLuZHEsjCxPeFTFgsojsucmdyNDsz1"
+	testUptimeNode2 = "NodeID-CZYx3on11wwYXFoHwZtAQZT5unZ9JHMf6"
+)
+
+var (
+	testUptimeVoter = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testUptimeStart = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newTestUptimeDB() *testUptimeDB {
+	node1 := testUptimeNode1
+	aggregation := func(epoch int, nodeID string, value, staking, ambiguous, excluded int64) database.UptimeAggregation {
+		return database.UptimeAggregation{
+			Epoch:             epoch,
+			NodeID:            nodeID,
+			StartTime:         testUptimeStart.Add(time.Duration(epoch) * 90 * time.Second),
+			EndTime:           testUptimeStart.Add(time.Duration(epoch+1) * 90 * time.Second),
+			Value:             value,
+			StakingDuration:   staking,
+			AmbiguousDuration: ambiguous,
+			ExcludedDuration:  excluded,
+		}
+	}
+	return &testUptimeDB{
+		aggregations: []database.UptimeAggregation{
+			{
+				Epoch: 0, NodeID: testUptimeNode1, StartTime: testUptimeStart, EndTime: testUptimeStart.Add(90 * time.Second),
+				Value: 90, StakingDuration: 90, ReportedUptime: 0.95, ReportedSamples: 9,
+			},
+			aggregation(0, testUptimeNode2, 40, 90, 20, 0),
+			aggregation(1, testUptimeNode1, 50, 90, 30, 30),
+			aggregation(1, testUptimeNode2, 0, 0, 0, 0),
+		},
+		intervals: []database.UptimeInterval{
+			{NodeID: testUptimeNode1, Status: database.UptimeCronjobStatusConnected, StartTime: testUptimeStart, EndTime: testUptimeStart.Add(100 * time.Second)},
+			{NodeID: testUptimeNode1, Status: database.UptimeCronjobStatusTimeout, StartTime: testUptimeStart.Add(100 * time.Second), EndTime: testUptimeStart.Add(130 * time.Second)},
+		},
+		samples: []database.UptimeCronjob{
+			{NodeID: &node1, Observer: "a", Status: database.UptimeCronjobStatusConnected, Timestamp: testUptimeStart.Add(100 * time.Second)},
+			{Observer: "a", Status: database.UptimeCronjobStatusTimeout, Timestamp: testUptimeStart.Add(130 * time.Second)},
+		},
+		uptimeVotes: []database.VotingUptimeVote{
+			{RewardEpoch: 0, Voter: testUptimeVoter.Hex(), NodeIDs: testUptimeNode2 + "," + testUptimeNode1},
+			{RewardEpoch: 0, Voter: "0x2000000000000000000000000000000000000002", NodeIDs: testUptimeNode1},
+		},
+	}
+}
+
+func getUptimeRoute[T any](t *testing.T, router *mux.Router, path string) T {
+	r, err := http.NewRequest(http.MethodGet, path, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	var wResponse api.ApiResponseWrapper[T]
+	serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+	return wResponse.Data
+}
+
+func TestGetNodeUptime(t *testing.T) {
+	rh := &uptimeRouteHandlers{db: newTestUptimeDB(), threshold: 0.8}
+	router := mux.NewRouter()
+	router.HandleFunc("/uptime/{nodeId}", rh.getNodeUptime().Handler)
+
+	response := getUptimeRoute[GetNodeUptimeResponse](t, router, "/uptime/"+testUptimeNode1)
+	require.Len(t, response.Aggregations, 2)
+
+	// Excluded time is not part of the ratio
+	require.Equal(t, int64(1), response.Aggregations[0].Epoch)
+	require.InDelta(t, 50.0/60.0, response.Aggregations[0].Ratio, 1e-9)
+	require.True(t, response.Aggregations[0].PassedThreshold)
+	require.Nil(t, response.Aggregations[0].ReportedRatio)
+	require.InDelta(t, 0.05, *response.Aggregations[1].ReportedDivergence, 1e-9)
+
+	cupaloy.SnapshotT(t, response)
+}
+
+func TestGetNodeUptimeTimeline(t *testing.T) {
+	rh := &uptimeRouteHandlers{db: newTestUptimeDB()}
+	router := mux.NewRouter()
+	router.HandleFunc("/uptime/{nodeId}/timeline/{start}/{end}", rh.getNodeUptimeTimeline().Handler)
+
+	start := testUptimeStart.Unix()
+	path := "/uptime/" + testUptimeNode1 + "/timeline/"
+	response := getUptimeRoute[GetNodeUptimeTimelineResponse](t, router,
+		path+strconv.FormatInt(start+50, 10)+"/"+strconv.FormatInt(start+150, 10))
+	require.Len(t, response.Intervals, 2)
+	require.Len(t, response.Samples, 2)
+	cupaloy.SnapshotT(t, response)
+
+	for _, timeRange := range [][2]int64{{start + 10, start}, {start, start + 86401}} {
+		r, err := http.NewRequest(http.MethodGet, path+strconv.FormatInt(timeRange[0], 10)+"/"+strconv.FormatInt(timeRange[1], 10), nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+}
+
+func TestGetUptimeEpoch(t *testing.T) {
+	db := newTestUptimeDB()
+	rh := &uptimeRouteHandlers{db: db, threshold: 0.8, voter: testUptimeVoter}
+	router := mux.NewRouter()
+	router.HandleFunc("/uptime/epochs/{epoch}", rh.getUptimeEpoch().Handler)
+
+	response := getUptimeRoute[GetUptimeEpochResponse](t, router, "/uptime/epochs/0")
+	require.Equal(t, []string{testUptimeNode2, testUptimeNode1}, response.VotedNodeIDs)
+	require.Len(t, response.Nodes, 2)
+	for _, node := range response.Nodes {
+		require.True(t, node.Voted)
+	}
+	// Voted although it did not pass the threshold of the services config
+	require.False(t, response.Nodes[1].PassedThreshold)
+
+	// Node without staking time in the epoch, voter did not vote
+	notVoted := getUptimeRoute[GetUptimeEpochResponse](t, router, "/uptime/epochs/1")
+	require.Empty(t, notVoted.VotedNodeIDs)
+	require.False(t, notVoted.Nodes[1].PassedThreshold)
+
+	cupaloy.SnapshotT(t, response, notVoted)
+}
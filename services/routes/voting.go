@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/services/context"
+	"flare-indexer/services/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type VoteResponse struct {
+	Voter       string `json:"voter"`
+	MerkleRoot  string `json:"merkleRoot"`
+	BlockNumber uint64 `json:"blockNumber"`
+	TxHash      string `json:"txHash"`
+}
+
+type GetEpochVotesResponse struct {
+	Epoch int64 `json:"epoch"`
+
+	// Merkle root finalized by the voting contract, empty if the epoch is not
+	// finalized (yet)
+	FinalizedRoot string         `json:"finalizedRoot,omitempty"`
+	Votes         []VoteResponse `json:"votes"`
+}
+
+type UptimeVoteResponse struct {
+	Voter       string    `json:"voter"`
+	NodeIDs     []string  `json:"nodeIds"`
+	Timestamp   time.Time `json:"timestamp"`
+	BlockNumber uint64    `json:"blockNumber"`
+	TxHash      string    `json:"txHash"`
+}
+
+type GetUptimeVotesResponse struct {
+	RewardEpoch int64                `json:"rewardEpoch"`
+	Votes       []UptimeVoteResponse `json:"votes"`
+}
+
+type votingDB interface {
+	GetVotes(epoch int64) ([]database.VotingVote, error)
+	GetFinalized(epoch int64) (*database.VotingFinalized, error)
+	GetUptimeVotes(rewardEpoch int64) ([]database.VotingUptimeVote, error)
+}
+
+type votingRouteHandlers struct {
+	db votingDB
+}
+
+func newVotingRouteHandlers(ctx context.ServicesContext) *votingRouteHandlers {
+	return &votingRouteHandlers{
+		db: NewVotingDBGorm(ctx.DB()),
+	}
+}
+
+func (rh *votingRouteHandlers) getEpochVotes() utils.RouteHandler {
+	handler := func(params map[string]string) (GetEpochVotesResponse, *utils.ErrorHandler) {
+		epoch, err := strconv.ParseInt(params["epoch"], 10, 64)
+		if err != nil {
+			return GetEpochVotesResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, "invalid epoch")
+		}
+		votes, err := rh.db.GetVotes(epoch)
+		if err != nil {
+			return GetEpochVotesResponse{}, utils.InternalServerErrorHandler(err)
+		}
+		finalized, err := rh.db.GetFinalized(epoch)
+		if err != nil {
+			return GetEpochVotesResponse{}, utils.InternalServerErrorHandler(err)
+		}
+
+		response := GetEpochVotesResponse{
+			Epoch: epoch,
+			Votes: make([]VoteResponse, len(votes)),
+		}
+		if finalized != nil {
+			response.FinalizedRoot = finalized.MerkleRoot
+		}
+		for i, vote := range votes {
+			response.Votes[i] = VoteResponse{
+				Voter:       vote.Voter,
+				MerkleRoot:  vote.MerkleRoot,
+				BlockNumber: vote.BlockNumber,
+				TxHash:      vote.TxHash,
+			}
+		}
+		return response, nil
+	}
+
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{"epoch:[0-9]+": "Epoch"},
+		GetEpochVotesResponse{})
+}
+
+func (rh *votingRouteHandlers) getUptimeVotes() utils.RouteHandler {
+	handler := func(params map[string]string) (GetUptimeVotesResponse, *utils.ErrorHandler) {
+		rewardEpoch, err := strconv.ParseInt(params["epoch"], 10, 64)
+		if err != nil {
+			return GetUptimeVotesResponse{}, utils.HttpErrorHandler(http.StatusBadRequest, "invalid epoch")
+		}
+		votes, err := rh.db.GetUptimeVotes(rewardEpoch)
+		if err != nil {
+			return GetUptimeVotesResponse{}, utils.InternalServerErrorHandler(err)
+		}
+
+		response := GetUptimeVotesResponse{
+			RewardEpoch: rewardEpoch,
+			Votes:       make([]UptimeVoteResponse, len(votes)),
+		}
+		for i, vote := range votes {
+			nodeIDs := []string{}
+			if len(vote.NodeIDs) > 0 {
+				nodeIDs = strings.Split(vote.NodeIDs, ",")
+			}
+			response.Votes[i] = UptimeVoteResponse{
+				Voter:       vote.Voter,
+				NodeIDs:     nodeIDs,
+				Timestamp:   vote.Timestamp,
+				BlockNumber: vote.BlockNumber,
+				TxHash:      vote.TxHash,
+			}
+		}
+		return response, nil
+	}
+
+	return utils.NewParamRouteHandler(handler, http.MethodGet,
+		map[string]string{"epoch:[0-9]+": "Reward epoch"},
+		GetUptimeVotesResponse{})
+}
+
+func AddVotingRoutes(router utils.Router, ctx context.ServicesContext) {
+	rh := newVotingRouteHandlers(ctx)
+
+	votingSubrouter := router.WithPrefix("/voting", "Voting")
+	votingSubrouter.AddRoute("/votes/{epoch:[0-9]+}", rh.getEpochVotes(),
+		"Merkle root votes", "Votes of each voter and the finalized merkle root of the epoch, as indexed from the voting contract")
+	votingSubrouter.AddRoute("/uptime_votes/{epoch:[0-9]+}", rh.getUptimeVotes(),
+		"Uptime votes", "Uptime votes of each voter for the reward epoch, as indexed from the voting contract")
+}
+
+type votingDBGorm struct {
+	db *gorm.DB
+}
+
+func NewVotingDBGorm(db *gorm.DB) votingDBGorm {
+	return votingDBGorm{db: db}
+}
+
+func (v votingDBGorm) GetVotes(epoch int64) ([]database.VotingVote, error) {
+	return database.FetchVotingVotes(v.db, epoch)
+}
+
+func (v votingDBGorm) GetFinalized(epoch int64) (*database.VotingFinalized, error) {
+	return database.FetchVotingFinalized(v.db, epoch)
+}
+
+func (v votingDBGorm) GetUptimeVotes(rewardEpoch int64) ([]database.VotingUptimeVote, error) {
+	return database.FetchVotingUptimeVotes(v.db, rewardEpoch)
+}
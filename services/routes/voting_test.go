@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"flare-indexer/database"
+	"flare-indexer/services/api"
+	serviceUtils "flare-indexer/services/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type testVotingDB struct {
+	votes       []database.VotingVote
+	finalized   []database.VotingFinalized
+	uptimeVotes []database.VotingUptimeVote
+}
+
+func (db *testVotingDB) GetVotes(epoch int64) ([]database.VotingVote, error) {
+	var votes []database.VotingVote
+	for _, vote := range db.votes {
+		if vote.Epoch == epoch {
+			votes = append(votes, vote)
+		}
+	}
+	return votes, nil
+}
+
+func (db *testVotingDB) GetFinalized(epoch int64) (*database.VotingFinalized, error) {
+	for _, finalized := range db.finalized {
+		if finalized.Epoch == epoch {
+			return &finalized, nil
+		}
+	}
+	return nil, nil
+}
+
+func (db *testVotingDB) GetUptimeVotes(rewardEpoch int64) ([]database.VotingUptimeVote, error) {
+	var votes []database.VotingUptimeVote
+	for _, vote := range db.uptimeVotes {
+		if vote.RewardEpoch == rewardEpoch {
+			votes = append(votes, vote)
+		}
+	}
+	return votes, nil
+}
+
+var testVotingData = testVotingDB{
+	votes: []database.VotingVote{
+		{
+			ContractLog: database.ContractLog{BlockNumber: 100, TxHash: "0x01", LogIndex: 0},
+			Epoch:       3,
+			Voter:       "0x1000000000000000000000000000000000000001",
+			MerkleRoot:  "0xb3ec965b802c71f9058d2ed4d80bdf5af902a3741a75221992c5eb2f879a116c",
+		},
+		{
+			ContractLog: database.ContractLog{BlockNumber: 102, TxHash: "0x02", LogIndex: 0},
+			Epoch:       3,
+			Voter:       "0x2000000000000000000000000000000000000002",
+			MerkleRoot:  "0xb3ec965b802c71f9058d2ed4d80bdf5af902a3741a75221992c5eb2f879a116c",
+		},
+		{
+			ContractLog: database.ContractLog{BlockNumber: 110, TxHash: "0x03", LogIndex: 0},
+			Epoch:       4,
+			Voter:       "0x1000000000000000000000000000000000000001",
+			MerkleRoot:  "0x0000000000000000000000000000000000000000000000000000000000000001",
+		},
+	},
+	finalized: []database.VotingFinalized{
+		{
+			ContractLog: database.ContractLog{BlockNumber: 102, TxHash: "0x02", LogIndex: 1},
+			Epoch:       3,
+			MerkleRoot:  "0xb3ec965b802c71f9058d2ed4d80bdf5af902a3741a75221992c5eb2f879a116c",
+		},
+	},
+	uptimeVotes: []database.VotingUptimeVote{
+		{
+			ContractLog: database.ContractLog{BlockNumber: 120, TxHash: "0x04", LogIndex: 0},
+			RewardEpoch: 7,
+			Timestamp:   time.Date(2023, time.January, 8, 0, 0, 0, 0, time.UTC),
+			Voter:       "0x1000000000000000000000000000000000000001",
+			NodeIDs:     "NodeID-FQKTLuZHEsjCxPeFTFgsojsucmdyNDsz1,NodeID-CZYx3on11wwYXFoHwZtAQZT5unZ9JHMf6",
+		},
+		{
+			ContractLog: database.ContractLog{BlockNumber: 121, TxHash: "0x05", LogIndex: 0},
+			RewardEpoch: 7,
+			Timestamp:   time.Date(2023, time.January, 8, 0, 1, 0, 0, time.UTC),
+			Voter:       "0x2000000000000000000000000000000000000002",
+		},
+	},
+}
+
+func TestGetEpochVotes(t *testing.T) {
+	rh := &votingRouteHandlers{db: &testVotingData}
+	router := mux.NewRouter()
+	router.HandleFunc("/votes/{epoch}", rh.getEpochVotes().Handler)
+
+	// Finalized epoch, epoch that is not finalized and epoch without votes
+	var responses []api.ApiResponseWrapper[GetEpochVotesResponse]
+	for _, epoch := range []string{"3", "4", "5"} {
+		r, err := http.NewRequest(http.MethodGet, "/votes/"+epoch, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		var wResponse api.ApiResponseWrapper[GetEpochVotesResponse]
+		serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+		responses = append(responses, wResponse)
+	}
+	cupaloy.SnapshotT(t, responses)
+}
+
+func TestGetUptimeVotes(t *testing.T) {
+	rh := &votingRouteHandlers{db: &testVotingData}
+	router := mux.NewRouter()
+	router.HandleFunc("/uptime_votes/{epoch}", rh.getUptimeVotes().Handler)
+
+	r, err := http.NewRequest(http.MethodGet, "/uptime_votes/7", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	var wResponse api.ApiResponseWrapper[GetUptimeVotesResponse]
+	serviceUtils.DecodeStruct(t, w.Result().Body, &wResponse)
+	cupaloy.SnapshotT(t, wResponse)
+
+	r, err = http.NewRequest(http.MethodGet, "/uptime_votes/abc", nil)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
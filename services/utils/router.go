@@ -26,6 +26,8 @@ type ErrorHandler struct {
 type Router interface {
 	AddRoute(path string, handler RouteHandler, description ...string)
 	WithPrefix(prefix string, tag string) Router
+	// Add the middleware to the routes of this router
+	Use(middleware mux.MiddlewareFunc)
 	Finalize()
 }
 
@@ -44,6 +46,10 @@ func (r *defaultRouter) WithPrefix(prefix string, tag string) Router {
 	}
 }
 
+func (r *defaultRouter) Use(middleware mux.MiddlewareFunc) {
+	r.router.Use(middleware)
+}
+
 func (r *defaultRouter) Finalize() {
 }
 
@@ -107,6 +113,10 @@ func (r *swaggerRouter) WithPrefix(prefix string, tag string) Router {
 	}
 }
 
+func (r *swaggerRouter) Use(middleware mux.MiddlewareFunc) {
+	r.mRouter.Use(middleware)
+}
+
 func (r *swaggerRouter) Finalize() {
 	if err := r.router.GenerateAndExposeOpenapi(); err != nil {
 		log.Fatal(err)
@@ -33,19 +33,31 @@ func NewAvalancheIndexerClient(uri string, opts ...rpc.Option) *AvalancheIndexer
 }
 
 func (ic *AvalancheIndexerClient) GetLastAccepted(ctx context.Context) (indexer.Container, uint64, error) {
-	return ic.client.GetLastAccepted(ctx, ic.rpcOptions...)
+	start := time.Now()
+	container, index, err := ic.client.GetLastAccepted(ctx, ic.rpcOptions...)
+	ObserveRPC("index.getLastAccepted", start, err)
+	return container, index, err
 }
 
 func (ic *AvalancheIndexerClient) GetContainerByIndex(ctx context.Context, index uint64) (indexer.Container, error) {
-	return ic.client.GetContainerByIndex(ctx, index, ic.rpcOptions...)
+	start := time.Now()
+	container, err := ic.client.GetContainerByIndex(ctx, index, ic.rpcOptions...)
+	ObserveRPC("index.getContainerByIndex", start, err)
+	return container, err
 }
 
 func (ic *AvalancheIndexerClient) GetContainerRange(ctx context.Context, from uint64, numToFetch int) ([]indexer.Container, error) {
-	return ic.client.GetContainerRange(ctx, from, numToFetch, ic.rpcOptions...)
+	start := time.Now()
+	containers, err := ic.client.GetContainerRange(ctx, from, numToFetch, ic.rpcOptions...)
+	ObserveRPC("index.getContainerRange", start, err)
+	return containers, err
 }
 
 func (ic *AvalancheIndexerClient) GetIndex(ctx context.Context, id ids.ID) (uint64, error) {
-	return ic.client.GetIndex(ctx, id, ic.rpcOptions...)
+	start := time.Now()
+	index, err := ic.client.GetIndex(ctx, id, ic.rpcOptions...)
+	ObserveRPC("index.getIndex", start, err)
+	return index, err
 }
 
 //
@@ -0,0 +1,29 @@
+package chain
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	nodeRPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "node_rpc_duration_seconds",
+		Help:    "Duration of RPC calls to the node",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"method"})
+	nodeRPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "node_rpc_errors_total",
+		Help: "Number of failed RPC calls to the node",
+	}, []string{"method"})
+)
+
+// Record the duration of the RPC call started at start and count it as
+// failed if err is not nil
+func ObserveRPC(method string, start time.Time, err error) {
+	nodeRPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		nodeRPCErrors.WithLabelValues(method).Inc()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/ava-labs/avalanchego/api"
 	"github.com/ava-labs/avalanchego/ids"
@@ -46,11 +47,12 @@ func (c *AvalancheRPCClient) GetRewardUTXOs(id ids.ID) (*GetRewardUTXOsReply, er
 	}
 	reply := &GetRewardUTXOsReply{}
 	ctx := context.Background()
+	start := time.Now()
 	response, err := c.client.Call(ctx, "platform.getRewardUTXOs", params)
-	if err != nil {
-		return nil, err
+	if err == nil {
+		err = response.GetObject(reply)
 	}
-	err = response.GetObject(reply)
+	ObserveRPC("platform.getRewardUTXOs", start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -64,11 +66,12 @@ func (c *AvalancheRPCClient) GetTx(id ids.ID) (*api.GetTxReply, error) {
 	}
 	reply := &api.GetTxReply{}
 	ctx := context.Background()
+	start := time.Now()
 	response, err := c.client.Call(ctx, "platform.getTx", params)
-	if err != nil {
-		return nil, err
+	if err == nil {
+		err = response.GetObject(reply)
 	}
-	err = response.GetObject(reply)
+	ObserveRPC("platform.getTx", start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -6,10 +6,22 @@ import (
 	"time"
 )
 
-func PChainTestClient() (*RecordedIndexerClient, error) {
+// File names of the recorded data
+const (
+	PChainBlocksRecordingFile = "p_chain_indexer_blocks.json"
+	PChainRPCRecordingFile    = "p_chain_rpc_data.json"
+	UptimeRecordingFile       = "uptime_data.json"
+)
+
+// Directory with the recorded data used in tests
+func TestRecordingsDir() string {
 	_, filename, _, _ := runtime.Caller(0)
 	dir, _ := path.Split(filename)
-	blocksFile := path.Join(dir, "../../resources/test/p_chain_indexer_blocks.json")
+	return path.Join(dir, "../../resources/test")
+}
+
+func PChainTestClient() (*RecordedIndexerClient, error) {
+	blocksFile := path.Join(TestRecordingsDir(), PChainBlocksRecordingFile)
 	client, err := NewRecordedIndexerClient(blocksFile)
 	if err != nil {
 		return nil, err
@@ -18,9 +30,7 @@ func PChainTestClient() (*RecordedIndexerClient, error) {
 }
 
 func PChainTestRPCClient() (*RecordedRPCClient, error) {
-	_, filename, _, _ := runtime.Caller(0)
-	dir, _ := path.Split(filename)
-	blocksFile := path.Join(dir, "../../resources/test/p_chain_rpc_data.json")
+	blocksFile := path.Join(TestRecordingsDir(), PChainRPCRecordingFile)
 	client, err := NewRecordedRPCClient(blocksFile)
 	if err != nil {
 		return nil, err
@@ -29,9 +39,7 @@ func PChainTestRPCClient() (*RecordedRPCClient, error) {
 }
 
 func UptimeTestClient() (*RecordedUptimeClient, error) {
-	_, filename, _, _ := runtime.Caller(0)
-	dir, _ := path.Split(filename)
-	blocksFile := path.Join(dir, "../../resources/test/uptime_data.json")
+	blocksFile := path.Join(TestRecordingsDir(), UptimeRecordingFile)
 	client, err := NewRecordedUptimeClient(blocksFile, time.Now())
 	if err != nil {
 		return nil, err
@@ -20,6 +20,10 @@ const (
 type ValidatorStatus struct {
 	NodeID    string `json:"nodeID"`
 	Connected bool   `json:"connected"`
+
+	// Uptime percentage of the validator as seen by the node, nil if the node
+	// does not report it
+	Uptime *float64 `json:"uptime,omitempty"`
 }
 
 type UptimeClient interface {
@@ -48,6 +52,10 @@ func (c *AvalancheUptimeClient) GetValidatorStatus() ([]*ValidatorStatus, databa
 			NodeID:    v.NodeID.String(),
 			Connected: v.Connected,
 		}
+		if v.Uptime != nil {
+			uptime := float64(*v.Uptime)
+			vs[i].Uptime = &uptime
+		}
 	}
 	return vs, status, nil
 }
@@ -66,7 +74,9 @@ type permissionedValidators struct {
 func CallPChainGetConnectedValidators(client jsonrpc.RPCClient) ([]*api.PermissionedValidator, database.UptimeCronjobStatus, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), ConnectionTimeout)
 	defer cancel()
+	start := time.Now()
 	response, err := client.Call(ctx, "platform.getCurrentValidators")
+	ObserveRPC("platform.getCurrentValidators", start, err)
 
 	switch err.(type) {
 	case nil:
@@ -137,6 +147,18 @@ func (c *RecordedUptimeClient) SetNowUnix(startNow int64) {
 	c.Time.SetNowUnix(startNow)
 }
 
+// Return the time of the first recorded sample (zero time if there are no samples)
+func (c *RecordedUptimeClient) FirstTimestamp() time.Time {
+	if len(c.data) == 0 {
+		return time.Time{}
+	}
+	first := c.data[0].Start
+	for _, data := range c.data[1:] {
+		first = utils.Min(first, data.Start)
+	}
+	return time.Unix(first, 0)
+}
+
 func readUptimeRecordings(fileName string) ([]RecordedUptimeData, error) {
 	var data []RecordedUptimeData
 	jsonFile, err := os.ReadFile(fileName)
@@ -0,0 +1,2 @@
+//go:generate  abigen --abi=multicall.abi --pkg=multicall --type=Multicall --out=autogen.go
+package multicall
@@ -0,0 +1,161 @@
+// Package reverts decodes revert data of the mirroring, voting and address
+// binder contracts into typed errors.
+package reverts
+
+import (
+	"bytes"
+	"flare-indexer/utils/contracts/addresses"
+	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/voting"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Name of the error of require and revert with a message
+	NameError = "Error"
+
+	// Name of the error of failed assertions and arithmetic errors
+	NamePanic = "Panic"
+)
+
+// Reverts of the mirroring contract
+var (
+	ErrTransactionAlreadyMirrored = FromMessage("transaction already mirrored")
+	ErrStakingEnded               = FromMessage("staking already ended")
+	ErrUnknownStakingAddress      = FromMessage("unknown staking address")
+	ErrStakingDataInvalid         = FromMessage("staking data invalid")
+	ErrMaxNodeIDsExceeded         = FromMessage("Max node ids exceeded")
+)
+
+var (
+	errorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+	uint256Type, _ = abi.NewType("uint256", "", nil)
+
+	customErrors     map[[4]byte]abi.Error
+	customErrorsOnce sync.Once
+	customErrorsErr  error
+)
+
+// Decoded revert of a contract call
+type Error struct {
+	// NameError, NamePanic or the name of the custom error
+	Name string
+
+	// Message of the revert for NameError
+	Message string
+
+	// Arguments of the panic (error code) or of the custom error
+	Args []interface{}
+}
+
+// Create the error of require or revert with the message, e.g. when the node
+// returns the revert reason without the revert data
+func FromMessage(message string) *Error {
+	return &Error{Name: NameError, Message: message}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Name == NameError:
+		return e.Message
+	case len(e.Args) == 0:
+		return e.Name
+	default:
+		return fmt.Sprintf("%s%v", e.Name, e.Args)
+	}
+}
+
+// Errors are equal if they have the same name and message, arguments of
+// custom errors are not compared
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e.Name == t.Name && e.Message == t.Message
+}
+
+// Panic code of the error, nil if it is not a panic
+func (e *Error) PanicCode() *big.Int {
+	if e.Name != NamePanic || len(e.Args) != 1 {
+		return nil
+	}
+	code, _ := e.Args[0].(*big.Int)
+	return code
+}
+
+// Decode the revert data as Error(string), Panic(uint256) or as one of the
+// custom errors of the contracts
+func Decode(data []byte) (*Error, error) {
+	if len(data) < 4 {
+		return nil, errors.New("revert data too short")
+	}
+	selector := data[:4]
+
+	switch {
+	case bytes.Equal(selector, errorSelector):
+		message, err := abi.UnpackRevert(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "abi.UnpackRevert")
+		}
+		return FromMessage(message), nil
+	case bytes.Equal(selector, panicSelector):
+		args, err := abi.Arguments{{Type: uint256Type}}.Unpack(data[4:])
+		if err != nil {
+			return nil, errors.Wrap(err, "unpack panic code")
+		}
+		return &Error{Name: NamePanic, Args: args}, nil
+	}
+
+	errs, err := loadCustomErrors()
+	if err != nil {
+		return nil, err
+	}
+	var id [4]byte
+	copy(id[:], selector)
+	customErr, ok := errs[id]
+	if !ok {
+		return nil, errors.Errorf("unknown revert selector %x", selector)
+	}
+	args, err := customErr.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unpack %s", customErr.Name)
+	}
+	return &Error{Name: customErr.Name, Args: args}, nil
+}
+
+func loadCustomErrors() (map[[4]byte]abi.Error, error) {
+	customErrorsOnce.Do(func() {
+		customErrors = make(map[[4]byte]abi.Error)
+		for _, metaData := range []*bindMetaData{
+			{"mirroring", mirroring.MirroringMetaData.GetAbi},
+			{"voting", voting.VotingMetaData.GetAbi},
+			{"addresses", addresses.BinderMetaData.GetAbi},
+		} {
+			contractABI, err := metaData.getAbi()
+			if err != nil {
+				customErrorsErr = errors.Wrapf(err, "%s abi", metaData.name)
+				return
+			}
+			for _, e := range contractABI.Errors {
+				var id [4]byte
+				copy(id[:], e.ID[:4])
+				customErrors[id] = e
+			}
+		}
+	})
+	return customErrors, customErrorsErr
+}
+
+type bindMetaData struct {
+	name   string
+	getAbi func() (*abi.ABI, error)
+}
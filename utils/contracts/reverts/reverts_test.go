@@ -0,0 +1,57 @@
+package reverts
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeRevert(t *testing.T, selector []byte, typeName string, arg interface{}) []byte {
+	argType, err := abi.NewType(typeName, "", nil)
+	require.NoError(t, err)
+	data, err := abi.Arguments{{Type: argType}}.Pack(arg)
+	require.NoError(t, err)
+	return append(append([]byte{}, selector...), data...)
+}
+
+func TestDecodeError(t *testing.T) {
+	data := encodeRevert(t, errorSelector, "string", "transaction already mirrored")
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, NameError, decoded.Name)
+	require.Equal(t, "transaction already mirrored", decoded.Error())
+
+	wrapped := errors.Wrap(decoded, "MirrorStake")
+	require.ErrorIs(t, wrapped, ErrTransactionAlreadyMirrored)
+	require.False(t, errors.Is(wrapped, ErrStakingEnded))
+}
+
+func TestDecodePanic(t *testing.T) {
+	data := encodeRevert(t, panicSelector, "uint256", big.NewInt(0x11))
+
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, NamePanic, decoded.Name)
+	require.Equal(t, int64(0x11), decoded.PanicCode().Int64())
+	require.Nil(t, ErrStakingEnded.PanicCode())
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte{1, 2})
+	require.Error(t, err)
+
+	_, err = Decode([]byte{1, 2, 3, 4})
+	require.ErrorContains(t, err, "unknown revert selector")
+
+	_, err = Decode(append(append([]byte{}, errorSelector...), 1, 2, 3))
+	require.Error(t, err)
+}
+
+func TestMessageDoesNotMatchSubstring(t *testing.T) {
+	require.False(t, errors.Is(FromMessage("error: staking already ended"), ErrStakingEnded))
+	require.True(t, errors.Is(FromMessage("staking already ended"), ErrStakingEnded))
+}
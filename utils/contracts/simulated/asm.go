@@ -0,0 +1,171 @@
+package simulated
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Minimal EVM assembler used by the code generation of the simulated contracts
+// (see codegen.go). Jumps are resolved to labels when the code is assembled.
+type assembler struct {
+	code   []byte
+	labels map[string]int
+	refs   map[int]string
+	nextID int
+}
+
+func newAssembler() *assembler {
+	return &assembler{
+		labels: make(map[string]int),
+		refs:   make(map[int]string),
+	}
+}
+
+// Append raw opcodes
+func (a *assembler) op(ops ...vm.OpCode) *assembler {
+	for _, o := range ops {
+		a.code = append(a.code, byte(o))
+	}
+	return a
+}
+
+// Push a value of at most 32 bytes using the shortest push instruction
+func (a *assembler) push(value []byte) *assembler {
+	for len(value) > 1 && value[0] == 0 {
+		value = value[1:]
+	}
+	if len(value) == 0 {
+		value = []byte{0}
+	}
+	if len(value) > 32 {
+		panic("push value too long")
+	}
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(value)-1))
+	a.code = append(a.code, value...)
+	return a
+}
+
+func (a *assembler) pushInt(value uint64) *assembler {
+	return a.push(new(big.Int).SetUint64(value).Bytes())
+}
+
+func (a *assembler) pushHash(value common.Hash) *assembler {
+	return a.push(value.Bytes())
+}
+
+func (a *assembler) pushAddress(value common.Address) *assembler {
+	return a.push(value.Bytes())
+}
+
+// Push a four byte selector, left aligned as it is in calldata
+func (a *assembler) pushSelector(selector []byte) *assembler {
+	a.code = append(a.code, byte(vm.PUSH4))
+	a.code = append(a.code, selector...)
+	return a
+}
+
+// Push the position of a label (resolved in assemble)
+func (a *assembler) pushLabel(name string) *assembler {
+	a.code = append(a.code, byte(vm.PUSH2))
+	a.refs[len(a.code)] = name
+	a.code = append(a.code, 0, 0)
+	return a
+}
+
+// Mark the current position with a label (emits JUMPDEST)
+func (a *assembler) label(name string) *assembler {
+	if _, ok := a.labels[name]; ok {
+		panic(fmt.Sprintf("duplicate label %s", name))
+	}
+	a.labels[name] = len(a.code)
+	return a.op(vm.JUMPDEST)
+}
+
+// Return a new unique label name with the given prefix
+func (a *assembler) newLabel(prefix string) string {
+	a.nextID++
+	return fmt.Sprintf("%s_%d", prefix, a.nextID)
+}
+
+func (a *assembler) jump(name string) *assembler {
+	return a.pushLabel(name).op(vm.JUMP)
+}
+
+// Jump to the label if the value on top of the stack is not zero
+func (a *assembler) jumpi(name string) *assembler {
+	return a.pushLabel(name).op(vm.JUMPI)
+}
+
+// Store the value on top of the stack to memory at offset
+func (a *assembler) mstore(offset uint64) *assembler {
+	return a.pushInt(offset).op(vm.MSTORE)
+}
+
+// Revert with Error(string) encoded reason, reason must not exceed 32 bytes
+func (a *assembler) revert(reason string) *assembler {
+	if len(reason) > 32 {
+		panic("revert reason too long")
+	}
+	padded := common.RightPadBytes([]byte(reason), 32)
+	a.push(common.RightPadBytes(errorSelector, 32)).mstore(0)
+	a.pushInt(32).mstore(4)
+	a.pushInt(uint64(len(reason))).mstore(36)
+	a.push(padded).mstore(68)
+	return a.pushInt(100).pushInt(0).op(vm.REVERT)
+}
+
+// Revert with the reason if the value on top of the stack is zero
+func (a *assembler) require(reason string) *assembler {
+	ok := a.newLabel("require")
+	a.jumpi(ok)
+	a.revert(reason)
+	return a.label(ok)
+}
+
+// Jump to the label if the selector on top of the stack equals the selector of
+// the given method. The selector is kept on the stack.
+func (a *assembler) dispatch(method abi.Method, name string) *assembler {
+	return a.op(vm.DUP1).pushSelector(method.ID).op(vm.EQ).jumpi(name)
+}
+
+// Push the function selector of the call
+func (a *assembler) selector() *assembler {
+	return a.pushInt(0).op(vm.CALLDATALOAD).pushInt(224).op(vm.SHR)
+}
+
+// Resolve labels and return the code
+func (a *assembler) assemble() []byte {
+	code := make([]byte, len(a.code))
+	copy(code, a.code)
+	for pos, name := range a.refs {
+		target, ok := a.labels[name]
+		if !ok {
+			panic(fmt.Sprintf("unknown label %s", name))
+		}
+		code[pos] = byte(target >> 8)
+		code[pos+1] = byte(target)
+	}
+	return code
+}
+
+var errorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+// Prepend runtime code with a constructor that returns it, so it can be deployed
+// with a regular contract creation transaction
+func deploymentCode(runtime []byte) []byte {
+	if len(runtime) > 0xffff {
+		panic("runtime code too long")
+	}
+	const constructorLength = 12
+	a := newAssembler()
+	a.code = append(a.code, byte(vm.PUSH2), byte(len(runtime)>>8), byte(len(runtime)))
+	a.op(vm.DUP1)
+	a.code = append(a.code, byte(vm.PUSH1), constructorLength)
+	a.pushInt(0).op(vm.CODECOPY).pushInt(0).op(vm.RETURN)
+	return append(a.assemble(), runtime...)
+}
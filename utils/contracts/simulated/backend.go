@@ -0,0 +1,301 @@
+// Package simulated provides minimal voting, mirroring, address binder and
+// multicall contracts running on go-ethereum's simulated backend. The contracts
+// implement only the parts of the original contracts used by the indexer, but
+// use the same ABI, reverts and events, so the generated bindings can be used
+// as with the real chain. The contracts are generated from Go code structured
+// like their Solidity sources (see codegen.go), since they must be built
+// without a Solidity compiler.
+package simulated
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"flare-indexer/utils"
+	"flare-indexer/utils/contracts/addresses"
+	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/multicall"
+	"flare-indexer/utils/contracts/voting"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Chain id of the simulated backend
+	ChainID = 1337
+
+	gasLimit = 30_000_000
+
+	// Time between a block and the next block generated by go-ethereum
+	blockPeriod = 10 * time.Second
+)
+
+var (
+	// Initial balance of funded accounts (1M ether)
+	initialBalance = new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1e18))
+)
+
+// Simulated backend which mines a block after each transaction (similar to
+// hardhat's automine), so transaction effects are visible immediately.
+type Backend struct {
+	*backends.SimulatedBackend
+
+	// Time followed by the chain, if set
+	clock *utils.ShiftedTime
+
+	mu sync.Mutex
+}
+
+// Create a new simulated backend funding the given accounts
+func NewBackend(accounts ...common.Address) *Backend {
+	alloc := make(core.GenesisAlloc, len(accounts))
+	for _, account := range accounts {
+		alloc[account] = core.GenesisAccount{Balance: initialBalance}
+	}
+	return &Backend{
+		SimulatedBackend: backends.NewSimulatedBackend(alloc, gasLimit),
+	}
+}
+
+// Follow the (possibly accelerated) clock with the time of the chain. Calls
+// and gas estimations see the time of the clock as the block timestamp.
+// Transactions are mined at the time of the clock, or 10s after the previous
+// block if it is later, since go-ethereum's simulated blocks are 10s apart.
+func (b *Backend) SetClock(clock *utils.ShiftedTime) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clock = clock
+}
+
+func (b *Backend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.clock != nil {
+		// The transaction is mined in the block following the empty block
+		if err := b.mineAt(b.clock.Now().Add(-blockPeriod)); err != nil {
+			return err
+		}
+	}
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.Commit()
+	return nil
+}
+
+// Calls on the latest block are executed on the (empty) pending block at the
+// time of the clock, if it is set. Calls on earlier blocks are executed on the
+// state after that block, which go-ethereum's simulated backend does not
+// support.
+func (b *Backend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber != nil {
+		if blockNumber.Cmp(b.Blockchain().CurrentBlock().Number()) < 0 {
+			return b.callAt(call, blockNumber)
+		}
+		return b.SimulatedBackend.CallContract(ctx, call, blockNumber)
+	}
+	following, err := b.followClock()
+	if err != nil {
+		return nil, err
+	}
+	if following {
+		return b.SimulatedBackend.PendingCallContract(ctx, call)
+	}
+	return b.SimulatedBackend.CallContract(ctx, call, nil)
+}
+
+func (b *Backend) callAt(call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	block := b.Blockchain().GetBlockByNumber(blockNumber.Uint64())
+	if block == nil {
+		return nil, errors.Errorf("block %s not found", blockNumber)
+	}
+	stateDB, err := b.Blockchain().StateAt(block.Root())
+	if err != nil {
+		return nil, errors.Wrapf(err, "state of block %s", blockNumber)
+	}
+
+	gas := call.Gas
+	if gas == 0 {
+		gas = block.GasLimit()
+	}
+	value := call.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	// Fees are ignored as in eth_call (zero fees with the base fee disabled)
+	msg := types.NewMessage(
+		call.From, call.To, stateDB.GetNonce(call.From), value, gas,
+		new(big.Int), new(big.Int), new(big.Int), call.Data, call.AccessList, true,
+	)
+	blockContext := core.NewEVMBlockContext(block.Header(), b.Blockchain(), nil)
+	evm := vm.NewEVM(blockContext, core.NewEVMTxContext(msg), stateDB, b.Blockchain().Config(), vm.Config{NoBaseFee: true})
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+	if err != nil {
+		return nil, err
+	}
+	if revert := result.Revert(); len(revert) > 0 {
+		return nil, newRevertError(revert)
+	}
+	return result.Return(), result.Err
+}
+
+// Revert error of a call, with the revert data in the same form as returned by
+// the node (and go-ethereum's simulated backend)
+type revertError struct {
+	reason string
+	data   []byte
+}
+
+func newRevertError(data []byte) *revertError {
+	reason, err := abi.UnpackRevert(data)
+	if err != nil {
+		return &revertError{reason: vm.ErrExecutionReverted.Error(), data: data}
+	}
+	return &revertError{reason: vm.ErrExecutionReverted.Error() + ": " + reason, data: data}
+}
+
+func (e *revertError) Error() string {
+	return e.reason
+}
+
+// Error code of reverts in the JSON-RPC API
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+func (e *revertError) ErrorData() interface{} {
+	return hexutil.Encode(e.data)
+}
+
+func (b *Backend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	if _, err := b.followClock(); err != nil {
+		return 0, err
+	}
+	return b.SimulatedBackend.EstimateGas(ctx, call)
+}
+
+// Move the time of the pending block to the time of the clock, if it is set
+func (b *Backend) followClock() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.clock == nil {
+		return false, nil
+	}
+	return true, b.setPendingTime(b.clock.Now())
+}
+
+// Mine an empty block at time t, if it is after the time of the last block
+func (b *Backend) SetTime(t time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.mineAt(t)
+}
+
+func (b *Backend) mineAt(t time.Time) error {
+	head := b.Blockchain().CurrentHeader()
+	if t.Unix() <= int64(head.Time) {
+		return nil
+	}
+	if err := b.setPendingTime(t); err != nil {
+		return err
+	}
+	b.Commit()
+	return nil
+}
+
+// Set the time of the empty pending block to t, or to 1s after the last block
+// if t is not after it, since block times must increase
+func (b *Backend) setPendingTime(t time.Time) error {
+	head := b.Blockchain().CurrentHeader()
+	blockTime := utils.Max(t.Unix(), int64(head.Time)+1)
+	return b.AdjustTime(time.Duration(blockTime-int64(head.Time))*time.Second - blockPeriod)
+}
+
+// Return transact options for the private key on the simulated chain
+func TransactOpts(privateKey *ecdsa.PrivateKey) (*bind.TransactOpts, error) {
+	return bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(ChainID))
+}
+
+// Deployed simulated contracts
+type Contracts struct {
+	VotingAddress    common.Address
+	MirroringAddress common.Address
+	BinderAddress    common.Address
+	MulticallAddress common.Address
+
+	Voting    *voting.Voting
+	Mirroring *mirroring.Mirroring
+	Binder    *addresses.Binder
+	Multicall *multicall.Multicall
+}
+
+// Deploy voting, address binder, mirroring and multicall contracts using the deployer
+// account, which must be funded on the backend
+func DeployContracts(
+	backend bind.ContractBackend,
+	deployer *bind.TransactOpts,
+	params *VotingParams,
+) (*Contracts, error) {
+	var err error
+	c := &Contracts{}
+
+	c.VotingAddress, _, c.Voting, err = DeployVoting(deployer, backend, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "DeployVoting")
+	}
+	c.BinderAddress, _, c.Binder, err = DeployAddressBinder(deployer, backend)
+	if err != nil {
+		return nil, errors.Wrap(err, "DeployAddressBinder")
+	}
+	c.MirroringAddress, _, c.Mirroring, err = DeployMirroring(deployer, backend, c.VotingAddress, c.BinderAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "DeployMirroring")
+	}
+	c.MulticallAddress, _, c.Multicall, err = DeployMulticall(deployer, backend)
+	if err != nil {
+		return nil, errors.Wrap(err, "DeployMulticall")
+	}
+	return c, nil
+}
+
+// Deploy the runtime code. Constructor arguments of the original contract are
+// not used, so the constructor is removed from the ABI.
+func deploy(
+	opts *bind.TransactOpts,
+	backend bind.ContractBackend,
+	contractABI abi.ABI,
+	runtime []byte,
+) (common.Address, *types.Transaction, error) {
+	contractABI.Constructor = abi.Method{}
+	address, tx, _, err := bind.DeployContract(opts, contractABI, deploymentCode(runtime), backend)
+	if err != nil {
+		return common.Address{}, nil, errors.Wrap(err, "bind.DeployContract")
+	}
+	return address, tx, nil
+}
+
+// Generate a new key pair, return the private key and its address
+func NewAccount() (*ecdsa.PrivateKey, common.Address, error) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return privateKey, crypto.PubkeyToAddress(privateKey.PublicKey), nil
+}
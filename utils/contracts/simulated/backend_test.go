@@ -0,0 +1,178 @@
+package simulated
+
+import (
+	"context"
+	"errors"
+	"flare-indexer/utils"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/crypto"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func deployTestContracts(t *testing.T) (*Backend, *Contracts, *bind.TransactOpts, *bind.TransactOpts) {
+	voterKey, voterAddress, err := NewAccount()
+	require.NoError(t, err)
+	otherKey, otherAddress, err := NewAccount()
+	require.NoError(t, err)
+
+	backend := NewBackend(voterAddress, otherAddress)
+	t.Cleanup(func() { backend.Close() })
+
+	voter, err := TransactOpts(voterKey)
+	require.NoError(t, err)
+	other, err := TransactOpts(otherKey)
+	require.NoError(t, err)
+
+	contracts, err := DeployContracts(backend, voter, &VotingParams{
+		FirstEpochStart: time.Unix(1000, 0),
+		EpochPeriod:     90 * time.Second,
+		Voters:          []common.Address{voterAddress},
+	})
+	require.NoError(t, err)
+
+	return backend, contracts, voter, other
+}
+
+func TestEpochConfiguration(t *testing.T) {
+	_, contracts, voter, _ := deployTestContracts(t)
+
+	cfg, err := contracts.Voting.GetEpochConfiguration(new(bind.CallOpts))
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), cfg.FirstEpochStartTs.Int64())
+	require.Equal(t, int64(90), cfg.EpochDurationSeconds.Int64())
+
+	epoch, err := contracts.Voting.GetEpochId(new(bind.CallOpts), big.NewInt(1000+2*90+10))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), epoch.Int64())
+
+	voters, err := contracts.Voting.GetVoters(new(bind.CallOpts))
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{voter.From}, voters)
+
+	threshold, err := contracts.Voting.GetVotingThreshold(new(bind.CallOpts))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), threshold.Int64())
+}
+
+func TestUptimeVote(t *testing.T) {
+	_, contracts, voter, other := deployTestContracts(t)
+
+	nodeIDs := [][20]byte{{1, 2, 3}, {4, 5, 6}}
+	_, err := contracts.Voting.SubmitValidatorUptimeVote(voter, big.NewInt(7), nodeIDs)
+	require.NoError(t, err)
+
+	_, err = contracts.Voting.SubmitValidatorUptimeVote(other, big.NewInt(7), nodeIDs)
+	require.ErrorContains(t, err, "only voters")
+
+	votes, err := contracts.Voting.FilterPChainStakeMirrorValidatorUptimeVoteSubmitted(
+		&bind.FilterOpts{}, []*big.Int{big.NewInt(7)}, nil,
+	)
+	require.NoError(t, err)
+	require.True(t, votes.Next())
+	require.Equal(t, voter.From, votes.Event.Voter)
+	require.Equal(t, nodeIDs, votes.Event.NodeIds)
+	require.False(t, votes.Next())
+}
+
+func TestCallAtEarlierBlock(t *testing.T) {
+	backend, contracts, voter, _ := deployTestContracts(t)
+
+	root := [32]byte{1}
+	tx, err := contracts.Voting.SubmitVote(voter, big.NewInt(7), root)
+	require.NoError(t, err)
+	receipt, err := backend.TransactionReceipt(context.Background(), tx.Hash())
+	require.NoError(t, err)
+	parent := new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+
+	shouldVote, err := contracts.Voting.ShouldVote(&bind.CallOpts{BlockNumber: parent}, big.NewInt(7), voter.From)
+	require.NoError(t, err)
+	require.True(t, shouldVote)
+	shouldVote, err = contracts.Voting.ShouldVote(&bind.CallOpts{BlockNumber: receipt.BlockNumber}, big.NewInt(7), voter.From)
+	require.NoError(t, err)
+	require.False(t, shouldVote)
+
+	// Replaying the vote succeeds before its block and reverts after it
+	msg := ethereum.CallMsg{From: voter.From, To: tx.To(), Data: tx.Data()}
+	_, err = backend.CallContract(context.Background(), msg, parent)
+	require.NoError(t, err)
+	_, err = backend.CallContract(context.Background(), msg, receipt.BlockNumber)
+	require.ErrorContains(t, err, "epoch already finalized")
+
+	backend.Commit()
+	_, err = backend.CallContract(context.Background(), msg, receipt.BlockNumber)
+	require.ErrorContains(t, err, "epoch already finalized")
+	var dataErr rpc.DataError
+	require.True(t, errors.As(err, &dataErr))
+	data, err := hexutil.Decode(dataErr.ErrorData().(string))
+	require.NoError(t, err)
+	reason, err := abi.UnpackRevert(data)
+	require.NoError(t, err)
+	require.Equal(t, "epoch already finalized", reason)
+}
+
+func TestRegisterAddresses(t *testing.T) {
+	_, contracts, voter, _ := deployTestContracts(t)
+
+	// Compressed public key and the corresponding p-chain address
+	publicKey := common.FromHex("0x02e2a1e7a7ef9f3b1f4e6b0a3d1a8c6e2f2f3e8e1d5c2b2a1f0e9d8c7b6a5f4e3d")
+	pAddress := [20]byte(common.HexToAddress("0x85a9d2cd3b0b3d68b7d0d7ec8db1f7b8c4a8ab77"))
+	_, err := contracts.Binder.RegisterAddresses(voter, publicKey, pAddress, voter.From)
+	require.ErrorContains(t, err, "p-address invalid")
+
+	factory := crypto.FactorySECP256K1R{}
+	privateKey, err := factory.NewPrivateKey()
+	require.NoError(t, err)
+	publicKey = privateKey.PublicKey().Bytes()
+	pAddress = [20]byte(privateKey.PublicKey().Address())
+	_, err = contracts.Binder.RegisterAddresses(voter, publicKey, pAddress, voter.From)
+	require.NoError(t, err)
+
+	cAddress, err := contracts.Binder.PAddressToCAddress(new(bind.CallOpts), pAddress)
+	require.NoError(t, err)
+	require.Equal(t, voter.From, cAddress)
+
+	registered, err := contracts.Binder.FilterAddressesRegistered(&bind.FilterOpts{})
+	require.NoError(t, err)
+	require.True(t, registered.Next())
+	require.Equal(t, publicKey, registered.Event.PublicKey)
+	require.Equal(t, pAddress, registered.Event.PAddress)
+	require.Equal(t, voter.From, registered.Event.CAddress)
+}
+
+func TestChainTimeFollowsClock(t *testing.T) {
+	backend, contracts, voter, _ := deployTestContracts(t)
+
+	// Start in the middle of epoch 0 with time running 10 times faster
+	clock := utils.NewAcceleratedTime(time.Unix(1000+45, 0), 10)
+	backend.SetClock(clock)
+
+	for epoch := int64(0); epoch < 4; epoch++ {
+		current, err := contracts.Voting.GetCurrentEpochId(new(bind.CallOpts))
+		require.NoError(t, err)
+		require.Equal(t, epoch, current.Int64())
+
+		before := clock.Now().Unix()
+		_, err = contracts.Voting.SubmitValidatorUptimeVote(voter, big.NewInt(epoch), nil)
+		require.NoError(t, err)
+		after := clock.Now().Unix()
+
+		votes, err := contracts.Voting.FilterPChainStakeMirrorValidatorUptimeVoteSubmitted(
+			&bind.FilterOpts{}, []*big.Int{big.NewInt(epoch)}, nil,
+		)
+		require.NoError(t, err)
+		require.True(t, votes.Next())
+		require.GreaterOrEqual(t, votes.Event.Timestamp.Int64(), before)
+		require.LessOrEqual(t, votes.Event.Timestamp.Int64(), after+1)
+
+		clock.AdvanceNow(90 * time.Second)
+	}
+}
@@ -0,0 +1,75 @@
+package simulated
+
+import (
+	"flare-indexer/utils/contracts/addresses"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Storage slots of the address binder contract mappings
+const (
+	binderPToCSlot = 0 // p-chain address => c-chain address
+	binderCToPSlot = 1 // c-chain address => p-chain address
+)
+
+// Precompiled contracts used to verify p-chain addresses
+var (
+	sha256Precompile    = common.BytesToAddress([]byte{2})
+	ripemd160Precompile = common.BytesToAddress([]byte{3})
+)
+
+// Minimal implementation of the AddressBinder contract. The p-chain address is
+// verified against the public key (ripemd160(sha256(publicKey))), the c-chain
+// address is accepted as given since the public key is compressed.
+func binderRuntimeCode() ([]byte, error) {
+	contractABI, err := addresses.BinderMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	c := newContract(contractABI)
+
+	c.function("pAddressToCAddress", func(f *function) {
+		f.ret(sload(mapping(binderPToCSlot, f.arg(""))))
+	})
+
+	c.function("cAddressToPAddress", func(f *function) {
+		f.ret(sload(mapping(binderCToPSlot, f.arg(""))))
+	})
+
+	c.function("registerAddresses", func(f *function) {
+		pAddress, cAddress := f.arg("_pAddress"), f.arg("_cAddress")
+		// ripemd160 output is right aligned, bytes20 is left aligned
+		hash := precompileWord(ripemd160Precompile, precompileBytes(sha256Precompile, f.argData("_publicKey")))
+		f.require(eq(shl(96, hash), pAddress), "p-address invalid")
+
+		f.sstore(mapping(binderPToCSlot, pAddress), cAddress)
+		f.sstore(mapping(binderCToPSlot, cAddress), pAddress)
+		f.emit("AddressesRegistered", f.argTail("_publicKey"), pAddress, cAddress)
+	})
+
+	return c.code(), nil
+}
+
+// Deploy the simulated address binder contract and return its address and binding
+func DeployAddressBinder(
+	opts *bind.TransactOpts,
+	backend bind.ContractBackend,
+) (common.Address, *types.Transaction, *addresses.Binder, error) {
+	code, err := binderRuntimeCode()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contractABI, err := addresses.BinderMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, err := deploy(opts, backend, *contractABI, code)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contract, err := addresses.NewBinder(address, backend)
+	return address, tx, contract, err
+}
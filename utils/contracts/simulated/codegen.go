@@ -0,0 +1,519 @@
+package simulated
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Structured code generation on top of the assembler, so the simulated
+// contracts are written as expressions and statements (similar to the Solidity
+// source of the original contracts) instead of stack operations. Arguments,
+// return values and events are encoded from the ABI of the generated bindings.
+//
+// Memory layout: [0x00, 0x40) is scratch space for hashing and results of
+// external calls, local variables follow at 0x80 and the buffer used for abi
+// encoding (return data, events and calldata of external calls) starts at
+// 0x180.
+const (
+	localsMemory = 0x80
+	maxLocals    = 8
+	bufferMemory = localsMemory + 32*maxLocals
+)
+
+// Expression whose code pushes exactly one word on the stack
+type expr func(a *assembler)
+
+func lit(value uint64) expr {
+	return func(a *assembler) { a.pushInt(value) }
+}
+
+func litAddress(value common.Address) expr {
+	return func(a *assembler) { a.pushAddress(value) }
+}
+
+func caller() expr {
+	return func(a *assembler) { a.op(vm.CALLER) }
+}
+
+func timestamp() expr {
+	return func(a *assembler) { a.op(vm.TIMESTAMP) }
+}
+
+// Binary operation x op y, the first operand is on top of the stack
+func binary(op vm.OpCode, x, y expr) expr {
+	return func(a *assembler) {
+		y(a)
+		x(a)
+		a.op(op)
+	}
+}
+
+func add(x, y expr) expr { return binary(vm.ADD, x, y) }
+func sub(x, y expr) expr { return binary(vm.SUB, x, y) }
+func mul(x, y expr) expr { return binary(vm.MUL, x, y) }
+func div(x, y expr) expr { return binary(vm.DIV, x, y) }
+func lt(x, y expr) expr  { return binary(vm.LT, x, y) }
+func eq(x, y expr) expr  { return binary(vm.EQ, x, y) }
+func and(x, y expr) expr { return binary(vm.AND, x, y) }
+func or(x, y expr) expr  { return binary(vm.OR, x, y) }
+
+// x shifted left by bits
+func shl(bits uint64, x expr) expr { return binary(vm.SHL, lit(bits), x) }
+
+func isZero(x expr) expr {
+	return func(a *assembler) {
+		x(a)
+		a.op(vm.ISZERO)
+	}
+}
+
+func maxOf(x, y expr) expr { return choose(x, y, true) }
+func minOf(x, y expr) expr { return choose(x, y, false) }
+
+// Greater (or smaller) of x and y
+func choose(x, y expr, greater bool) expr {
+	return func(a *assembler) {
+		keepX := a.newLabel("keep_x")
+		x(a)
+		y(a)
+		// Stack: x, y
+		a.op(vm.DUP2, vm.DUP2)
+		if greater {
+			a.op(vm.LT)
+		} else {
+			a.op(vm.GT)
+		}
+		a.jumpi(keepX)
+		a.op(vm.SWAP1)
+		a.label(keepX)
+		a.op(vm.POP)
+	}
+}
+
+func sload(slot expr) expr {
+	return func(a *assembler) {
+		slot(a)
+		a.op(vm.SLOAD)
+	}
+}
+
+// Storage slot of mapping(...) at base slot, as computed by Solidity
+// (keccak256(key . slot) for each key)
+func mapping(base uint64, keys ...expr) expr {
+	return func(a *assembler) {
+		a.pushInt(base)
+		for _, key := range keys {
+			key(a)
+			a.mstore(0).mstore(32).pushInt(64).pushInt(0).op(vm.KECCAK256)
+		}
+	}
+}
+
+// keccak256(abi.encode(x, y))
+func keccakPair(x, y expr) expr {
+	return func(a *assembler) {
+		y(a)
+		x(a)
+		a.mstore(0).mstore(32).pushInt(64).pushInt(0).op(vm.KECCAK256)
+	}
+}
+
+// Word of the calldata at offset
+func calldataAt(offset expr) expr {
+	return func(a *assembler) {
+		offset(a)
+		a.op(vm.CALLDATALOAD)
+	}
+}
+
+// keccak256 of size bytes of calldata at offset
+func keccakCalldata(offset, size uint64) expr {
+	return func(a *assembler) {
+		a.pushInt(size).pushInt(offset).pushInt(bufferMemory).op(vm.CALLDATACOPY)
+		a.pushInt(size).pushInt(bufferMemory).op(vm.KECCAK256)
+	}
+}
+
+// Result (first word) of a view function of the contract at address, reverts
+// if the call fails
+func staticCall(address common.Address, method abi.Method, args ...expr) expr {
+	return func(a *assembler) {
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i](a)
+		}
+		a.push(common.RightPadBytes(method.ID, 32)).mstore(bufferMemory)
+		for i := range args {
+			a.mstore(bufferMemory + 4 + 32*uint64(i))
+		}
+		size := 4 + 32*uint64(len(args))
+		a.pushInt(32).pushInt(0).pushInt(size).pushInt(bufferMemory).pushAddress(address).op(vm.GAS, vm.STATICCALL)
+		a.require(method.Name + " failed")
+		a.pushInt(0).op(vm.MLOAD)
+	}
+}
+
+// Output (one word) of the precompiled contract at address for the bytes
+// encoded in calldata at offset
+func precompileBytes(address common.Address, offset expr) expr {
+	return func(a *assembler) {
+		calldataAt(offset)(a)
+		a.op(vm.DUP1)
+		add(offset, lit(32))(a)
+		a.pushInt(bufferMemory).op(vm.CALLDATACOPY)
+		// Stack: length of the input
+		a.pushInt(32).pushInt(0).op(vm.DUP3).pushInt(bufferMemory).pushAddress(address).op(vm.GAS, vm.STATICCALL)
+		a.require("precompile call failed")
+		a.op(vm.POP).pushInt(0).op(vm.MLOAD)
+	}
+}
+
+// Output (one word) of the precompiled contract at address for the word x
+func precompileWord(address common.Address, x expr) expr {
+	return func(a *assembler) {
+		x(a)
+		a.mstore(0)
+		a.pushInt(32).pushInt(0).pushInt(32).pushInt(0).pushAddress(address).op(vm.GAS, vm.STATICCALL)
+		a.require("precompile call failed")
+		a.pushInt(0).op(vm.MLOAD)
+	}
+}
+
+// Call the contract at target with the bytes encoded in calldata at offset as
+// calldata, returns 1 if the call succeeded, 0 otherwise
+func forwardCall(target expr, offset expr) expr {
+	return func(a *assembler) {
+		calldataAt(offset)(a)
+		a.op(vm.DUP1)
+		add(offset, lit(32))(a)
+		a.pushInt(bufferMemory).op(vm.CALLDATACOPY)
+		// Stack: length of the calldata
+		a.pushInt(0).pushInt(0).op(vm.DUP3).pushInt(bufferMemory).pushInt(0)
+		target(a)
+		a.op(vm.GAS, vm.CALL, vm.SWAP1, vm.POP)
+	}
+}
+
+// Dynamic value of an abi encoding (return data or event data)
+type tail interface {
+	// Write the encoding to memory at the offset on top of the stack and
+	// replace the offset with the size of the encoding
+	write(a *assembler)
+}
+
+// Dynamic argument copied from calldata, since it is already abi encoded. The
+// argument must be bytes or an array of static items.
+type calldataTail struct {
+	offset expr
+	// Size of an item, 0 for bytes
+	itemSize uint64
+}
+
+func (t calldataTail) write(a *assembler) {
+	length := calldataAt(t.offset)
+	var size expr
+	if t.itemSize == 0 {
+		size = add(lit(32), mul(div(add(length, lit(31)), lit(32)), lit(32)))
+	} else {
+		size = add(lit(32), mul(length, lit(t.itemSize)))
+	}
+	size(a)
+	// Stack: destination, size
+	a.op(vm.DUP1)
+	t.offset(a)
+	a.op(vm.DUP4, vm.CALLDATACOPY, vm.SWAP1, vm.POP)
+}
+
+// Array of words
+type wordsTail []expr
+
+func (t wordsTail) write(a *assembler) {
+	a.pushInt(uint64(len(t))).op(vm.DUP2, vm.MSTORE)
+	for i, item := range t {
+		item(a)
+		a.op(vm.DUP2).pushInt(32*uint64(i+1)).op(vm.ADD, vm.MSTORE)
+	}
+	a.op(vm.POP).pushInt(32 * uint64(len(t)+1))
+}
+
+// Contract with functions dispatched by the selectors of the ABI
+type contract struct {
+	abi       *abi.ABI
+	functions []*function
+}
+
+func newContract(contractABI *abi.ABI) *contract {
+	return &contract{abi: contractABI}
+}
+
+// Function of the contract, the code is generated by body
+type function struct {
+	contract *contract
+	method   *abi.Method
+	a        *assembler
+	body     func(f *function)
+	locals   uint64
+}
+
+// Add the function of the ABI with the given name
+func (c *contract) function(name string, body func(f *function)) {
+	method, ok := c.abi.Methods[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown method %s", name))
+	}
+	c.functions = append(c.functions, &function{contract: c, method: &method, body: body})
+}
+
+// Runtime code of the contract, calls of other functions revert
+func (c *contract) code() []byte {
+	a := newAssembler()
+	a.selector()
+	for _, f := range c.functions {
+		a.dispatch(*f.method, f.method.Name)
+	}
+	a.pushInt(0).pushInt(0).op(vm.REVERT)
+	for _, f := range c.functions {
+		a.label(f.method.Name)
+		f.a = a
+		f.body(f)
+		a.op(vm.STOP)
+	}
+	return a.assemble()
+}
+
+// Argument with the given name, or a field of a (static) tuple argument
+func (f *function) arg(name string, fields ...string) expr {
+	offset, t := argumentOffset(f.method.Inputs, name, fields)
+	if isDynamic(t) {
+		panic(fmt.Sprintf("argument %s of %s is dynamic", name, f.method.Name))
+	}
+	return calldataAt(lit(4 + offset))
+}
+
+// Calldata offset of the encoding of the dynamic argument with the given name
+func (f *function) argData(name string) expr {
+	offset, t := argumentOffset(f.method.Inputs, name, nil)
+	if !isDynamic(t) {
+		panic(fmt.Sprintf("argument %s of %s is static", name, f.method.Name))
+	}
+	return add(lit(4), calldataAt(lit(4+offset)))
+}
+
+// Calldata offset and size of the static argument with the given name
+func (f *function) argRange(name string) (uint64, uint64) {
+	offset, t := argumentOffset(f.method.Inputs, name, nil)
+	if isDynamic(t) {
+		panic(fmt.Sprintf("argument %s of %s is dynamic", name, f.method.Name))
+	}
+	return 4 + offset, headSize(t)
+}
+
+// Type of the argument with the given name
+func (f *function) argType(name string) abi.Type {
+	_, t := argumentOffset(f.method.Inputs, name, nil)
+	return t
+}
+
+// Dynamic argument with the given name, copied to an encoding as is
+func (f *function) argTail(name string) tail {
+	t := f.argType(name)
+	switch t.T {
+	case abi.BytesTy:
+		return calldataTail{offset: f.argData(name)}
+	case abi.SliceTy:
+		if isDynamic(*t.Elem) {
+			panic(fmt.Sprintf("argument %s of %s has dynamic items", name, f.method.Name))
+		}
+		return calldataTail{offset: f.argData(name), itemSize: headSize(*t.Elem)}
+	}
+	panic(fmt.Sprintf("argument %s of %s is not bytes or an array", name, f.method.Name))
+}
+
+// Local variable (a word of memory)
+type variable uint64
+
+func (f *function) local() variable {
+	if f.locals == maxLocals {
+		panic(fmt.Sprintf("too many local variables in %s", f.method.Name))
+	}
+	f.locals++
+	return variable(localsMemory + 32*(f.locals-1))
+}
+
+func (v variable) get() expr {
+	return func(a *assembler) { a.pushInt(uint64(v)).op(vm.MLOAD) }
+}
+
+func (f *function) set(v variable, x expr) {
+	x(f.a)
+	f.a.mstore(uint64(v))
+}
+
+func (f *function) sstore(slot expr, value expr) {
+	value(f.a)
+	slot(f.a)
+	f.a.op(vm.SSTORE)
+}
+
+// Revert with the reason if cond is zero
+func (f *function) require(cond expr, reason string) {
+	cond(f.a)
+	f.a.require(reason)
+}
+
+func (f *function) when(cond expr, then func()) {
+	done := f.a.newLabel("when_done")
+	isZero(cond)(f.a)
+	f.a.jumpi(done)
+	then()
+	f.a.label(done)
+}
+
+func (f *function) while(cond expr, body func()) {
+	loop := f.a.newLabel("while")
+	done := f.a.newLabel("while_done")
+	f.a.label(loop)
+	isZero(cond)(f.a)
+	f.a.jumpi(done)
+	body()
+	f.a.jump(loop)
+	f.a.label(done)
+}
+
+// Return the abi encoded values, each either an expr or a tail
+func (f *function) ret(values ...interface{}) {
+	f.encode(values)
+	f.a.pushInt(bufferMemory).op(vm.RETURN)
+}
+
+// Emit the event of the contract with the values of its inputs, indexed inputs
+// must be exprs
+func (f *function) emit(name string, values ...interface{}) {
+	event, ok := f.contract.abi.Events[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown event %s", name))
+	}
+	if len(values) != len(event.Inputs) {
+		panic(fmt.Sprintf("event %s has %d inputs", name, len(event.Inputs)))
+	}
+	var topics []expr
+	var data []interface{}
+	for i, input := range event.Inputs {
+		if input.Indexed {
+			topics = append(topics, values[i].(expr))
+		} else {
+			data = append(data, values[i])
+		}
+	}
+	for i := len(topics) - 1; i >= 0; i-- {
+		topics[i](f.a)
+	}
+	f.encode(data)
+	f.a.pushHash(event.ID).op(vm.SWAP1).pushInt(bufferMemory)
+	f.a.op(vm.LOG1 + vm.OpCode(len(topics)))
+}
+
+// Write the abi encoding of the values to the buffer and push its size. Word
+// values are evaluated before the buffer is written, tails must not use it.
+func (f *function) encode(values []interface{}) {
+	a := f.a
+	var words []int
+	var tails []int
+	for i, value := range values {
+		switch value.(type) {
+		case expr:
+			words = append(words, i)
+		case tail:
+			tails = append(tails, i)
+		default:
+			panic(fmt.Sprintf("invalid value %T in %s", value, f.method.Name))
+		}
+	}
+	for j := len(words) - 1; j >= 0; j-- {
+		values[words[j]].(expr)(a)
+	}
+	for _, i := range words {
+		a.mstore(bufferMemory + 32*uint64(i))
+	}
+	// Stack: size of the encoding so far
+	a.pushInt(32 * uint64(len(values)))
+	for _, i := range tails {
+		a.op(vm.DUP1).mstore(bufferMemory + 32*uint64(i))
+		a.op(vm.DUP1).pushInt(bufferMemory).op(vm.ADD)
+		values[i].(tail).write(a)
+		a.op(vm.ADD)
+	}
+}
+
+// Offset in the head of the encoding and the type of the argument with the
+// given name, or of the field of a static tuple argument
+func argumentOffset(args abi.Arguments, name string, fields []string) (uint64, abi.Type) {
+	var offset uint64
+	for _, arg := range args {
+		if arg.Name != name {
+			offset += headSize(arg.Type)
+			continue
+		}
+		t := arg.Type
+		for _, field := range fields {
+			if isDynamic(t) {
+				panic(fmt.Sprintf("field %s of a dynamic tuple", field))
+			}
+			fieldOffset, fieldType := tupleFieldOffset(t, field)
+			offset += fieldOffset
+			t = fieldType
+		}
+		return offset, t
+	}
+	panic(fmt.Sprintf("unknown argument %s", name))
+}
+
+// Offset in the head of the tuple encoding and the type of its field
+func tupleFieldOffset(t abi.Type, name string) (uint64, abi.Type) {
+	if t.T != abi.TupleTy {
+		panic(fmt.Sprintf("field %s of a type that is not a tuple", name))
+	}
+	var offset uint64
+	for i, elem := range t.TupleElems {
+		if t.TupleRawNames[i] == name {
+			return offset, *elem
+		}
+		offset += headSize(*elem)
+	}
+	panic(fmt.Sprintf("unknown field %s", name))
+}
+
+// Size of the value in the head of an encoding (the offset for dynamic types)
+func headSize(t abi.Type) uint64 {
+	switch {
+	case isDynamic(t):
+		return 32
+	case t.T == abi.TupleTy:
+		var size uint64
+		for _, elem := range t.TupleElems {
+			size += headSize(*elem)
+		}
+		return size
+	case t.T == abi.ArrayTy:
+		return uint64(t.Size) * headSize(*t.Elem)
+	}
+	return 32
+}
+
+func isDynamic(t abi.Type) bool {
+	switch t.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy:
+		return true
+	case abi.TupleTy:
+		for _, elem := range t.TupleElems {
+			if isDynamic(*elem) {
+				return true
+			}
+		}
+	case abi.ArrayTy:
+		return isDynamic(*t.Elem)
+	}
+	return false
+}
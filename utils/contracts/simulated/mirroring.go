@@ -0,0 +1,105 @@
+package simulated
+
+import (
+	"flare-indexer/utils/contracts/addresses"
+	"flare-indexer/utils/contracts/mirroring"
+	"flare-indexer/utils/contracts/voting"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Storage slot of the mirroring contract mapping
+const (
+	mirroringMirroredSlot = 0 // p-chain tx id => mirrored
+)
+
+// Minimal implementation of the PChainStakeMirror contract. Stake data is
+// verified with the merkle proof against the root finalized on the voting
+// contract for the epoch of the stake start time, and the input address must be
+// registered on the address binder contract. Reverts use the same messages as
+// the original contract.
+func mirroringRuntimeCode(votingAddress, binderAddress common.Address) ([]byte, error) {
+	contractABI, err := mirroring.MirroringMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	votingABI, err := voting.VotingMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	binderABI, err := addresses.BinderMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	c := newContract(contractABI)
+
+	c.function("addressBinder", func(f *function) {
+		f.ret(litAddress(binderAddress))
+	})
+
+	c.function("isActiveStakeMirrored", func(f *function) {
+		f.ret(sload(mapping(mirroringMirroredSlot, f.arg("_txId"))))
+	})
+
+	c.function("mirrorStake", func(f *function) {
+		stake := func(field string) expr { return f.arg("_stakeData", field) }
+		f.require(isZero(sload(mapping(mirroringMirroredSlot, stake("txId")))), "transaction already mirrored")
+		f.require(lt(timestamp(), stake("endTime")), "staking already ended")
+
+		owner := f.local()
+		f.set(owner, staticCall(binderAddress, binderABI.Methods["pAddressToCAddress"], stake("inputAddress")))
+		f.require(owner.get(), "unknown staking address")
+
+		// Leaf of the merkle tree is the hash of the abi encoded stake data,
+		// pairs are hashed in sorted order
+		leaf, hash := f.local(), f.local()
+		f.set(leaf, keccakCalldata(f.argRange("_stakeData")))
+		f.set(hash, leaf.get())
+		item, end := f.local(), f.local()
+		f.set(item, add(f.argData("_merkleProof"), lit(32)))
+		f.set(end, add(item.get(), mul(calldataAt(f.argData("_merkleProof")), lit(32))))
+		f.while(lt(item.get(), end.get()), func() {
+			proof := calldataAt(item.get())
+			f.set(hash, keccakPair(minOf(hash.get(), proof), maxOf(hash.get(), proof)))
+			f.set(item, add(item.get(), lit(32)))
+		})
+
+		// Compare with the merkle root of the epoch of the stake start time
+		epoch := staticCall(votingAddress, votingABI.Methods["getEpochId"], stake("startTime"))
+		root := staticCall(votingAddress, votingABI.Methods["getMerkleRoot"], epoch)
+		f.require(eq(hash.get(), root), "staking data invalid")
+
+		f.sstore(mapping(mirroringMirroredSlot, stake("txId")), lit(1))
+		f.emit("StakeConfirmed",
+			owner.get(), stake("nodeId"), leaf.get(), mul(stake("weight"), lit(1e9)), stake("txId"),
+		)
+	})
+
+	return c.code(), nil
+}
+
+// Deploy the simulated mirroring contract and return its address and binding
+func DeployMirroring(
+	opts *bind.TransactOpts,
+	backend bind.ContractBackend,
+	votingAddress common.Address,
+	binderAddress common.Address,
+) (common.Address, *types.Transaction, *mirroring.Mirroring, error) {
+	code, err := mirroringRuntimeCode(votingAddress, binderAddress)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contractABI, err := mirroring.MirroringMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, err := deploy(opts, backend, *contractABI, code)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contract, err := mirroring.NewMirroring(address, backend)
+	return address, tx, contract, err
+}
@@ -0,0 +1,67 @@
+package simulated
+
+import (
+	"flare-indexer/utils/contracts/multicall"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Minimal implementation of the Multicall3 contract supporting aggregate3 in
+// transactions. Calls are executed in order, a failed call without allowFailure
+// reverts with the same message as the original contract. Results are not
+// returned.
+func multicallRuntimeCode() ([]byte, error) {
+	contractABI, err := multicall.MulticallMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	c := newContract(contractABI)
+
+	c.function("aggregate3", func(f *function) {
+		call3 := *f.argType("calls").Elem
+		field := func(call variable, name string) expr {
+			offset, _ := tupleFieldOffset(call3, name)
+			return add(call.get(), lit(offset))
+		}
+
+		// Offsets of the calls are relative to the start of the array items
+		items, count, i := f.local(), f.local(), f.local()
+		f.set(items, add(f.argData("calls"), lit(32)))
+		f.set(count, calldataAt(f.argData("calls")))
+		f.set(i, lit(0))
+		f.while(lt(i.get(), count.get()), func() {
+			call := f.local()
+			f.set(call, add(items.get(), calldataAt(add(items.get(), mul(i.get(), lit(32))))))
+			callData := add(call.get(), calldataAt(field(call, "callData")))
+			success := forwardCall(calldataAt(field(call, "target")), callData)
+			f.require(or(success, calldataAt(field(call, "allowFailure"))), "Multicall3: call failed")
+			f.set(i, add(i.get(), lit(1)))
+		})
+	})
+
+	return c.code(), nil
+}
+
+// Deploy the simulated multicall contract and return its address and binding
+func DeployMulticall(
+	opts *bind.TransactOpts,
+	backend bind.ContractBackend,
+) (common.Address, *types.Transaction, *multicall.Multicall, error) {
+	code, err := multicallRuntimeCode()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contractABI, err := multicall.MulticallMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, err := deploy(opts, backend, *contractABI, code)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contract, err := multicall.NewMulticall(address, backend)
+	return address, tx, contract, err
+}
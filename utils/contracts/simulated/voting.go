@@ -0,0 +1,150 @@
+package simulated
+
+import (
+	"flare-indexer/utils/contracts/voting"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// Storage slots of the voting contract mappings
+const (
+	votingRootsSlot  = 0 // epoch => finalized merkle root
+	votingVotedSlot  = 1 // epoch => voter => voted
+	votingCountsSlot = 2 // epoch => merkle root => number of votes
+)
+
+type VotingParams struct {
+	FirstEpochStart time.Time
+	EpochPeriod     time.Duration
+	Voters          []common.Address
+
+	// Number of equal votes needed to finalize the merkle root of an epoch,
+	// defaults to the number of voters
+	Threshold uint64
+}
+
+// Minimal implementation of the PChainStakeMirrorMultiSigVoting contract. It
+// supports epoch configuration, vote submission and finalization (with the
+// corresponding events), and uptime vote submission. Voters and the threshold
+// are fixed at deployment.
+func votingRuntimeCode(params *VotingParams) ([]byte, error) {
+	if params.EpochPeriod < time.Second {
+		return nil, errors.New("epoch period must be at least one second")
+	}
+	if len(params.Voters) == 0 {
+		return nil, errors.New("at least one voter is required")
+	}
+	threshold := params.Threshold
+	if threshold == 0 {
+		threshold = uint64(len(params.Voters))
+	}
+
+	contractABI, err := voting.VotingMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	start := uint64(params.FirstEpochStart.Unix())
+	period := uint64(params.EpochPeriod / time.Second)
+
+	isVoter := func(account expr) expr {
+		result := lit(0)
+		for _, voter := range params.Voters {
+			result = or(result, eq(account, litAddress(voter)))
+		}
+		return result
+	}
+	epochID := func(timestamp expr) expr {
+		return div(sub(maxOf(timestamp, lit(start)), lit(start)), lit(period))
+	}
+
+	c := newContract(contractABI)
+
+	c.function("getEpochConfiguration", func(f *function) {
+		f.ret(lit(start), lit(period))
+	})
+
+	c.function("getEpochId", func(f *function) {
+		f.ret(epochID(f.arg("_timestamp")))
+	})
+
+	c.function("getCurrentEpochId", func(f *function) {
+		f.ret(epochID(timestamp()))
+	})
+
+	c.function("getMerkleRoot", func(f *function) {
+		f.ret(sload(mapping(votingRootsSlot, f.arg("_epochId"))))
+	})
+
+	c.function("getVotingThreshold", func(f *function) {
+		f.ret(lit(threshold))
+	})
+
+	c.function("getVoters", func(f *function) {
+		voters := make(wordsTail, len(params.Voters))
+		for i, voter := range params.Voters {
+			voters[i] = litAddress(voter)
+		}
+		f.ret(voters)
+	})
+
+	c.function("shouldVote", func(f *function) {
+		epoch, voter := f.arg("_epochId"), f.arg("_voter")
+		f.ret(and(
+			and(isVoter(voter), isZero(sload(mapping(votingRootsSlot, epoch)))),
+			isZero(sload(mapping(votingVotedSlot, epoch, voter))),
+		))
+	})
+
+	c.function("submitVote", func(f *function) {
+		epoch, root := f.arg("_epochId"), f.arg("_merkleRoot")
+		f.require(isVoter(caller()), "only voters")
+		f.require(isZero(sload(mapping(votingRootsSlot, epoch))), "epoch already finalized")
+		f.require(isZero(sload(mapping(votingVotedSlot, epoch, caller()))), "vote already submitted")
+		f.sstore(mapping(votingVotedSlot, epoch, caller()), lit(1))
+
+		count := f.local()
+		f.set(count, add(sload(mapping(votingCountsSlot, epoch, root)), lit(1)))
+		f.sstore(mapping(votingCountsSlot, epoch, root), count.get())
+		f.emit("PChainStakeMirrorVoteSubmitted", epoch, caller(), root)
+
+		f.when(isZero(lt(count.get(), lit(threshold))), func() {
+			f.sstore(mapping(votingRootsSlot, epoch), root)
+			f.emit("PChainStakeMirrorVotingFinalized", epoch, root)
+		})
+	})
+
+	c.function("submitValidatorUptimeVote", func(f *function) {
+		f.require(isVoter(caller()), "only voters")
+		f.emit("PChainStakeMirrorValidatorUptimeVoteSubmitted",
+			f.arg("_rewardEpochId"), timestamp(), caller(), f.argTail("_nodeIds"),
+		)
+	})
+
+	return c.code(), nil
+}
+
+// Deploy the simulated voting contract and return its address and binding
+func DeployVoting(
+	opts *bind.TransactOpts,
+	backend bind.ContractBackend,
+	params *VotingParams,
+) (common.Address, *types.Transaction, *voting.Voting, error) {
+	code, err := votingRuntimeCode(params)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contractABI, err := voting.VotingMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, err := deploy(opts, backend, *contractABI, code)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	contract, err := voting.NewVoting(address, backend)
+	return address, tx, contract, err
+}
@@ -0,0 +1,170 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"flare-indexer/config"
+	"flare-indexer/database"
+	"flare-indexer/logger"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+
+	defaultCheckTimeout = 5 * time.Second
+)
+
+// Check of a dependency of the process. Liveness checks are run by /healthz,
+// all checks by /readyz.
+type Check struct {
+	Name     string
+	Liveness bool
+	Run      func(ctx context.Context) error
+}
+
+// Serves /healthz and /readyz, both respond with 503 if any of their checks
+// fails
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+type Response struct {
+	Status string `json:"status"`
+	// Status of each check, the error if it failed
+	Checks map[string]string `json:"checks"`
+}
+
+func NewChecker(cfg *config.HealthConfig, checks ...Check) *Checker {
+	timeout := cfg.CheckTimeout
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+func (c *Checker) Register(r *mux.Router) {
+	r.HandleFunc("/healthz", c.handler(true)).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", c.handler(false)).Methods(http.MethodGet)
+}
+
+func (c *Checker) handler(liveness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := c.Run(r.Context(), liveness)
+		code := http.StatusOK
+		if resp.Status != StatusOk {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logger.Error("Failed writing health response: %v", err)
+		}
+	}
+}
+
+// Run the liveness checks or all checks
+func (c *Checker) Run(ctx context.Context, liveness bool) Response {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp := Response{Status: StatusOk, Checks: make(map[string]string)}
+	for _, check := range c.checks {
+		if liveness && !check.Liveness {
+			continue
+		}
+		if err := check.Run(ctx); err != nil {
+			resp.Status = StatusFail
+			resp.Checks[check.Name] = err.Error()
+		} else {
+			resp.Checks[check.Name] = StatusOk
+		}
+	}
+	return resp
+}
+
+// Check of the database connection
+func DBCheck(db *gorm.DB) Check {
+	return Check{
+		Name:     "db",
+		Liveness: true,
+		Run: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	}
+}
+
+// Check of the lag and the age of the last update of the indexer state
+func StateCheck(db *gorm.DB, name string, cfg *config.HealthConfig) Check {
+	return Check{
+		Name: "state_" + name,
+		Run: func(ctx context.Context) error {
+			state, err := database.FetchState(db.WithContext(ctx), name)
+			if err != nil {
+				return errors.Wrap(err, "failed fetching state")
+			}
+			return CheckState(&state, cfg, time.Now())
+		},
+	}
+}
+
+func CheckState(state *database.State, cfg *config.HealthConfig, now time.Time) error {
+	if lag := StateLag(state); cfg.MaxIndexLag > 0 && lag > cfg.MaxIndexLag {
+		return fmt.Errorf("index lag %d exceeds %d", lag, cfg.MaxIndexLag)
+	}
+	if age := now.Sub(state.Updated); cfg.MaxStateAge > 0 && age > cfg.MaxStateAge {
+		return fmt.Errorf("last update %v ago exceeds %v", age.Truncate(time.Second), cfg.MaxStateAge)
+	}
+	return nil
+}
+
+// Number of indexes accepted by the node that are not indexed yet
+func StateLag(state *database.State) uint64 {
+	if state.LastChainIndex < state.NextDBIndex {
+		return 0
+	}
+	return state.LastChainIndex + 1 - state.NextDBIndex
+}
+
+// Check that the node responds to a request of the url, any response
+// (including errors of the node) counts as reachable
+func NodeCheck(url string) Check {
+	return Check{
+		Name: "node",
+		Run: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return errors.Wrap(err, "node unreachable")
+			}
+			return resp.Body.Close()
+		},
+	}
+}
+
+// Check that the node responds to an eth RPC request
+func EthNodeCheck(client *ethclient.Client) Check {
+	return Check{
+		Name: "node",
+		Run: func(ctx context.Context) error {
+			_, err := client.BlockNumber(ctx)
+			return errors.Wrap(err, "node unreachable")
+		},
+	}
+}
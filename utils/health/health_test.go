@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flare-indexer/config"
+	"flare-indexer/database"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckState(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &config.HealthConfig{MaxIndexLag: 10, MaxStateAge: time.Minute}
+
+	state := &database.State{NextDBIndex: 101, LastChainIndex: 100, Updated: now.Add(-10 * time.Second)}
+	require.Equal(t, uint64(0), StateLag(state))
+	require.NoError(t, CheckState(state, cfg, now))
+
+	state.NextDBIndex = 90
+	require.Equal(t, uint64(11), StateLag(state))
+	require.EqualError(t, CheckState(state, cfg, now), "index lag 11 exceeds 10")
+
+	state.NextDBIndex = 91
+	state.Updated = now.Add(-2 * time.Minute)
+	require.EqualError(t, CheckState(state, cfg, now), "last update 2m0s ago exceeds 1m0s")
+
+	// Disabled thresholds
+	require.NoError(t, CheckState(state, &config.HealthConfig{}, now))
+}
+
+func TestChecker(t *testing.T) {
+	var nodeErr error
+	checker := NewChecker(&config.HealthConfig{},
+		Check{Name: "db", Liveness: true, Run: func(ctx context.Context) error { return nil }},
+		Check{Name: "node", Run: func(ctx context.Context) error { return nodeErr }},
+	)
+	r := mux.NewRouter()
+	checker.Register(r)
+
+	get := func(path string, code int) Response {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, code, rr.Code)
+		var resp Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := get("/readyz", http.StatusOK)
+	require.Equal(t, StatusOk, resp.Status)
+	require.Equal(t, map[string]string{"db": StatusOk, "node": StatusOk}, resp.Checks)
+
+	// Node is only checked for readiness
+	nodeErr = errors.New("node unreachable")
+	resp = get("/readyz", http.StatusServiceUnavailable)
+	require.Equal(t, StatusFail, resp.Status)
+	require.Equal(t, "node unreachable", resp.Checks["node"])
+	resp = get("/healthz", http.StatusOK)
+	require.Equal(t, map[string]string{"db": StatusOk}, resp.Checks)
+}
+
+func TestNodeCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	url := srv.URL + "/ext/health"
+
+	// Unhealthy node is reachable
+	require.NoError(t, NodeCheck(url).Run(context.Background()))
+	srv.Close()
+	require.Error(t, NodeCheck(url).Run(context.Background()))
+}
@@ -144,8 +144,7 @@ func (t Tree) GetProof(i int) ([]common.Hash, error) {
 	var proof []common.Hash
 
 	for pos := len(t.tree) - numLeaves + i; pos > 0; pos = parent(pos) {
-		sibling := pos + ((2 * (pos % 2)) - 1)
-		proof = append(proof, t.tree[sibling])
+		proof = append(proof, t.tree[sibling(pos)])
 	}
 
 	return proof, nil
@@ -156,6 +155,11 @@ func parent(i int) int {
 	return (i - 1) / 2
 }
 
+// sibling returns the index of the sibling node.
+func sibling(i int) int {
+	return i + ((2 * (i % 2)) - 1)
+}
+
 func (t Tree) GetProofFromHash(hash common.Hash) ([]common.Hash, error) {
 	i, err := t.binarySearch(hash)
 	if err != nil {
@@ -178,12 +182,18 @@ func (t Tree) binarySearch(hash common.Hash) (int, error) {
 	return 0, ErrHashNotFound
 }
 
-// VerifyProof verifies a Merkle proof for a given leaf.
-func VerifyProof(leaf common.Hash, proof []common.Hash, root common.Hash) bool {
+// ProcessProof returns the root reconstructed from a Merkle proof for a given
+// leaf.
+func ProcessProof(leaf common.Hash, proof []common.Hash) common.Hash {
 	hash := leaf
 	for _, pair := range proof {
 		hash = SortedHashPair(pair, hash)
 	}
 
-	return hash == root
+	return hash
+}
+
+// VerifyProof verifies a Merkle proof for a given leaf.
+func VerifyProof(leaf common.Hash, proof []common.Hash, root common.Hash) bool {
+	return ProcessProof(leaf, proof) == root
 }
@@ -3,7 +3,11 @@ package merkle_test
 import (
 	"flare-indexer/utils/merkle"
 	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
 	"testing"
+	"testing/quick"
 
 	"github.com/bradleyjkemp/cupaloy"
 	"github.com/ethereum/go-ethereum/common"
@@ -125,3 +129,109 @@ func TestMultiLeafTree(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiProof(t *testing.T) {
+	tree := merkle.BuildFromHex([]string{"0x01", "0x02", "0x03", "0x04", "0x05"}, true)
+	root, err := tree.Root()
+	require.NoError(t, err)
+
+	proof, err := tree.GetMultiProof([]int{0, 2, 3})
+	require.NoError(t, err)
+	cupaloy.SnapshotT(t, proof)
+	assert.True(t, merkle.VerifyMultiProof(proof, root))
+
+	_, err = tree.GetMultiProof([]int{1, 1})
+	assert.Equal(t, merkle.ErrDuplicateIndex, err)
+
+	_, err = tree.GetMultiProof([]int{5})
+	assert.Equal(t, merkle.ErrInvalidIndex, err)
+
+	_, err = merkle.New(nil).GetMultiProof(nil)
+	assert.Equal(t, merkle.ErrEmptyTree, err)
+}
+
+// Random tree with a random subset of its leaf indices
+type multiProofInput struct {
+	Tree    merkle.Tree
+	Indices []int
+}
+
+func (multiProofInput) Generate(r *rand.Rand, size int) reflect.Value {
+	hashes := make([]common.Hash, r.Intn(size+1)+1)
+	for i := range hashes {
+		r.Read(hashes[i][:])
+	}
+	input := multiProofInput{Tree: merkle.Build(hashes, false)}
+	for _, i := range r.Perm(len(hashes)) {
+		if r.Intn(2) == 0 {
+			input.Indices = append(input.Indices, i)
+		}
+	}
+	return reflect.ValueOf(input)
+}
+
+func TestMultiProofProperties(t *testing.T) {
+	verifies := func(in multiProofInput) bool {
+		root, _ := in.Tree.Root()
+		proof, err := in.Tree.GetMultiProof(in.Indices)
+		if err != nil || len(proof.Leaves) != len(in.Indices) {
+			return false
+		}
+		return merkle.VerifyMultiProof(proof, root)
+	}
+	require.NoError(t, quick.Check(verifies, nil))
+
+	// Changing any leaf or proof hash invalidates the proof
+	tampered := func(in multiProofInput, k int, bit uint8) bool {
+		root, _ := in.Tree.Root()
+		proof, err := in.Tree.GetMultiProof(in.Indices)
+		if err != nil {
+			return false
+		}
+		hashes := append(append([]common.Hash(nil), proof.Leaves...), proof.Proof...)
+		k = int(uint(k) % uint(len(hashes)))
+		hashes[k][bit%32] ^= 1 << (bit % 8)
+		proof.Leaves = hashes[:len(proof.Leaves)]
+		proof.Proof = hashes[len(proof.Leaves):]
+		return !merkle.VerifyMultiProof(proof, root)
+	}
+	require.NoError(t, quick.Check(tampered, nil))
+
+	// Multi-proof of a single leaf is its single-leaf proof
+	single := func(in multiProofInput, k int) bool {
+		i := int(uint(k) % uint(in.Tree.HashCount()))
+		multiProof, err := in.Tree.GetMultiProof([]int{i})
+		if err != nil {
+			return false
+		}
+		proof, err := in.Tree.GetProof(i)
+		if err != nil {
+			return false
+		}
+		for _, flag := range multiProof.ProofFlags {
+			if flag {
+				return false
+			}
+		}
+		return len(proof) == len(multiProof.Proof) && (len(proof) == 0 || reflect.DeepEqual(proof, multiProof.Proof))
+	}
+	require.NoError(t, quick.Check(single, nil))
+
+	// Leaves are in the descending order of their positions in the tree
+	ordered := func(in multiProofInput) bool {
+		proof, err := in.Tree.GetMultiProof(in.Indices)
+		if err != nil {
+			return false
+		}
+		indices := append([]int(nil), in.Indices...)
+		sort.Sort(sort.Reverse(sort.IntSlice(indices)))
+		for j, i := range indices {
+			hash, err := in.Tree.GetHash(i)
+			if err != nil || hash != proof.Leaves[j] {
+				return false
+			}
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(ordered, nil))
+}
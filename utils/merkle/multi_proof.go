@@ -0,0 +1,147 @@
+package merkle
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrDuplicateIndex    = errors.New("duplicate index")
+	ErrInvalidMultiProof = errors.New("invalid multi-proof")
+)
+
+// MultiProof proves several leaves of a tree at once. It is compatible with
+// OpenZeppelin's MerkleProof.multiProofVerify(proof, proofFlags, root, leaves):
+// the leaves must be passed in the order given here, which is the descending
+// order of their positions in the tree.
+type MultiProof struct {
+	Leaves     []common.Hash `json:"leaves"`
+	Proof      []common.Hash `json:"proof"`
+	ProofFlags []bool        `json:"proofFlags"`
+}
+
+// GetMultiProof returns the multi-proof for the leaves with the given indices
+// (in the order of SortedHashes). The algorithm is the one of OpenZeppelin's
+// StandardMerkleTree.getMultiProof.
+func (t Tree) GetMultiProof(indices []int) (*MultiProof, error) {
+	numLeaves := t.HashCount()
+	if numLeaves == 0 {
+		return nil, ErrEmptyTree
+	}
+
+	positions := make([]int, len(indices))
+	for j, i := range indices {
+		if i < 0 || i >= numLeaves {
+			return nil, ErrInvalidIndex
+		}
+		positions[j] = len(t.tree) - numLeaves + i
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(positions)))
+	for j := 1; j < len(positions); j++ {
+		if positions[j] == positions[j-1] {
+			return nil, ErrDuplicateIndex
+		}
+	}
+
+	proof := &MultiProof{
+		Leaves:     make([]common.Hash, len(positions)),
+		Proof:      make([]common.Hash, 0),
+		ProofFlags: make([]bool, 0),
+	}
+	for j, pos := range positions {
+		proof.Leaves[j] = t.tree[pos]
+	}
+
+	// Nodes are processed in descending order of their positions, so the
+	// sibling of a node, if known, is the next node in the queue
+	queue := append([]int(nil), positions...)
+	for len(queue) > 0 && queue[0] > 0 {
+		pos := queue[0]
+		queue = queue[1:]
+
+		s := sibling(pos)
+		if len(queue) > 0 && queue[0] == s {
+			proof.ProofFlags = append(proof.ProofFlags, true)
+			queue = queue[1:]
+		} else {
+			proof.ProofFlags = append(proof.ProofFlags, false)
+			proof.Proof = append(proof.Proof, t.tree[s])
+		}
+		queue = append(queue, parent(pos))
+	}
+
+	if len(positions) == 0 {
+		proof.Proof = append(proof.Proof, t.tree[0])
+	}
+
+	return proof, nil
+}
+
+// ProcessMultiProof returns the root reconstructed from the multi-proof, as
+// OpenZeppelin's MerkleProof.processMultiProof.
+func ProcessMultiProof(p *MultiProof) (common.Hash, error) {
+	numLeaves := len(p.Leaves)
+	numProof := len(p.Proof)
+	numHashes := len(p.ProofFlags)
+	if numLeaves+numProof != numHashes+1 {
+		return common.Hash{}, ErrInvalidMultiProof
+	}
+
+	hashes := make([]common.Hash, numHashes)
+	leafPos, hashPos, proofPos := 0, 0, 0
+
+	// Next leaf, or next computed hash once all leaves are consumed
+	next := func(i int) (common.Hash, error) {
+		if leafPos < numLeaves {
+			leafPos++
+			return p.Leaves[leafPos-1], nil
+		}
+		if hashPos >= i {
+			return common.Hash{}, ErrInvalidMultiProof
+		}
+		hashPos++
+		return hashes[hashPos-1], nil
+	}
+
+	for i := 0; i < numHashes; i++ {
+		a, err := next(i)
+		if err != nil {
+			return common.Hash{}, err
+		}
+
+		var b common.Hash
+		if p.ProofFlags[i] {
+			b, err = next(i)
+			if err != nil {
+				return common.Hash{}, err
+			}
+		} else {
+			if proofPos >= numProof {
+				return common.Hash{}, ErrInvalidMultiProof
+			}
+			b = p.Proof[proofPos]
+			proofPos++
+		}
+
+		hashes[i] = SortedHashPair(a, b)
+	}
+
+	if numHashes > 0 {
+		if proofPos != numProof {
+			return common.Hash{}, ErrInvalidMultiProof
+		}
+		return hashes[numHashes-1], nil
+	}
+	if numLeaves > 0 {
+		return p.Leaves[0], nil
+	}
+	return p.Proof[0], nil
+}
+
+// VerifyMultiProof verifies a multi-proof for the given root.
+func VerifyMultiProof(p *MultiProof, root common.Hash) bool {
+	computed, err := ProcessMultiProof(p)
+	return err == nil && computed == root
+}
@@ -0,0 +1,25 @@
+package signer
+
+import (
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/pkg/errors"
+)
+
+// Create signer from a go-ethereum encrypted JSON keystore file. The key is
+// decrypted once and kept in memory.
+func NewKeystoreSigner(file string, passphrase string) (Signer, error) {
+	if file == "" {
+		return nil, errors.New("keystore file not set")
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening keystore file")
+	}
+	key, err := keystore.DecryptKey(content, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "keystore.DecryptKey")
+	}
+	return newECDSASigner(key.PrivateKey), nil
+}
@@ -0,0 +1,139 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+const remoteSignerTimeout = 10 * time.Second
+
+// Signer calling eth_signTransaction of a remote signing service (e.g., clef or
+// web3signer) over HTTP
+type remoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// Arguments of eth_signTransaction
+type signTransactionArgs struct {
+	From                 common.Address    `json:"from"`
+	To                   *common.Address   `json:"to,omitempty"`
+	Gas                  hexutil.Uint64    `json:"gas"`
+	GasPrice             *hexutil.Big      `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big      `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big      `json:"maxPriorityFeePerGas,omitempty"`
+	Value                *hexutil.Big      `json:"value"`
+	Nonce                hexutil.Uint64    `json:"nonce"`
+	Data                 hexutil.Bytes     `json:"data"`
+	ChainID              *hexutil.Big      `json:"chainId"`
+	AccessList           *types.AccessList `json:"accessList,omitempty"`
+}
+
+// Result of eth_signTransaction, either the raw transaction or an object
+// containing it (as returned by geth and clef)
+type signTransactionResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+func NewRemoteSigner(url string, address common.Address) (Signer, error) {
+	if url == "" {
+		return nil, errors.New("remote signer url not set")
+	}
+	if address == (common.Address{}) {
+		return nil, errors.New("remote signer address not set")
+	}
+	client, err := rpc.DialHTTP(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "rpc.DialHTTP")
+	}
+	return &remoteSigner{client: client, address: address}, nil
+}
+
+func (s *remoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *remoteSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := signTransactionArgs{
+		From:    s.address,
+		To:      tx.To(),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   (*hexutil.Big)(tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	switch tx.Type() {
+	case types.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	case types.AccessListTxType:
+		accessList := tx.AccessList()
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+		args.AccessList = &accessList
+	default:
+		accessList := tx.AccessList()
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+		args.AccessList = &accessList
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+
+	var result json.RawMessage
+	if err := s.client.CallContext(ctx, &result, "eth_signTransaction", args); err != nil {
+		return nil, errors.Wrap(err, "eth_signTransaction")
+	}
+	raw, err := decodeSignTransactionResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx := new(types.Transaction)
+	if err := signedTx.UnmarshalBinary(raw); err != nil {
+		return nil, errors.Wrap(err, "invalid signed transaction")
+	}
+	if err := checkSignedTx(tx, signedTx, chainID, s.address); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
+
+func decodeSignTransactionResult(result json.RawMessage) ([]byte, error) {
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err == nil {
+		return raw, nil
+	}
+	var obj signTransactionResult
+	if err := json.Unmarshal(result, &obj); err != nil {
+		return nil, errors.Wrap(err, "invalid eth_signTransaction result")
+	}
+	if len(obj.Raw) == 0 {
+		return nil, errors.New("eth_signTransaction result does not contain the raw transaction")
+	}
+	return obj.Raw, nil
+}
+
+// Check that the remote signer signed the requested transaction with the expected account
+func checkSignedTx(tx, signedTx *types.Transaction, chainID *big.Int, address common.Address) error {
+	signer := types.LatestSignerForChainID(chainID)
+	if signer.Hash(tx) != signer.Hash(signedTx) {
+		return errors.New("remote signer signed a different transaction")
+	}
+	sender, err := types.Sender(signer, signedTx)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+	if sender != address {
+		return errors.Errorf("remote signer signed with %s instead of %s", sender, address)
+	}
+	return nil
+}
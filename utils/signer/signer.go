@@ -0,0 +1,103 @@
+// Signers of the transactions sent to the C-chain contracts. The private key
+// signer is intended for development, use the keystore or remote signer in
+// production.
+package signer
+
+import (
+	"crypto/ecdsa"
+	"flare-indexer/config"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	TypePrivateKey = "private_key"
+	TypeKeystore   = "keystore"
+	TypeRemote     = "remote"
+)
+
+type Signer interface {
+	// Address of the signing account
+	Address() common.Address
+
+	// Sign the transaction for the chain
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// Create the signer from the configuration
+func New(cfg *config.SignerConfig, chainCfg *config.ChainConfig) (Signer, error) {
+	switch cfg.Type {
+	case "", TypePrivateKey:
+		privateKey, err := chainCfg.GetPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		return NewPrivateKeySigner(privateKey)
+	case TypeKeystore:
+		passphrase, err := cfg.GetKeystorePassphrase()
+		if err != nil {
+			return nil, err
+		}
+		return NewKeystoreSigner(cfg.KeystoreFile, passphrase)
+	case TypeRemote:
+		return NewRemoteSigner(cfg.RemoteURL, cfg.RemoteAddress)
+	default:
+		return nil, errors.Errorf("unknown signer type %s", cfg.Type)
+	}
+}
+
+// Return transact options signing with the signer
+func TransactOpts(s Signer, chainID int) *bind.TransactOpts {
+	id := big.NewInt(int64(chainID))
+	return &bind.TransactOpts{
+		From: s.Address(),
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != s.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return s.SignTx(tx, id)
+		},
+	}
+}
+
+type privateKeySigner struct {
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
+}
+
+// Create signer from a hex encoded private key (with or without 0x prefix)
+func NewPrivateKeySigner(privateKey string) (Signer, error) {
+	if len(privateKey) < 2 {
+		return nil, errors.New("privateKey is too short")
+	}
+
+	if privateKey[:2] == "0x" {
+		privateKey = privateKey[2:]
+	}
+
+	pk, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "crypto.HexToECDSA")
+	}
+	return newECDSASigner(pk), nil
+}
+
+func newECDSASigner(pk *ecdsa.PrivateKey) *privateKeySigner {
+	return &privateKeySigner{
+		privateKey: pk,
+		address:    crypto.PubkeyToAddress(pk.PublicKey),
+	}
+}
+
+func (s *privateKeySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *privateKeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.privateKey)
+}
@@ -0,0 +1,196 @@
+package signer
+
+import (
+	"encoding/json"
+	"flare-indexer/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrivateKey = "0xd49743deccbccc5dc7baa8e69e5be03298da8688a15dd202e20f15d5e0e9a9fb"
+
+var (
+	testChainID = big.NewInt(1337)
+	testTo      = common.HexToAddress("0x7c2C195CD6D34B8F845992d380aADB2730bB9C6F")
+)
+
+func testTx() *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   testChainID,
+		Nonce:     3,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(50e9),
+		Gas:       100_000,
+		To:        &testTo,
+		Value:     big.NewInt(0),
+		Data:      []byte{1, 2, 3, 4},
+	})
+}
+
+func requireSignedBy(t *testing.T, s Signer, address common.Address) {
+	tx := testTx()
+	opts := TransactOpts(s, int(testChainID.Int64()))
+	require.Equal(t, address, opts.From)
+
+	signedTx, err := opts.Signer(opts.From, tx)
+	require.NoError(t, err)
+
+	sender, err := types.Sender(types.LatestSignerForChainID(testChainID), signedTx)
+	require.NoError(t, err)
+	require.Equal(t, address, sender)
+	require.Equal(t, tx.Nonce(), signedTx.Nonce())
+	require.Equal(t, tx.Data(), signedTx.Data())
+
+	_, err = opts.Signer(testTo, tx)
+	require.Error(t, err)
+}
+
+func testKey(t *testing.T) *keystore.Key {
+	pk, err := crypto.HexToECDSA(testPrivateKey[2:])
+	require.NoError(t, err)
+	return &keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(pk.PublicKey),
+		PrivateKey: pk,
+	}
+}
+
+func TestPrivateKeySigner(t *testing.T) {
+	key := testKey(t)
+
+	s, err := New(&config.SignerConfig{}, &config.ChainConfig{PrivateKey: testPrivateKey})
+	require.NoError(t, err)
+	requireSignedBy(t, s, key.Address)
+
+	_, err = NewPrivateKeySigner("0x")
+	require.Error(t, err)
+}
+
+func TestKeystoreSigner(t *testing.T) {
+	key := testKey(t)
+	dir := t.TempDir()
+
+	content, err := keystore.EncryptKey(key, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	keystoreFile := path.Join(dir, "keystore.json")
+	require.NoError(t, os.WriteFile(keystoreFile, content, 0600))
+	passphraseFile := path.Join(dir, "passphrase")
+	require.NoError(t, os.WriteFile(passphraseFile, []byte("secret\n"), 0600))
+
+	cfg := config.SignerConfig{
+		Type:                   TypeKeystore,
+		KeystoreFile:           keystoreFile,
+		KeystorePassphraseFile: passphraseFile,
+	}
+	s, err := New(&cfg, &config.ChainConfig{})
+	require.NoError(t, err)
+	requireSignedBy(t, s, key.Address)
+
+	cfg = config.SignerConfig{
+		Type:               TypeKeystore,
+		KeystoreFile:       keystoreFile,
+		KeystorePassphrase: "secret",
+	}
+	s, err = New(&cfg, &config.ChainConfig{})
+	require.NoError(t, err)
+	requireSignedBy(t, s, key.Address)
+
+	cfg.KeystorePassphrase = "wrong"
+	_, err = New(&cfg, &config.ChainConfig{})
+	require.Error(t, err)
+
+	cfg.KeystorePassphrase = ""
+	_, err = New(&cfg, &config.ChainConfig{})
+	require.ErrorContains(t, err, "passphrase not set")
+}
+
+// Minimal eth_signTransaction server signing dynamic fee transactions with the
+// key, the result is returned as an object if rawObject is set
+func newTestSigningServer(t *testing.T, key *keystore.Key, rawObject bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage       `json:"id"`
+			Method string                `json:"method"`
+			Params []signTransactionArgs `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "eth_signTransaction", req.Method)
+		require.Len(t, req.Params, 1)
+
+		args := req.Params[0]
+		require.Equal(t, key.Address, args.From)
+		tx := types.NewTx(&types.DynamicFeeTx{
+			ChainID:   args.ChainID.ToInt(),
+			Nonce:     uint64(args.Nonce),
+			GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap: args.MaxFeePerGas.ToInt(),
+			Gas:       uint64(args.Gas),
+			To:        args.To,
+			Value:     args.Value.ToInt(),
+			Data:      args.Data,
+		})
+		signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(args.ChainID.ToInt()), key.PrivateKey)
+		require.NoError(t, err)
+		raw, err := signedTx.MarshalBinary()
+		require.NoError(t, err)
+
+		var result interface{} = hexutil.Bytes(raw)
+		if rawObject {
+			result = map[string]interface{}{"raw": hexutil.Bytes(raw), "tx": signedTx}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  result,
+		}))
+	}))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key := testKey(t)
+
+	for _, rawObject := range []bool{false, true} {
+		server := newTestSigningServer(t, key, rawObject)
+		defer server.Close()
+
+		cfg := config.SignerConfig{
+			Type:          TypeRemote,
+			RemoteURL:     server.URL,
+			RemoteAddress: key.Address,
+		}
+		s, err := New(&cfg, &config.ChainConfig{})
+		require.NoError(t, err)
+		requireSignedBy(t, s, key.Address)
+	}
+}
+
+func TestCheckSignedTx(t *testing.T) {
+	key := testKey(t)
+
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signedTx, err := types.SignTx(testTx(), types.LatestSignerForChainID(testChainID), otherKey)
+	require.NoError(t, err)
+
+	err = checkSignedTx(testTx(), signedTx, testChainID, key.Address)
+	require.ErrorContains(t, err, "remote signer signed with")
+
+	otherTx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{ChainID: testChainID, Nonce: 4}),
+		types.LatestSignerForChainID(testChainID), key.PrivateKey)
+	require.NoError(t, err)
+	err = checkSignedTx(testTx(), otherTx, testChainID, key.Address)
+	require.ErrorContains(t, err, "different transaction")
+}
@@ -0,0 +1,167 @@
+package staking
+
+import (
+	"encoding/json"
+	"flare-indexer/database"
+	"flare-indexer/utils/merkle"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Merkle root voted for epochs without staking transactions
+	EmptyTreeRoot = crypto.Keccak256Hash(make([]byte, 32))
+
+	ErrTxNotInTree = errors.New("tx not in epoch merkle tree")
+)
+
+// Leaf of the epoch merkle tree
+type EpochTreeLeaf struct {
+	TxID         string      `json:"txId"`
+	InputAddress string      `json:"inputAddress"`
+	Hash         common.Hash `json:"hash"`
+}
+
+// Merkle tree of the staking transactions of an epoch with the transactions
+// of its leaves
+type EpochTree struct {
+	Epoch int64
+	Tree  merkle.Tree
+
+	// Leaves in the order of the tree
+	Leaves []EpochTreeLeaf
+
+	// Fingerprint of the data the tree was built from
+	Fingerprint database.PChainTxsFingerprint
+}
+
+// Build the merkle tree of the epoch from the (not deduplicated) staking
+// transactions of the epoch
+func NewEpochTree(epoch int64, txs []database.PChainTxData) (*EpochTree, error) {
+	fingerprint := database.PChainTxsFingerprint{}
+	rowIDs := make(map[uint64]bool)
+	for i := range txs {
+		if !rowIDs[txs[i].ID] {
+			rowIDs[txs[i].ID] = true
+			fingerprint.TxCount++
+		}
+		if txs[i].ID > fingerprint.MaxTxID {
+			fingerprint.MaxTxID = txs[i].ID
+		}
+	}
+
+	txs = DedupeTxs(txs)
+	leaves := make([]EpochTreeLeaf, len(txs))
+	for i := range txs {
+		hash, err := HashTransaction(&txs[i])
+		if err != nil {
+			return nil, err
+		}
+		leaves[i] = EpochTreeLeaf{TxID: *txs[i].TxID, InputAddress: txs[i].InputAddress, Hash: hash}
+	}
+	return newEpochTree(epoch, leaves, fingerprint), nil
+}
+
+// Build the tree of the leaves, sorting them in the order of the tree
+func newEpochTree(epoch int64, leaves []EpochTreeLeaf, fingerprint database.PChainTxsFingerprint) *EpochTree {
+	t := &EpochTree{
+		Epoch:       epoch,
+		Leaves:      make([]EpochTreeLeaf, 0, len(leaves)),
+		Fingerprint: fingerprint,
+	}
+	if len(leaves) == 0 {
+		return t
+	}
+
+	hashes := make([]common.Hash, len(leaves))
+	leafMap := make(map[common.Hash]EpochTreeLeaf, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = leaf.Hash
+		leafMap[leaf.Hash] = leaf
+	}
+	t.Tree = merkle.Build(hashes, false)
+
+	// Hashes are unique (they contain the tx id), so the leaves are in the
+	// order of the sorted hashes
+	for _, hash := range t.Tree.SortedHashes() {
+		t.Leaves = append(t.Leaves, leafMap[hash])
+	}
+	return t
+}
+
+// Root of the tree, EmptyTreeRoot if the epoch has no staking transactions
+func (t *EpochTree) Root() common.Hash {
+	root, err := t.Tree.Root()
+	if err != nil {
+		return EmptyTreeRoot
+	}
+	return root
+}
+
+// Leaf of the tx and its merkle proof
+func (t *EpochTree) Proof(txID string) (*EpochTreeLeaf, [][32]byte, error) {
+	for i := range t.Leaves {
+		if t.Leaves[i].TxID != txID {
+			continue
+		}
+		proof, err := t.ProofAt(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &t.Leaves[i], proof, nil
+	}
+	return nil, nil, ErrTxNotInTree
+}
+
+// Merkle proof of the i-th leaf
+func (t *EpochTree) ProofAt(i int) ([][32]byte, error) {
+	proof, err := t.Tree.GetProof(i)
+	if err != nil {
+		return nil, err
+	}
+	proofBytes := make([][32]byte, len(proof))
+	for j := range proof {
+		proofBytes[j] = [32]byte(proof[j])
+	}
+	return proofBytes, nil
+}
+
+// Check if the tree was built from the data with the given fingerprint
+func (t *EpochTree) IsCurrent(fingerprint database.PChainTxsFingerprint) bool {
+	return t.Fingerprint == fingerprint
+}
+
+// Database entity of the tree
+func (t *EpochTree) Entity(updated time.Time) (*database.EpochMerkleTree, error) {
+	leaves, err := json.Marshal(t.Leaves)
+	if err != nil {
+		return nil, err
+	}
+	return &database.EpochMerkleTree{
+		Epoch:     t.Epoch,
+		Root:      t.Root().Hex(),
+		NumLeaves: len(t.Leaves),
+		TreeSize:  len(t.Tree.Tree()),
+		Leaves:    string(leaves),
+		TxCount:   t.Fingerprint.TxCount,
+		MaxTxID:   t.Fingerprint.MaxTxID,
+		Updated:   updated,
+	}, nil
+}
+
+// Rebuild the tree from the stored leaves
+func EpochTreeFromEntity(e *database.EpochMerkleTree) (*EpochTree, error) {
+	var leaves []EpochTreeLeaf
+	if err := json.Unmarshal([]byte(e.Leaves), &leaves); err != nil {
+		return nil, errors.Wrap(err, "unmarshal leaves")
+	}
+	fingerprint := database.PChainTxsFingerprint{TxCount: e.TxCount, MaxTxID: e.MaxTxID}
+	t := newEpochTree(e.Epoch, leaves, fingerprint)
+	if t.Root().Hex() != e.Root {
+		return nil, errors.Errorf("root of the stored tree of epoch %d does not match its leaves", e.Epoch)
+	}
+	return t, nil
+}
@@ -2,28 +2,231 @@ package staking
 
 import (
 	"flare-indexer/config"
+	"flare-indexer/database"
 	"flare-indexer/utils/contracts/voting"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/pkg/errors"
 )
 
+// Epoch configuration of the voting contract, valid from epoch FirstEpoch on.
+// Epochs are numbered as by the contract: epoch e starts at Start + e * Period.
+type EpochSegment struct {
+	FirstEpoch int64
+	Start      time.Time
+	Period     time.Duration
+}
+
+// Start time of the first epoch of the segment
+func (s EpochSegment) startTime() time.Time {
+	return s.epochStart(s.FirstEpoch)
+}
+
+func (s EpochSegment) epochStart(epoch int64) time.Time {
+	return s.Start.Add(time.Duration(epoch) * s.Period)
+}
+
+func (s EpochSegment) epochIndex(t time.Time) int64 {
+	return int64(t.Sub(s.Start) / s.Period)
+}
+
+// Schedule of the epoch configurations, ordered by the first epoch. It is
+// shared by all users of the epochs and extended when the epoch configuration
+// of the voting contract changes.
+//
+// Epochs of a segment that start after the first epoch of the next segment are
+// cut at its start time, so the time ranges of the epochs are contiguous also
+// when the configuration changes. The last epoch of a segment is extended until
+// the start of the next segment if needed.
+type EpochSchedule struct {
+	mu       sync.RWMutex
+	segments []EpochSegment
+}
+
+func NewEpochSchedule(segments ...EpochSegment) *EpochSchedule {
+	s := &EpochSchedule{}
+	s.SetSegments(segments)
+	return s
+}
+
+func (s *EpochSchedule) Segments() []EpochSegment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]EpochSegment(nil), s.segments...)
+}
+
+func (s *EpochSchedule) SetSegments(segments []EpochSegment) {
+	segments = append([]EpochSegment(nil), segments...)
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].FirstEpoch < segments[j].FirstEpoch
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.segments = segments
+}
+
+// Update the schedule with the epoch configuration of the contract observed at
+// time now. If the configuration changed, a new segment starts with the first
+// epoch (in the new numbering) that starts after the current epoch, and is
+// returned.
+func (s *EpochSchedule) Update(start time.Time, period time.Duration, now time.Time) (*EpochSegment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) == 0 {
+		s.segments = []EpochSegment{{FirstEpoch: 0, Start: start, Period: period}}
+		return &s.segments[0], true
+	}
+
+	last := s.segments[len(s.segments)-1]
+	if last.Start.Equal(start) && last.Period == period {
+		return nil, false
+	}
+
+	current := last.epochIndex(now)
+	if current < last.FirstEpoch {
+		current = last.FirstEpoch
+	}
+	end := last.epochStart(current + 1)
+
+	segment := EpochSegment{Start: start, Period: period, FirstEpoch: current + 1}
+	if end.After(start) {
+		// First epoch of the new configuration that starts at or after the end
+		// of the current epoch
+		first := int64((end.Sub(start) + period - 1) / period)
+		if first > segment.FirstEpoch {
+			segment.FirstEpoch = first
+		}
+	}
+	s.segments = append(s.segments, segment)
+	return &segment, true
+}
+
+// Start time of the epoch, see EpochSchedule for epochs at segment boundaries
+func (s *EpochSchedule) epochStart(epoch int64) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].FirstEpoch > epoch
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	start := s.segments[i].epochStart(epoch)
+	if i+1 < len(s.segments) {
+		if next := s.segments[i+1].startTime(); next.Before(start) {
+			return next
+		}
+	}
+	return start
+}
+
+func (s *EpochSchedule) epochIndex(t time.Time) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].startTime().After(t)
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	epoch := s.segments[i].epochIndex(t)
+	if i+1 < len(s.segments) && epoch >= s.segments[i+1].FirstEpoch {
+		epoch = s.segments[i+1].FirstEpoch - 1
+	}
+	return epoch
+}
+
+func (s *EpochSchedule) currentPeriod() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.segments[len(s.segments)-1].Period
+}
+
+// Persisted epoch configurations
+type EpochScheduleDB interface {
+	FetchEpochConfigSegments() ([]database.EpochConfigSegment, error)
+	CreateEpochConfigSegment(segment *database.EpochConfigSegment) error
+}
+
+// Refresh the schedule with the persisted segments and the epoch configuration
+// of the contract observed at time now. Persisted segments replace the segments
+// of the schedule unless it has more segments, a new segment is persisted and
+// returned.
+func (s *EpochSchedule) Refresh(
+	db EpochScheduleDB, start time.Time, period time.Duration, now time.Time,
+) (*EpochSegment, error) {
+	stored, err := db.FetchEpochConfigSegments()
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 && len(stored) >= len(s.Segments()) {
+		segments := make([]EpochSegment, len(stored))
+		for i, e := range stored {
+			segments[i] = EpochSegment{
+				FirstEpoch: e.FirstEpoch,
+				Start:      e.Start,
+				Period:     time.Duration(e.PeriodSeconds) * time.Second,
+			}
+		}
+		s.SetSegments(segments)
+	}
+
+	segment, changed := s.Update(start, period, now)
+	if !changed {
+		return nil, nil
+	}
+	err = db.CreateEpochConfigSegment(&database.EpochConfigSegment{
+		FirstEpoch:    segment.FirstEpoch,
+		Start:         segment.Start,
+		PeriodSeconds: int64(segment.Period / time.Second),
+		Created:       now,
+	})
+	if err != nil {
+		return segment, errors.Wrap(err, "persist epoch configuration")
+	}
+	return segment, nil
+}
+
 type EpochInfo struct {
-	Period time.Duration
-	Start  time.Time
-	First  int64
+	First int64
+
+	schedule *EpochSchedule
 }
 
+// Epochs with a single configuration
 func NewEpochInfo(cfg *config.EpochConfig, start time.Time, period time.Duration) EpochInfo {
+	return NewScheduledEpochInfo(cfg, NewEpochSchedule(EpochSegment{Start: start, Period: period}))
+}
+
+// Epochs following the (shared) schedule of epoch configurations
+func NewScheduledEpochInfo(cfg *config.EpochConfig, schedule *EpochSchedule) EpochInfo {
 	return EpochInfo{
-		Period: period,
-		Start:  start,
-		First:  cfg.First,
+		First:    cfg.First,
+		schedule: schedule,
 	}
 }
 
+func (e EpochInfo) Schedule() *EpochSchedule {
+	return e.schedule
+}
+
+// Period of the epochs of the latest configuration
+func (e EpochInfo) CurrentPeriod() time.Duration {
+	return e.schedule.currentPeriod()
+}
+
 func (e EpochInfo) GetStartTime(epoch int64) time.Time {
-	return e.Start.Add(time.Duration(epoch) * e.Period)
+	return e.schedule.epochStart(epoch)
 }
 
 func (e EpochInfo) GetEndTime(epoch int64) time.Time {
@@ -35,7 +238,7 @@ func (e EpochInfo) GetTimeRange(epoch int64) (time.Time, time.Time) {
 }
 
 func (e EpochInfo) GetEpochIndex(t time.Time) int64 {
-	return int64(t.Sub(e.Start) / e.Period)
+	return e.schedule.epochIndex(t)
 }
 
 func GetEpochConfig(votingContract *voting.Voting) (time.Time, time.Duration, error) {
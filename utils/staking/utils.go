@@ -138,13 +138,26 @@ func ToStakeData(
 	}, nil
 }
 
-func encodeTreeItem(tx *database.PChainTxData) ([]byte, error) {
-	// ABI Encode mirroring.IPChainStakeMirrorVerifierPChainStake
+// Leaf hash of the stake data in the epoch merkle tree
+func HashStakeData(stakeData *mirroring.IPChainStakeMirrorVerifierPChainStake) (common.Hash, error) {
+	encodedBytes, err := encodeStakeData(stakeData)
+	if err != nil {
+		return common.Hash{}, errors.Wrap(err, "encodeStakeData")
+	}
 
+	return crypto.Keccak256Hash(encodedBytes), nil
+}
+
+func encodeTreeItem(tx *database.PChainTxData) ([]byte, error) {
 	stakeData, err := ToStakeData(tx)
 	if err != nil {
 		return nil, errors.Wrap(err, "toStakeData")
 	}
+	return encodeStakeData(stakeData)
+}
+
+func encodeStakeData(stakeData *mirroring.IPChainStakeMirrorVerifierPChainStake) ([]byte, error) {
+	// ABI Encode mirroring.IPChainStakeMirrorVerifierPChainStake
 	return merkleTreeItemABIObjectArguments.Pack(
 		stakeData.TxId,
 		stakeData.StakingType,
@@ -4,6 +4,11 @@ import "time"
 
 type ShiftedTime struct {
 	Shift time.Duration
+
+	// If Speed is set (and not 1), time runs Speed times faster than the real
+	// time, starting at Origin (real time)
+	Origin time.Time
+	Speed  float64
 }
 
 func NewShiftedTime(startNow time.Time) *ShiftedTime {
@@ -11,12 +16,36 @@ func NewShiftedTime(startNow time.Time) *ShiftedTime {
 	return &ShiftedTime{Shift: shift}
 }
 
+// Create time starting at startNow and running speed times faster than the real time.
+// Copies of the returned value show the same time.
+func NewAcceleratedTime(startNow time.Time, speed float64) *ShiftedTime {
+	s := &ShiftedTime{Origin: time.Now(), Speed: speed}
+	s.SetNow(startNow)
+	return s
+}
+
 func (s *ShiftedTime) SetNow(startNow time.Time) {
-	s.Shift = time.Until(startNow)
+	s.Shift = startNow.Sub(s.unshiftedNow())
 }
 
 func (s *ShiftedTime) Now() time.Time {
-	return time.Now().Add(s.Shift)
+	return s.unshiftedNow().Add(s.Shift)
+}
+
+func (s *ShiftedTime) unshiftedNow() time.Time {
+	now := time.Now()
+	if s.Speed == 0 || s.Speed == 1 {
+		return now
+	}
+	return s.Origin.Add(time.Duration(float64(now.Sub(s.Origin)) * s.Speed))
+}
+
+// Return the real time duration corresponding to duration d of this time
+func (s *ShiftedTime) RealDuration(d time.Duration) time.Duration {
+	if s.Speed == 0 || s.Speed == 1 {
+		return d
+	}
+	return time.Duration(float64(d) / s.Speed)
 }
 
 func (s *ShiftedTime) SetNowUnix(now int64) {
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcceleratedTime(t *testing.T) {
+	start := time.Date(2023, 2, 2, 0, 0, 0, 0, time.UTC)
+	s := NewAcceleratedTime(start, 1000)
+
+	// Copies show the same time
+	c := *s
+
+	time.Sleep(10 * time.Millisecond)
+	now := s.Now()
+	if elapsed := now.Sub(start); elapsed < 10*time.Second || elapsed > time.Minute {
+		t.Fatalf("Expected about 10s of accelerated time to elapse, got %v", elapsed)
+	}
+	if diff := c.Now().Sub(now); diff < 0 || diff > time.Second {
+		t.Fatalf("Expected copy to show the same time, difference %v", diff)
+	}
+
+	s.AdvanceNow(time.Hour)
+	if elapsed := s.Now().Sub(start); elapsed < time.Hour {
+		t.Fatalf("Expected time to advance by an hour, got %v", elapsed)
+	}
+
+	if d := s.RealDuration(time.Minute); d != 60*time.Millisecond {
+		t.Fatalf("Expected real duration 60ms, got %v", d)
+	}
+}